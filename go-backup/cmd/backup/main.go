@@ -1,32 +1,57 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/backup-cronjob/internal/audit"
 	"github.com/backup-cronjob/internal/auth"
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/compression"
 	"github.com/backup-cronjob/internal/config"
 	"github.com/backup-cronjob/internal/database"
 	"github.com/backup-cronjob/internal/dbdump"
 	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/encryption"
 	"github.com/backup-cronjob/internal/handlers"
+	"github.com/backup-cronjob/internal/integrity"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
 	"github.com/backup-cronjob/internal/scheduler"
+	"github.com/backup-cronjob/internal/secrets"
+	"github.com/backup-cronjob/internal/storage"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	// Thiết lập các flag dòng lệnh
 	var (
-		dumpOnly   = flag.Bool("dump", false, "Chỉ thực hiện dump database")
-		uploadLast = flag.Bool("upload-last", false, "Upload file backup mới nhất")
-		uploadAll  = flag.Bool("upload-all", false, "Upload tất cả các file backup")
-		webMode    = flag.Bool("web", false, "Khởi động ứng dụng web")
-		port       = flag.String("port", "8080", "Port cho ứng dụng web")
+		dumpOnly      = flag.Bool("dump", false, "Chỉ thực hiện dump database")
+		uploadLast    = flag.Bool("upload-last", false, "Upload file backup mới nhất")
+		uploadAll     = flag.Bool("upload-all", false, "Upload tất cả các file backup")
+		webMode       = flag.Bool("web", false, "Khởi động ứng dụng web")
+		port          = flag.String("port", "8080", "Port cho ứng dụng web")
+		restoreID     = flag.Int64("restore", 0, "Khôi phục file backup theo ID vào database")
+		profileID     = flag.Int64("profile", 0, "ID profile dùng với --upload-last, --restore-latest, khôi phục hoặc kiểm tra backup (mặc định: profile đang hoạt động, khôi phục và kiểm tra backup theo ID dùng profile đã tạo backup)")
+		restoreLatest = flag.Bool("restore-latest", false, "Khôi phục backup mới nhất của profile (--profile) vào database")
+		dropDB        = flag.Bool("drop-db", false, "Xóa và tạo lại database trước khi khôi phục")
+		allowOther    = flag.Bool("allow-other-profile", false, "Cho phép khôi phục backup vào profile (--profile) khác profile đã tạo backup")
+		drillID       = flag.Int64("drill", 0, "Chạy restore drill cho file backup theo ID vào container PostgreSQL tạm thời")
+		verifyID      = flag.Int64("verify", 0, "Tính lại SHA-256 của file backup theo ID (cục bộ và trên các destination) và đối chiếu với catalog, manifest")
+		decrypt       = flag.String("decrypt", "", "Giải mã và giải nén một file backup (ví dụ file tải từ Google Drive)")
+		output        = flag.String("output", "", "File đích khi dùng --decrypt (mặc định: bỏ đuôi nén/mã hóa)")
+		identity      = flag.String("identity-file", "", "File age identity dùng để giải mã (mặc định: ENCRYPTION_AGE_IDENTITY)")
+		passphrase    = flag.String("passphrase", os.Getenv("BACKUP_PASSPHRASE"), "Passphrase giải mã backup (nên dùng biến môi trường BACKUP_PASSPHRASE)")
+		migrate       = flag.String("migrate", "", "Quản lý schema database ứng dụng: status (xem các migration) hoặc up (áp dụng migration còn thiếu)")
+		rotateKey     = flag.Bool("rotate-master-key", false, "Mã hóa lại credentials bằng master key mới (SECRETS_NEW_MASTER_KEY hoặc SECRETS_NEW_MASTER_KEY_FILE)")
 	)
 	flag.Parse()
 
@@ -35,6 +60,17 @@ func main() {
 	if err != nil {
 		log.Fatalf("Không thể khởi tạo cấu hình: %v", err)
 	}
+	if err := logger.Configure(cfg); err != nil {
+		log.Printf("Cảnh báo: %v, dùng cấu hình log mặc định", err)
+	}
+
+	// Chế độ quản lý migration chạy xong thì thoát, không thực hiện các thao tác khác
+	if *migrate != "" {
+		if err := runMigrate(cfg, *migrate); err != nil {
+			log.Fatalf("Lỗi khi migrate database: %v", err)
+		}
+		return
+	}
 
 	// Khởi tạo database
 	err = database.InitDB(cfg)
@@ -43,22 +79,47 @@ func main() {
 	}
 	defer database.Close()
 
+	// Mã hóa các file token Google Drive còn lưu dạng plaintext
+	tokenFiles, err := drive.TokenFiles(cfg.TokenDir)
+	if err != nil {
+		log.Printf("Cảnh báo: không thể liệt kê token Google Drive: %v", err)
+	}
+	for _, tokenFile := range tokenFiles {
+		if migrated, err := secrets.EncryptFile(tokenFile); err != nil {
+			log.Printf("Cảnh báo: không thể mã hóa token Google Drive %s: %v", filepath.Base(tokenFile), err)
+		} else if migrated {
+			log.Printf("Đã mã hóa file token Google Drive %s bằng master key", filepath.Base(tokenFile))
+		}
+	}
+
 	// Nạp cấu hình từ database
 	err = cfg.LoadConfigFromDB(database.GetConfigValue)
 	if err != nil {
 		log.Printf("Không thể nạp cấu hình từ database: %v", err)
 		log.Println("Tiếp tục với cấu hình mặc định...")
 	}
+	if err := logger.Configure(cfg); err != nil {
+		log.Printf("Cảnh báo: %v", err)
+	}
 
 	// Khởi tạo module xác thực
 	auth.Init(cfg)
 
+	// Age identity chỉ định qua dòng lệnh được ưu tiên hơn cấu hình
+	if *identity != "" {
+		content, err := os.ReadFile(*identity)
+		if err != nil {
+			log.Fatalf("Không thể đọc file age identity: %v", err)
+		}
+		cfg.AgeIdentity = string(content)
+	}
+
 	// Khởi tạo các đối tượng
 	dumper := dbdump.NewDatabaseDumper(cfg)
 	uploader := drive.NewDriveUploader(cfg)
 
 	// Thực hiện theo flag
-	if *dumpOnly || (!*uploadLast && !*uploadAll && !*webMode) {
+	if *dumpOnly || (!*uploadLast && !*uploadAll && !*webMode && *restoreID == 0 && !*restoreLatest && *drillID == 0 && *verifyID == 0 && *decrypt == "" && !*rotateKey) {
 		// Nếu chỉ có flag dump hoặc không có flag nào, thực hiện dump
 		fmt.Println("Đang thực hiện dump database...")
 		result, err := dumper.DumpDatabase(0) // Sử dụng profile đang hoạt động
@@ -71,13 +132,19 @@ func main() {
 	if *uploadLast {
 		// Upload file mới nhất
 		fmt.Println("Đang tìm file backup mới nhất...")
-		latestFile := findLatestBackup(cfg.BackupDir)
-		if latestFile == "" {
-			log.Fatalf("Không tìm thấy file backup nào")
+		latest, err := findLatestBackup(*profileID)
+		if err != nil {
+			log.Fatalf("Không tìm thấy file backup: %v", err)
+		}
+
+		// Upload bằng thư mục Drive và tài khoản Google của profile đã tạo backup
+		latestUploader := uploader
+		if profile, err := database.GetProfileByID(latest.ProfileID); err == nil {
+			latestUploader = uploader.ForProfile(*profile)
 		}
 
-		fmt.Printf("Đang upload file %s lên Google Drive...\n", filepath.Base(latestFile))
-		result := uploader.UploadFile(latestFile)
+		fmt.Printf("Đang upload file %s lên Google Drive...\n", latest.Name)
+		result := latestUploader.UploadFile(latest.Path)
 		if !result.Success {
 			log.Fatalf("Lỗi khi upload file: %v", result.Message)
 		}
@@ -94,6 +161,73 @@ func main() {
 		fmt.Println("Upload thành công!")
 	}
 
+	if *restoreLatest {
+		// Khôi phục backup mới nhất của profile đích
+		latest, err := findLatestBackup(*profileID)
+		if err != nil {
+			log.Fatalf("Không tìm thấy file backup: %v", err)
+		}
+		*restoreID, _ = strconv.ParseInt(latest.ID, 10, 64)
+	}
+
+	if *restoreID > 0 {
+		// Khôi phục backup vào database
+		fmt.Printf("Đang khôi phục backup ID %d...\n", *restoreID)
+		restorer := dbdump.NewRestorer(cfg)
+		result, err := restorer.RestoreBackup(*restoreID, dbdump.RestoreOptions{
+			ProfileID:         *profileID,
+			DropDatabase:      *dropDB,
+			Passphrase:        *passphrase,
+			AllowOtherProfile: *allowOther,
+		})
+		if err != nil {
+			log.Fatalf("Lỗi khi khôi phục backup: %v", err)
+		}
+		fmt.Println(result.Message)
+	}
+
+	if *drillID > 0 {
+		// Kiểm tra backup bằng cách khôi phục vào container tạm thời
+		fmt.Printf("Đang chạy restore drill cho backup ID %d...\n", *drillID)
+		driller := dbdump.NewDriller(cfg, storage.NewManager(cfg, uploader))
+		result, err := driller.RunDrill(context.Background(), *drillID, *profileID)
+		if err != nil {
+			log.Fatalf("Lỗi khi kiểm tra backup: %v", err)
+		}
+		fmt.Println(result.Message)
+	}
+
+	if *verifyID > 0 {
+		// Kiểm tra checksum của backup trên tất cả bản sao
+		fmt.Printf("Đang kiểm tra checksum của backup ID %d...\n", *verifyID)
+		report, err := integrity.VerifyBackup(context.Background(), cfg, storage.NewManager(cfg, uploader), *verifyID)
+		if err != nil {
+			log.Fatalf("Lỗi khi kiểm tra backup: %v", err)
+		}
+		printVerifyReport(report)
+		if !report.OK {
+			os.Exit(1)
+		}
+	}
+
+	if *decrypt != "" {
+		// Giải mã file backup ra file SQL/archive gốc
+		target, err := decryptBackupFile(cfg, *decrypt, *output, *passphrase)
+		if err != nil {
+			log.Fatalf("Lỗi khi giải mã backup: %v", err)
+		}
+		fmt.Printf("Đã giải mã backup vào: %s\n", target)
+	}
+
+	if *rotateKey {
+		// Bọc lại data key của credentials bằng master key mới
+		updated, err := rotateMasterKey(cfg)
+		if err != nil {
+			log.Fatalf("Lỗi khi đổi master key: %v", err)
+		}
+		fmt.Printf("Đã mã hóa lại %d credentials, hãy cập nhật SECRETS_MASTER_KEY thành khóa mới trước khi khởi động lại\n", updated)
+	}
+
 	if *webMode {
 		// Khởi động ứng dụng web
 		fmt.Printf("Đang khởi động ứng dụng web trên port %s...\n", *port)
@@ -101,85 +235,173 @@ func main() {
 	}
 }
 
-// findLatestBackup tìm file backup mới nhất
-func findLatestBackup(backupDir string) string {
-	var (
-		latestFile  string
-		latestMTime int64
-	)
+// runMigrate xử lý --migrate: status chỉ đọc trạng thái, up áp dụng các migration còn thiếu
+func runMigrate(cfg *config.Config, mode string) error {
+	switch mode {
+	case "status":
+		if err := database.Open(cfg); err != nil {
+			return err
+		}
+	case "up":
+		if err := database.InitDB(cfg); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("chế độ --migrate không hợp lệ: %s (hỗ trợ: status, up)", mode)
+	}
+	defer database.Close()
+
+	current, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	statuses, err := database.MigrationStatuses()
+	if err != nil {
+		return err
+	}
 
-	// Kiểm tra và xử lý đường dẫn backup
-	if strings.HasPrefix(backupDir, "./") {
-		// Đường dẫn tương đối so với thư mục hiện tại, giữ nguyên
-		fmt.Printf("Sử dụng đường dẫn tương đối: %s\n", backupDir)
-	} else if !filepath.IsAbs(backupDir) {
-		// Đường dẫn tương đối không bắt đầu bằng './', chuyển thành tuyệt đối
-		absPath, err := filepath.Abs(backupDir)
+	fmt.Printf("Schema database: version %d (ứng dụng hỗ trợ đến version %d)\n", current, database.LatestSchemaVersion())
+	for _, status := range statuses {
+		state := "chưa áp dụng"
+		if status.AppliedAt != nil {
+			state = "đã áp dụng " + status.AppliedAt.Local().Format("02/01/2006 15:04:05")
+		}
+		if status.Version > database.LatestSchemaVersion() {
+			state += " (phiên bản ứng dụng mới hơn)"
+		}
+		fmt.Printf("  %3d  %-20s %s\n", status.Version, status.Name, state)
+	}
+	if current > database.LatestSchemaVersion() {
+		return database.ErrSchemaTooNew
+	}
+	return nil
+}
+
+// rewrapTokenFiles bọc lại data key của các file token Google Drive (chung và của profile) bằng newKey
+func rewrapTokenFiles(tokenDir string, newKey []byte) error {
+	tokenFiles, err := drive.TokenFiles(tokenDir)
+	if err != nil {
+		return err
+	}
+	for _, tokenFile := range tokenFiles {
+		if err := secrets.RewrapFile(tokenFile, newKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateMasterKey bọc lại data key của credentials trong database và token Google Drive
+// bằng master key mới đọc từ SECRETS_NEW_MASTER_KEY hoặc SECRETS_NEW_MASTER_KEY_FILE
+func rotateMasterKey(cfg *config.Config) (int, error) {
+	if !secrets.Enabled() {
+		return 0, secrets.ErrNoMasterKey
+	}
+
+	raw := os.Getenv("SECRETS_NEW_MASTER_KEY")
+	if keyFile := os.Getenv("SECRETS_NEW_MASTER_KEY_FILE"); keyFile != "" && raw == "" {
+		content, err := os.ReadFile(keyFile)
 		if err != nil {
-			fmt.Printf("Warning: Không thể chuyển đổi đường dẫn tương đối thành tuyệt đối: %v\n", err)
-		} else {
-			fmt.Printf("Đã chuyển đổi đường dẫn từ '%s' thành '%s'\n", backupDir, absPath)
-			backupDir = absPath
+			return 0, fmt.Errorf("không thể đọc SECRETS_NEW_MASTER_KEY_FILE: %v", err)
 		}
-	} else {
-		fmt.Printf("Sử dụng đường dẫn tuyệt đối: %s\n", backupDir)
+		raw = string(content)
+	}
+	if raw == "" {
+		return 0, fmt.Errorf("chưa cấu hình master key mới (SECRETS_NEW_MASTER_KEY hoặc SECRETS_NEW_MASTER_KEY_FILE)")
+	}
+	newKey, err := secrets.ParseKey(raw)
+	if err != nil {
+		return 0, err
 	}
 
-	// Đảm bảo đường dẫn là đường dẫn hợp lệ và tồn tại
-	backupDir = filepath.Clean(backupDir)
-	fmt.Printf("Đường dẫn sau khi làm sạch: %s\n", backupDir)
+	updated, err := database.RotateSecrets(newKey)
+	if err == nil {
+		err = rewrapTokenFiles(cfg.TokenDir, newKey)
+		if err != nil {
+			err = fmt.Errorf("database đã dùng master key mới nhưng %v, hãy xác thực lại Google Drive", err)
+		}
+	}
+	audit.System("secrets.rotate", "master_key", secrets.KeyID(newKey),
+		fmt.Sprintf("%d credentials đã được mã hóa lại", updated), err)
+	if err != nil {
+		return updated, err
+	}
 
-	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
-		fmt.Printf("Thư mục backup không tồn tại: %s\n", backupDir)
-		return ""
+	secrets.SetMasterKey(newKey)
+	return updated, nil
+}
+
+// decryptBackupFile giải mã và giải nén file backup source vào target,
+// chế độ mã hóa và thuật toán nén được xác định theo đuôi file
+func decryptBackupFile(cfg *config.Config, source, target, passphrase string) (string, error) {
+	mode := encryption.FromFileName(source)
+	algorithm := compression.FromFileName(encryption.TrimExtension(source, mode))
+	if target == "" {
+		target = compression.TrimExtension(encryption.TrimExtension(source, mode), algorithm)
+	}
+	if target == source {
+		return "", fmt.Errorf("file %s không được mã hóa hoặc nén, hãy chỉ định --output", source)
 	}
 
-	fmt.Printf("Đang tìm backup mới nhất trong thư mục: %s\n", backupDir)
+	in, err := os.Open(source)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
 
-	// Duyệt qua tất cả thư mục ngày
-	dateDirs, err := os.ReadDir(backupDir)
+	reader, err := dbdump.DecodeBackup(in, mode, algorithm, dbdump.DecryptionKeys(cfg, 0, passphrase))
 	if err != nil {
-		fmt.Printf("Lỗi khi đọc thư mục backup: %v\n", err)
-		return ""
+		return "", err
 	}
+	defer reader.Close()
 
-	for _, dateDir := range dateDirs {
-		if dateDir.IsDir() {
-			datePath := filepath.Join(backupDir, dateDir.Name())
-			fmt.Printf("Kiểm tra thư mục ngày: %s\n", datePath)
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		os.Remove(target)
+		return "", err
+	}
 
-			files, err := filepath.Glob(filepath.Join(datePath, "*.sql"))
-			if err != nil {
-				fmt.Printf("Lỗi khi tìm file SQL trong thư mục %s: %v\n", datePath, err)
-				continue
-			}
+	return target, out.Close()
+}
 
-			fmt.Printf("Tìm thấy %d file SQL trong thư mục %s\n", len(files), datePath)
-
-			for _, file := range files {
-				info, err := os.Stat(file)
-				if err != nil {
-					fmt.Printf("Lỗi khi đọc thông tin file %s: %v\n", file, err)
-					continue
-				}
-
-				modTime := info.ModTime().Unix()
-				if modTime > latestMTime {
-					latestMTime = modTime
-					latestFile = file
-					fmt.Printf("Đã tìm thấy file mới hơn: %s (mtime: %d)\n", file, modTime)
-				}
-			}
+// printVerifyReport in kết quả kiểm tra checksum của backup
+func printVerifyReport(report *integrity.Report) {
+	fmt.Printf("Backup: %s\n", report.Name)
+	fmt.Printf("Checksum (catalog): %s\n", report.Checksum)
+	checks := append([]integrity.CopyCheck{report.Local}, report.Remote...)
+	for _, check := range checks {
+		line := fmt.Sprintf("  %-8s %-12s %s", check.Destination, check.Status, check.Checksum)
+		if check.Message != "" {
+			line += " (" + check.Message + ")"
 		}
+		fmt.Println(line)
+	}
+	manifest := fmt.Sprintf("  %-8s %-12s chữ ký: %s", "manifest", report.Manifest.Status, report.Manifest.Signature)
+	if report.Manifest.Message != "" {
+		manifest += " (" + report.Manifest.Message + ")"
 	}
+	fmt.Println(manifest)
 
-	if latestFile != "" {
-		fmt.Printf("File backup mới nhất: %s\n", latestFile)
+	if report.OK {
+		fmt.Println("Tất cả bản sao khớp checksum")
 	} else {
-		fmt.Println("Không tìm thấy file backup nào")
+		fmt.Printf("Phát hiện vấn đề: %s\n", strings.Join(report.Problems, ", "))
 	}
+}
 
-	return latestFile
+// findLatestBackup tìm backup mới nhất trong catalog của profile, profileID = 0 dùng profile đang hoạt động.
+// Khi chưa có profile nào, backup mới nhất trong toàn bộ catalog được chọn
+func findLatestBackup(profileID int64) (*models.BackupFile, error) {
+	if profileID == 0 {
+		if profile, err := database.GetActiveProfile(); err == nil {
+			profileID = profile.ID
+		}
+	}
+	return backupdb.FindLatestBackup(profileID)
 }
 
 // startWebApp khởi động ứng dụng web
@@ -276,6 +498,9 @@ func startWebApp(cfg *config.Config, port string) {
 	router.GET("/auth", h.AuthHandler)
 	router.GET("/callback", h.AuthCallbackHandler)
 
+	// Metric cho Prometheus, bảo vệ bằng METRICS_TOKEN thay vì JWT
+	router.GET("/metrics", h.MetricsHandler)
+
 	// Thêm các route API có tiền tố /api
 	router.POST("/api/login", h.LoginHandler)
 	router.POST("/api/logout", h.LogoutHandler)
@@ -285,49 +510,104 @@ func startWebApp(cfg *config.Config, port string) {
 	router.POST("/api/auth/exchange", h.ExchangeAuthCodeHandler)
 	router.GET("/api/callback", h.AuthCallbackHandler)
 
-	// API routes - Các route cần xác thực
+	// API routes - Các route cần xác thực.
+	// viewer: xem và tải backup; operator: chạy backup, upload, tạm dừng lịch;
+	// admin: profile, cấu hình, người dùng, khôi phục và xóa backup
 	protected := router.Group("/api")
 	protected.Use(auth.AuthMiddleware())
 	{
 		protected.GET("/me", h.MeHandler)
 		protected.GET("/backups", h.GetBackupsHandler)
-		protected.DELETE("/backups/:id", h.DeleteBackupHandler)
-		protected.GET("/configs", h.GetConfigsHandler)
-		protected.POST("/configs", h.UpdateConfigsHandler)
-		protected.GET("/configs/:group", h.GetConfigsByGroupHandler)
+		protected.GET("/backups/:id/uploads", h.GetBackupUploadsHandler)
 		protected.GET("/drive/status", h.CheckDriveStatusHandler)
+		protected.GET("/storage/destinations", h.GetDestinationsHandler)
+		protected.GET("/storage/:name/objects", h.ListStorageObjectsHandler)
+		protected.GET("/notify/channels", h.GetNotifyChannelsHandler)
+		protected.GET("/logs", h.GetLogsHandler)
 
-		// Quản lý profile database
+		// Xem profile database
 		protected.GET("/profiles", h.GetProfilesHandler)
 		protected.GET("/profiles/active", h.GetActiveProfileHandler)
 		protected.GET("/profiles/:id", h.GetProfileHandler)
-		protected.POST("/profiles", h.CreateProfileHandler)
-		protected.PUT("/profiles/:id", h.UpdateProfileHandler)
-		protected.DELETE("/profiles/:id", h.DeleteProfileHandler)
-		protected.POST("/profiles/:id/activate", h.SetActiveProfileHandler)
+		protected.GET("/profiles/:id/backups", h.GetProfileBackupsHandler)
+		protected.GET("/profiles/:id/drive/status", h.GetProfileDriveStatusHandler)
 
-		// Route mới cho tính năng lập lịch backup tự động
+		// Xem lịch backup và hàng đợi
 		protected.GET("/schedule/options", h.GetScheduleOptionsHandler)
 		protected.GET("/schedule/jobs", h.GetActiveJobsHandler)
-		protected.POST("/schedule/update", h.UpdateScheduleHandler)
-		protected.POST("/schedule/run-now", h.RunBackupNowHandler)
-		protected.POST("/schedule/delete", h.DeleteScheduleHandler)
-		protected.POST("/schedule/pause", h.PauseScheduleHandler)
-		protected.POST("/schedule/resume", h.ResumeScheduleHandler)
+		protected.GET("/schedule/queue", h.GetBackupQueueHandler)
+		protected.GET("/schedule/maintenance", h.GetMaintenanceHandler)
 		protected.GET("/schedule/logs/:id", h.GetJobLogsHandler)
+
+		// Sự kiện của job backup theo thời gian thực qua WebSocket
+		protected.GET("/ws/jobs", h.JobEventsWSHandler)
+
+		// API token cá nhân cho tự động hóa, mỗi người dùng quản lý token của mình
+		protected.GET("/tokens", h.GetAPITokensHandler)
+		protected.POST("/tokens", h.CreateAPITokenHandler)
+		protected.DELETE("/tokens/:id", h.RevokeAPITokenHandler)
+	}
+
+	operator := protected.Group("", auth.RequireRole(models.RoleOperator))
+	{
+		operator.POST("/backups/:id/drill", h.RestoreDrillHandler)
+		operator.POST("/backups/:id/verify", h.VerifyBackupHandler)
+		operator.POST("/backups/:id/upload/:destination", h.UploadToDestinationHandler)
+		operator.POST("/notify/test", h.TestNotifyHandler)
+		operator.POST("/jobs/:id/cancel", h.CancelJobHandler)
+		operator.POST("/schedule/run-now", h.RunBackupNowHandler)
+		operator.POST("/schedule/pause", h.PauseScheduleHandler)
+		operator.POST("/schedule/resume", h.ResumeScheduleHandler)
+		operator.POST("/schedule/maintenance", h.SetMaintenanceHandler)
+	}
+
+	admin := protected.Group("", auth.RequireRole(models.RoleAdmin))
+	{
+		admin.DELETE("/backups/:id", h.DeleteBackupHandler)
+		admin.POST("/backups/:id/restore", h.RestoreBackupHandler)
+		admin.GET("/configs", h.GetConfigsHandler)
+		admin.POST("/configs", h.UpdateConfigsHandler)
+		admin.GET("/configs/:group", h.GetConfigsByGroupHandler)
+
+		// Quản lý profile database
+		admin.POST("/profiles", h.CreateProfileHandler)
+		admin.PUT("/profiles/:id", h.UpdateProfileHandler)
+		admin.DELETE("/profiles/:id", h.DeleteProfileHandler)
+		admin.POST("/profiles/:id/activate", h.SetActiveProfileHandler)
+		admin.POST("/profiles/:id/retention", h.RunRetentionHandler)
+
+		// Xác thực tài khoản Google riêng của profile
+		admin.GET("/profiles/:id/drive/auth-url", h.GetProfileDriveAuthURLHandler)
+		admin.POST("/profiles/:id/drive/exchange", h.ExchangeProfileDriveCodeHandler)
+		admin.DELETE("/profiles/:id/drive/token", h.DisconnectProfileDriveHandler)
+		admin.POST("/schedule/update", h.UpdateScheduleHandler)
+		admin.POST("/schedule/delete", h.DeleteScheduleHandler)
+
+		// Quản lý người dùng
+		admin.GET("/users", h.GetUsersHandler)
+		admin.POST("/users", h.CreateUserHandler)
+		admin.PUT("/users/:id", h.UpdateUserHandler)
+		admin.POST("/users/:id/password", h.ResetUserPasswordHandler)
+
+		// Audit log, hỗ trợ xuất CSV với ?format=csv
+		admin.GET("/audit", h.GetAuditHandler)
 	}
 
 	// Action routes - Các hành động cần xác thực
 	actions := router.Group("/")
 	actions.Use(auth.AuthMiddleware())
 	{
-		actions.POST("/dump", h.DumpHandler)
-		actions.POST("/upload-last", h.UploadLastHandler)
-		actions.POST("/upload-all", h.UploadAllHandler)
-		actions.POST("/upload/:id", h.UploadSingleHandler)
 		actions.GET("/download/:id", h.DownloadHandler)
 	}
 
+	actionOperator := actions.Group("", auth.RequireRole(models.RoleOperator))
+	{
+		actionOperator.POST("/dump", h.DumpHandler)
+		actionOperator.POST("/upload-last", h.UploadLastHandler)
+		actionOperator.POST("/upload-all", h.UploadAllHandler)
+		actionOperator.POST("/upload/:id", h.UploadSingleHandler)
+	}
+
 	// Khởi động server
 	fmt.Printf("Server đang lắng nghe tại http://localhost:%s\n", port)
 	if err := router.Run(":" + port); err != nil {
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// RedactedValue là giá trị thay thế cho trường nhạy cảm trong audit log
+const RedactedValue = "[đã ẩn]"
+
+// secretMarkers là các từ trong tên trường cho biết trường chứa dữ liệu nhạy cảm
+var secretMarkers = []string{"password", "secret", "passphrase", "private_key", "token"}
+
+// ignoredFields là các trường không đưa vào so sánh thay đổi
+var ignoredFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// Record ghi sự kiện vào audit log. Lỗi ghi chỉ được log lại để không làm hỏng hành động chính
+func Record(event models.AuditEvent) {
+	if event.Result == "" {
+		event.Result = models.AuditResultSuccess
+	}
+	if _, err := database.InsertAuditEvent(event); err != nil {
+		logger.Component("audit").Error("Không thể ghi audit log", "action", event.Action, "error", err)
+	}
+}
+
+// System ghi sự kiện do scheduler hoặc tác vụ tự động thực hiện, err khác nil đánh dấu thất bại
+func System(action, targetType, targetID, message string, err error) {
+	Record(WithResult(models.AuditEvent{
+		Actor:      models.AuditActorSystem,
+		ActorType:  models.AuditActorSystem,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Message:    message,
+	}, err))
+}
+
+// WithResult đặt kết quả của sự kiện theo err, lỗi được nối vào thông báo
+func WithResult(event models.AuditEvent, err error) models.AuditEvent {
+	event.Result = models.AuditResultSuccess
+	if err != nil {
+		event.Result = models.AuditResultFailure
+		if event.Message != "" {
+			event.Message += "; "
+		}
+		event.Message += err.Error()
+	}
+	return event
+}
+
+// IsSecretField cho biết trường hoặc khóa cấu hình có chứa dữ liệu nhạy cảm hay không
+func IsSecretField(name string) bool {
+	if models.IsSecretConfig(name) {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, marker := range secretMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff so sánh hai giá trị (struct hoặc map) theo tên trường JSON và trả về các trường đã thay đổi.
+// before hoặc after có thể là nil khi tạo mới hoặc xóa. Giá trị của trường nhạy cảm được ẩn
+func Diff(before, after interface{}) map[string]models.AuditChange {
+	beforeFields := toFields(before)
+	afterFields := toFields(after)
+
+	changes := make(map[string]models.AuditChange)
+	for name := range union(beforeFields, afterFields) {
+		if ignoredFields[name] {
+			continue
+		}
+		oldValue, newValue := beforeFields[name], afterFields[name]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if IsSecretField(name) {
+			oldValue, newValue = redact(oldValue), redact(newValue)
+		}
+		changes[name] = models.AuditChange{Before: oldValue, After: newValue}
+	}
+	return changes
+}
+
+// Redact trả về bản sao của map với giá trị của các khóa nhạy cảm đã được ẩn
+func Redact(values map[string]string) map[string]string {
+	redacted := make(map[string]string, len(values))
+	for key, value := range values {
+		if IsSecretField(key) && value != "" {
+			value = RedactedValue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// toFields chuyển giá trị thành map theo tên trường JSON
+func toFields(value interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return fields
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
+
+// union trả về tập hợp khóa của hai map
+func union(a, b map[string]interface{}) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// redact ẩn giá trị nhạy cảm, giá trị rỗng được giữ nguyên để thấy trường được đặt hay xóa
+func redact(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return RedactedValue
+}
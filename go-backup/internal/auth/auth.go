@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"log"
@@ -8,8 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/backup-cronjob/internal/audit"
 	"github.com/backup-cronjob/internal/config"
 	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/logger"
 	"github.com/backup-cronjob/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -17,6 +20,12 @@ import (
 
 var cfg *config.Config
 
+// ErrUserDisabled là lỗi khi người dùng đã bị khóa
+var ErrUserDisabled = errors.New("Tài khoản đã bị khóa")
+
+// ErrInvalidCredentials là lỗi chung khi đăng nhập thất bại
+var ErrInvalidCredentials = errors.New("Tên đăng nhập hoặc mật khẩu không chính xác")
+
 // Init khởi tạo module xác thực
 func Init(c *config.Config) {
 	oldSecret := ""
@@ -47,6 +56,7 @@ func GenerateJWT(user *models.User) (string, error) {
 	claims := jwt.MapClaims{
 		"username": user.Username,
 		"user_id":  user.ID,
+		"role":     user.Role,
 		"exp":      expirationTime.Unix(),
 	}
 
@@ -89,10 +99,12 @@ func ValidateJWT(tokenString string) (*models.JWTClaims, error) {
 		// Lấy thông tin từ claims
 		userID, _ := claims["user_id"].(float64)
 		username, _ := claims["username"].(string)
+		role, _ := claims["role"].(string)
 
 		return &models.JWTClaims{
 			Username: username,
 			UserID:   int64(userID),
+			Role:     role,
 		}, nil
 	}
 
@@ -104,6 +116,7 @@ func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var tokenString string
 		var source string
+		authLogger := logger.Component("auth")
 
 		// 1. Kiểm tra header Authorization
 		authHeader := c.GetHeader("Authorization")
@@ -133,11 +146,11 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Nếu không có token từ bất kỳ nguồn nào
 		if tokenString == "" {
 			// 4. Ghi log về truy cập không có token
-			log.Printf("❌ Access denied: No authentication token found at %s", c.Request.URL.Path)
+			authLogger.Warn("Từ chối truy cập: không có token xác thực", "path", c.Request.URL.Path)
 
 			// Chuyển hướng các request GET đến trang đăng nhập thay vì trả về JSON
 			if c.Request.Method == "GET" && !strings.HasPrefix(c.Request.URL.Path, "/api/") {
-				log.Printf("🔄 Redirecting to login page from %s", c.Request.URL.Path)
+				authLogger.Debug("Chuyển hướng đến trang đăng nhập", "path", c.Request.URL.Path)
 				c.Redirect(http.StatusFound, "/login")
 				c.Abort()
 				return
@@ -148,21 +161,38 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Xác thực token
+		// API token cá nhân chỉ được nhận qua header và không bao giờ được lưu vào cookie
+		if strings.HasPrefix(tokenString, APITokenPrefix) {
+			if source != "header" {
+				authLogger.Warn("Từ chối API token không gửi qua header", "source", source, "path", c.Request.URL.Path)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": "API token phải được gửi qua header Authorization: Bearer"})
+				return
+			}
+			if authenticateAPIToken(c, tokenString) {
+				c.Next()
+			}
+			return
+		}
+
+		// Xác thực token, vai trò và trạng thái khóa được đọc lại từ database
+		// để thay đổi quyền có hiệu lực ngay với các token đã cấp
 		claims, err := ValidateJWT(tokenString)
+		if err == nil {
+			claims.Role, err = currentRole(claims)
+		}
 		if err != nil {
-			log.Printf("Token validation failed: %v (source: %s)", err, source)
+			authLogger.Warn("Token không hợp lệ", "error", err, "source", source, "path", c.Request.URL.Path)
 
 			// Xóa cookie nếu token không hợp lệ
 			if source == "cookie" {
-				log.Printf("Removing invalid auth_token cookie")
+				authLogger.Debug("Xóa cookie auth_token không hợp lệ")
 				c.SetCookie("auth_token", "", -1, "/", "", false, true)
 				c.SetCookie("logged_in", "", -1, "/", "", false, false)
 			}
 
 			// Chuyển hướng các request GET đến trang đăng nhập
 			if c.Request.Method == "GET" && !strings.HasPrefix(c.Request.URL.Path, "/api/") {
-				log.Printf("🔄 Redirecting to login page due to invalid token")
+				authLogger.Debug("Chuyển hướng đến trang đăng nhập do token không hợp lệ", "path", c.Request.URL.Path)
 				c.Redirect(http.StatusFound, "/login")
 				c.Abort()
 				return
@@ -175,11 +205,12 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Lưu thông tin người dùng vào context
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
 		c.Set("auth_source", source)
 
 		// Đảm bảo token được lưu trong cookie nếu chưa có
 		if source != "cookie" {
-			log.Printf("Setting auth cookies from %s source", source)
+			authLogger.Debug("Lưu token vào cookie", "source", source)
 			c.SetSameSite(http.SameSiteLaxMode)
 			c.SetCookie(
 				"auth_token",
@@ -205,41 +236,78 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// AuthenticateUser kiểm tra thông tin đăng nhập và trả về thông tin người dùng nếu hợp lệ
+// AuthenticateUser kiểm tra thông tin đăng nhập với người dùng trong database và trả về thông tin người dùng nếu hợp lệ.
+// Tài khoản đã bị khóa trả về cùng lỗi với sai mật khẩu để không lộ tên đăng nhập nào tồn tại
 func AuthenticateUser(credentials *models.Auth) (*models.User, error) {
-	// Ghi log thông tin đăng nhập để gỡ lỗi
-	log.Printf("Đang xác thực người dùng: username=%s, config.AdminUsername=%s",
-		credentials.Username, cfg.AdminUsername)
+	authLogger := logger.Component("auth").With("username", credentials.Username)
+	authLogger.Debug("Đang xác thực người dùng")
 
-	// Thử xác thực từ database trước
 	user, err := database.GetUserByUsername(credentials.Username)
-	if err == nil {
-		// Nếu tìm thấy người dùng, kiểm tra mật khẩu
-		log.Printf("Tìm thấy người dùng trong DB: %s, kiểm tra mật khẩu", credentials.Username)
-		if models.CheckPasswordHash(credentials.Password, user.Password) {
-			log.Printf("Xác thực thành công qua database cho người dùng: %s", credentials.Username)
-			return user, nil
-		}
-		log.Printf("Mật khẩu không chính xác cho người dùng: %s", credentials.Username)
-	} else {
-		log.Printf("Không tìm thấy người dùng trong DB: %s (%v), thử với cấu hình",
-			credentials.Username, err)
+	if err != nil {
+		authLogger.Warn("Xác thực thất bại: không tìm thấy người dùng", "error", err)
+		return nil, ErrInvalidCredentials
 	}
+	if !models.CheckPasswordHash(credentials.Password, user.Password) {
+		authLogger.Warn("Xác thực thất bại: mật khẩu không khớp")
+		return nil, ErrInvalidCredentials
+	}
+	if user.Disabled {
+		authLogger.Warn("Xác thực thất bại: tài khoản đã bị khóa")
+		return nil, ErrInvalidCredentials
+	}
+
+	authLogger.Info("Xác thực thành công")
+	return user, nil
+}
 
-	// Thử với tài khoản admin từ cấu hình
-	log.Printf("Kiểm tra mật khẩu admin: credentials.Password==cfg.AdminPassword? %v",
-		credentials.Password == cfg.AdminPassword)
+// currentRole đọc vai trò hiện tại của người dùng trong token từ database
+func currentRole(claims *models.JWTClaims) (string, error) {
+	user, err := database.GetUserByID(claims.UserID)
+	if err == sql.ErrNoRows {
+		return "", errors.New("người dùng không tồn tại")
+	}
+	if err != nil {
+		return "", err
+	}
+	if user.Username != claims.Username {
+		return "", errors.New("token không khớp với người dùng")
+	}
+	if user.Disabled {
+		return "", ErrUserDisabled
+	}
+	return user.Role, nil
+}
 
-	// Kiểm tra thông tin đăng nhập với admin từ cấu hình
-	if credentials.Username == cfg.AdminUsername && credentials.Password == cfg.AdminPassword {
-		log.Printf("Xác thực thành công với admin từ cấu hình: %s", credentials.Username)
-		return &models.User{
-			ID:       1,
-			Username: cfg.AdminUsername,
-		}, nil
+// RequireRole chỉ cho phép người dùng có vai trò tối thiểu là role, dùng sau AuthMiddleware
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current := c.GetString("role")
+		if !models.RoleAllows(current, role) {
+			logger.Component("auth").Warn("Từ chối truy cập: không đủ quyền",
+				"username", c.GetString("username"), "role", current, "required", role, "path", c.Request.URL.Path)
+			actorType := models.AuditActorUser
+			if c.GetString("auth_source") == "api_token" {
+				actorType = models.AuditActorAPIToken
+			}
+			recordDenied(c, c.GetString("username"), actorType, fmt.Sprintf("vai trò %s, yêu cầu %s", current, role))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Bạn không có quyền thực hiện thao tác này (yêu cầu vai trò %s)", role),
+			})
+			return
+		}
+		c.Next()
 	}
+}
 
-	// Thất bại, trả về lỗi
-	log.Printf("Xác thực thất bại cho người dùng: %s", credentials.Username)
-	return nil, errors.New("Tên đăng nhập hoặc mật khẩu không chính xác")
+// recordDenied ghi lần truy cập bị từ chối vào audit log
+func recordDenied(c *gin.Context, actor, actorType, reason string) {
+	audit.Record(audit.WithResult(models.AuditEvent{
+		Actor:      actor,
+		ActorType:  actorType,
+		Action:     "access.denied",
+		TargetType: "route",
+		TargetID:   c.Request.Method + " " + c.Request.URL.Path,
+		IP:         c.ClientIP(),
+	}, errors.New(reason)))
 }
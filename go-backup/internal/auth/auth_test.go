@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupAuthTest tạo database tạm với schema đầy đủ và một người dùng admin
+func setupAuthTest(t *testing.T) *models.User {
+	t.Helper()
+	testCfg := &config.Config{
+		DBSource:      filepath.Join(t.TempDir(), "auth.db"),
+		JWTSecret:     "test-secret",
+		AdminUsername: "admin",
+		AdminPassword: "env-password",
+	}
+	if err := database.Open(testCfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(testCfg.DBSource); err != nil {
+		t.Fatal(err)
+	}
+	Init(testCfg)
+
+	// Cost thấp để test chạy nhanh, CheckPasswordHash đọc cost từ hash
+	hash, err := bcrypt.GenerateFromPassword([]byte("db-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := database.CreateUser("admin", string(hash), models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	setupAuthTest(t)
+
+	if _, err := AuthenticateUser(&models.Auth{Username: "admin", Password: "db-password"}); err != nil {
+		t.Fatalf("đăng nhập với mật khẩu trong database: %v", err)
+	}
+	if _, err := AuthenticateUser(&models.Auth{Username: "admin", Password: "wrong"}); err != ErrInvalidCredentials {
+		t.Errorf("sai mật khẩu: err = %v, muốn ErrInvalidCredentials", err)
+	}
+	if _, err := AuthenticateUser(&models.Auth{Username: "nobody", Password: "db-password"}); err != ErrInvalidCredentials {
+		t.Errorf("người dùng không tồn tại: err = %v, muốn ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthenticateUserIgnoresConfigPassword(t *testing.T) {
+	setupAuthTest(t)
+
+	// Mật khẩu ADMIN_PASSWORD chỉ dùng khi tạo admin lần đầu, không dùng để đăng nhập
+	if _, err := AuthenticateUser(&models.Auth{Username: "admin", Password: "env-password"}); err != ErrInvalidCredentials {
+		t.Errorf("đăng nhập bằng mật khẩu cấu hình: err = %v, muốn ErrInvalidCredentials", err)
+	}
+}
+
+func TestAuthenticateUserDisabled(t *testing.T) {
+	user := setupAuthTest(t)
+	if err := database.UpdateUserRole(user.ID, user.Role, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tài khoản bị khóa trả về cùng lỗi dù mật khẩu đúng hay sai
+	for _, password := range []string{"db-password", "wrong", "env-password"} {
+		if _, err := AuthenticateUser(&models.Auth{Username: "admin", Password: password}); err != ErrInvalidCredentials {
+			t.Errorf("tài khoản bị khóa, mật khẩu %q: err = %v, muốn ErrInvalidCredentials", password, err)
+		}
+	}
+	if _, err := currentRole(&models.JWTClaims{UserID: user.ID, Username: user.Username}); err != ErrUserDisabled {
+		t.Errorf("currentRole với tài khoản bị khóa: err = %v, muốn ErrUserDisabled", err)
+	}
+}
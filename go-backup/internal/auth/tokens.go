@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenPrefix là tiền tố của API token cá nhân, dùng để phân biệt với JWT
+const APITokenPrefix = "gbk_"
+
+// touchInterval là khoảng thời gian tối thiểu giữa hai lần cập nhật last_used_at của token
+const touchInterval = time.Minute
+
+// scopeSessionOnly đánh dấu route chỉ dùng được khi đăng nhập, không dùng được với API token
+const scopeSessionOnly = "-"
+
+// routeScopes là scope cần có để gọi từng route bằng API token, theo "METHOD đường_dẫn".
+// Route không có trong danh sách yêu cầu scope "*"
+var routeScopes = map[string]string{
+	"GET /api/me":                               "",
+	"GET /api/backups":                          models.ScopeBackupsRead,
+	"GET /api/backups/:id/uploads":              models.ScopeBackupsRead,
+	"GET /api/drive/status":                     models.ScopeBackupsRead,
+	"GET /api/storage/destinations":             models.ScopeBackupsRead,
+	"GET /api/storage/:name/objects":            models.ScopeBackupsRead,
+	"GET /api/notify/channels":                  models.ScopeBackupsRead,
+	"GET /api/logs":                             models.ScopeBackupsRead,
+	"GET /api/profiles":                         models.ScopeBackupsRead,
+	"GET /api/profiles/active":                  models.ScopeBackupsRead,
+	"GET /api/profiles/:id":                     models.ScopeBackupsRead,
+	"GET /api/profiles/:id/backups":             models.ScopeBackupsRead,
+	"GET /api/profiles/:id/drive/status":        models.ScopeBackupsRead,
+	"GET /api/schedule/options":                 models.ScopeBackupsRead,
+	"GET /api/schedule/jobs":                    models.ScopeBackupsRead,
+	"GET /api/schedule/queue":                   models.ScopeBackupsRead,
+	"GET /api/schedule/maintenance":             models.ScopeBackupsRead,
+	"GET /api/schedule/logs/:id":                models.ScopeBackupsRead,
+	"GET /api/ws/jobs":                          models.ScopeBackupsRead,
+	"GET /download/:id":                         models.ScopeBackupsRead,
+	"POST /api/schedule/run-now":                models.ScopeBackupsRun,
+	"POST /api/jobs/:id/cancel":                 models.ScopeBackupsRun,
+	"POST /dump":                                models.ScopeBackupsRun,
+	"POST /api/backups/:id/upload/:destination": models.ScopeBackupsUpload,
+	"POST /upload-last":                         models.ScopeBackupsUpload,
+	"POST /upload-all":                          models.ScopeBackupsUpload,
+	"POST /upload/:id":                          models.ScopeBackupsUpload,
+	"POST /api/backups/:id/verify":              models.ScopeBackupsVerify,
+	"POST /api/backups/:id/drill":               models.ScopeBackupsVerify,
+	"POST /api/schedule/pause":                  models.ScopeScheduleWrite,
+	"POST /api/schedule/resume":                 models.ScopeScheduleWrite,
+	"POST /api/schedule/maintenance":            models.ScopeScheduleWrite,
+	"GET /api/tokens":                           scopeSessionOnly,
+	"POST /api/tokens":                          scopeSessionOnly,
+	"DELETE /api/tokens/:id":                    scopeSessionOnly,
+}
+
+// profileScopedRoutes là các route tự kiểm tra profile bằng ProfileAllowed,
+// token giới hạn theo profile chỉ dùng được với các route này
+var profileScopedRoutes = map[string]bool{
+	"GET /api/me":                        true,
+	"GET /api/profiles/:id":              true,
+	"GET /api/profiles/:id/backups":      true,
+	"GET /api/profiles/:id/drive/status": true,
+	"GET /api/schedule/logs/:id":         true,
+	"POST /api/schedule/run-now":         true,
+	"POST /api/jobs/:id/cancel":          true,
+	"POST /api/schedule/pause":           true,
+	"POST /api/schedule/resume":          true,
+}
+
+// GenerateAPIToken tạo API token ngẫu nhiên, trả về token gốc (chỉ hiển thị một lần),
+// hash để lưu vào database và tiền tố để nhận diện
+func GenerateAPIToken() (plain, hash, prefix string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	plain = APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return plain, HashAPIToken(plain), plain[:len(APITokenPrefix)+6], nil
+}
+
+// HashAPIToken trả về hash SHA-256 (hex) của API token
+func HashAPIToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIToken xác thực API token, kiểm tra scope của route và lưu thông tin
+// người dùng sở hữu token vào context. Trả về false nếu request đã bị từ chối
+func authenticateAPIToken(c *gin.Context, plain string) bool {
+	authLogger := logger.Component("auth")
+
+	token, err := database.GetAPITokenByHash(HashAPIToken(plain))
+	if err == sql.ErrNoRows {
+		err = errors.New("API token không tồn tại")
+	}
+	if err == nil && !token.Active(time.Now()) {
+		err = errors.New("API token đã bị thu hồi hoặc hết hạn")
+	}
+	var role string
+	if err == nil {
+		role, err = currentRole(&models.JWTClaims{UserID: token.UserID, Username: token.Username})
+	}
+	if err != nil {
+		authLogger.Warn("API token không hợp lệ", "error", err, "path", c.Request.URL.Path)
+		recordDenied(c, "anonymous", models.AuditActorAPIToken, err.Error())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": err.Error()})
+		return false
+	}
+	authLogger = authLogger.With("token_id", token.ID, "username", token.Username)
+
+	route := c.Request.Method + " " + c.FullPath()
+	scope, known := routeScopes[route]
+	if !known {
+		scope = models.ScopeAll
+	}
+	denied := ""
+	switch {
+	case scope == scopeSessionOnly:
+		denied = "API này không dùng được với API token"
+	case scope != "" && !token.HasScope(scope):
+		denied = "API token không có scope " + scope
+	case len(token.ProfileIDs) > 0 && !profileScopedRoutes[route]:
+		denied = "API token chỉ dùng được cho một số profile, không dùng được với API này"
+	}
+	if denied != "" {
+		authLogger.Warn("Từ chối API token", "reason", denied, "path", c.Request.URL.Path)
+		recordDenied(c, token.Username, models.AuditActorAPIToken, denied)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"success": false, "error": denied})
+		return false
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= touchInterval {
+		if err := database.TouchAPIToken(token.ID, now); err != nil {
+			authLogger.Warn("Không thể cập nhật thời điểm sử dụng của API token", "error", err)
+		}
+	}
+
+	c.Set("user_id", token.UserID)
+	c.Set("username", token.Username)
+	c.Set("role", role)
+	c.Set("auth_source", "api_token")
+	c.Set("api_token", token)
+	return true
+}
+
+// ProfileAllowed kiểm tra request được phép thao tác với profile hay không.
+// Luôn đúng khi đăng nhập bằng JWT hoặc API token không giới hạn profile
+func ProfileAllowed(c *gin.Context, profileID int64) bool {
+	value, exists := c.Get("api_token")
+	if !exists {
+		return true
+	}
+	token, ok := value.(*models.APIToken)
+	return ok && token.AllowsProfile(profileID)
+}
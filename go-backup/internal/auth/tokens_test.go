@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// createTestToken tạo API token cho người dùng, trả về token gốc
+func createTestToken(t *testing.T, user *models.User, scopes []string, profileIDs []int64, expiresAt *time.Time) (string, *models.APIToken) {
+	t.Helper()
+	plain, hash, prefix, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := database.CreateAPIToken(models.APIToken{
+		UserID:     user.ID,
+		Name:       "test",
+		Prefix:     prefix,
+		TokenHash:  hash,
+		Scopes:     scopes,
+		ProfileIDs: profileIDs,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plain, token
+}
+
+// tokenTestRouter tạo router với AuthMiddleware và một số route đại diện cho từng loại scope.
+// Route run-now kiểm tra profile bằng ProfileAllowed như handler thật
+func tokenTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"success": true}) }
+
+	api := router.Group("/", AuthMiddleware())
+	api.GET("/api/backups", ok)
+	api.POST("/dump", ok)
+	api.GET("/api/tokens", ok)
+	api.POST("/api/users", ok) // Không có trong routeScopes, cần scope "*"
+	api.POST("/api/schedule/run-now", func(c *gin.Context) {
+		profileID, _ := strconv.ParseInt(c.Query("profile_id"), 10, 64)
+		if !ProfileAllowed(c, profileID) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false})
+			return
+		}
+		ok(c)
+	})
+	return router
+}
+
+// callWithToken gọi route với API token qua header Authorization
+func callWithToken(router *gin.Engine, method, path, token string) int {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	user := setupAuthTest(t)
+	router := tokenTestRouter()
+
+	readToken, _ := createTestToken(t, user, []string{models.ScopeBackupsRead}, nil, nil)
+	runToken, _ := createTestToken(t, user, []string{models.ScopeBackupsRun}, nil, nil)
+	allToken, _ := createTestToken(t, user, []string{models.ScopeAll}, nil, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"đủ scope", http.MethodGet, "/api/backups", readToken, http.StatusOK},
+		{"thiếu scope", http.MethodPost, "/dump", readToken, http.StatusForbidden},
+		{"scope khác không bao gồm quyền đọc", http.MethodGet, "/api/backups", runToken, http.StatusForbidden},
+		{"scope run", http.MethodPost, "/dump", runToken, http.StatusOK},
+		{"route không khai báo cần scope *", http.MethodPost, "/api/users", readToken, http.StatusForbidden},
+		{"scope * dùng được route không khai báo", http.MethodPost, "/api/users", allToken, http.StatusOK},
+		{"scope * bao gồm các scope khác", http.MethodPost, "/dump", allToken, http.StatusOK},
+		{"route chỉ dùng khi đăng nhập", http.MethodGet, "/api/tokens", allToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if got := callWithToken(router, tt.method, tt.path, tt.token); got != tt.want {
+			t.Errorf("%s: %s %s = %d, muốn %d", tt.name, tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAPITokenProfileLimit(t *testing.T) {
+	user := setupAuthTest(t)
+	router := tokenTestRouter()
+
+	token, _ := createTestToken(t, user, []string{models.ScopeAll}, []int64{1}, nil)
+
+	if got := callWithToken(router, http.MethodPost, "/api/schedule/run-now?profile_id=1", token); got != http.StatusOK {
+		t.Errorf("profile được phép: mã trả về = %d, muốn 200", got)
+	}
+	if got := callWithToken(router, http.MethodPost, "/api/schedule/run-now?profile_id=2", token); got != http.StatusForbidden {
+		t.Errorf("profile khác: mã trả về = %d, muốn 403", got)
+	}
+	// Route không tự kiểm tra profile bị từ chối với token giới hạn profile, kể cả scope *
+	if got := callWithToken(router, http.MethodPost, "/dump", token); got != http.StatusForbidden {
+		t.Errorf("route không kiểm tra profile: mã trả về = %d, muốn 403", got)
+	}
+}
+
+func TestAPITokenRevokedOrExpired(t *testing.T) {
+	user := setupAuthTest(t)
+	router := tokenTestRouter()
+
+	revoked, revokedToken := createTestToken(t, user, []string{models.ScopeAll}, nil, nil)
+	if err := database.RevokeAPIToken(revokedToken.ID); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	expired, _ := createTestToken(t, user, []string{models.ScopeAll}, nil, &past)
+	future := time.Now().Add(time.Hour)
+	valid, validToken := createTestToken(t, user, []string{models.ScopeAll}, nil, &future)
+
+	tests := map[string]string{
+		"đã thu hồi":    revoked,
+		"đã hết hạn":    expired,
+		"không tồn tại": APITokenPrefix + "unknown",
+	}
+	for name, token := range tests {
+		if got := callWithToken(router, http.MethodGet, "/api/backups", token); got != http.StatusUnauthorized {
+			t.Errorf("token %s: mã trả về = %d, muốn 401", name, got)
+		}
+	}
+
+	if got := callWithToken(router, http.MethodGet, "/api/backups", valid); got != http.StatusOK {
+		t.Fatalf("token còn hạn: mã trả về = %d, muốn 200", got)
+	}
+	used, err := database.GetAPIToken(validToken.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used.LastUsedAt == nil {
+		t.Error("last_used_at chưa được cập nhật sau khi dùng token")
+	}
+
+	// API token chỉ được nhận qua header
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/backups?token="+valid, nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("token qua query: mã trả về = %d, muốn 401", recorder.Code)
+	}
+}
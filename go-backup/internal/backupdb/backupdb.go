@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/logger"
 	"github.com/backup-cronjob/internal/models"
 )
 
@@ -33,11 +34,27 @@ func (br *BackupRecord) ToBackupFile() *models.BackupFile {
 	}
 }
 
+// NewBackup chứa thông tin của một file backup mới
+type NewBackup struct {
+	Filename     string
+	Filepath     string
+	Filesize     int64 // Kích thước file trên đĩa (sau khi nén)
+	CreatedAt    time.Time
+	DumpFormat   string
+	Compression  string
+	OriginalSize int64 // Kích thước dữ liệu dump trước khi nén
+	Encryption   string
+	Checksum     string // SHA-256 (hex) của file backup
+	ProfileID    int64  // Profile đã tạo backup, 0 nếu dùng cấu hình tạm từ configs
+	DBName       string // Tên database nguồn
+	JobLogID     int64  // job_log của lần chạy đã tạo backup, 0 nếu không chạy qua scheduler
+}
+
 // AddBackup thêm thông tin backup mới vào database
-func AddBackup(filename, filepath string, filesize int64, createdAt time.Time) (int64, error) {
+func AddBackup(backup NewBackup) (int64, error) {
 	// Kiểm tra xem file đã tồn tại trong database chưa
 	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM backups WHERE filepath = ?", filepath).Scan(&count)
+	err := database.DB.QueryRow("SELECT COUNT(*) FROM backups WHERE filepath = ?", backup.Filepath).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("lỗi khi kiểm tra file backup: %w", err)
 	}
@@ -45,7 +62,7 @@ func AddBackup(filename, filepath string, filesize int64, createdAt time.Time) (
 	// Nếu file đã tồn tại, return id của nó
 	if count > 0 {
 		var id int64
-		err := database.DB.QueryRow("SELECT id FROM backups WHERE filepath = ?", filepath).Scan(&id)
+		err := database.DB.QueryRow("SELECT id FROM backups WHERE filepath = ?", backup.Filepath).Scan(&id)
 		if err != nil {
 			return 0, fmt.Errorf("lỗi khi lấy id file backup: %w", err)
 		}
@@ -54,8 +71,12 @@ func AddBackup(filename, filepath string, filesize int64, createdAt time.Time) (
 
 	// Thêm thông tin backup vào database
 	result, err := database.DB.Exec(
-		"INSERT INTO backups (filename, filepath, filesize, created_at, uploaded) VALUES (?, ?, ?, ?, ?)",
-		filename, filepath, filesize, createdAt, false,
+		`INSERT INTO backups (filename, filepath, filesize, created_at, uploaded, dump_format, compression, original_size, encryption, checksum,
+			profile_id, db_name, job_log_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		backup.Filename, backup.Filepath, backup.Filesize, backup.CreatedAt, false,
+		backup.DumpFormat, backup.Compression, backup.OriginalSize, backup.Encryption, backup.Checksum,
+		backup.ProfileID, backup.DBName, backup.JobLogID,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("lỗi khi thêm thông tin backup: %w", err)
@@ -70,13 +91,129 @@ func AddBackup(filename, filepath string, filesize int64, createdAt time.Time) (
 	return id, nil
 }
 
+// backupColumns là danh sách cột dùng chung cho các truy vấn bảng backups
+const backupColumns = `id, filename, filepath, filesize, created_at, uploaded, uploaded_at, drive_link,
+	COALESCE(dump_format, 'data-only'), COALESCE(compression, 'none'), COALESCE(original_size, 0),
+	COALESCE(encryption, 'none'), COALESCE(checksum, ''), COALESCE(drill_status, ''), drilled_at, COALESCE(drill_duration_ms, 0),
+	COALESCE(drill_message, ''), COALESCE(profile_id, 0), COALESCE(db_name, ''), COALESCE(job_log_id, 0)`
+
+// rowScanner là interface chung của *sql.Row và *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBackup đọc một dòng của bảng backups thành BackupFile
+func scanBackup(row rowScanner) (*models.BackupFile, error) {
+	var id int64
+	var filename, filepath string
+	var filesize int64
+	var createdAt string
+	var uploaded bool
+	var uploadedAt sql.NullString
+	var driveLink sql.NullString
+	var dumpFormat, compression, encryptionMode, checksum string
+	var originalSize int64
+	var drillStatus, drillMessage string
+	var drilledAt sql.NullTime
+	var drillDuration int64
+	var profileID, jobLogID int64
+	var dbName string
+
+	err := row.Scan(&id, &filename, &filepath, &filesize, &createdAt, &uploaded, &uploadedAt, &driveLink,
+		&dumpFormat, &compression, &originalSize, &encryptionMode, &checksum,
+		&drillStatus, &drilledAt, &drillDuration, &drillMessage, &profileID, &dbName, &jobLogID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Chuyển đổi thời gian
+	t, err := time.Parse("2006-01-02 15:04:05", createdAt)
+	if err != nil {
+		// Thử parse với định dạng ISO 8601
+		t, err = time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			// Nếu không parse được thời gian, dùng thời gian hiện tại
+			t = time.Now()
+			logger.Component("backupdb").Warn("Không thể parse thời gian tạo backup", logger.KeyBackupID, id, "created_at", createdAt, "error", err)
+		}
+	}
+
+	// Kiểm tra nếu file tồn tại trên hệ thống
+	fileExists := true
+	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+		fileExists = false
+	}
+
+	backup := &models.BackupFile{
+		ID:           fmt.Sprintf("%d", id),
+		Name:         filename,
+		Path:         filepath,
+		Size:         filesize,
+		CreatedAt:    t,
+		Uploaded:     uploaded,
+		FileExists:   fileExists,
+		DumpFormat:   dumpFormat,
+		Compression:  compression,
+		OriginalSize: originalSize,
+		Encryption:   encryptionMode,
+		Encrypted:    encryptionMode != "" && encryptionMode != "none",
+		Checksum:     checksum,
+
+		DrillStatus:     drillStatus,
+		DrillDurationMs: drillDuration,
+		DrillMessage:    drillMessage,
+
+		ProfileID: profileID,
+		DBName:    dbName,
+		JobLogID:  jobLogID,
+	}
+	if drilledAt.Valid {
+		backup.DrilledAt = &drilledAt.Time
+	}
+
+	// Thêm đường dẫn Drive nếu có
+	if driveLink.Valid && driveLink.String != "" {
+		backup.DriveLink = driveLink.String
+	}
+
+	// Thêm thông tin thời gian upload nếu có
+	if uploaded && uploadedAt.Valid {
+		uploadTime, err := time.Parse("2006-01-02 15:04:05", uploadedAt.String)
+		if err != nil {
+			// Thử parse với định dạng ISO 8601
+			uploadTime, err = time.Parse(time.RFC3339, uploadedAt.String)
+			if err != nil {
+				// Bỏ qua nếu không parse được
+				logger.Component("backupdb").Warn("Không thể parse thời gian upload", logger.KeyBackupID, id, "uploaded_at", uploadedAt.String, "error", err)
+			} else {
+				backup.UploadedAt = &uploadTime
+			}
+		} else {
+			backup.UploadedAt = &uploadTime
+		}
+	}
+
+	return backup, nil
+}
+
 // GetAllBackups lấy danh sách backup từ database
 func GetAllBackups() ([]*models.BackupFile, error) {
+	return queryBackups("")
+}
+
+// GetProfileBackups lấy danh sách backup do profile tạo ra, mới nhất trước
+func GetProfileBackups(profileID int64) ([]*models.BackupFile, error) {
+	return queryBackups("WHERE profile_id = ?", profileID)
+}
+
+// queryBackups truy vấn bảng backups với điều kiện where, sắp xếp mới nhất trước
+func queryBackups(where string, args ...interface{}) ([]*models.BackupFile, error) {
 	rows, err := database.DB.Query(`
-		SELECT id, filename, filepath, filesize, created_at, uploaded, uploaded_at, drive_link
+		SELECT `+backupColumns+`
 		FROM backups
-		ORDER BY created_at DESC
-	`)
+		`+where+`
+		ORDER BY created_at DESC, id DESC
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("lỗi khi truy vấn danh sách backup: %w", err)
 	}
@@ -84,75 +221,67 @@ func GetAllBackups() ([]*models.BackupFile, error) {
 
 	var backups []*models.BackupFile
 	for rows.Next() {
-		var id int64
-		var filename, filepath string
-		var filesize int64
-		var createdAt string
-		var uploaded bool
-		var uploadedAt sql.NullString
-		var driveLink sql.NullString
-
-		err := rows.Scan(&id, &filename, &filepath, &filesize, &createdAt, &uploaded, &uploadedAt, &driveLink)
+		backup, err := scanBackup(rows)
 		if err != nil {
 			return nil, fmt.Errorf("lỗi khi đọc dữ liệu backup: %w", err)
 		}
+		backups = append(backups, backup)
+	}
 
-		// Chuyển đổi thời gian
-		t, err := time.Parse("2006-01-02 15:04:05", createdAt)
-		if err != nil {
-			// Thử parse với định dạng ISO 8601
-			t, err = time.Parse(time.RFC3339, createdAt)
-			if err != nil {
-				// Nếu không parse được thời gian, dùng thời gian hiện tại
-				t = time.Now()
-				fmt.Printf("Không thể parse thời gian '%s' cho file %s: %v\n", createdAt, filename, err)
-			}
-		}
+	if err := attachDestinations(backups); err != nil {
+		return nil, err
+	}
 
-		// Kiểm tra nếu file tồn tại trên hệ thống
-		fileExists := true
-		if _, err := os.Stat(filepath); os.IsNotExist(err) {
-			fileExists = false
-		}
+	logger.Component("backupdb").Debug("Đã tìm thấy backup trong database", "count", len(backups))
+	return backups, nil
+}
 
-		backup := &models.BackupFile{
-			ID:         fmt.Sprintf("%d", id),
-			Name:       filename,
-			Path:       filepath,
-			Size:       filesize,
-			CreatedAt:  t,
-			Uploaded:   uploaded,
-			FileExists: fileExists,
-		}
+// GetBackupByID lấy thông tin một file backup theo ID
+func GetBackupByID(id int64) (*models.BackupFile, error) {
+	row := database.DB.QueryRow(`
+		SELECT `+backupColumns+`
+		FROM backups
+		WHERE id = ?
+	`, id)
 
-		// Thêm đường dẫn Drive nếu có
-		if driveLink.Valid && driveLink.String != "" {
-			backup.DriveLink = driveLink.String
+	backup, err := scanBackup(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("không tìm thấy file backup có ID: %d", id)
 		}
+		return nil, fmt.Errorf("lỗi khi đọc dữ liệu backup: %w", err)
+	}
 
-		// Thêm thông tin thời gian upload nếu có
-		if uploaded && uploadedAt.Valid {
-			uploadTime, err := time.Parse("2006-01-02 15:04:05", uploadedAt.String)
-			if err != nil {
-				// Thử parse với định dạng ISO 8601
-				uploadTime, err = time.Parse(time.RFC3339, uploadedAt.String)
-				if err != nil {
-					// Bỏ qua nếu không parse được
-					fmt.Printf("Không thể parse thời gian upload '%s' cho file %s: %v\n", uploadedAt.String, filename, err)
-				} else {
-					backup.UploadedAt = &uploadTime
-				}
-			} else {
-				backup.UploadedAt = &uploadTime
-			}
+	if err := attachDestinations([]*models.BackupFile{backup}); err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+// GetBackupByPath lấy backup mới nhất có đường dẫn file cho trước
+func GetBackupByPath(path string) (*models.BackupFile, error) {
+	row := database.DB.QueryRow(`
+		SELECT `+backupColumns+`
+		FROM backups
+		WHERE filepath = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, path)
+
+	backup, err := scanBackup(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("không tìm thấy file backup: %s", path)
 		}
+		return nil, fmt.Errorf("lỗi khi đọc dữ liệu backup: %w", err)
+	}
 
-		backups = append(backups, backup)
+	if err := attachDestinations([]*models.BackupFile{backup}); err != nil {
+		return nil, err
 	}
 
-	// Ghi log số lượng backup đã tìm thấy
-	fmt.Printf("Đã tìm thấy %d file backup trong database\n", len(backups))
-	return backups, nil
+	return backup, nil
 }
 
 // GetAllBackupsFromFolder đọc tất cả các file backup từ thư mục
@@ -165,14 +294,23 @@ func GetAllBackupsFromFolder(backupDir string) ([]*models.BackupFile, error) {
 	return backups, nil
 }
 
-// FindLatestBackup tìm file backup mới nhất
-func FindLatestBackup() (*models.BackupFile, error) {
-	backups, err := GetAllBackups()
+// FindLatestBackup tìm file backup mới nhất của profile, profileID = 0 tìm trong tất cả backup
+func FindLatestBackup(profileID int64) (*models.BackupFile, error) {
+	var backups []*models.BackupFile
+	var err error
+	if profileID > 0 {
+		backups, err = GetProfileBackups(profileID)
+	} else {
+		backups, err = GetAllBackups()
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if len(backups) == 0 {
+		if profileID > 0 {
+			return nil, fmt.Errorf("không tìm thấy file backup nào của profile %d", profileID)
+		}
 		return nil, fmt.Errorf("không tìm thấy file backup nào")
 	}
 
@@ -199,3 +337,128 @@ func UpdateBackupUploadStatus(id int64, uploaded bool, driveLink string) error {
 
 	return nil
 }
+
+// UpdateBackupDrill lưu kết quả restore drill của backup
+func UpdateBackupDrill(id int64, status string, drilledAt time.Time, duration time.Duration, message string) error {
+	_, err := database.DB.Exec(
+		"UPDATE backups SET drill_status = ?, drilled_at = ?, drill_duration_ms = ?, drill_message = ? WHERE id = ?",
+		status, drilledAt, duration.Milliseconds(), message, id,
+	)
+	if err != nil {
+		return fmt.Errorf("lỗi khi cập nhật kết quả kiểm tra backup: %w", err)
+	}
+	return nil
+}
+
+// BackupUpload là thông tin bản sao của một backup trên một destination
+type BackupUpload struct {
+	BackupID    int64     `json:"backup_id"`
+	ProfileID   int64     `json:"profile_id,omitempty"` // Profile đã upload bản sao (dùng cho destination cấu hình theo profile)
+	Destination string    `json:"destination"`
+	Key         string    `json:"key"`
+	Location    string    `json:"location,omitempty"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum,omitempty"` // SHA-256 của bản sao, đã được kiểm tra sau khi copy
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// RecordUpload lưu bản sao của backup trên một destination và đánh dấu backup đã được upload.
+// Với Google Drive, link của file cũng được lưu vào drive_link
+func RecordUpload(upload BackupUpload) error {
+	if upload.UploadedAt.IsZero() {
+		upload.UploadedAt = time.Now()
+	}
+
+	_, err := database.DB.Exec(
+		`INSERT OR REPLACE INTO backup_uploads (backup_id, profile_id, destination, object_key, location, size, checksum, uploaded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		upload.BackupID, upload.ProfileID, upload.Destination, upload.Key, upload.Location, upload.Size, upload.Checksum, upload.UploadedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("lỗi khi lưu thông tin upload: %w", err)
+	}
+
+	if upload.Destination == models.DestinationDrive {
+		return UpdateBackupUploadStatus(upload.BackupID, true, upload.Location)
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE backups SET uploaded = ?, uploaded_at = ? WHERE id = ?",
+		true, upload.UploadedAt, upload.BackupID,
+	)
+	if err != nil {
+		return fmt.Errorf("lỗi khi cập nhật trạng thái upload: %w", err)
+	}
+
+	return nil
+}
+
+// GetBackupUploads lấy danh sách bản sao của một backup trên các destination
+func GetBackupUploads(backupID int64) ([]BackupUpload, error) {
+	rows, err := database.DB.Query(`
+		SELECT backup_id, COALESCE(profile_id, 0), destination, object_key, COALESCE(location, ''), COALESCE(size, 0),
+			COALESCE(checksum, ''), uploaded_at
+		FROM backup_uploads
+		WHERE backup_id = ?
+		ORDER BY id
+	`, backupID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi khi truy vấn thông tin upload: %w", err)
+	}
+	defer rows.Close()
+
+	uploads := []BackupUpload{}
+	for rows.Next() {
+		var upload BackupUpload
+		if err := rows.Scan(&upload.BackupID, &upload.ProfileID, &upload.Destination, &upload.Key, &upload.Location,
+			&upload.Size, &upload.Checksum, &upload.UploadedAt); err != nil {
+			return nil, fmt.Errorf("lỗi khi đọc thông tin upload: %w", err)
+		}
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, nil
+}
+
+// DeleteBackupUpload xóa thông tin bản sao của backup trên một destination
+func DeleteBackupUpload(backupID int64, destination string) error {
+	_, err := database.DB.Exec("DELETE FROM backup_uploads WHERE backup_id = ? AND destination = ?", backupID, destination)
+	if err != nil {
+		return fmt.Errorf("lỗi khi xóa thông tin upload: %w", err)
+	}
+	return nil
+}
+
+// attachDestinations điền danh sách destination đã có bản sao cho các backup
+func attachDestinations(backups []*models.BackupFile) error {
+	if len(backups) == 0 {
+		return nil
+	}
+
+	rows, err := database.DB.Query("SELECT backup_id, destination FROM backup_uploads ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("lỗi khi truy vấn thông tin upload: %w", err)
+	}
+	defer rows.Close()
+
+	destinations := make(map[string][]string)
+	for rows.Next() {
+		var backupID int64
+		var destination string
+		if err := rows.Scan(&backupID, &destination); err != nil {
+			return fmt.Errorf("lỗi khi đọc thông tin upload: %w", err)
+		}
+		id := fmt.Sprintf("%d", backupID)
+		destinations[id] = append(destinations[id], destination)
+	}
+
+	for _, backup := range backups {
+		backup.Destinations = destinations[backup.ID]
+		// Backup cũ được upload lên Drive trước khi có bảng backup_uploads
+		if len(backup.Destinations) == 0 && backup.DriveLink != "" {
+			backup.Destinations = []string{models.DestinationDrive}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Các thuật toán nén được hỗ trợ
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// Algorithms trả về danh sách thuật toán nén hợp lệ
+func Algorithms() []string {
+	return []string{None, Gzip, Zstd}
+}
+
+// IsValid kiểm tra thuật toán nén có được hỗ trợ không (chuỗi rỗng tương đương none)
+func IsValid(algorithm string) bool {
+	switch algorithm {
+	case "", None, Gzip, Zstd:
+		return true
+	}
+	return false
+}
+
+// Normalize chuẩn hóa tên thuật toán, chuỗi rỗng trở thành none
+func Normalize(algorithm string) string {
+	if algorithm == "" {
+		return None
+	}
+	return algorithm
+}
+
+// Extension trả về phần mở rộng file tương ứng với thuật toán nén
+func Extension(algorithm string) string {
+	switch algorithm {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// ContentType trả về Content-Type của file đã nén
+func ContentType(algorithm string) string {
+	switch algorithm {
+	case Gzip:
+		return "application/gzip"
+	case Zstd:
+		return "application/zstd"
+	}
+	return ""
+}
+
+// FromFileName xác định thuật toán nén dựa vào phần mở rộng của tên file
+func FromFileName(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return Gzip
+	case strings.HasSuffix(name, ".zst"):
+		return Zstd
+	}
+	return None
+}
+
+// TrimExtension bỏ phần mở rộng nén khỏi tên file
+func TrimExtension(name, algorithm string) string {
+	return strings.TrimSuffix(name, Extension(algorithm))
+}
+
+// ValidateLevel kiểm tra mức nén, 0 nghĩa là mức mặc định của thuật toán
+func ValidateLevel(algorithm string, level int) error {
+	if level == 0 {
+		return nil
+	}
+	switch algorithm {
+	case Gzip:
+		if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return fmt.Errorf("mức nén gzip phải từ %d đến %d", gzip.BestSpeed, gzip.BestCompression)
+		}
+	case Zstd:
+		if level < 1 || level > 22 {
+			return fmt.Errorf("mức nén zstd phải từ 1 đến 22")
+		}
+	}
+	return nil
+}
+
+// NewWriter tạo writer nén dữ liệu ghi vào w. Với none, trả về writer ghi thẳng vào w.
+// Close phải được gọi để ghi phần cuối của dữ liệu nén, Close không đóng w
+func NewWriter(w io.Writer, algorithm string, level int) (io.WriteCloser, error) {
+	switch Normalize(algorithm) {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case Zstd:
+		opts := []zstd.EOption{}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	}
+	return nil, fmt.Errorf("thuật toán nén không được hỗ trợ: %s", algorithm)
+}
+
+// NewReader tạo reader giải nén dữ liệu đọc từ r. Với none, trả về reader đọc thẳng từ r.
+// Close không đóng r
+func NewReader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch Normalize(algorithm) {
+	case None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("thuật toán nén không được hỗ trợ: %s", algorithm)
+}
+
+// CountingWriter đếm số byte đã ghi qua nó
+type CountingWriter struct {
+	W     io.Writer
+	Count int64
+}
+
+// Write ghi dữ liệu và cộng dồn số byte
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.W.Write(p)
+	c.Count += int64(n)
+	return n, err
+}
+
+// nopWriteCloser bọc một io.Writer với Close không làm gì
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
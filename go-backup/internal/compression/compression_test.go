@@ -0,0 +1,135 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testData là dữ liệu dump giả đủ dài để dữ liệu nén có nhiều block
+var testData = []byte(strings.Repeat("INSERT INTO items VALUES (1, 'sản phẩm');\n", 5000))
+
+// compress nén data bằng thuật toán algorithm
+func compress(t *testing.T, data []byte, algorithm string, level int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, algorithm, level)
+	if err != nil {
+		t.Fatalf("NewWriter(%s): %v", algorithm, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// decompress giải nén toàn bộ dữ liệu, trả về lỗi của bước mở hoặc đọc
+func decompress(data []byte, algorithm string) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), algorithm)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		level     int
+	}{
+		{"", 0},
+		{None, 0},
+		{Gzip, 0},
+		{Gzip, 9},
+		{Zstd, 0},
+		{Zstd, 19},
+	}
+	for _, tt := range tests {
+		compressed := compress(t, testData, tt.algorithm, tt.level)
+		if Normalize(tt.algorithm) != None && len(compressed) >= len(testData) {
+			t.Errorf("%s mức %d: dữ liệu nén (%d byte) không nhỏ hơn dữ liệu gốc (%d byte)", tt.algorithm, tt.level, len(compressed), len(testData))
+		}
+		got, err := decompress(compressed, tt.algorithm)
+		if err != nil {
+			t.Errorf("%s mức %d: giải nén: %v", tt.algorithm, tt.level, err)
+			continue
+		}
+		if !bytes.Equal(got, testData) {
+			t.Errorf("%s mức %d: dữ liệu giải nén khác dữ liệu gốc", tt.algorithm, tt.level)
+		}
+	}
+}
+
+func TestWrongAlgorithm(t *testing.T) {
+	// Đọc dữ liệu bằng thuật toán khác thuật toán đã nén phải báo lỗi, không trả về dữ liệu rác
+	pairs := [][2]string{{Gzip, Zstd}, {Zstd, Gzip}}
+	for _, pair := range pairs {
+		compressed := compress(t, testData, pair[0], 0)
+		if _, err := decompress(compressed, pair[1]); err == nil {
+			t.Errorf("dữ liệu %s đọc bằng %s phải trả về lỗi", pair[0], pair[1])
+		}
+	}
+}
+
+func TestTruncatedStream(t *testing.T) {
+	for _, algorithm := range []string{Gzip, Zstd} {
+		compressed := compress(t, testData, algorithm, 0)
+		for _, size := range []int{10, len(compressed) / 2, len(compressed) - 1} {
+			got, err := decompress(compressed[:size], algorithm)
+			if err == nil {
+				t.Errorf("%s cắt còn %d/%d byte: giải nén được %d byte, muốn lỗi", algorithm, size, len(compressed), len(got))
+			}
+		}
+	}
+}
+
+func TestUnsupportedAlgorithm(t *testing.T) {
+	if _, err := NewWriter(io.Discard, "lz4", 0); err == nil {
+		t.Error("NewWriter với thuật toán không hỗ trợ phải trả về lỗi")
+	}
+	if _, err := NewReader(strings.NewReader(""), "lz4"); err == nil {
+		t.Error("NewReader với thuật toán không hỗ trợ phải trả về lỗi")
+	}
+	if IsValid("lz4") {
+		t.Error("IsValid(lz4) = true")
+	}
+}
+
+func TestValidateLevel(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		level     int
+		valid     bool
+	}{
+		{Gzip, 0, true},
+		{Gzip, 1, true},
+		{Gzip, 9, true},
+		{Gzip, 10, false},
+		{Gzip, -2, false},
+		{Zstd, 22, true},
+		{Zstd, 23, false},
+		{None, 50, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateLevel(tt.algorithm, tt.level); (err == nil) != tt.valid {
+			t.Errorf("ValidateLevel(%s, %d) = %v, hợp lệ muốn %v", tt.algorithm, tt.level, err, tt.valid)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	for _, algorithm := range Algorithms() {
+		name := "shop_20240102_030405.sql" + Extension(algorithm)
+		if got := FromFileName(name); got != algorithm {
+			t.Errorf("FromFileName(%q) = %q, muốn %q", name, got, algorithm)
+		}
+		if got := TrimExtension(name, algorithm); got != "shop_20240102_030405.sql" {
+			t.Errorf("TrimExtension(%q) = %q", name, got)
+		}
+	}
+}
@@ -33,6 +33,49 @@ type Config struct {
 	GoogleClientSecret  string
 	TokenDir            string
 	FolderDrive         string
+	AgeIdentity         string // Khóa bí mật age dùng để giải mã backup
+	ManifestSigningKey  string // Khóa bí mật ed25519 (PEM PKCS#8 hoặc base64) dùng để ký manifest của thư mục backup
+	MetricsToken        string // Bearer token bảo vệ endpoint /metrics, rỗng = không yêu cầu xác thực
+	SecretsMasterKey    string // Master key (base64/hex, 32 byte) mã hóa credentials trong database và token.json, chỉ nạp từ môi trường
+	LogLevel            string // Cấp độ log tối thiểu: debug, info, warn, error
+	LogFormat           string // Định dạng log: text hoặc json
+	LogBufferSize       int    // Số mục log gần nhất được giữ lại để xem qua API
+	S3Endpoint          string // Endpoint của S3 hoặc dịch vụ tương thích (MinIO)
+	S3Region            string
+	S3Bucket            string
+	S3AccessKey         string
+	S3SecretKey         string
+	S3UseSSL            bool
+	S3Prefix            string        // Thư mục (prefix) chứa backup trong bucket
+	LocalMirrorDir      string        // Thư mục đã mount (NFS, ổ đĩa ngoài) nhận bản sao của backup
+	BackupWorkers       int           // Số backup được chạy đồng thời
+	BackupQueueTimeout  time.Duration // Thời gian tối đa một backup chờ trong hàng đợi, 0 = không giới hạn
+
+	// Cấu hình các kênh thông báo kết quả backup
+	NotifySMTPHost         string
+	NotifySMTPPort         int
+	NotifySMTPUsername     string
+	NotifySMTPPassword     string
+	NotifySMTPFrom         string
+	NotifyEmailTo          string // Danh sách email nhận thông báo, phân cách bằng dấu phẩy
+	NotifyWebhookURL       string // Incoming webhook kiểu Slack/Mattermost
+	NotifyTelegramToken    string // Token của Telegram bot
+	NotifyTelegramChatID   string
+	NotifyTelegramAPIURL   string // Địa chỉ Telegram Bot API, đổi được để thử với server giả lập
+	NotifyDigestSchedule   string // Lịch cron gửi báo cáo tổng hợp hằng ngày
+	NotifyTemplateFailure  string // Mẫu thông báo (text/template), rỗng = mẫu mặc định
+	NotifyTemplateSuccess  string
+	NotifyTemplateRecovery string
+	NotifyTemplateDigest   string
+}
+
+// notifyKeys là các key cấu hình kênh thông báo
+var notifyKeys = []string{
+	"NOTIFY_SMTP_HOST", "NOTIFY_SMTP_PORT", "NOTIFY_SMTP_USERNAME", "NOTIFY_SMTP_PASSWORD", "NOTIFY_SMTP_FROM",
+	"NOTIFY_EMAIL_TO", "NOTIFY_WEBHOOK_URL",
+	"NOTIFY_TELEGRAM_BOT_TOKEN", "NOTIFY_TELEGRAM_CHAT_ID", "NOTIFY_TELEGRAM_API_URL",
+	"NOTIFY_DIGEST_SCHEDULE",
+	"NOTIFY_TEMPLATE_FAILURE", "NOTIFY_TEMPLATE_SUCCESS", "NOTIFY_TEMPLATE_RECOVERY", "NOTIFY_TEMPLATE_DIGEST",
 }
 
 // ConfigLoader định nghĩa interface để nạp cấu hình từ database
@@ -58,6 +101,8 @@ func InitConfig() (cfg *Config, err error) {
 		TokenSymmetricKey:   "12345678901234567890123456789012",
 		AccessTokenDuration: time.Hour * 24,
 		JWTSecret:           "",
+		DBHost:              getEnv("DB_HOST", ""),
+		DBPort:              getEnv("DB_PORT", "5432"),
 		AdminUsername:       getEnv("ADMIN_USERNAME", "admin"),
 		AdminPassword:       getEnv("ADMIN_PASSWORD", "admin123"),
 		BackupDir:           getEnv("BACKUP_DIR", "./backup/"),
@@ -65,6 +110,60 @@ func InitConfig() (cfg *Config, err error) {
 		GoogleClientSecret:  getEnv("GOOGLE_CLIENT_SECRET", ""),
 		TokenDir:            getEnv("TOKEN_DIR", "./data/token/"),
 		FolderDrive:         getEnv("GOOGLE_FOLDER", ""),
+		AgeIdentity:         getEnv("AGE_IDENTITY", ""),
+		ManifestSigningKey:  getEnv("MANIFEST_SIGNING_KEY", ""),
+		MetricsToken:        getEnv("METRICS_TOKEN", ""),
+		SecretsMasterKey:    getEnv("SECRETS_MASTER_KEY", ""),
+		LogLevel:            getEnv("LOG_LEVEL", "info"),
+		LogFormat:           getEnv("LOG_FORMAT", "text"),
+		LogBufferSize:       GetInt("LOG_BUFFER_SIZE", 2000),
+		S3Endpoint:          getEnv("S3_ENDPOINT", ""),
+		S3Region:            getEnv("S3_REGION", ""),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		S3AccessKey:         getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:         getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:            getEnv("S3_USE_SSL", "true") != "false",
+		S3Prefix:            getEnv("S3_PREFIX", ""),
+		LocalMirrorDir:      getEnv("LOCAL_MIRROR_DIR", ""),
+		BackupWorkers:       GetInt("BACKUP_WORKERS", 2),
+		BackupQueueTimeout:  time.Duration(GetInt("BACKUP_QUEUE_TIMEOUT", 60)) * time.Minute,
+
+		NotifySMTPHost:       getEnv("NOTIFY_SMTP_HOST", ""),
+		NotifySMTPPort:       GetInt("NOTIFY_SMTP_PORT", 587),
+		NotifySMTPUsername:   getEnv("NOTIFY_SMTP_USERNAME", ""),
+		NotifySMTPPassword:   getEnv("NOTIFY_SMTP_PASSWORD", ""),
+		NotifySMTPFrom:       getEnv("NOTIFY_SMTP_FROM", ""),
+		NotifyEmailTo:        getEnv("NOTIFY_EMAIL_TO", ""),
+		NotifyWebhookURL:     getEnv("NOTIFY_WEBHOOK_URL", ""),
+		NotifyTelegramToken:  getEnv("NOTIFY_TELEGRAM_BOT_TOKEN", ""),
+		NotifyTelegramChatID: getEnv("NOTIFY_TELEGRAM_CHAT_ID", ""),
+		NotifyTelegramAPIURL: getEnv("NOTIFY_TELEGRAM_API_URL", "https://api.telegram.org"),
+		NotifyDigestSchedule: getEnv("NOTIFY_DIGEST_SCHEDULE", "0 8 * * *"),
+	}
+
+	// Cho phép nạp age identity từ file (ví dụ Docker secret)
+	if identityFile := os.Getenv("AGE_IDENTITY_FILE"); identityFile != "" && cfg.AgeIdentity == "" {
+		content, err := os.ReadFile(identityFile)
+		if err != nil {
+			log.Printf("Warning: Không thể đọc AGE_IDENTITY_FILE: %v", err)
+		} else {
+			cfg.AgeIdentity = string(content)
+		}
+	}
+	if keyFile := os.Getenv("MANIFEST_SIGNING_KEY_FILE"); keyFile != "" && cfg.ManifestSigningKey == "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			log.Printf("Warning: Không thể đọc MANIFEST_SIGNING_KEY_FILE: %v", err)
+		} else {
+			cfg.ManifestSigningKey = string(content)
+		}
+	}
+	if keyFile := os.Getenv("SECRETS_MASTER_KEY_FILE"); keyFile != "" && cfg.SecretsMasterKey == "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("không thể đọc SECRETS_MASTER_KEY_FILE: %v", err)
+		}
+		cfg.SecretsMasterKey = string(content)
 	}
 
 	// Chỉ chuyển đổi đường dẫn tuyệt đối nếu không bắt đầu bằng ./
@@ -135,9 +234,13 @@ func (cfg *Config) LoadConfigFromDB(getConfigFn ConfigLoader) error {
 	keys := []string{
 		"ADMIN_USERNAME", "ADMIN_PASSWORD", "JWT_SECRET",
 		"GOOGLE_CLIENT_ID", "GOOGLE_CLIENT_SECRET", "FOLDER_DRIVE",
-		"DB_USER", "DB_PASSWORD", "CONTAINER_NAME", "DB_NAME",
-		"BACKUP_DIR", "BACKUP_RETENTION_DAYS", "CRON_SCHEDULE",
+		"DB_USER", "DB_PASSWORD", "CONTAINER_NAME", "DB_NAME", "DB_HOST", "DB_PORT",
+		"BACKUP_DIR", "BACKUP_RETENTION_DAYS", "CRON_SCHEDULE", "ENCRYPTION_AGE_IDENTITY",
+		"S3_ENDPOINT", "S3_REGION", "S3_BUCKET", "S3_ACCESS_KEY", "S3_SECRET_KEY", "S3_USE_SSL", "S3_PREFIX",
+		"LOCAL_MIRROR_DIR", "BACKUP_WORKERS", "BACKUP_QUEUE_TIMEOUT", "MANIFEST_SIGNING_KEY",
+		"METRICS_TOKEN", "LOG_LEVEL", "LOG_FORMAT", "LOG_BUFFER_SIZE",
 	}
+	keys = append(keys, notifyKeys...)
 
 	// Nạp từng giá trị
 	for _, key := range keys {
@@ -207,8 +310,34 @@ func (cfg *Config) LoadConfigFromDB(getConfigFn ConfigLoader) error {
 			cfg.DBPassword = value
 		case "DB_NAME":
 			cfg.DBName = value
+		case "DB_HOST":
+			cfg.DBHost = value
+		case "DB_PORT":
+			cfg.DBPort = value
 		case "CONTAINER_NAME":
 			log.Printf("Nạp CONTAINER_NAME từ database: %s", value)
+		case "ENCRYPTION_AGE_IDENTITY":
+			cfg.AgeIdentity = value
+		case "MANIFEST_SIGNING_KEY":
+			cfg.ManifestSigningKey = value
+		case "METRICS_TOKEN":
+			cfg.MetricsToken = value
+		case "LOG_LEVEL":
+			cfg.LogLevel = value
+		case "LOG_FORMAT":
+			cfg.LogFormat = value
+		case "LOG_BUFFER_SIZE":
+			if size, err := strconv.Atoi(value); err == nil && size >= 0 {
+				cfg.LogBufferSize = size
+			}
+		case "BACKUP_WORKERS", "BACKUP_QUEUE_TIMEOUT":
+			cfg.setQueueConfig(key, value)
+		default:
+			if strings.HasPrefix(key, "NOTIFY_") {
+				cfg.setNotifyConfig(key, value)
+			} else {
+				cfg.setStorageConfig(key, value)
+			}
 		}
 	}
 
@@ -298,10 +427,36 @@ func (cfg *Config) UpdateConfig(newValues map[string]string) {
 			cfg.DBPassword = value
 		case "DB_NAME":
 			cfg.DBName = value
+		case "DB_HOST":
+			cfg.DBHost = value
+		case "DB_PORT":
+			cfg.DBPort = value
 		case "CONTAINER_NAME":
 			log.Printf("Cập nhật CONTAINER_NAME: %s", value)
 		case "CRON_SCHEDULE":
 			log.Printf("Cập nhật CRON_SCHEDULE: %s", value)
+		case "ENCRYPTION_AGE_IDENTITY":
+			cfg.AgeIdentity = value
+		case "MANIFEST_SIGNING_KEY":
+			cfg.ManifestSigningKey = value
+		case "METRICS_TOKEN":
+			cfg.MetricsToken = value
+		case "LOG_LEVEL":
+			cfg.LogLevel = value
+		case "LOG_FORMAT":
+			cfg.LogFormat = value
+		case "LOG_BUFFER_SIZE":
+			if size, err := strconv.Atoi(value); err == nil && size >= 0 {
+				cfg.LogBufferSize = size
+			}
+		case "BACKUP_WORKERS", "BACKUP_QUEUE_TIMEOUT":
+			cfg.setQueueConfig(key, value)
+		default:
+			if strings.HasPrefix(key, "NOTIFY_") {
+				cfg.setNotifyConfig(key, value)
+			} else {
+				cfg.setStorageConfig(key, value)
+			}
 		}
 	}
 }
@@ -314,3 +469,88 @@ func GetInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+// setQueueConfig cập nhật cấu hình hàng đợi backup tương ứng với key, bỏ qua giá trị không hợp lệ
+func (cfg *Config) setQueueConfig(key, value string) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Giá trị không hợp lệ cho %s: %s", key, value)
+		return
+	}
+	switch key {
+	case "BACKUP_WORKERS":
+		if n == 0 {
+			log.Printf("BACKUP_WORKERS phải lớn hơn 0, giữ nguyên %d", cfg.BackupWorkers)
+			return
+		}
+		cfg.BackupWorkers = n
+	case "BACKUP_QUEUE_TIMEOUT":
+		cfg.BackupQueueTimeout = time.Duration(n) * time.Minute
+	}
+}
+
+// setStorageConfig cập nhật cấu hình destination (S3, thư mục mirror) tương ứng với key
+func (cfg *Config) setStorageConfig(key, value string) {
+	switch key {
+	case "S3_ENDPOINT":
+		cfg.S3Endpoint = value
+	case "S3_REGION":
+		cfg.S3Region = value
+	case "S3_BUCKET":
+		cfg.S3Bucket = value
+	case "S3_ACCESS_KEY":
+		cfg.S3AccessKey = value
+	case "S3_SECRET_KEY":
+		cfg.S3SecretKey = value
+	case "S3_USE_SSL":
+		cfg.S3UseSSL = value != "false"
+	case "S3_PREFIX":
+		cfg.S3Prefix = value
+	case "LOCAL_MIRROR_DIR":
+		cfg.LocalMirrorDir = value
+	}
+}
+
+// setNotifyConfig cập nhật cấu hình kênh thông báo tương ứng với key
+func (cfg *Config) setNotifyConfig(key, value string) {
+	switch key {
+	case "NOTIFY_SMTP_HOST":
+		cfg.NotifySMTPHost = value
+	case "NOTIFY_SMTP_PORT":
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 {
+			log.Printf("Giá trị không hợp lệ cho %s: %s", key, value)
+			return
+		}
+		cfg.NotifySMTPPort = port
+	case "NOTIFY_SMTP_USERNAME":
+		cfg.NotifySMTPUsername = value
+	case "NOTIFY_SMTP_PASSWORD":
+		cfg.NotifySMTPPassword = value
+	case "NOTIFY_SMTP_FROM":
+		cfg.NotifySMTPFrom = value
+	case "NOTIFY_EMAIL_TO":
+		cfg.NotifyEmailTo = value
+	case "NOTIFY_WEBHOOK_URL":
+		cfg.NotifyWebhookURL = value
+	case "NOTIFY_TELEGRAM_BOT_TOKEN":
+		cfg.NotifyTelegramToken = value
+	case "NOTIFY_TELEGRAM_CHAT_ID":
+		cfg.NotifyTelegramChatID = value
+	case "NOTIFY_TELEGRAM_API_URL":
+		if value == "" {
+			value = "https://api.telegram.org"
+		}
+		cfg.NotifyTelegramAPIURL = value
+	case "NOTIFY_DIGEST_SCHEDULE":
+		cfg.NotifyDigestSchedule = value
+	case "NOTIFY_TEMPLATE_FAILURE":
+		cfg.NotifyTemplateFailure = value
+	case "NOTIFY_TEMPLATE_SUCCESS":
+		cfg.NotifyTemplateSuccess = value
+	case "NOTIFY_TEMPLATE_RECOVERY":
+		cfg.NotifyTemplateRecovery = value
+	case "NOTIFY_TEMPLATE_DIGEST":
+		cfg.NotifyTemplateDigest = value
+	}
+}
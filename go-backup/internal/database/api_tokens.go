@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+)
+
+// apiTokenColumns là danh sách cột dùng cho các truy vấn bảng api_tokens
+const apiTokenColumns = `t.id, t.user_id, COALESCE(u.username, ''), t.name, t.prefix, t.token_hash, t.scopes, t.profile_ids,
+	t.created_at, t.last_used_at, t.expires_at, t.revoked_at`
+
+// scanAPIToken đọc một dòng của bảng api_tokens thành APIToken
+func scanAPIToken(row rowScanner) (*models.APIToken, error) {
+	token := &models.APIToken{}
+	var scopes, profileIDs string
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Username, &token.Name, &token.Prefix, &token.TokenHash,
+		&scopes, &profileIDs, &token.CreatedAt, &lastUsedAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = splitNonEmpty(scopes)
+	token.ProfileIDs = []int64{}
+	for _, value := range splitNonEmpty(profileIDs) {
+		if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+			token.ProfileIDs = append(token.ProfileIDs, id)
+		}
+	}
+	token.LastUsedAt = nullTimePtr(lastUsedAt)
+	token.ExpiresAt = nullTimePtr(expiresAt)
+	token.RevokedAt = nullTimePtr(revokedAt)
+	return token, nil
+}
+
+// CreateAPIToken lưu API token mới (chỉ lưu hash) và trả về bản ghi đã tạo
+func CreateAPIToken(token models.APIToken) (*models.APIToken, error) {
+	ids := make([]string, 0, len(token.ProfileIDs))
+	for _, id := range token.ProfileIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	result, err := DB.Exec(
+		`INSERT INTO api_tokens (user_id, name, prefix, token_hash, scopes, profile_ids, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.UserID, token.Name, token.Prefix, token.TokenHash,
+		strings.Join(token.Scopes, ","), strings.Join(ids, ","), time.Now(), token.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetAPIToken(id)
+}
+
+// GetAPIToken lấy API token theo ID
+func GetAPIToken(id int64) (*models.APIToken, error) {
+	return scanAPIToken(DB.QueryRow(`SELECT `+apiTokenColumns+`
+		FROM api_tokens t LEFT JOIN users u ON u.id = t.user_id
+		WHERE t.id = ?`, id))
+}
+
+// GetAPITokenByHash lấy API token theo hash SHA-256 của token
+func GetAPITokenByHash(hash string) (*models.APIToken, error) {
+	return scanAPIToken(DB.QueryRow(`SELECT `+apiTokenColumns+`
+		FROM api_tokens t LEFT JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ?`, hash))
+}
+
+// GetAPITokens lấy các API token của người dùng, userID = 0 lấy tất cả
+func GetAPITokens(userID int64) ([]models.APIToken, error) {
+	rows, err := DB.Query(`SELECT `+apiTokenColumns+`
+		FROM api_tokens t LEFT JOIN users u ON u.id = t.user_id
+		WHERE ? = 0 OR t.user_id = ?
+		ORDER BY t.id DESC`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// TouchAPIToken cập nhật thời điểm sử dụng gần nhất của API token
+func TouchAPIToken(id int64, usedAt time.Time) error {
+	_, err := DB.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, usedAt, id)
+	return err
+}
+
+// RevokeAPIToken thu hồi API token, bản ghi được giữ lại để tra cứu
+func RevokeAPIToken(id int64) error {
+	_, err := DB.Exec(`UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now(), id)
+	return err
+}
+
+// splitNonEmpty tách chuỗi phân cách bằng dấu phẩy, bỏ phần tử rỗng
+func splitNonEmpty(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// nullTimePtr chuyển sql.NullTime thành con trỏ, nil nếu không có giá trị
+func nullTimePtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t := value.Time
+	return &t
+}
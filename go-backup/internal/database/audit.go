@@ -0,0 +1,107 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+)
+
+// InsertAuditEvent thêm một bản ghi vào audit log.
+// Thời gian được lưu theo UTC để lọc theo khoảng thời gian so sánh đúng
+func InsertAuditEvent(event models.AuditEvent) (int64, error) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+
+	changes := ""
+	if len(event.Changes) > 0 {
+		data, err := json.Marshal(event.Changes)
+		if err != nil {
+			return 0, err
+		}
+		changes = string(data)
+	}
+
+	result, err := DB.Exec(
+		`INSERT INTO audit_events (created_at, actor, actor_type, action, target_type, target_id, changes, ip, result, message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.CreatedAt, event.Actor, event.ActorType, event.Action, event.TargetType, event.TargetID,
+		changes, event.IP, event.Result, event.Message,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// GetAuditEvents truy vấn audit log theo bộ lọc, mới nhất trước
+func GetAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "(action = ? OR action LIKE ?)")
+		args = append(args, filter.Action, strings.TrimSuffix(filter.Action, ".")+".%")
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.Result != "" {
+		conditions = append(conditions, "result = ?")
+		args = append(args, filter.Result)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := `SELECT id, created_at, actor, actor_type, action, target_type, target_id, changes, ip, result, message
+		FROM audit_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var changes string
+		if err := rows.Scan(&event.ID, &event.CreatedAt, &event.Actor, &event.ActorType, &event.Action,
+			&event.TargetType, &event.TargetID, &changes, &event.IP, &event.Result, &event.Message); err != nil {
+			return nil, err
+		}
+		if changes != "" {
+			if err := json.Unmarshal([]byte(changes), &event.Changes); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -4,11 +4,14 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/logger"
 	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/secrets"
 	_ "modernc.org/sqlite"
 )
 
@@ -26,12 +29,35 @@ type BackupRecord struct {
 // DB là đối tượng database chung cho ứng dụng
 var DB *sql.DB
 
-// InitDB khởi tạo kết nối database
+// InitDB khởi tạo kết nối database, áp dụng các migration còn thiếu và dữ liệu mặc định
 func InitDB(cfg *config.Config) error {
-	var err error
+	// Nạp master key trước khi đọc/ghi credentials
+	if err := secrets.Configure(cfg.SecretsMasterKey); err != nil {
+		return fmt.Errorf("master key không hợp lệ: %w", err)
+	}
+
+	if err := Open(cfg); err != nil {
+		return err
+	}
+
+	// Lỗi khi thiết lập không bao giờ được xử lý bằng cách tạo lại database vì sẽ làm mất dữ liệu
+	if err := setupDatabase(cfg); err != nil {
+		return err
+	}
 
-	// Kết nối đến database SQLite
-	DB, err = sql.Open("sqlite", cfg.DBSource)
+	// Mã hóa credentials còn lưu dạng plaintext
+	if err := MigrateSecrets(); err != nil {
+		return err
+	}
+
+	log.Println("Database initialized successfully")
+	return nil
+}
+
+// Open chỉ mở kết nối đến database SQLite, không thay đổi schema
+func Open(cfg *config.Config) error {
+	var err error
+	DB, err = sql.Open("sqlite", sqliteDSN(cfg.DBSource))
 	if err != nil {
 		return fmt.Errorf("error connecting to SQLite database: %w", err)
 	}
@@ -40,31 +66,31 @@ func InitDB(cfg *config.Config) error {
 	if err = DB.Ping(); err != nil {
 		return fmt.Errorf("error pinging SQLite database: %w", err)
 	}
+	return nil
+}
 
-	// Cố gắng tạo schema và các cấu hình
-	err = setupDatabase(cfg)
-	if err != nil {
-		// Nếu gặp lỗi, có thể là do schema cũ không tương thích
-		// Tạo lại database từ đầu
-		log.Printf("Gặp lỗi khi thiết lập database: %v. Đang thử tạo lại database...", err)
-		if recreateErr := recreateDatabase(cfg); recreateErr != nil {
-			return fmt.Errorf("không thể tạo lại database: %w", recreateErr)
-		}
-		// Thiết lập lại database sau khi tạo mới
-		if setupErr := setupDatabase(cfg); setupErr != nil {
-			return fmt.Errorf("không thể thiết lập lại database sau khi tạo mới: %w", setupErr)
-		}
+// sqliteDSN thêm busy_timeout vào đường dẫn database để các backup chạy song song
+// chờ nhau khi ghi thay vì lỗi "database is locked"
+func sqliteDSN(source string) string {
+	if strings.Contains(source, "_pragma=busy_timeout") {
+		return source
 	}
-
-	log.Println("Database initialized successfully")
-	return nil
+	separator := "?"
+	if strings.Contains(source, "?") {
+		separator = "&"
+	}
+	return source + separator + "_pragma=busy_timeout(5000)"
 }
 
 // setupDatabase thiết lập cơ sở dữ liệu
 func setupDatabase(cfg *config.Config) error {
-	// Tạo schema
-	if err := createSchema(); err != nil {
-		return fmt.Errorf("error creating database schema: %w", err)
+	// Nâng cấp schema bằng các migration còn thiếu
+	applied, err := MigrateUp(cfg.DBSource)
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		log.Printf("Đã nâng cấp schema database lên version %d", LatestSchemaVersion())
 	}
 
 	// Kiểm tra và tạo tài khoản admin nếu chưa tồn tại
@@ -85,129 +111,6 @@ func setupDatabase(cfg *config.Config) error {
 	return nil
 }
 
-// recreateDatabase xóa và tạo lại database từ đầu
-func recreateDatabase(cfg *config.Config) error {
-	log.Println("Đang tạo lại database từ đầu...")
-
-	// Đóng kết nối hiện tại
-	if DB != nil {
-		DB.Close()
-	}
-
-	// Xóa file database cũ
-	if err := os.Remove(cfg.DBSource); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("không thể xóa file database cũ: %w", err)
-	}
-
-	// Kết nối lại đến database mới
-	var err error
-	DB, err = sql.Open("sqlite", cfg.DBSource)
-	if err != nil {
-		return fmt.Errorf("error connecting to new SQLite database: %w", err)
-	}
-
-	// Kiểm tra kết nối
-	if err = DB.Ping(); err != nil {
-		return fmt.Errorf("error pinging new SQLite database: %w", err)
-	}
-
-	log.Println("Đã tạo lại database thành công")
-	return nil
-}
-
-// createSchema tạo cấu trúc cơ sở dữ liệu nếu chưa tồn tại
-func createSchema() error {
-	// Tạo bảng users
-	_, err := DB.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT NOT NULL UNIQUE,
-			password TEXT NOT NULL,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Tạo bảng configs
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS configs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			key TEXT NOT NULL UNIQUE,
-			value TEXT NOT NULL,
-			group_name TEXT NOT NULL,
-			label TEXT NOT NULL,
-			type TEXT NOT NULL,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Tạo bảng backups
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS backups (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			filename TEXT NOT NULL,
-			filepath TEXT NOT NULL UNIQUE,
-			filesize INTEGER NOT NULL,
-			created_at DATETIME NOT NULL,
-			uploaded BOOLEAN DEFAULT 0,
-			uploaded_at DATETIME,
-			drive_link TEXT
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Tạo bảng profiles với tất cả các cột cần thiết
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS profiles (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL UNIQUE,
-			description TEXT,
-			db_user TEXT NOT NULL,
-			db_password TEXT NOT NULL,
-			container_name TEXT NOT NULL,
-			db_name TEXT NOT NULL,
-			is_active BOOLEAN DEFAULT 1,
-			google_client_id TEXT DEFAULT '',
-			google_client_secret TEXT DEFAULT '',
-			backup_dir TEXT DEFAULT '',
-			cron_schedule TEXT DEFAULT '0 0 * * *',
-			backup_retention INTEGER DEFAULT 7,
-			upload_to_drive BOOLEAN DEFAULT 0,
-			folder_drive TEXT DEFAULT '',
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Tạo bảng job_logs để lưu trữ lịch sử chạy các job backup
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS job_logs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			profile_id INTEGER NOT NULL,
-			status TEXT NOT NULL,
-			start_time DATETIME NOT NULL,
-			end_time DATETIME,
-			backup_file TEXT,
-			message TEXT,
-			FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
-		)
-	`)
-
-	return err
-}
-
 // ensureAdminExists đảm bảo tài khoản admin tồn tại trong hệ thống
 func ensureAdminExists(cfg *config.Config) error {
 	// Kiểm tra xem admin đã tồn tại chưa
@@ -228,8 +131,8 @@ func ensureAdminExists(cfg *config.Config) error {
 		// Tạo admin
 		now := time.Now()
 		_, err = DB.Exec(
-			"INSERT INTO users (username, password, created_at, updated_at) VALUES (?, ?, ?, ?)",
-			cfg.AdminUsername, hashedPassword, now, now,
+			"INSERT INTO users (username, password, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+			cfg.AdminUsername, hashedPassword, models.RoleAdmin, now, now,
 		)
 		if err != nil {
 			return err
@@ -241,57 +144,55 @@ func ensureAdminExists(cfg *config.Config) error {
 	return nil
 }
 
-// ensureConfigsExist đảm bảo các cấu hình mặc định tồn tại trong database
+// ensureConfigsExist đảm bảo các cấu hình mặc định tồn tại trong database.
+// Các key mới được bổ sung ở phiên bản sau cũng được thêm vào database cũ
 func ensureConfigsExist() error {
-	// Kiểm tra xem đã có cấu hình nào chưa
-	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM configs").Scan(&count)
+	defaultConfigs := models.DefaultConfigs()
+
+	// Bắt đầu transaction
+	tx, err := DB.Begin()
 	if err != nil {
 		return err
 	}
 
-	// Nếu chưa có cấu hình nào, thêm cấu hình mặc định
-	if count == 0 {
-		defaultConfigs := models.DefaultConfigs()
-
-		// Bắt đầu transaction
-		tx, err := DB.Begin()
-		if err != nil {
-			return err
-		}
+	// Chuẩn bị câu lệnh SQL, bỏ qua các key đã tồn tại
+	stmt, err := tx.Prepare(`
+		INSERT OR IGNORE INTO configs (key, value, group_name, label, type, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
 
-		// Chuẩn bị câu lệnh SQL
-		stmt, err := tx.Prepare(`
-			INSERT INTO configs (key, value, group_name, label, type, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`)
+	// Thêm từng cấu hình
+	added := int64(0)
+	for _, cfg := range defaultConfigs {
+		result, err := stmt.Exec(cfg.Key, cfg.Value, cfg.Group, cfg.Label, cfg.Type, cfg.CreatedAt, cfg.UpdatedAt)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
-		defer stmt.Close()
-
-		// Thêm từng cấu hình
-		for _, cfg := range defaultConfigs {
-			_, err = stmt.Exec(cfg.Key, cfg.Value, cfg.Group, cfg.Label, cfg.Type, cfg.CreatedAt, cfg.UpdatedAt)
-			if err != nil {
-				tx.Rollback()
-				return err
-			}
+		if n, err := result.RowsAffected(); err == nil {
+			added += n
 		}
+	}
 
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return err
-		}
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
-		log.Println("Default configurations added successfully")
+	if added > 0 {
+		log.Printf("Đã thêm %d cấu hình mặc định", added)
 	}
 
 	return nil
 }
 
-// GetAllConfigs lấy tất cả cấu hình từ database
+// GetAllConfigs lấy tất cả cấu hình từ database.
+// Cấu hình nhạy cảm được giữ nguyên dạng đã mã hóa, dùng GetConfigValue để đọc giá trị thật
 func GetAllConfigs() ([]models.AppConfig, error) {
 	rows, err := DB.Query(`
 		SELECT id, key, value, group_name, label, type, created_at, updated_at
@@ -312,13 +213,14 @@ func GetAllConfigs() ([]models.AppConfig, error) {
 			return nil, err
 		}
 		cfg.Group = groupName
+		cfg.IsSet = cfg.Value != ""
 		configs = append(configs, cfg)
 	}
 
 	return configs, nil
 }
 
-// GetConfigsByGroup lấy cấu hình theo nhóm
+// GetConfigsByGroup lấy cấu hình theo nhóm, cấu hình nhạy cảm không được giải mã
 func GetConfigsByGroup(groupName string) ([]models.AppConfig, error) {
 	rows, err := DB.Query(`
 		SELECT id, key, value, group_name, label, type, created_at, updated_at
@@ -340,23 +242,31 @@ func GetConfigsByGroup(groupName string) ([]models.AppConfig, error) {
 			return nil, err
 		}
 		cfg.Group = groupName
+		cfg.IsSet = cfg.Value != ""
 		configs = append(configs, cfg)
 	}
 
 	return configs, nil
 }
 
-// GetConfigValue lấy giá trị của một cấu hình
+// GetConfigValue lấy giá trị của một cấu hình, giá trị đã mã hóa được giải mã
 func GetConfigValue(key string) (string, error) {
 	var value string
 	err := DB.QueryRow("SELECT value FROM configs WHERE key = ?", key).Scan(&value)
-	return value, err
+	if err != nil {
+		return value, err
+	}
+	return secrets.Decrypt(value)
 }
 
-// UpdateConfig cập nhật giá trị của một cấu hình
+// UpdateConfig cập nhật giá trị của một cấu hình, cấu hình nhạy cảm được mã hóa trước khi lưu
 func UpdateConfig(key, value string) error {
+	value, err := encryptConfigValue(key, value)
+	if err != nil {
+		return err
+	}
 	now := time.Now()
-	_, err := DB.Exec(
+	_, err = DB.Exec(
 		"UPDATE configs SET value = ?, updated_at = ? WHERE key = ?",
 		value, now, key,
 	)
@@ -368,6 +278,17 @@ func SetConfigValue(key, value string) error {
 	return UpdateConfig(key, value)
 }
 
+// SchedulesPaused cho biết tất cả lịch backup có đang bị tạm dừng (chế độ bảo trì) hay không
+func SchedulesPaused() bool {
+	value, err := GetConfigValue(models.ConfigSchedulesPaused)
+	return err == nil && value == "true"
+}
+
+// SetSchedulesPaused bật/tắt tạm dừng tất cả lịch backup
+func SetSchedulesPaused(paused bool) error {
+	return UpdateConfig(models.ConfigSchedulesPaused, strconv.FormatBool(paused))
+}
+
 // UpdateConfigs cập nhật nhiều cấu hình cùng lúc
 func UpdateConfigs(keyValues map[string]string) error {
 	tx, err := DB.Begin()
@@ -384,6 +305,11 @@ func UpdateConfigs(keyValues map[string]string) error {
 	defer stmt.Close()
 
 	for key, value := range keyValues {
+		value, err = encryptConfigValue(key, value)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
 		_, err = stmt.Exec(value, now, key)
 		if err != nil {
 			tx.Rollback()
@@ -394,21 +320,6 @@ func UpdateConfigs(keyValues map[string]string) error {
 	return tx.Commit()
 }
 
-// GetUserByUsername lấy thông tin người dùng theo username
-func GetUserByUsername(username string) (*models.User, error) {
-	user := &models.User{}
-	err := DB.QueryRow(
-		"SELECT id, username, password, created_at, updated_at FROM users WHERE username = ?",
-		username,
-	).Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.UpdatedAt)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
-}
-
 // Close đóng kết nối đến database
 func Close() {
 	if DB != nil {
@@ -487,7 +398,7 @@ func GetBackupsByFolder() ([]*models.BackupFile, error) {
 			if err != nil {
 				// Nếu không parse được thời gian, dùng thời gian hiện tại
 				backup.CreatedAt = time.Now()
-				fmt.Printf("Không thể parse thời gian '%s' cho file %s: %v\n", createdAt, backup.Name, err)
+				logger.Component("database").Warn("Không thể parse thời gian tạo backup", logger.KeyBackupID, id, "created_at", createdAt, "error", err)
 			} else {
 				backup.CreatedAt = t
 			}
@@ -528,5 +439,10 @@ func DeleteBackup(id int64) error {
 		return fmt.Errorf("lỗi khi xóa bản ghi backup: %w", err)
 	}
 
+	_, err = DB.Exec("DELETE FROM backup_uploads WHERE backup_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("lỗi khi xóa thông tin upload của backup: %w", err)
+	}
+
 	return nil
 }
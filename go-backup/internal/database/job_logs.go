@@ -7,11 +7,48 @@ import (
 	"github.com/backup-cronjob/internal/models"
 )
 
-// CreateJobLog tạo một bản ghi log mới cho việc chạy job
+// Các loại job được ghi vào job_logs
+const (
+	JobTypeBackup  = "backup"
+	JobTypeRestore = "restore"
+	JobTypeDrill   = "drill" // Restore drill kiểm tra backup
+)
+
+// CreateJobLog tạo một bản ghi log mới cho việc chạy job backup
 func CreateJobLog(profileID int64, status string, startTime time.Time) (int64, error) {
+	return CreateJobLogWithType(profileID, JobTypeBackup, status, startTime)
+}
+
+// CreateJobLogWithType tạo một bản ghi log mới cho một loại job cụ thể
+func CreateJobLogWithType(profileID int64, jobType, status string, startTime time.Time) (int64, error) {
+	result, err := DB.Exec(
+		`INSERT INTO job_logs (profile_id, status, start_time, job_type) VALUES (?, ?, ?, ?)`,
+		profileID, status, startTime, jobType,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// Các trạng thái của job trong job_logs
+const (
+	JobStatusQueued    = "queued"  // Đang chờ trong hàng đợi
+	JobStatusRunning   = "running" // Đang chạy
+	JobStatusSuccess   = "success"
+	JobStatusFailed    = "failed"
+	JobStatusSkipped   = "skipped"   // Bị bỏ qua (đã có job của profile đang chờ)
+	JobStatusTimeout   = "timeout"   // Chờ trong hàng đợi hoặc chạy quá thời gian cho phép
+	JobStatusCancelled = "cancelled" // Bị hủy theo yêu cầu
+)
+
+// InsertFinishedJobLog ghi một bản ghi log cho job đã kết thúc ngay (bị bỏ qua, hết thời gian chờ...)
+func InsertFinishedJobLog(profileID int64, jobType, status, message string) (int64, error) {
+	now := time.Now()
 	result, err := DB.Exec(
-		`INSERT INTO job_logs (profile_id, status, start_time) VALUES (?, ?, ?)`,
-		profileID, status, startTime,
+		`INSERT INTO job_logs (profile_id, status, start_time, end_time, message, job_type) VALUES (?, ?, ?, ?, ?, ?)`,
+		profileID, status, now, now, message, jobType,
 	)
 	if err != nil {
 		return 0, err
@@ -20,6 +57,29 @@ func CreateJobLog(profileID int64, status string, startTime time.Time) (int64, e
 	return result.LastInsertId()
 }
 
+// StartJobLog chuyển bản ghi log của job đang chờ sang trạng thái đang chạy
+func StartJobLog(logID int64, startTime time.Time) error {
+	_, err := DB.Exec(
+		`UPDATE job_logs SET status = ?, start_time = ? WHERE id = ?`,
+		JobStatusRunning, startTime, logID,
+	)
+	return err
+}
+
+// AbortUnfinishedJobLogs đánh dấu thất bại các job còn chờ hoặc đang chạy từ lần chạy trước của ứng dụng
+func AbortUnfinishedJobLogs(jobType, message string) (int64, error) {
+	result, err := DB.Exec(
+		`UPDATE job_logs SET status = ?, end_time = ?, message = ?
+		WHERE status IN (?, ?) AND COALESCE(job_type, 'backup') = ?`,
+		JobStatusFailed, time.Now(), message, JobStatusQueued, JobStatusRunning, jobType,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 // UpdateJobLog cập nhật trạng thái của một bản ghi log
 func UpdateJobLog(logID int64, status string, endTime time.Time, backupFile, message string) error {
 	_, err := DB.Exec(
@@ -32,7 +92,7 @@ func UpdateJobLog(logID int64, status string, endTime time.Time, backupFile, mes
 // GetJobLogsByProfile lấy lịch sử các lần chạy job của một profile
 func GetJobLogsByProfile(profileID int64, limit int) ([]models.JobLog, error) {
 	rows, err := DB.Query(
-		`SELECT id, profile_id, status, start_time, end_time, backup_file, message 
+		`SELECT id, profile_id, status, start_time, end_time, backup_file, message, COALESCE(job_type, 'backup')
 		FROM job_logs 
 		WHERE profile_id = ? 
 		ORDER BY start_time DESC 
@@ -50,7 +110,7 @@ func GetJobLogsByProfile(profileID int64, limit int) ([]models.JobLog, error) {
 		var endTime sql.NullTime
 		var backupFile, message sql.NullString
 
-		err := rows.Scan(&log.ID, &log.ProfileID, &log.Status, &log.StartTime, &endTime, &backupFile, &message)
+		err := rows.Scan(&log.ID, &log.ProfileID, &log.Status, &log.StartTime, &endTime, &backupFile, &message, &log.JobType)
 		if err != nil {
 			return nil, err
 		}
@@ -74,7 +134,8 @@ func GetJobLogsByProfile(profileID int64, limit int) ([]models.JobLog, error) {
 // GetRecentJobLogs lấy các bản ghi log gần đây nhất
 func GetRecentJobLogs(limit int) ([]models.JobLog, error) {
 	rows, err := DB.Query(
-		`SELECT jl.id, jl.profile_id, jl.status, jl.start_time, jl.end_time, jl.backup_file, jl.message
+		`SELECT jl.id, jl.profile_id, jl.status, jl.start_time, jl.end_time, jl.backup_file, jl.message,
+			COALESCE(jl.job_type, 'backup')
 		FROM job_logs jl
 		JOIN profiles p ON jl.profile_id = p.id
 		ORDER BY jl.start_time DESC 
@@ -94,7 +155,7 @@ func GetRecentJobLogs(limit int) ([]models.JobLog, error) {
 
 		err := rows.Scan(
 			&log.ID, &log.ProfileID, &log.Status, &log.StartTime, &endTime,
-			&backupFile, &message,
+			&backupFile, &message, &log.JobType,
 		)
 		if err != nil {
 			return nil, err
@@ -116,12 +177,78 @@ func GetRecentJobLogs(limit int) ([]models.JobLog, error) {
 	return logs, nil
 }
 
+// jobLogColumns là danh sách cột dùng cho các truy vấn một bản ghi job_logs
+const jobLogColumns = `id, profile_id, status, start_time, end_time, backup_file, message, COALESCE(job_type, 'backup')`
+
+// scanJobLog đọc một dòng của bảng job_logs thành JobLog
+func scanJobLog(row rowScanner) (models.JobLog, error) {
+	var log models.JobLog
+	var endTime sql.NullTime
+	var backupFile, message sql.NullString
+
+	err := row.Scan(&log.ID, &log.ProfileID, &log.Status, &log.StartTime, &endTime, &backupFile, &message, &log.JobType)
+	if endTime.Valid {
+		log.EndTime = endTime.Time
+	}
+	log.BackupFile = backupFile.String
+	log.Message = message.String
+	return log, err
+}
+
+// GetJobLog lấy một bản ghi log theo ID
+func GetJobLog(id int64) (models.JobLog, error) {
+	return scanJobLog(DB.QueryRow(`SELECT `+jobLogColumns+` FROM job_logs WHERE id = ?`, id))
+}
+
+// PreviousBackupStatus trả về trạng thái của lần backup đã kết thúc (success, failed, timeout)
+// gần nhất trước job beforeID của profile, rỗng nếu chưa có
+func PreviousBackupStatus(profileID, beforeID int64) (string, error) {
+	var status string
+	err := DB.QueryRow(
+		`SELECT status FROM job_logs
+		WHERE profile_id = ? AND id < ? AND COALESCE(job_type, 'backup') = 'backup'
+			AND status IN (?, ?, ?)
+		ORDER BY id DESC
+		LIMIT 1`,
+		profileID, beforeID, JobStatusSuccess, JobStatusFailed, JobStatusTimeout,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return status, err
+}
+
+// GetBackupJobLogsSince lấy các lần backup của profile bắt đầu từ thời điểm since (mới nhất trước)
+func GetBackupJobLogsSince(profileID int64, since time.Time) ([]models.JobLog, error) {
+	rows, err := DB.Query(
+		`SELECT `+jobLogColumns+`
+		FROM job_logs
+		WHERE profile_id = ? AND COALESCE(job_type, 'backup') = 'backup' AND start_time >= ?
+		ORDER BY start_time DESC`,
+		profileID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []models.JobLog{}
+	for rows.Next() {
+		log, err := scanJobLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
+}
+
 // CountJobRunsByProfile đếm số lần chạy job theo từng trạng thái cho profile cụ thể
 func CountJobRunsByProfile(profileID int64) (map[string]int, error) {
 	rows, err := DB.Query(
 		`SELECT status, COUNT(*) as count
 		FROM job_logs
-		WHERE profile_id = ?
+		WHERE profile_id = ? AND COALESCE(job_type, 'backup') = 'backup'
 		GROUP BY status`,
 		profileID,
 	)
@@ -158,3 +285,20 @@ func DeleteOldJobLogs(olderThan time.Time) (int64, error) {
 
 	return result.RowsAffected()
 }
+
+// GetLastBackupJobLog trả về lần backup gần nhất của profile có trạng thái status,
+// found = false nếu chưa có
+func GetLastBackupJobLog(profileID int64, status string) (jobLog models.JobLog, found bool, err error) {
+	jobLog, err = scanJobLog(DB.QueryRow(
+		`SELECT `+jobLogColumns+`
+		FROM job_logs
+		WHERE profile_id = ? AND COALESCE(job_type, 'backup') = 'backup' AND status = ?
+		ORDER BY id DESC
+		LIMIT 1`,
+		profileID, status,
+	))
+	if err == sql.ErrNoRows {
+		return jobLog, false, nil
+	}
+	return jobLog, err == nil, err
+}
@@ -0,0 +1,676 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+)
+
+// ErrSchemaTooNew là lỗi khi database được tạo bởi phiên bản ứng dụng mới hơn
+var ErrSchemaTooNew = errors.New("schema database mới hơn phiên bản ứng dụng")
+
+// migration là một bước nâng cấp schema, được áp dụng đúng một lần theo thứ tự version.
+// Migration chỉ được thêm mới, không sửa hay xóa migration đã phát hành và không xóa dữ liệu người dùng
+type migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// migrations là danh sách migration theo thứ tự version tăng dần.
+// Các migration 1-4 tương ứng schema có trước khi có bảng schema_migrations nên phải chạy lại được
+// trên database cũ (CREATE TABLE IF NOT EXISTS, addColumnIfNotExists)
+var migrations = []migration{
+	{1, "core tables", createCoreTables},
+	{2, "backup_uploads", createBackupUploadsTable},
+	{3, "api_tokens", createAPITokensTable},
+	{4, "audit_events", createAuditEventsTable},
+	{5, "backup profile reference", addBackupProfileReference},
+}
+
+// MigrationStatus là trạng thái của một migration trong database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// LatestSchemaVersion trả về version migration mới nhất mà ứng dụng biết
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ensureMigrationsTable tạo bảng schema_migrations nếu chưa tồn tại
+func ensureMigrationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`)
+	return err
+}
+
+// migrationsTableExists cho biết database đã có bảng schema_migrations hay chưa
+func migrationsTableExists() (bool, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&count)
+	return count > 0, err
+}
+
+// SchemaVersion trả về version migration mới nhất đã áp dụng, 0 nếu chưa có migration nào
+func SchemaVersion() (int, error) {
+	if exists, err := migrationsTableExists(); err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err := DB.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// MigrationStatuses trả về trạng thái của các migration ứng dụng biết,
+// kèm các migration đã áp dụng bởi phiên bản ứng dụng mới hơn
+func MigrationStatuses() ([]MigrationStatus, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status, ok := applied[m.Version]
+		if !ok {
+			status = MigrationStatus{Version: m.Version, Name: m.Name}
+		}
+		statuses = append(statuses, status)
+		delete(applied, m.Version)
+	}
+
+	unknown := make([]int, 0, len(applied))
+	for version := range applied {
+		unknown = append(unknown, version)
+	}
+	sort.Ints(unknown)
+	for _, version := range unknown {
+		statuses = append(statuses, applied[version])
+	}
+	return statuses, nil
+}
+
+// appliedMigrations đọc các migration đã ghi nhận trong schema_migrations theo version
+func appliedMigrations() (map[int]MigrationStatus, error) {
+	applied := make(map[int]MigrationStatus)
+	if exists, err := migrationsTableExists(); err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := DB.Query("SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&status.Version, &status.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		status.AppliedAt = &appliedAt
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
+
+// checkSchemaVersion từ chối chạy khi database đã được nâng cấp bởi phiên bản ứng dụng mới hơn
+func checkSchemaVersion() (int, error) {
+	current, err := SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if current > LatestSchemaVersion() {
+		return current, fmt.Errorf("%w: database ở version %d, ứng dụng chỉ hỗ trợ đến version %d, hãy cập nhật ứng dụng",
+			ErrSchemaTooNew, current, LatestSchemaVersion())
+	}
+	return current, nil
+}
+
+// MigrateUp áp dụng các migration còn thiếu theo thứ tự, mỗi migration trong một transaction.
+// Trước khi nâng cấp database đã có dữ liệu, một bản sao được tạo cạnh file database (source)
+func MigrateUp(source string) (int, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+	current, err := checkSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	if err := snapshotBeforeMigrate(source, current); err != nil {
+		return 0, fmt.Errorf("không thể sao lưu database trước khi migrate: %w", err)
+	}
+
+	for _, m := range pending {
+		if err := applyMigration(m); err != nil {
+			return 0, fmt.Errorf("migration %d (%s) thất bại: %w", m.Version, m.Name, err)
+		}
+		log.Printf("Đã áp dụng migration %d: %s", m.Version, m.Name)
+	}
+	return len(pending), nil
+}
+
+// applyMigration chạy một migration và ghi nhận vào schema_migrations trong cùng transaction
+func applyMigration(m migration) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// snapshotBeforeMigrate sao lưu database đã có dữ liệu bằng VACUUM INTO trước khi nâng cấp schema.
+// Database mới tạo hoặc database trong bộ nhớ không cần sao lưu
+func snapshotBeforeMigrate(source string, current int) error {
+	path := strings.TrimPrefix(strings.SplitN(source, "?", 2)[0], "file:")
+	if path == "" || strings.Contains(path, ":memory:") {
+		return nil
+	}
+
+	var tables int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'configs'").Scan(&tables)
+	if err != nil || tables == 0 {
+		return err
+	}
+
+	snapshot := fmt.Sprintf("%s.v%d-%s.bak", path, current, time.Now().Format("20060102-150405"))
+	if _, err := DB.Exec("VACUUM INTO ?", snapshot); err != nil {
+		return err
+	}
+	log.Printf("Đã sao lưu database trước khi migrate vào %s", snapshot)
+	return nil
+}
+
+// createCoreTables tạo các bảng users, configs, backups, profiles, job_logs và bổ sung các cột được thêm trước khi có migration
+func createCoreTables(tx *sql.Tx) error {
+	// Tạo bảng users
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL UNIQUE,
+			password TEXT NOT NULL,
+			role TEXT DEFAULT '',
+			disabled BOOLEAN DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Tạo bảng configs
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS configs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key TEXT NOT NULL UNIQUE,
+			value TEXT NOT NULL,
+			group_name TEXT NOT NULL,
+			label TEXT NOT NULL,
+			type TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Tạo bảng backups
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS backups (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			filename TEXT NOT NULL,
+			filepath TEXT NOT NULL UNIQUE,
+			filesize INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			uploaded BOOLEAN DEFAULT 0,
+			uploaded_at DATETIME,
+			drive_link TEXT,
+			dump_format TEXT DEFAULT 'data-only',
+			compression TEXT DEFAULT 'none',
+			original_size INTEGER DEFAULT 0,
+			encryption TEXT DEFAULT 'none',
+			checksum TEXT DEFAULT '',
+			drill_status TEXT DEFAULT '',
+			drilled_at DATETIME,
+			drill_duration_ms INTEGER DEFAULT 0,
+			drill_message TEXT DEFAULT ''
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Tạo bảng profiles với tất cả các cột cần thiết
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS profiles (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			description TEXT,
+			db_user TEXT NOT NULL,
+			db_password TEXT NOT NULL,
+			container_name TEXT NOT NULL,
+			db_name TEXT NOT NULL,
+			is_active BOOLEAN DEFAULT 1,
+			google_client_id TEXT DEFAULT '',
+			google_client_secret TEXT DEFAULT '',
+			backup_dir TEXT DEFAULT '',
+			cron_schedule TEXT DEFAULT '0 0 * * *',
+			backup_retention INTEGER DEFAULT 7,
+			upload_to_drive BOOLEAN DEFAULT 0,
+			folder_drive TEXT DEFAULT '',
+			connection_mode TEXT DEFAULT 'docker',
+			db_host TEXT DEFAULT '',
+			db_port INTEGER DEFAULT 5432,
+			ssl_mode TEXT DEFAULT '',
+			ssl_root_cert TEXT DEFAULT '',
+			retention_keep_last INTEGER DEFAULT 0,
+			retention_keep_daily INTEGER DEFAULT 0,
+			retention_keep_weekly INTEGER DEFAULT 0,
+			retention_keep_monthly INTEGER DEFAULT 0,
+			dump_format TEXT DEFAULT 'data-only',
+			compression TEXT DEFAULT 'none',
+			compression_level INTEGER DEFAULT 0,
+			encryption TEXT DEFAULT 'none',
+			encryption_recipients TEXT DEFAULT '',
+			encryption_passphrase TEXT DEFAULT '',
+			destinations TEXT DEFAULT '',
+			sftp_host TEXT DEFAULT '',
+			sftp_port INTEGER DEFAULT 22,
+			sftp_user TEXT DEFAULT '',
+			sftp_password TEXT DEFAULT '',
+			sftp_private_key TEXT DEFAULT '',
+			sftp_host_key TEXT DEFAULT '',
+			sftp_remote_dir TEXT DEFAULT '',
+			schedule_paused BOOLEAN DEFAULT 0,
+			paused_until DATETIME,
+			job_timeout_minutes INTEGER DEFAULT 0,
+			drill_after_backup BOOLEAN DEFAULT 0,
+			drill_schedule TEXT DEFAULT '',
+			drill_sql TEXT DEFAULT '',
+			drill_image TEXT DEFAULT '',
+			notify_on_failure BOOLEAN DEFAULT 1,
+			notify_on_success BOOLEAN DEFAULT 0,
+			notify_on_recovery BOOLEAN DEFAULT 0,
+			notify_digest BOOLEAN DEFAULT 0,
+			notify_channels TEXT DEFAULT '',
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Tạo bảng job_logs để lưu trữ lịch sử chạy các job backup
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS job_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			profile_id INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME,
+			backup_file TEXT,
+			message TEXT,
+			job_type TEXT DEFAULT 'backup',
+			FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Bổ sung các cột mới cho database được tạo từ phiên bản cũ
+	newColumns := []struct {
+		table, column, definition string
+	}{
+		{"job_logs", "job_type", "TEXT DEFAULT 'backup'"},
+		{"profiles", "connection_mode", "TEXT DEFAULT 'docker'"},
+		{"profiles", "db_host", "TEXT DEFAULT ''"},
+		{"profiles", "db_port", "INTEGER DEFAULT 5432"},
+		{"profiles", "ssl_mode", "TEXT DEFAULT ''"},
+		{"profiles", "ssl_root_cert", "TEXT DEFAULT ''"},
+		{"profiles", "retention_keep_last", "INTEGER DEFAULT 0"},
+		{"profiles", "retention_keep_daily", "INTEGER DEFAULT 0"},
+		{"profiles", "retention_keep_weekly", "INTEGER DEFAULT 0"},
+		{"profiles", "retention_keep_monthly", "INTEGER DEFAULT 0"},
+		{"profiles", "dump_format", "TEXT DEFAULT 'data-only'"},
+		{"backups", "dump_format", "TEXT DEFAULT 'data-only'"},
+		{"profiles", "compression", "TEXT DEFAULT 'none'"},
+		{"profiles", "compression_level", "INTEGER DEFAULT 0"},
+		{"backups", "compression", "TEXT DEFAULT 'none'"},
+		{"backups", "original_size", "INTEGER DEFAULT 0"},
+		{"profiles", "encryption", "TEXT DEFAULT 'none'"},
+		{"profiles", "encryption_recipients", "TEXT DEFAULT ''"},
+		{"profiles", "encryption_passphrase", "TEXT DEFAULT ''"},
+		{"backups", "encryption", "TEXT DEFAULT 'none'"},
+		{"profiles", "destinations", "TEXT DEFAULT ''"},
+		{"profiles", "sftp_host", "TEXT DEFAULT ''"},
+		{"profiles", "sftp_port", "INTEGER DEFAULT 22"},
+		{"profiles", "sftp_user", "TEXT DEFAULT ''"},
+		{"profiles", "sftp_password", "TEXT DEFAULT ''"},
+		{"profiles", "sftp_private_key", "TEXT DEFAULT ''"},
+		{"profiles", "sftp_host_key", "TEXT DEFAULT ''"},
+		{"profiles", "sftp_remote_dir", "TEXT DEFAULT ''"},
+		{"profiles", "schedule_paused", "BOOLEAN DEFAULT 0"},
+		{"profiles", "paused_until", "DATETIME"},
+		{"profiles", "job_timeout_minutes", "INTEGER DEFAULT 0"},
+		{"profiles", "drill_after_backup", "BOOLEAN DEFAULT 0"},
+		{"profiles", "drill_schedule", "TEXT DEFAULT ''"},
+		{"profiles", "drill_sql", "TEXT DEFAULT ''"},
+		{"profiles", "drill_image", "TEXT DEFAULT ''"},
+		{"backups", "checksum", "TEXT DEFAULT ''"},
+		{"backups", "drill_status", "TEXT DEFAULT ''"},
+		{"backups", "drilled_at", "DATETIME"},
+		{"backups", "drill_duration_ms", "INTEGER DEFAULT 0"},
+		{"backups", "drill_message", "TEXT DEFAULT ''"},
+		{"profiles", "notify_on_failure", "BOOLEAN DEFAULT 1"},
+		{"profiles", "notify_on_success", "BOOLEAN DEFAULT 0"},
+		{"profiles", "notify_on_recovery", "BOOLEAN DEFAULT 0"},
+		{"profiles", "notify_digest", "BOOLEAN DEFAULT 0"},
+		{"profiles", "notify_channels", "TEXT DEFAULT ''"},
+		{"users", "role", "TEXT DEFAULT ''"},
+		{"users", "disabled", "BOOLEAN DEFAULT 0"},
+	}
+	for _, col := range newColumns {
+		if err := addColumnIfNotExists(tx, col.table, col.column, col.definition); err != nil {
+			return err
+		}
+	}
+
+	// Trước khi có phân quyền, mọi người dùng đều có toàn quyền
+	if _, err := tx.Exec(`UPDATE users SET role = ? WHERE role IS NULL OR role = ''`, models.RoleAdmin); err != nil {
+		return err
+	}
+
+	// Chuyển cờ upload_to_drive cũ sang danh sách destinations
+	_, err = tx.Exec(`UPDATE profiles SET destinations = 'drive'
+		WHERE upload_to_drive = 1 AND (destinations IS NULL OR destinations = '')`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// createBackupUploadsTable tạo bảng backup_uploads lưu các bản sao của backup trên từng destination
+func createBackupUploadsTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS backup_uploads (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			backup_id INTEGER NOT NULL,
+			profile_id INTEGER DEFAULT 0,
+			destination TEXT NOT NULL,
+			object_key TEXT NOT NULL,
+			location TEXT DEFAULT '',
+			size INTEGER DEFAULT 0,
+			checksum TEXT DEFAULT '',
+			uploaded_at DATETIME NOT NULL,
+			UNIQUE(backup_id, destination)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(tx, "backup_uploads", "checksum", "TEXT DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfNotExists(tx, "backup_uploads", "profile_id", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// createAPITokensTable tạo bảng api_tokens lưu hash của các API token cá nhân
+func createAPITokensTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT NOT NULL DEFAULT '',
+			profile_ids TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			last_used_at DATETIME,
+			expires_at DATETIME,
+			revoked_at DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// createAuditEventsTable tạo bảng audit_events, trigger chặn sửa/xóa để audit log chỉ được thêm mới
+func createAuditEventsTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at DATETIME NOT NULL,
+			actor TEXT NOT NULL,
+			actor_type TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target_type TEXT NOT NULL DEFAULT '',
+			target_id TEXT NOT NULL DEFAULT '',
+			changes TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			result TEXT NOT NULL,
+			message TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+		CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events(action);
+		CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_events chỉ được thêm mới');
+		END;
+		CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_events chỉ được thêm mới');
+		END;
+	`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// backupFileName khớp tên file backup <db_name>_YYYYMMDD_HHMMSS..., backupTimestamp khớp phần sau <db_name>_
+var (
+	backupFileName  = regexp.MustCompile(`^(.+)_\d{8}_\d{6}`)
+	backupTimestamp = regexp.MustCompile(`^\d{8}_\d{6}`)
+)
+
+// addBackupProfileReference thêm profile, database nguồn và job_log vào bảng backups.
+// Backup cũ được điền từ job backup trong job_logs (theo đường dẫn file, bỏ qua job restore/drill
+// cũng ghi đường dẫn này) và từ tên file <db_name>_<timestamp>;
+// profile chỉ được gán khi đúng một profile có db_name khớp
+func addBackupProfileReference(tx *sql.Tx) error {
+	columns := []struct{ column, definition string }{
+		{"profile_id", "INTEGER DEFAULT 0"},
+		{"db_name", "TEXT DEFAULT ''"},
+		{"job_log_id", "INTEGER DEFAULT 0"},
+	}
+	for _, col := range columns {
+		if err := addColumnIfNotExists(tx, "backups", col.column, col.definition); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(`
+		UPDATE backups SET
+			job_log_id = (SELECT id FROM job_logs WHERE backup_file = backups.filepath
+				AND COALESCE(job_type, 'backup') = 'backup' ORDER BY id DESC LIMIT 1),
+			profile_id = (SELECT profile_id FROM job_logs WHERE backup_file = backups.filepath
+				AND COALESCE(job_type, 'backup') = 'backup' ORDER BY id DESC LIMIT 1)
+		WHERE COALESCE(job_log_id, 0) = 0
+			AND EXISTS (SELECT 1 FROM job_logs WHERE backup_file = backups.filepath
+				AND COALESCE(job_type, 'backup') = 'backup')
+	`)
+	if err != nil {
+		return fmt.Errorf("không thể điền job_log cho backup cũ: %w", err)
+	}
+
+	// Danh sách profile theo db_name để nhận diện backup qua tên file
+	profileNames := map[int64]string{}
+	profilesByDB := map[string][]int64{}
+	rows, err := tx.Query("SELECT id, db_name FROM profiles")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int64
+		var dbName string
+		if err := rows.Scan(&id, &dbName); err != nil {
+			rows.Close()
+			return err
+		}
+		profileNames[id] = dbName
+		profilesByDB[dbName] = append(profilesByDB[dbName], id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	type backupRef struct {
+		id        int64
+		filename  string
+		profileID int64
+	}
+	var pending []backupRef
+	rows, err = tx.Query("SELECT id, filename, COALESCE(profile_id, 0) FROM backups WHERE COALESCE(db_name, '') = ''")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var ref backupRef
+		if err := rows.Scan(&ref.id, &ref.filename, &ref.profileID); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, ref)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, ref := range pending {
+		dbName := backupDBName(ref.filename, profileNames[ref.profileID], profilesByDB)
+		if dbName == "" {
+			continue
+		}
+		profileID := ref.profileID
+		if profileID == 0 && len(profilesByDB[dbName]) == 1 {
+			profileID = profilesByDB[dbName][0]
+		}
+		if _, err := tx.Exec("UPDATE backups SET db_name = ?, profile_id = ? WHERE id = ?", dbName, profileID, ref.id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_backups_profile_id ON backups(profile_id, created_at)")
+	return err
+}
+
+// backupDBName lấy tên database nguồn từ tên file backup. Ưu tiên db_name của profile đã biết,
+// sau đó db_name dài nhất của các profile khớp tiền tố, cuối cùng là phần trước timestamp
+func backupDBName(filename, knownDB string, profilesByDB map[string][]int64) string {
+	matches := func(dbName string) bool {
+		return dbName != "" && strings.HasPrefix(filename, dbName+"_") &&
+			backupTimestamp.MatchString(strings.TrimPrefix(filename, dbName+"_"))
+	}
+	if matches(knownDB) {
+		return knownDB
+	}
+	best := ""
+	for dbName := range profilesByDB {
+		if matches(dbName) && len(dbName) > len(best) {
+			best = dbName
+		}
+	}
+	if best != "" {
+		return best
+	}
+	if match := backupFileName.FindStringSubmatch(filename); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// addColumnIfNotExists thêm cột vào bảng nếu cột đó chưa tồn tại
+func addColumnIfNotExists(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+
+	exists := false
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	rows.Close()
+
+	if exists {
+		return nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("không thể thêm cột %s.%s: %w", table, column, err)
+	}
+
+	log.Printf("Đã thêm cột %s vào bảng %s", column, table)
+	return nil
+}
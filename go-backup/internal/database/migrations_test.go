@@ -0,0 +1,323 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// openTestDB mở database SQLite tạm và gán vào DB, trả về đường dẫn file database
+func openTestDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		db.Close()
+		DB = previous
+	})
+	return path
+}
+
+// runMigration áp dụng một migration trong transaction
+func runMigration(t *testing.T, up func(tx *sql.Tx) error) {
+	t.Helper()
+	tx, err := DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := up(tx); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupProfileReferenceIgnoresRestoreJobs(t *testing.T) {
+	openTestDB(t)
+	for _, m := range migrations {
+		if m.Version < 5 {
+			runMigration(t, m.Up)
+		}
+	}
+
+	now := time.Now()
+	path := "/backups/shop_20240101_000000.sql"
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO profiles (id, name, db_user, db_password, container_name, db_name, created_at, updated_at)
+			VALUES (1, 'source', 'postgres', '', 'pg', 'shop', ?, ?)`, []interface{}{now, now}},
+		{`INSERT INTO profiles (id, name, db_user, db_password, container_name, db_name, created_at, updated_at)
+			VALUES (2, 'staging', 'postgres', '', 'pg', 'shop_staging', ?, ?)`, []interface{}{now, now}},
+		{`INSERT INTO backups (id, filename, filepath, filesize, created_at) VALUES (1, 'shop_20240101_000000.sql', ?, 10, ?)`,
+			[]interface{}{path, now}},
+		{`INSERT INTO job_logs (id, profile_id, status, start_time, backup_file, job_type) VALUES (1, 1, 'success', ?, ?, 'backup')`,
+			[]interface{}{now, path}},
+		// Job restore và drill sau đó cũng ghi đường dẫn backup, profile đích khác profile nguồn
+		{`INSERT INTO job_logs (id, profile_id, status, start_time, backup_file, job_type) VALUES (2, 2, 'success', ?, ?, 'restore')`,
+			[]interface{}{now, path}},
+		{`INSERT INTO job_logs (id, profile_id, status, start_time, backup_file, job_type) VALUES (3, 2, 'success', ?, ?, 'drill')`,
+			[]interface{}{now, path}},
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runMigration(t, addBackupProfileReference)
+
+	var jobLogID, profileID int64
+	var dbName string
+	err := DB.QueryRow("SELECT job_log_id, profile_id, db_name FROM backups WHERE id = 1").Scan(&jobLogID, &profileID, &dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobLogID != 1 || profileID != 1 {
+		t.Errorf("backup gắn với job_log %d, profile %d; muốn job_log 1, profile 1", jobLogID, profileID)
+	}
+	if dbName != "shop" {
+		t.Errorf("db_name = %q, muốn %q", dbName, "shop")
+	}
+}
+
+// legacySchema là schema của phiên bản trước khi có bảng schema_migrations
+var legacySchema = []string{
+	`CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE configs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key TEXT NOT NULL UNIQUE,
+		value TEXT NOT NULL,
+		group_name TEXT NOT NULL,
+		label TEXT NOT NULL,
+		type TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE backups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		filename TEXT NOT NULL,
+		filepath TEXT NOT NULL UNIQUE,
+		filesize INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		uploaded BOOLEAN DEFAULT 0,
+		uploaded_at DATETIME,
+		drive_link TEXT
+	)`,
+	`CREATE TABLE profiles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT,
+		db_user TEXT NOT NULL,
+		db_password TEXT NOT NULL,
+		container_name TEXT NOT NULL,
+		db_name TEXT NOT NULL,
+		is_active BOOLEAN DEFAULT 1,
+		google_client_id TEXT DEFAULT '',
+		google_client_secret TEXT DEFAULT '',
+		backup_dir TEXT DEFAULT '',
+		cron_schedule TEXT DEFAULT '0 0 * * *',
+		backup_retention INTEGER DEFAULT 7,
+		upload_to_drive BOOLEAN DEFAULT 0,
+		folder_drive TEXT DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE job_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		profile_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		start_time DATETIME NOT NULL,
+		end_time DATETIME,
+		backup_file TEXT,
+		message TEXT
+	)`,
+}
+
+// createLegacyDB tạo schema cũ với một người dùng, một profile upload lên Drive và một backup
+func createLegacyDB(t *testing.T) {
+	t.Helper()
+	now := time.Now()
+	path := "/backups/shop_20240101_000000.sql"
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO users (username, password, created_at, updated_at) VALUES ('admin', 'hash', ?, ?)`, []interface{}{now, now}},
+		{`INSERT INTO configs (key, value, group_name, label, type, created_at, updated_at)
+			VALUES ('BACKUP_DIR', './backups', 'backup', 'Thư mục backup', 'text', ?, ?)`, []interface{}{now, now}},
+		{`INSERT INTO profiles (id, name, db_user, db_password, container_name, db_name, upload_to_drive, created_at, updated_at)
+			VALUES (1, 'shop', 'postgres', '', 'pg', 'shop', 1, ?, ?)`, []interface{}{now, now}},
+		{`INSERT INTO backups (id, filename, filepath, filesize, created_at) VALUES (1, 'shop_20240101_000000.sql', ?, 10, ?)`,
+			[]interface{}{path, now}},
+		{`INSERT INTO job_logs (id, profile_id, status, start_time, backup_file) VALUES (1, 1, 'success', ?, ?)`,
+			[]interface{}{now, path}},
+	}
+	for _, query := range legacySchema {
+		if _, err := DB.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, stmt := range statements {
+		if _, err := DB.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// snapshots trả về các bản sao được tạo trước khi migrate database path
+func snapshots(t *testing.T, path string) []string {
+	t.Helper()
+	files, err := filepath.Glob(path + ".v*.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// hasColumn kiểm tra bảng của database db có cột column hay không
+func hasColumn(t *testing.T, db *sql.DB, table, column string) bool {
+	t.Helper()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func TestMigrateUpFreshDatabase(t *testing.T) {
+	path := openTestDB(t)
+
+	applied, err := MigrateUp(path)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("số migration đã áp dụng = %d, muốn %d", applied, len(migrations))
+	}
+	if version, err := SchemaVersion(); err != nil || version != LatestSchemaVersion() {
+		t.Errorf("SchemaVersion = %d, %v; muốn %d", version, err, LatestSchemaVersion())
+	}
+	// Database mới không cần sao lưu
+	if files := snapshots(t, path); len(files) != 0 {
+		t.Errorf("database mới tạo có bản sao %v", files)
+	}
+
+	// Chạy lại không áp dụng gì thêm
+	if applied, err := MigrateUp(path); err != nil || applied != 0 {
+		t.Errorf("MigrateUp lần hai = %d, %v; muốn 0", applied, err)
+	}
+}
+
+func TestMigrateUpLegacyDatabase(t *testing.T) {
+	path := openTestDB(t)
+	createLegacyDB(t)
+
+	applied, err := MigrateUp(path)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("số migration đã áp dụng = %d, muốn %d", applied, len(migrations))
+	}
+
+	// Dữ liệu cũ được giữ và chuyển sang schema mới
+	var role, destinations string
+	if err := DB.QueryRow("SELECT role FROM users WHERE username = 'admin'").Scan(&role); err != nil {
+		t.Fatal(err)
+	}
+	if role != models.RoleAdmin {
+		t.Errorf("vai trò của người dùng cũ = %q, muốn %q", role, models.RoleAdmin)
+	}
+	if err := DB.QueryRow("SELECT destinations FROM profiles WHERE id = 1").Scan(&destinations); err != nil {
+		t.Fatal(err)
+	}
+	if destinations != models.DestinationDrive {
+		t.Errorf("destinations của profile upload_to_drive = %q, muốn %q", destinations, models.DestinationDrive)
+	}
+	var profileID int64
+	if err := DB.QueryRow("SELECT profile_id FROM backups WHERE id = 1").Scan(&profileID); err != nil {
+		t.Fatal(err)
+	}
+	if profileID != 1 {
+		t.Errorf("backup cũ gắn với profile %d, muốn 1", profileID)
+	}
+
+	// Bản sao VACUUM INTO giữ nguyên schema và dữ liệu trước khi migrate
+	files := snapshots(t, path)
+	if len(files) != 1 {
+		t.Fatalf("bản sao trước khi migrate = %v, muốn một file", files)
+	}
+	snapshot, err := sql.Open("sqlite", sqliteDSN(files[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+	var backups int
+	if err := snapshot.QueryRow("SELECT COUNT(*) FROM backups").Scan(&backups); err != nil {
+		t.Fatal(err)
+	}
+	if backups != 1 {
+		t.Errorf("bản sao có %d backup, muốn 1", backups)
+	}
+	if hasColumn(t, snapshot, "backups", "profile_id") || hasColumn(t, snapshot, "users", "role") {
+		t.Error("bản sao có schema sau khi migrate, muốn schema cũ")
+	}
+
+	// Đã ở version mới nhất: không sao lưu lại
+	if applied, err := MigrateUp(path); err != nil || applied != 0 {
+		t.Errorf("MigrateUp lần hai = %d, %v; muốn 0", applied, err)
+	}
+	if files := snapshots(t, path); len(files) != 1 {
+		t.Errorf("bản sao sau lần migrate thứ hai = %v, muốn một file", files)
+	}
+}
+
+func TestMigrateUpRefusesNewerSchema(t *testing.T) {
+	path := openTestDB(t)
+	if _, err := MigrateUp(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Database đã được nâng cấp bởi phiên bản ứng dụng mới hơn
+	newer := LatestSchemaVersion() + 1
+	if _, err := DB.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', ?)", newer, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(path); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("MigrateUp: err = %v, muốn ErrSchemaTooNew", err)
+	}
+	if files := snapshots(t, path); len(files) != 0 {
+		t.Errorf("không được sao lưu khi từ chối migrate, có %v", files)
+	}
+
+	statuses, err := MigrationStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := statuses[len(statuses)-1]
+	if len(statuses) != len(migrations)+1 || last.Version != newer || last.AppliedAt == nil {
+		t.Errorf("MigrationStatuses không có migration %d của phiên bản mới hơn: %+v", newer, statuses)
+	}
+}
@@ -9,32 +9,6 @@ import (
 	"github.com/backup-cronjob/internal/models"
 )
 
-// CreateProfileTable tạo bảng profiles nếu chưa tồn tại
-func CreateProfileTable() error {
-	_, err := DB.Exec(`
-		CREATE TABLE IF NOT EXISTS profiles (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL UNIQUE,
-			description TEXT,
-			db_user TEXT NOT NULL,
-			db_password TEXT NOT NULL,
-			container_name TEXT NOT NULL,
-			db_name TEXT NOT NULL,
-			is_active BOOLEAN DEFAULT 1,
-			google_client_id TEXT,
-			google_client_secret TEXT,
-			backup_dir TEXT,
-			cron_schedule TEXT DEFAULT '0 0 * * *',
-			backup_retention INTEGER DEFAULT 7,
-			upload_to_drive BOOLEAN DEFAULT 0,
-			folder_drive TEXT,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)
-	`)
-	return err
-}
-
 // EnsureDefaultProfile đảm bảo có ít nhất một profile mặc định
 func EnsureDefaultProfile() error {
 	var count int
@@ -56,6 +30,18 @@ func EnsureDefaultProfile() error {
 		folderDrive, _ := GetConfigValue("GOOGLE_FOLDER")
 		cronSchedule, _ := GetConfigValue("CRON_SCHEDULE")
 		backupRetentionStr, _ := GetConfigValue("BACKUP_RETENTION_DAYS")
+		dbHost, _ := GetConfigValue("DB_HOST")
+		dbPortStr, _ := GetConfigValue("DB_PORT")
+
+		// Nếu không có container nhưng có host thì kết nối trực tiếp qua TCP
+		connectionMode := models.ConnectionModeDocker
+		if containerName == "" && dbHost != "" {
+			connectionMode = models.ConnectionModeTCP
+		}
+		dbPort, err := parseInt(dbPortStr, 5432)
+		if err != nil {
+			log.Printf("Không thể chuyển đổi DB_PORT thành số: %v", err)
+		}
 
 		backupRetention := 0
 		if backupRetentionStr != "" {
@@ -66,19 +52,21 @@ func EnsureDefaultProfile() error {
 			}
 		}
 
-		// Tạo profile mặc định
+		// Tạo profile mặc định, credentials được mã hóa bởi MigrateSecrets sau khi thiết lập database
 		now := time.Now()
 		_, err = DB.Exec(
 			`INSERT INTO profiles (
 				name, description, db_user, db_password, container_name, db_name, 
 				is_active, google_client_id, google_client_secret, backup_dir, 
 				cron_schedule, backup_retention, upload_to_drive, folder_drive, 
+				connection_mode, db_host, db_port, dump_format,
 				created_at, updated_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 			"Default", "Profile mặc định",
 			dbUser, dbPassword, containerName, dbName,
 			true, googleClientID, googleClientSecret, backupDir,
 			cronSchedule, backupRetention, false, folderDrive,
+			connectionMode, dbHost, dbPort, models.DefaultDumpFormat,
 			now, now,
 		)
 		if err != nil {
@@ -91,13 +79,71 @@ func EnsureDefaultProfile() error {
 	return nil
 }
 
+// profileColumns là danh sách cột dùng chung cho các truy vấn bảng profiles
+const profileColumns = `id, name, description, db_user, db_password, container_name, db_name,
+	is_active, google_client_id, google_client_secret, backup_dir,
+	cron_schedule, backup_retention, upload_to_drive, folder_drive,
+	COALESCE(connection_mode, 'docker'), COALESCE(db_host, ''), COALESCE(db_port, 5432),
+	COALESCE(ssl_mode, ''), COALESCE(ssl_root_cert, ''),
+	COALESCE(retention_keep_last, 0), COALESCE(retention_keep_daily, 0),
+	COALESCE(retention_keep_weekly, 0), COALESCE(retention_keep_monthly, 0),
+	COALESCE(dump_format, 'data-only'), COALESCE(compression, 'none'), COALESCE(compression_level, 0),
+	COALESCE(encryption, 'none'), COALESCE(encryption_recipients, ''), COALESCE(encryption_passphrase, ''),
+	COALESCE(destinations, ''),
+	COALESCE(sftp_host, ''), COALESCE(sftp_port, 22), COALESCE(sftp_user, ''), COALESCE(sftp_password, ''),
+	COALESCE(sftp_private_key, ''), COALESCE(sftp_host_key, ''), COALESCE(sftp_remote_dir, ''),
+	COALESCE(schedule_paused, 0), paused_until, COALESCE(job_timeout_minutes, 0),
+	COALESCE(drill_after_backup, 0), COALESCE(drill_schedule, ''), COALESCE(drill_sql, ''), COALESCE(drill_image, ''),
+	COALESCE(notify_on_failure, 1), COALESCE(notify_on_success, 0), COALESCE(notify_on_recovery, 0),
+	COALESCE(notify_digest, 0), COALESCE(notify_channels, ''),
+	created_at, updated_at`
+
+// rowScanner là interface chung của *sql.Row và *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProfile đọc một dòng của bảng profiles thành DatabaseProfile
+func scanProfile(row rowScanner) (models.DatabaseProfile, error) {
+	var profile models.DatabaseProfile
+	var destinations, notifyChannels string
+	var pausedUntil sql.NullTime
+	err := row.Scan(
+		&profile.ID, &profile.Name, &profile.Description,
+		&profile.DBUser, &profile.DBPassword, &profile.ContainerName, &profile.DBName,
+		&profile.IsActive, &profile.GoogleClientID, &profile.GoogleClientSecret, &profile.BackupDir,
+		&profile.CronSchedule, &profile.BackupRetention, &profile.UploadToDrive, &profile.FolderDrive,
+		&profile.ConnectionMode, &profile.DBHost, &profile.DBPort,
+		&profile.SSLMode, &profile.SSLRootCert,
+		&profile.RetentionKeepLast, &profile.RetentionKeepDaily,
+		&profile.RetentionKeepWeekly, &profile.RetentionKeepMonthly,
+		&profile.DumpFormat, &profile.Compression, &profile.CompressionLevel,
+		&profile.Encryption, &profile.EncryptionRecipients, &profile.EncryptionPassphrase,
+		&destinations,
+		&profile.SFTPHost, &profile.SFTPPort, &profile.SFTPUser, &profile.SFTPPassword,
+		&profile.SFTPPrivateKey, &profile.SFTPHostKey, &profile.SFTPRemoteDir,
+		&profile.SchedulePaused, &pausedUntil, &profile.JobTimeoutMinutes,
+		&profile.DrillAfterBackup, &profile.DrillSchedule, &profile.DrillSQL, &profile.DrillImage,
+		&profile.NotifyOnFailure, &profile.NotifyOnSuccess, &profile.NotifyOnRecovery,
+		&profile.NotifyDigest, &notifyChannels,
+		&profile.CreatedAt, &profile.UpdatedAt,
+	)
+	if pausedUntil.Valid {
+		profile.PausedUntil = &pausedUntil.Time
+	}
+	profile.Destinations = models.ParseDestinations(destinations)
+	profile.NotifyChannels = models.ParseDestinations(notifyChannels)
+	profile.UploadToDrive = profile.HasDestination(models.DestinationDrive)
+	if err == nil {
+		err = decryptProfileSecrets(&profile)
+	}
+	return profile, err
+}
+
 // GetAllProfiles lấy tất cả các profile
 func GetAllProfiles() ([]models.DatabaseProfile, error) {
 	rows, err := DB.Query(`
-		SELECT id, name, description, db_user, db_password, container_name, db_name, 
-			is_active, google_client_id, google_client_secret, backup_dir, 
-			cron_schedule, backup_retention, upload_to_drive, folder_drive, 
-			created_at, updated_at
+		SELECT ` + profileColumns + `
 		FROM profiles
 		ORDER BY name
 	`)
@@ -108,14 +154,7 @@ func GetAllProfiles() ([]models.DatabaseProfile, error) {
 
 	profiles := []models.DatabaseProfile{}
 	for rows.Next() {
-		var profile models.DatabaseProfile
-		err := rows.Scan(
-			&profile.ID, &profile.Name, &profile.Description,
-			&profile.DBUser, &profile.DBPassword, &profile.ContainerName, &profile.DBName,
-			&profile.IsActive, &profile.GoogleClientID, &profile.GoogleClientSecret, &profile.BackupDir,
-			&profile.CronSchedule, &profile.BackupRetention, &profile.UploadToDrive, &profile.FolderDrive,
-			&profile.CreatedAt, &profile.UpdatedAt,
-		)
+		profile, err := scanProfile(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -127,41 +166,16 @@ func GetAllProfiles() ([]models.DatabaseProfile, error) {
 
 // GetProfile lấy thông tin của một profile theo ID
 func GetProfile(id int64) (models.DatabaseProfile, error) {
-	var profile models.DatabaseProfile
-	err := DB.QueryRow(`
-		SELECT id, name, description, db_user, db_password, container_name, db_name, 
-			is_active, google_client_id, google_client_secret, backup_dir, 
-			cron_schedule, backup_retention, upload_to_drive, folder_drive, 
-			created_at, updated_at
+	return scanProfile(DB.QueryRow(`
+		SELECT `+profileColumns+`
 		FROM profiles
 		WHERE id = ?
-	`, id).Scan(
-		&profile.ID, &profile.Name, &profile.Description,
-		&profile.DBUser, &profile.DBPassword, &profile.ContainerName, &profile.DBName,
-		&profile.IsActive, &profile.GoogleClientID, &profile.GoogleClientSecret, &profile.BackupDir,
-		&profile.CronSchedule, &profile.BackupRetention, &profile.UploadToDrive, &profile.FolderDrive,
-		&profile.CreatedAt, &profile.UpdatedAt,
-	)
-	return profile, err
+	`, id))
 }
 
 // GetProfileByID lấy thông tin profile theo ID
 func GetProfileByID(id int64) (*models.DatabaseProfile, error) {
-	var profile models.DatabaseProfile
-	err := DB.QueryRow(`
-		SELECT id, name, description, db_user, db_password, container_name, db_name, 
-			is_active, google_client_id, google_client_secret, backup_dir, 
-			cron_schedule, backup_retention, upload_to_drive, folder_drive, 
-			created_at, updated_at
-		FROM profiles
-		WHERE id = ?
-	`, id).Scan(
-		&profile.ID, &profile.Name, &profile.Description,
-		&profile.DBUser, &profile.DBPassword, &profile.ContainerName, &profile.DBName,
-		&profile.IsActive, &profile.GoogleClientID, &profile.GoogleClientSecret, &profile.BackupDir,
-		&profile.CronSchedule, &profile.BackupRetention, &profile.UploadToDrive, &profile.FolderDrive,
-		&profile.CreatedAt, &profile.UpdatedAt,
-	)
+	profile, err := GetProfile(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("không tìm thấy profile với ID %d", id)
@@ -172,23 +186,44 @@ func GetProfileByID(id int64) (*models.DatabaseProfile, error) {
 	return &profile, nil
 }
 
-// CreateProfile tạo một profile mới
+// CreateProfile tạo một profile mới, credentials được mã hóa trước khi lưu
 func CreateProfile(profile models.DatabaseProfile) (int64, error) {
 	now := time.Now()
 	profile.CreatedAt = now
 	profile.UpdatedAt = now
+	if err := encryptProfileSecrets(&profile); err != nil {
+		return 0, err
+	}
 
 	result, err := DB.Exec(
 		`INSERT INTO profiles (
 			name, description, db_user, db_password, container_name, db_name, 
 			is_active, google_client_id, google_client_secret, backup_dir, 
 			cron_schedule, backup_retention, upload_to_drive, folder_drive, 
+			connection_mode, db_host, db_port, ssl_mode, ssl_root_cert,
+			retention_keep_last, retention_keep_daily, retention_keep_weekly, retention_keep_monthly,
+			dump_format, compression, compression_level,
+			encryption, encryption_recipients, encryption_passphrase, destinations,
+			sftp_host, sftp_port, sftp_user, sftp_password, sftp_private_key, sftp_host_key, sftp_remote_dir,
+			job_timeout_minutes, drill_after_backup, drill_schedule, drill_sql, drill_image,
+			notify_on_failure, notify_on_success, notify_on_recovery, notify_digest, notify_channels,
 			created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		profile.Name, profile.Description, profile.DBUser, profile.DBPassword,
 		profile.ContainerName, profile.DBName, profile.IsActive,
 		profile.GoogleClientID, profile.GoogleClientSecret, profile.BackupDir,
-		profile.CronSchedule, profile.BackupRetention, profile.UploadToDrive, profile.FolderDrive,
+		profile.CronSchedule, profile.BackupRetention, profile.HasDestination(models.DestinationDrive), profile.FolderDrive,
+		profile.ConnectionMode, profile.DBHost, profile.DBPort, profile.SSLMode, profile.SSLRootCert,
+		profile.RetentionKeepLast, profile.RetentionKeepDaily, profile.RetentionKeepWeekly, profile.RetentionKeepMonthly,
+		profile.DumpFormat, profile.Compression, profile.CompressionLevel,
+		profile.Encryption, profile.EncryptionRecipients, profile.EncryptionPassphrase,
+		models.JoinDestinations(profile.Destinations),
+		profile.SFTPHost, profile.SFTPPort, profile.SFTPUser, profile.SFTPPassword,
+		profile.SFTPPrivateKey, profile.SFTPHostKey, profile.SFTPRemoteDir,
+		profile.JobTimeoutMinutes, profile.DrillAfterBackup, profile.DrillSchedule, profile.DrillSQL, profile.DrillImage,
+		profile.NotifyOnFailure, profile.NotifyOnSuccess, profile.NotifyOnRecovery, profile.NotifyDigest,
+		models.JoinDestinations(profile.NotifyChannels),
 		profile.CreatedAt, profile.UpdatedAt,
 	)
 	if err != nil {
@@ -199,9 +234,12 @@ func CreateProfile(profile models.DatabaseProfile) (int64, error) {
 	return id, err
 }
 
-// UpdateProfile cập nhật thông tin của một profile
+// UpdateProfile cập nhật thông tin của một profile, credentials được mã hóa trước khi lưu
 func UpdateProfile(profile models.DatabaseProfile) error {
 	profile.UpdatedAt = time.Now()
+	if err := encryptProfileSecrets(&profile); err != nil {
+		return err
+	}
 
 	_, err := DB.Exec(
 		`UPDATE profiles SET 
@@ -209,17 +247,54 @@ func UpdateProfile(profile models.DatabaseProfile) error {
 			container_name = ?, db_name = ?, is_active = ?, 
 			google_client_id = ?, google_client_secret = ?, backup_dir = ?, 
 			cron_schedule = ?, backup_retention = ?, upload_to_drive = ?, folder_drive = ?, 
+			connection_mode = ?, db_host = ?, db_port = ?, ssl_mode = ?, ssl_root_cert = ?,
+			retention_keep_last = ?, retention_keep_daily = ?, retention_keep_weekly = ?, retention_keep_monthly = ?,
+			dump_format = ?, compression = ?, compression_level = ?,
+			encryption = ?, encryption_recipients = ?, encryption_passphrase = ?, destinations = ?,
+			sftp_host = ?, sftp_port = ?, sftp_user = ?, sftp_password = ?,
+			sftp_private_key = ?, sftp_host_key = ?, sftp_remote_dir = ?,
+			job_timeout_minutes = ?, drill_after_backup = ?, drill_schedule = ?, drill_sql = ?, drill_image = ?,
+			notify_on_failure = ?, notify_on_success = ?, notify_on_recovery = ?, notify_digest = ?, notify_channels = ?,
 			updated_at = ? 
 		WHERE id = ?`,
 		profile.Name, profile.Description, profile.DBUser, profile.DBPassword,
 		profile.ContainerName, profile.DBName, profile.IsActive,
 		profile.GoogleClientID, profile.GoogleClientSecret, profile.BackupDir,
-		profile.CronSchedule, profile.BackupRetention, profile.UploadToDrive, profile.FolderDrive,
+		profile.CronSchedule, profile.BackupRetention, profile.HasDestination(models.DestinationDrive), profile.FolderDrive,
+		profile.ConnectionMode, profile.DBHost, profile.DBPort, profile.SSLMode, profile.SSLRootCert,
+		profile.RetentionKeepLast, profile.RetentionKeepDaily, profile.RetentionKeepWeekly, profile.RetentionKeepMonthly,
+		profile.DumpFormat, profile.Compression, profile.CompressionLevel,
+		profile.Encryption, profile.EncryptionRecipients, profile.EncryptionPassphrase,
+		models.JoinDestinations(profile.Destinations),
+		profile.SFTPHost, profile.SFTPPort, profile.SFTPUser, profile.SFTPPassword,
+		profile.SFTPPrivateKey, profile.SFTPHostKey, profile.SFTPRemoteDir,
+		profile.JobTimeoutMinutes, profile.DrillAfterBackup, profile.DrillSchedule, profile.DrillSQL, profile.DrillImage,
+		profile.NotifyOnFailure, profile.NotifyOnSuccess, profile.NotifyOnRecovery, profile.NotifyDigest,
+		models.JoinDestinations(profile.NotifyChannels),
 		profile.UpdatedAt, profile.ID,
 	)
 	return err
 }
 
+// SetProfileSchedulePause lưu trạng thái tạm dừng lịch backup của profile.
+// until khác nil thì lịch tự chạy lại sau thời điểm đó
+func SetProfileSchedulePause(id int64, paused bool, until *time.Time) error {
+	if !paused {
+		until = nil
+	}
+	result, err := DB.Exec(
+		`UPDATE profiles SET schedule_paused = ?, paused_until = ?, updated_at = ? WHERE id = ?`,
+		paused, until, time.Now(), id,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("không tìm thấy profile với ID %d", id)
+	}
+	return nil
+}
+
 // DeleteProfile xóa một profile
 func DeleteProfile(id int64) error {
 	_, err := DB.Exec("DELETE FROM profiles WHERE id = ?", id)
@@ -228,22 +303,12 @@ func DeleteProfile(id int64) error {
 
 // GetActiveProfile lấy profile đang được kích hoạt
 func GetActiveProfile() (models.DatabaseProfile, error) {
-	var profile models.DatabaseProfile
-	err := DB.QueryRow(`
-		SELECT id, name, description, db_user, db_password, container_name, db_name, 
-			is_active, google_client_id, google_client_secret, backup_dir, 
-			cron_schedule, backup_retention, upload_to_drive, folder_drive, 
-			created_at, updated_at
+	profile, err := scanProfile(DB.QueryRow(`
+		SELECT ` + profileColumns + `
 		FROM profiles
 		WHERE is_active = 1
 		LIMIT 1
-	`).Scan(
-		&profile.ID, &profile.Name, &profile.Description,
-		&profile.DBUser, &profile.DBPassword, &profile.ContainerName, &profile.DBName,
-		&profile.IsActive, &profile.GoogleClientID, &profile.GoogleClientSecret, &profile.BackupDir,
-		&profile.CronSchedule, &profile.BackupRetention, &profile.UploadToDrive, &profile.FolderDrive,
-		&profile.CreatedAt, &profile.UpdatedAt,
-	)
+	`))
 	if err == sql.ErrNoRows {
 		return profile, fmt.Errorf("không tìm thấy profile nào đang hoạt động")
 	}
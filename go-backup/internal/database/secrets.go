@@ -0,0 +1,221 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/secrets"
+)
+
+// profileSecretColumns là các cột chứa credentials của bảng profiles, được mã hóa khi lưu
+var profileSecretColumns = []string{
+	"db_password", "google_client_secret", "encryption_passphrase", "sftp_password", "sftp_private_key",
+}
+
+// profileSecretFields trả về con trỏ tới các trường credentials của profile theo thứ tự profileSecretColumns
+func profileSecretFields(profile *models.DatabaseProfile) []*string {
+	return []*string{
+		&profile.DBPassword, &profile.GoogleClientSecret, &profile.EncryptionPassphrase,
+		&profile.SFTPPassword, &profile.SFTPPrivateKey,
+	}
+}
+
+// encryptProfileSecrets mã hóa các trường credentials của profile trước khi ghi vào database
+func encryptProfileSecrets(profile *models.DatabaseProfile) error {
+	for i, field := range profileSecretFields(profile) {
+		value, err := secrets.Encrypt(*field)
+		if err != nil {
+			return fmt.Errorf("không thể mã hóa %s: %w", profileSecretColumns[i], err)
+		}
+		*field = value
+	}
+	return nil
+}
+
+// decryptProfileSecrets giải mã các trường credentials của profile vừa đọc từ database
+func decryptProfileSecrets(profile *models.DatabaseProfile) error {
+	for i, field := range profileSecretFields(profile) {
+		value, err := secrets.Decrypt(*field)
+		if err != nil {
+			return fmt.Errorf("không thể giải mã %s của profile %d: %w", profileSecretColumns[i], profile.ID, err)
+		}
+		*field = value
+	}
+	return nil
+}
+
+// encryptConfigValue mã hóa giá trị của cấu hình nhạy cảm trước khi ghi vào database
+func encryptConfigValue(key, value string) (string, error) {
+	if !models.IsSecretConfig(key) {
+		return value, nil
+	}
+	encrypted, err := secrets.Encrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("không thể mã hóa cấu hình '%s': %w", key, err)
+	}
+	return encrypted, nil
+}
+
+// storedSecret là một giá trị credentials trong database cùng vị trí để ghi lại
+type storedSecret struct {
+	name  string
+	query string
+	id    interface{}
+	value string
+}
+
+// loadStoredSecrets đọc tất cả credentials đang lưu trong bảng profiles và configs
+func loadStoredSecrets(tx *sql.Tx) ([]storedSecret, error) {
+	var stored []storedSecret
+
+	rows, err := tx.Query(`SELECT id, COALESCE(` + strings.Join(profileSecretColumns, ", ''), COALESCE(") + `, '') FROM profiles`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		values := make([]string, len(profileSecretColumns))
+		dest := []interface{}{&id}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for i, column := range profileSecretColumns {
+			stored = append(stored, storedSecret{
+				name:  fmt.Sprintf("profiles.%s (ID %d)", column, id),
+				query: "UPDATE profiles SET " + column + " = ? WHERE id = ?",
+				id:    id,
+				value: values[i],
+			})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query("SELECT key, value FROM configs WHERE type = 'password'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		stored = append(stored, storedSecret{
+			name:  "configs." + key,
+			query: "UPDATE configs SET value = ? WHERE key = ?",
+			id:    key,
+			value: value,
+		})
+	}
+	return stored, rows.Err()
+}
+
+// rewriteSecrets áp dụng transform cho mọi credentials đang lưu trong một transaction
+// và trả về số giá trị đã được ghi lại
+func rewriteSecrets(transform func(value string) (string, error)) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stored, err := loadStoredSecrets(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, secret := range stored {
+		if secret.value == "" {
+			continue
+		}
+		value, err := transform(secret.value)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", secret.name, err)
+		}
+		if value == secret.value {
+			continue
+		}
+		if _, err := tx.Exec(secret.query, value, secret.id); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// MigrateSecrets mã hóa các credentials còn lưu dạng plaintext bằng master key hiện tại
+// và kiểm tra master key mở được các giá trị đã mã hóa. Khi chưa cấu hình master key,
+// catalog đã có dữ liệu mã hóa sẽ báo lỗi thay vì chạy với credentials không đọc được
+func MigrateSecrets() error {
+	if !secrets.Enabled() {
+		encrypted, err := countEncryptedSecrets()
+		if err != nil {
+			return err
+		}
+		if encrypted > 0 {
+			return fmt.Errorf("database chứa %d credentials đã mã hóa: %w", encrypted, secrets.ErrNoMasterKey)
+		}
+		log.Printf("Cảnh báo: chưa cấu hình SECRETS_MASTER_KEY, credentials được lưu dạng plaintext")
+		return nil
+	}
+
+	migrated, err := rewriteSecrets(func(value string) (string, error) {
+		if secrets.IsEncrypted(value) {
+			// Chỉ kiểm tra master key mở được giá trị, không ghi lại
+			_, err := secrets.Decrypt(value)
+			return value, err
+		}
+		return secrets.Encrypt(value)
+	})
+	if err != nil {
+		return fmt.Errorf("không thể mã hóa credentials: %w", err)
+	}
+	if migrated > 0 {
+		log.Printf("Đã mã hóa %d credentials còn lưu dạng plaintext", migrated)
+	}
+	return nil
+}
+
+// RotateSecrets bọc lại data key của mọi credentials bằng master key mới, trả về số giá trị đã cập nhật.
+// Dữ liệu được cập nhật trong một transaction nên lỗi giữa chừng không để catalog ở trạng thái lẫn hai khóa
+func RotateSecrets(newKey []byte) (int, error) {
+	return rewriteSecrets(func(value string) (string, error) {
+		return secrets.Rewrap(value, newKey)
+	})
+}
+
+// countEncryptedSecrets đếm số credentials đã được mã hóa trong database
+func countEncryptedSecrets() (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stored, err := loadStoredSecrets(tx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, secret := range stored {
+		if secrets.IsEncrypted(secret.value) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/secrets"
+)
+
+// testMasterKey trả về master key dạng base64 như trong SECRETS_MASTER_KEY
+func testMasterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, secrets.KeySize))
+}
+
+// initSecretsTestDB khởi tạo database tạm qua InitDB với master key cho trước (rỗng = không mã hóa)
+func initSecretsTestDB(t *testing.T, source, masterKey string) error {
+	t.Helper()
+	t.Cleanup(func() { secrets.SetMasterKey(nil) })
+	err := InitDB(&config.Config{DBSource: source, SecretsMasterKey: masterKey})
+	if DB != nil {
+		db := DB
+		t.Cleanup(func() { db.Close() })
+	}
+	return err
+}
+
+// createSecretProfile tạo profile có mật khẩu database và passphrase mã hóa backup
+func createSecretProfile(t *testing.T, name string) int64 {
+	t.Helper()
+	id, err := CreateProfile(models.DatabaseProfile{
+		Name:                 name,
+		DBUser:               "postgres",
+		DBName:               name,
+		DBPassword:           name + "-password",
+		EncryptionPassphrase: name + "-passphrase",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// storedProfileSecret đọc giá trị thô của cột credentials trong bảng profiles
+func storedProfileSecret(t *testing.T, id int64, column string) string {
+	t.Helper()
+	var value string
+	if err := DB.QueryRow("SELECT COALESCE("+column+", '') FROM profiles WHERE id = ?", id).Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestProfileSecretsEncryptedAtRest(t *testing.T) {
+	if err := initSecretsTestDB(t, filepath.Join(t.TempDir(), "app.db"), testMasterKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	id := createSecretProfile(t, "shop")
+
+	stored := storedProfileSecret(t, id, "db_password")
+	if !secrets.IsEncrypted(stored) || strings.Contains(stored, "shop-password") {
+		t.Fatalf("db_password lưu trong database = %q, muốn đã mã hóa", stored)
+	}
+	profile, err := GetProfileByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.DBPassword != "shop-password" || profile.EncryptionPassphrase != "shop-passphrase" {
+		t.Errorf("profile đọc ra = %q, %q", profile.DBPassword, profile.EncryptionPassphrase)
+	}
+}
+
+func TestMigrateSecretsEncryptsPlaintextRows(t *testing.T) {
+	if err := initSecretsTestDB(t, filepath.Join(t.TempDir(), "app.db"), ""); err != nil {
+		t.Fatal(err)
+	}
+	id := createSecretProfile(t, "shop")
+	if err := UpdateConfig("NOTIFY_SMTP_PASSWORD", "smtp-password"); err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedProfileSecret(t, id, "db_password"); stored != "shop-password" {
+		t.Fatalf("khi chưa có master key db_password = %q, muốn plaintext", stored)
+	}
+
+	if err := secrets.Configure(testMasterKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, column := range []string{"db_password", "encryption_passphrase"} {
+		if stored := storedProfileSecret(t, id, column); !secrets.IsEncrypted(stored) {
+			t.Errorf("%s sau khi migrate = %q, muốn đã mã hóa", column, stored)
+		}
+	}
+	var smtp string
+	DB.QueryRow("SELECT value FROM configs WHERE key = 'NOTIFY_SMTP_PASSWORD'").Scan(&smtp)
+	if !secrets.IsEncrypted(smtp) {
+		t.Errorf("NOTIFY_SMTP_PASSWORD sau khi migrate = %q, muốn đã mã hóa", smtp)
+	}
+	if value, err := GetConfigValue("NOTIFY_SMTP_PASSWORD"); err != nil || value != "smtp-password" {
+		t.Errorf("GetConfigValue = %q, %v", value, err)
+	}
+
+	// Chạy lại không ghi thêm gì
+	changed, err := rewriteSecrets(func(value string) (string, error) {
+		return secrets.Encrypt(value)
+	})
+	if err != nil || changed != 0 {
+		t.Errorf("lần migrate thứ hai thay đổi %d giá trị, %v", changed, err)
+	}
+}
+
+func TestRotateSecretsRewrapsEveryRow(t *testing.T) {
+	if err := initSecretsTestDB(t, filepath.Join(t.TempDir(), "app.db"), testMasterKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	ids := []int64{createSecretProfile(t, "shop"), createSecretProfile(t, "billing")}
+	if err := UpdateConfig("S3_SECRET_KEY", "s3-secret"); err != nil {
+		t.Fatal(err)
+	}
+	total, err := countEncryptedSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey, _ := secrets.ParseKey(testMasterKey(2))
+	updated, err := RotateSecrets(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != total {
+		t.Errorf("RotateSecrets cập nhật %d giá trị, muốn %d", updated, total)
+	}
+
+	// Mọi giá trị đều được bọc bằng khóa mới
+	tx, err := DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := loadStoredSecrets(tx)
+	tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range stored {
+		if secret.value != "" && !strings.HasPrefix(secret.value, secrets.Prefix+secrets.KeyID(newKey)+":") {
+			t.Errorf("%s chưa được bọc bằng khóa mới: %q", secret.name, secret.value)
+		}
+	}
+
+	secrets.SetMasterKey(newKey)
+	for _, id := range ids {
+		profile, err := GetProfileByID(id)
+		if err != nil {
+			t.Fatalf("đọc profile %d bằng khóa mới: %v", id, err)
+		}
+		if profile.DBPassword != profile.Name+"-password" {
+			t.Errorf("db_password = %q", profile.DBPassword)
+		}
+	}
+	if value, err := GetConfigValue("S3_SECRET_KEY"); err != nil || value != "s3-secret" {
+		t.Errorf("GetConfigValue = %q, %v", value, err)
+	}
+}
+
+func TestInitDBRefusesEncryptedRowsWithoutKey(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "app.db")
+	if err := initSecretsTestDB(t, source, testMasterKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	createSecretProfile(t, "shop")
+	DB.Close()
+
+	// Thiếu master key: không khởi động với credentials không đọc được
+	err := initSecretsTestDB(t, source, "")
+	if !errors.Is(err, secrets.ErrNoMasterKey) {
+		t.Fatalf("InitDB không có master key: err = %v, muốn ErrNoMasterKey", err)
+	}
+
+	// Sai master key cũng bị từ chối
+	if err := initSecretsTestDB(t, source, testMasterKey(2)); err == nil {
+		t.Fatal("InitDB với master key sai phải trả về lỗi")
+	}
+
+	// Đúng master key thì khởi động bình thường
+	if err := initSecretsTestDB(t, source, testMasterKey(1)); err != nil {
+		t.Fatalf("InitDB với master key đúng: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+)
+
+// userColumns là danh sách cột dùng cho các truy vấn bảng users
+const userColumns = `id, username, password, COALESCE(role, ''), COALESCE(disabled, 0), created_at, updated_at`
+
+// scanUser đọc một dòng của bảng users thành User
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.Disabled, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUserByUsername lấy thông tin người dùng theo username
+func GetUserByUsername(username string) (*models.User, error) {
+	return scanUser(DB.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, username))
+}
+
+// GetUserByID lấy thông tin người dùng theo ID
+func GetUserByID(id int64) (*models.User, error) {
+	return scanUser(DB.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+// GetAllUsers lấy danh sách tất cả người dùng
+func GetAllUsers() ([]models.User, error) {
+	rows, err := DB.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+// CreateUser tạo người dùng mới với mật khẩu đã được hash
+func CreateUser(username, passwordHash, role string) (*models.User, error) {
+	now := time.Now()
+	result, err := DB.Exec(
+		`INSERT INTO users (username, password, role, disabled, created_at, updated_at) VALUES (?, ?, ?, 0, ?, ?)`,
+		username, passwordHash, role, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetUserByID(id)
+}
+
+// UpdateUserRole cập nhật vai trò và trạng thái khóa của người dùng
+func UpdateUserRole(id int64, role string, disabled bool) error {
+	_, err := DB.Exec(`UPDATE users SET role = ?, disabled = ?, updated_at = ? WHERE id = ?`,
+		role, disabled, time.Now(), id)
+	return err
+}
+
+// UpdateUserPassword cập nhật mật khẩu (đã hash) của người dùng
+func UpdateUserPassword(id int64, passwordHash string) error {
+	_, err := DB.Exec(`UPDATE users SET password = ?, updated_at = ? WHERE id = ?`,
+		passwordHash, time.Now(), id)
+	return err
+}
+
+// CountActiveAdmins đếm số người dùng có vai trò admin chưa bị khóa
+func CountActiveAdmins() (int, error) {
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM users WHERE role = ? AND COALESCE(disabled, 0) = 0`, models.RoleAdmin).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,111 @@
+package dbdump
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// checkConnection kiểm tra môi trường cần thiết để chạy các công cụ PostgreSQL cho profile:
+// chế độ docker kiểm tra container, chế độ tcp kiểm tra pg_dump cục bộ và thông tin host
+func checkConnection(profile models.DatabaseProfile) error {
+	if profile.UsesDocker() {
+		return checkDockerContainer(profile.ContainerName)
+	}
+
+	if profile.DBHost == "" {
+		return fmt.Errorf("Thiếu thông tin host database (db_host) cho chế độ kết nối tcp")
+	}
+
+	// Kiểm tra pg_dump cục bộ (gói postgresql-client)
+	versionCmd := exec.Command("pg_dump", "--version")
+	versionOut, err := versionCmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pg_dump không có sẵn trên máy (cần cài postgresql-client): %v\nOutput: %s",
+			err, string(versionOut))
+	}
+	logger.Component("dbdump").Debug("Kết nối trực tiếp đến database", logger.KeyProfileID, profile.ID,
+		"host", fmt.Sprintf("%s:%d", profile.DBHost, dbPort(profile)), "sslmode", sslModeOrDefault(profile),
+		"pg_dump", strings.TrimSpace(string(versionOut)))
+
+	return nil
+}
+
+// pgCommand tạo lệnh chạy một công cụ PostgreSQL (pg_dump, psql, pg_restore...) theo chế độ kết nối của profile.
+// Hàm cleanup phải được gọi sau khi lệnh kết thúc để xóa các file tạm (CA certificate)
+func pgCommand(profile models.DatabaseProfile, interactive bool, tool string, args ...string) (*exec.Cmd, func(), error) {
+	return pgCommandContext(context.Background(), profile, interactive, nil, tool, args...)
+}
+
+// pgCommandContext giống pgCommand nhưng lệnh bị kill khi ctx bị hủy (với chế độ docker là tiến trình docker exec).
+// extraEnv là các biến môi trường bổ sung (KEY=VALUE) cho công cụ PostgreSQL
+func pgCommandContext(ctx context.Context, profile models.DatabaseProfile, interactive bool, extraEnv []string, tool string, args ...string) (*exec.Cmd, func(), error) {
+	if profile.UsesDocker() {
+		return dockerPgCommand(ctx, profile, interactive, extraEnv, tool, args...), func() {}, nil
+	}
+
+	cleanup := func() {}
+	env := append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", profile.DBPassword))
+	env = append(env, extraEnv...)
+	if profile.SSLMode != "" {
+		env = append(env, fmt.Sprintf("PGSSLMODE=%s", profile.SSLMode))
+	}
+
+	// Ghi CA certificate ra file tạm để libpq có thể đọc
+	if strings.TrimSpace(profile.SSLRootCert) != "" {
+		certFile, err := os.CreateTemp("", "pg-root-cert-*.crt")
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("không thể tạo file CA certificate tạm: %v", err)
+		}
+		if _, err := certFile.WriteString(profile.SSLRootCert); err != nil {
+			certFile.Close()
+			os.Remove(certFile.Name())
+			return nil, cleanup, fmt.Errorf("không thể ghi file CA certificate tạm: %v", err)
+		}
+		certFile.Close()
+
+		certPath := certFile.Name()
+		cleanup = func() { os.Remove(certPath) }
+		env = append(env, fmt.Sprintf("PGSSLROOTCERT=%s", certPath))
+	}
+
+	toolArgs := append([]string{"-h", profile.DBHost, "-p", strconv.Itoa(dbPort(profile))}, args...)
+	cmd := exec.CommandContext(ctx, tool, toolArgs...)
+	cmd.Env = env
+
+	return cmd, cleanup, nil
+}
+
+// describeCommand trả về chuỗi mô tả lệnh để ghi log, ẩn mật khẩu
+func describeCommand(cmd *exec.Cmd) string {
+	parts := make([]string, 0, len(cmd.Args))
+	for _, arg := range cmd.Args {
+		if strings.HasPrefix(arg, "PGPASSWORD=") {
+			arg = "PGPASSWORD=***"
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// dbPort trả về port của profile, mặc định 5432
+func dbPort(profile models.DatabaseProfile) int {
+	if profile.DBPort <= 0 {
+		return 5432
+	}
+	return profile.DBPort
+}
+
+// sslModeOrDefault trả về sslmode của profile, mặc định theo libpq là prefer
+func sslModeOrDefault(profile models.DatabaseProfile) string {
+	if profile.SSLMode == "" {
+		return "prefer"
+	}
+	return profile.SSLMode
+}
@@ -0,0 +1,77 @@
+package dbdump
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// checkDockerContainer kiểm tra Docker có sẵn, container tồn tại và có chứa PostgreSQL
+func checkDockerContainer(containerName string) error {
+	// Kiểm tra Docker có sẵn không
+	dockerCheck := exec.Command("docker", "--version")
+	dockerOut, dockerErr := dockerCheck.CombinedOutput()
+	if dockerErr != nil {
+		return fmt.Errorf("Docker không có sẵn: %v\nOutput: %s", dockerErr, string(dockerOut))
+	}
+	checkLogger := logger.Component("dbdump").With("container", containerName)
+	checkLogger.Debug("Docker có sẵn", "version", strings.TrimSpace(string(dockerOut)))
+
+	// Kiểm tra container có tồn tại không
+	containerCheck := exec.Command("docker", "container", "inspect", containerName)
+	containerOut, containerErr := containerCheck.CombinedOutput()
+	if containerErr != nil {
+		return fmt.Errorf("Container '%s' không tồn tại hoặc không thể truy cập: %v\nOutput: %s",
+			containerName, containerErr, string(containerOut))
+	}
+	checkLogger.Debug("Container tồn tại và có thể truy cập")
+
+	// Kiểm tra container có chạy PostgreSQL không
+	pgVersionCmd := exec.Command(
+		"docker", "exec",
+		containerName,
+		"sh", "-c", "command -v psql && psql --version || echo 'PostgreSQL not found'",
+	)
+	pgVersionOut, pgVersionErr := pgVersionCmd.CombinedOutput()
+	pgVersionOutput := string(pgVersionOut)
+
+	if pgVersionErr != nil || strings.Contains(pgVersionOutput, "not found") {
+		return fmt.Errorf("Container không chứa PostgreSQL hoặc PostgreSQL không thể truy cập: %v\nOutput: %s",
+			pgVersionErr, pgVersionOutput)
+	}
+	checkLogger.Debug("PostgreSQL được tìm thấy trong container", "version", strings.TrimSpace(pgVersionOutput))
+
+	return nil
+}
+
+// dockerPgCommand tạo lệnh chạy một công cụ PostgreSQL (psql, pg_restore...) bên trong container.
+// Nếu interactive = true, stdin của lệnh sẽ được chuyển vào container (docker exec -i).
+// env là các biến môi trường bổ sung (KEY=VALUE) cho lệnh trong container
+func dockerPgCommand(ctx context.Context, profile models.DatabaseProfile, interactive bool, env []string, tool string, args ...string) *exec.Cmd {
+	dockerArgs := []string{"exec"}
+	if interactive {
+		dockerArgs = append(dockerArgs, "-i")
+	}
+	dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("PGPASSWORD=%s", profile.DBPassword))
+	for _, e := range env {
+		dockerArgs = append(dockerArgs, "-e", e)
+	}
+	dockerArgs = append(dockerArgs, profile.ContainerName, tool)
+	dockerArgs = append(dockerArgs, args...)
+
+	return exec.CommandContext(ctx, "docker", dockerArgs...)
+}
+
+// quoteIdent đặt tên định danh PostgreSQL trong dấu ngoặc kép
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral đặt chuỗi PostgreSQL trong dấu nháy đơn
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
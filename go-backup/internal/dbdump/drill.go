@@ -0,0 +1,334 @@
+package dbdump
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/storage"
+)
+
+// drillReadyTimeout là thời gian chờ tối đa để PostgreSQL trong container drill sẵn sàng
+const drillReadyTimeout = 2 * time.Minute
+
+// drillUser là superuser mặc định của image postgres
+const drillUser = "postgres"
+
+// defaultDrillSQL được chạy khi profile không cấu hình câu SQL kiểm tra
+const defaultDrillSQL = "SELECT count(*) FROM pg_catalog.pg_tables WHERE schemaname NOT IN ('pg_catalog', 'information_schema')"
+
+// ErrDrillDataOnly là lỗi khi kiểm tra backup data-only, loại backup không chứa schema nên không thể khôi phục độc lập
+var ErrDrillDataOnly = errors.New("backup định dạng data-only không chứa schema nên không thể restore drill độc lập với database nguồn, hãy dùng định dạng plain, custom hoặc directory")
+
+// DrillResult chứa kết quả restore drill của một backup
+type DrillResult struct {
+	BackupID  int64         `json:"backup_id"`
+	ProfileID int64         `json:"profile_id"`
+	JobLogID  int64         `json:"job_log_id"`
+	Passed    bool          `json:"passed"`
+	Image     string        `json:"image"`
+	Message   string        `json:"message"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// Driller kiểm tra backup bằng cách khôi phục vào một container PostgreSQL tạm thời
+type Driller struct {
+	Config *config.Config
+	// Storage dùng để tải backup từ destination khi file cục bộ không còn
+	Storage *storage.Manager
+}
+
+// NewDriller tạo instance mới của Driller
+func NewDriller(cfg *config.Config, storageManager *storage.Manager) *Driller {
+	return &Driller{
+		Config:  cfg,
+		Storage: storageManager,
+	}
+}
+
+// RunDrill chạy restore drill cho backup: khởi động container postgres:<phiên bản server> tạm thời
+// qua Docker CLI, khôi phục backup, chạy các câu SQL kiểm tra của profile rồi xóa container.
+// Kết quả (passed/failed, thời gian chạy) được lưu vào bản ghi backups và job_logs.
+// profileID = 0 dùng profile đã tạo backup
+func (d *Driller) RunDrill(ctx context.Context, backupID, profileID int64) (*DrillResult, error) {
+	startTime := time.Now()
+	result := &DrillResult{BackupID: backupID}
+	drillLogger := logger.Component("dbdump").With(logger.KeyBackupID, backupID)
+
+	backup, err := backupdb.GetBackupByID(backupID)
+	if err != nil {
+		result.Message = err.Error()
+		drillLogger.Error(result.Message)
+		return result, err
+	}
+
+	// Drill đối chiếu với database nguồn nên chỉ chạy với profile đã tạo backup
+	profile, err := targetProfile(d.Config, backup, profileID, false)
+	if err != nil {
+		result.Message = err.Error()
+		drillLogger.Error(result.Message)
+		return result, err
+	}
+	result.ProfileID = profile.ID
+	drillLogger = drillLogger.With(logger.KeyProfileID, profile.ID, "profile", profile.Name)
+
+	var logID int64
+	if profile.ID > 0 {
+		logID, err = database.CreateJobLogWithType(profile.ID, database.JobTypeDrill, database.JobStatusRunning, startTime)
+		if err != nil {
+			drillLogger.Error("Lỗi khi tạo log job kiểm tra backup", "error", err)
+		}
+	}
+	result.JobLogID = logID
+	if logID > 0 {
+		drillLogger = drillLogger.With(logger.KeyJobID, logID)
+	}
+
+	drillLogger.Info("Bắt đầu restore drill", "backup", backup.Name)
+	report, err := d.drill(ctx, profile, backup, result)
+	result.Duration = time.Since(startTime)
+
+	status := models.DrillStatusPassed
+	jobStatus := database.JobStatusSuccess
+	if err != nil {
+		status = models.DrillStatusFailed
+		jobStatus = database.JobStatusFailed
+		result.Message = fmt.Sprintf("Restore drill thất bại: %v", err)
+	} else {
+		result.Passed = true
+		result.Message = fmt.Sprintf("Restore drill thành công sau %s (%s)\n%s",
+			result.Duration.Round(time.Second), result.Image, report)
+	}
+	if err != nil {
+		drillLogger.Error("Restore drill thất bại", "error", err)
+	} else {
+		drillLogger.Info("Restore drill thành công", "duration", result.Duration.Round(time.Second), "image", result.Image)
+	}
+
+	if err := backupdb.UpdateBackupDrill(backupID, status, time.Now(), result.Duration, result.Message); err != nil {
+		drillLogger.Error("Lỗi khi lưu kết quả restore drill", "error", err)
+	}
+	if logID > 0 {
+		database.UpdateJobLog(logID, jobStatus, time.Now(), backup.Path, result.Message)
+	}
+
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// drill thực hiện các bước của restore drill, trả về kết quả các câu SQL kiểm tra
+func (d *Driller) drill(ctx context.Context, profile models.DatabaseProfile, backup *models.BackupFile, result *DrillResult) (string, error) {
+	// Drill phải độc lập với database nguồn, dump data-only không chứa schema nên không thể khôi phục riêng
+	if backup.DumpFormat == models.DumpFormatDataOnly {
+		return "", ErrDrillDataOnly
+	}
+
+	// File cục bộ đã bị xóa thì tải lại từ destination đã upload
+	if !backup.FileExists && d.Storage != nil {
+		backupID, _ := strconv.ParseInt(backup.ID, 10, 64)
+		downloadLogger := logger.Component("dbdump").With(logger.KeyProfileID, profile.ID, logger.KeyBackupID, backupID)
+		if destination, err := d.Storage.DownloadBackup(ctx, backupID, backup.Path); err == nil {
+			downloadLogger.Info("Đã tải backup từ destination để kiểm tra", "destination", destination)
+			backup.FileExists = true
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			downloadLogger.Warn("Không thể tải backup từ destination", "error", err)
+		}
+	}
+	if !backup.FileExists {
+		return "", fmt.Errorf("file backup không tồn tại trên hệ thống: %s", backup.Path)
+	}
+
+	if output, err := exec.CommandContext(ctx, "docker", "--version").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("Docker không có sẵn: %v\nOutput: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	image := strings.TrimSpace(profile.DrillImage)
+	if image == "" {
+		version, err := serverMajorVersion(ctx, profile)
+		if err != nil {
+			return "", err
+		}
+		image = "postgres:" + version
+	}
+	result.Image = image
+
+	drillProfile, stop, err := startDrillContainer(ctx, profile, image)
+	if err != nil {
+		return "", err
+	}
+	defer stop()
+
+	keys := DecryptionKeys(d.Config, backupOwner(backup, profile), "")
+	switch backup.DumpFormat {
+	case models.DumpFormatCustom:
+		err = restoreCustom(drillProfile, backup, keys)
+	case models.DumpFormatDirectory:
+		err = restoreDirectory(drillProfile, backup, keys)
+	default:
+		err = restorePlain(drillProfile, backup, keys)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", err
+	}
+
+	return runDrillSQL(ctx, drillProfile, profile.DrillSQL)
+}
+
+// serverMajorVersion lấy phiên bản chính của PostgreSQL nguồn (vd: 16, 9.6) để chọn image cho drill
+func serverMajorVersion(ctx context.Context, profile models.DatabaseProfile) (string, error) {
+	cmd, cleanup, err := pgCommandContext(ctx, profile, false, nil,
+		"psql", "-X", "-tA", "-U", profile.DBUser, "-d", profile.DBName, "-c", "SHOW server_version_num")
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("không thể lấy phiên bản PostgreSQL nguồn: %v\nOutput: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	num, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return "", fmt.Errorf("phiên bản PostgreSQL không hợp lệ: %s", strings.TrimSpace(string(output)))
+	}
+	// Từ PostgreSQL 10, phiên bản chính chỉ gồm một số
+	if num >= 100000 {
+		return strconv.Itoa(num / 10000), nil
+	}
+	return fmt.Sprintf("%d.%d", num/10000, num/100%100), nil
+}
+
+// startDrillContainer khởi động container PostgreSQL tạm thời và chờ đến khi sẵn sàng nhận kết nối.
+// Trả về profile kết nối vào container và hàm xóa container; container cũng bị xóa khi ctx bị hủy
+func startDrillContainer(ctx context.Context, profile models.DatabaseProfile, image string) (models.DatabaseProfile, func(), error) {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return models.DatabaseProfile{}, nil, fmt.Errorf("không thể tạo mật khẩu cho container drill: %v", err)
+	}
+
+	drillProfile := models.DatabaseProfile{
+		Name:           profile.Name + " (restore drill)",
+		ConnectionMode: models.ConnectionModeDocker,
+		ContainerName:  fmt.Sprintf("go-backup-drill-%d-%d", profile.ID, time.Now().UnixNano()),
+		DBUser:         drillUser,
+		DBPassword:     hex.EncodeToString(secret),
+		DBName:         profile.DBName,
+	}
+
+	logger.Component("dbdump").Info("Đang khởi động container drill", logger.KeyProfileID, profile.ID,
+		"container", drillProfile.ContainerName, "image", image)
+	runCmd := exec.CommandContext(ctx, "docker", "run", "-d",
+		"--name", drillProfile.ContainerName,
+		"--label", "go-backup.drill=true",
+		"-e", "POSTGRES_USER="+drillUser,
+		"-e", "POSTGRES_PASSWORD="+drillProfile.DBPassword,
+		"-e", "POSTGRES_DB="+drillProfile.DBName,
+		image,
+	)
+	if output, err := runCmd.CombinedOutput(); err != nil {
+		removeDrillContainer(drillProfile.ContainerName)
+		return drillProfile, nil, fmt.Errorf("không thể khởi động container %s: %v\nOutput: %s", image, err, strings.TrimSpace(string(output)))
+	}
+
+	stopRemove := context.AfterFunc(ctx, func() {
+		removeDrillContainer(drillProfile.ContainerName)
+	})
+	stop := func() {
+		stopRemove()
+		removeDrillContainer(drillProfile.ContainerName)
+	}
+
+	if err := waitDrillReady(ctx, drillProfile); err != nil {
+		stop()
+		return drillProfile, nil, err
+	}
+	return drillProfile, stop, nil
+}
+
+// waitDrillReady chờ PostgreSQL trong container nhận kết nối TCP. Trong lúc khởi tạo, image postgres
+// chạy một server tạm chỉ lắng nghe qua unix socket nên kiểm tra qua 127.0.0.1
+func waitDrillReady(ctx context.Context, drillProfile models.DatabaseProfile) error {
+	deadline := time.Now().Add(drillReadyTimeout)
+	for {
+		cmd := exec.CommandContext(ctx, "docker", "exec", drillProfile.ContainerName,
+			"pg_isready", "-h", "127.0.0.1", "-U", drillProfile.DBUser, "-d", drillProfile.DBName)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("PostgreSQL trong container %s không sẵn sàng sau %s", drillProfile.ContainerName, drillReadyTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// removeDrillContainer xóa container drill cùng volume dữ liệu của nó
+func removeDrillContainer(name string) {
+	output, err := exec.Command("docker", "rm", "-f", "-v", name).CombinedOutput()
+	if err != nil {
+		logger.Component("dbdump").Error("Không thể xóa container drill", "container", name, "error", err,
+			"output", strings.TrimSpace(string(output)))
+		return
+	}
+	logger.Component("dbdump").Debug("Đã xóa container drill", "container", name)
+}
+
+// runDrillSQL chạy từng câu SQL kiểm tra (mỗi câu một dòng, bỏ qua dòng trống và dòng bắt đầu bằng --).
+// Câu SQL lỗi hoặc không trả về dòng nào làm drill thất bại
+func runDrillSQL(ctx context.Context, drillProfile models.DatabaseProfile, drillSQL string) (string, error) {
+	var queries []string
+	for _, line := range strings.Split(drillSQL, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	if len(queries) == 0 {
+		queries = []string{defaultDrillSQL}
+	}
+
+	var report []string
+	for _, query := range queries {
+		cmd := dockerPgCommand(ctx, drillProfile, false, nil,
+			"psql", "-X", "-v", "ON_ERROR_STOP=1", "-tA", "-U", drillProfile.DBUser, "-d", drillProfile.DBName, "-c", query)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return strings.Join(report, "\n"), fmt.Errorf("câu SQL kiểm tra lỗi: %s\n%v: %s", query, err, strings.TrimSpace(stderr.String()))
+		}
+		output := strings.TrimSpace(stdout.String())
+		if output == "" {
+			return strings.Join(report, "\n"), fmt.Errorf("câu SQL kiểm tra không trả về dòng nào: %s", query)
+		}
+		firstRow := strings.SplitN(output, "\n", 2)[0]
+		report = append(report, fmt.Sprintf("%s => %s", query, firstRow))
+	}
+
+	return strings.Join(report, "\n"), nil
+}
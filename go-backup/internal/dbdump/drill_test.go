@@ -0,0 +1,39 @@
+package dbdump
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/backup-cronjob/internal/models"
+)
+
+func TestDrillDataOnlyDoesNotTouchSource(t *testing.T) {
+	// docker, psql và pg_dump giả ghi lại mọi lần được gọi
+	binDir := t.TempDir()
+	marker := filepath.Join(binDir, "called")
+	script := "#!/bin/sh\necho \"$0 $@\" >> " + marker + "\n"
+	for _, tool := range []string{"docker", "psql", "pg_dump"} {
+		if err := os.WriteFile(filepath.Join(binDir, tool), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	backupPath := filepath.Join(t.TempDir(), "shop_data.sql")
+	if err := os.WriteFile(backupPath, []byte("INSERT INTO items VALUES (1);\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backup := &models.BackupFile{Path: backupPath, FileExists: true, DumpFormat: models.DumpFormatDataOnly}
+	profile := models.DatabaseProfile{Name: "shop", DBName: "shop", DBUser: "postgres", ContainerName: "pg"}
+
+	_, err := (&Driller{}).drill(context.Background(), profile, backup, &DrillResult{})
+	if !errors.Is(err, ErrDrillDataOnly) {
+		t.Fatalf("drill backup data-only: err = %v, muốn ErrDrillDataOnly", err)
+	}
+	if calls, err := os.ReadFile(marker); err == nil {
+		t.Fatalf("drill backup data-only không được chạy công cụ nào, đã gọi:\n%s", calls)
+	}
+}
@@ -1,27 +1,39 @@
 package dbdump
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/compression"
 	"github.com/backup-cronjob/internal/config"
 	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/encryption"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/integrity"
+	"github.com/backup-cronjob/internal/logger"
 	"github.com/backup-cronjob/internal/models"
 )
 
 // DumpResult chứa thông tin kết quả dump
 type DumpResult struct {
-	FilePath string
-	FileSize int64
-	Success  bool
-	Message  string
+	BackupID     int64 // ID bản ghi trong bảng backups, 0 nếu không lưu được
+	FilePath     string
+	FileSize     int64  // Kích thước file trên đĩa (sau khi nén)
+	OriginalSize int64  // Kích thước dữ liệu dump trước khi nén
+	Checksum     string // SHA-256 (hex) của file backup, tính trong lúc dump
+	Success      bool
+	Message      string
 }
 
 // DatabaseDumper là struct quản lý việc dump database
@@ -36,119 +48,56 @@ func NewDatabaseDumper(cfg *config.Config) *DatabaseDumper {
 	}
 }
 
-// DumpDatabase thực hiện việc dump database từ container Docker
+// DumpDatabase thực hiện việc dump database qua container Docker hoặc kết nối TCP trực tiếp
 func (d *DatabaseDumper) DumpDatabase(profileId int64) (*DumpResult, error) {
+	return d.DumpDatabaseContext(context.Background(), profileId)
+}
+
+// DumpDatabaseContext giống DumpDatabase nhưng dừng pg_dump khi ctx bị hủy hoặc hết thời gian:
+// tiến trình dump bị kill, kết nối của pg_dump trên server bị ngắt và file dump dở dang bị xóa
+func (d *DatabaseDumper) DumpDatabaseContext(ctx context.Context, profileId int64) (*DumpResult, error) {
 	// Tạo đối tượng result mặc định
 	result := &DumpResult{
 		Success: false,
 	}
+	dumpLogger := jobLogger(ctx, profileId)
+
+	// Lấy thông tin profile từ database
+	profile, err := loadProfile(d.Config, profileId)
+	if err != nil {
+		errMsg := err.Error()
+		dumpLogger.Error(errMsg)
+		result.Message = errMsg
+		return result, err
+	}
 
-	// Kiểm tra thư mục backup
-	backupBaseDir := d.Config.BackupDir
+	// Kiểm tra thư mục backup, profile có thư mục riêng thì ghi vào đó
+	backupBaseDir := profileBackupDir(d.Config, profile)
 	if backupBaseDir == "" {
 		errMsg := "Không thể dump database: Thiếu thông tin đường dẫn lưu backup (BACKUP_DIR)"
-		log.Printf(errMsg)
+		dumpLogger.Error(errMsg)
 		result.Message = errMsg
 		return result, fmt.Errorf(errMsg)
 	}
 
-	// Lấy thông tin profile từ database
-	var profile models.DatabaseProfile
-	var err error
-
-	if profileId > 0 {
-		// Lấy profile theo ID
-		profile, err = database.GetProfile(profileId)
-		if err != nil {
-			errMsg := fmt.Sprintf("Không thể tìm thấy profile với ID=%d: %v", profileId, err)
-			log.Printf(errMsg)
-			result.Message = errMsg
-			return result, fmt.Errorf(errMsg)
-		}
+	// Ghi thông tin dump
+	dumpLogger = dumpLogger.With("profile", profile.Name)
+	if profile.UsesDocker() {
+		dumpLogger.Info("Thực hiện dump", "db_user", profile.DBUser, "db_name", profile.DBName, "container", profile.ContainerName)
 	} else {
-		// Lấy profile đang hoạt động
-		profile, err = database.GetActiveProfile()
-		if err != nil {
-			// Thử lấy thông tin cấu hình từ database/config hoặc từ file .env
-			var dbConfigErrors []string
-
-			// Kiểm tra DB_USER
-			dbUser, err := database.GetConfigValue("DB_USER")
-			if err != nil || dbUser == "" {
-				dbUser = d.Config.DBUsername
-				if dbUser == "" {
-					errMsg := "Thiếu thông tin tên người dùng database (DB_USER)"
-					dbConfigErrors = append(dbConfigErrors, errMsg)
-				}
-			}
-
-			// Kiểm tra DB_PASSWORD
-			dbPassword, err := database.GetConfigValue("DB_PASSWORD")
-			if err != nil || dbPassword == "" {
-				dbPassword = d.Config.DBPassword
-				if dbPassword == "" {
-					errMsg := "Thiếu thông tin mật khẩu database (DB_PASSWORD)"
-					dbConfigErrors = append(dbConfigErrors, errMsg)
-				}
-			}
-
-			// Kiểm tra DB_NAME
-			dbName, err := database.GetConfigValue("DB_NAME")
-			if err != nil || dbName == "" {
-				dbName = d.Config.DBName
-				if dbName == "" {
-					errMsg := "Thiếu thông tin tên database (DB_NAME)"
-					dbConfigErrors = append(dbConfigErrors, errMsg)
-				}
-			}
-
-			// Kiểm tra CONTAINER_NAME
-			containerName, err := database.GetConfigValue("CONTAINER_NAME")
-			if err != nil || containerName == "" {
-				errMsg := "Thiếu thông tin tên container (CONTAINER_NAME)"
-				dbConfigErrors = append(dbConfigErrors, errMsg)
-			}
-
-			// Nếu có lỗi cấu hình, trả về ngay
-			if len(dbConfigErrors) > 0 {
-				errMsg := fmt.Sprintf("Không thể dump database: %s", strings.Join(dbConfigErrors, "; "))
-				log.Printf(errMsg)
-				result.Message = errMsg
-				return result, fmt.Errorf(errMsg)
-			}
-
-			// Tạo profile tạm thời từ cấu hình hiện tại
-			now := time.Now()
-			profile = models.DatabaseProfile{
-				Name:          "Temporary",
-				Description:   "Tạm thời từ cấu hình có sẵn",
-				DBUser:        dbUser,
-				DBPassword:    dbPassword,
-				ContainerName: containerName,
-				DBName:        dbName,
-				IsActive:      true,
-				CreatedAt:     now,
-				UpdatedAt:     now,
-			}
-		}
+		dumpLogger.Info("Thực hiện dump", "db_user", profile.DBUser, "db_name", profile.DBName,
+			"host", fmt.Sprintf("%s:%d", profile.DBHost, dbPort(profile)))
 	}
 
-	// Ghi thông tin dump
-	log.Printf("Thực hiện dump với profile: %s", profile.Name)
-	log.Printf("Thông tin kết nối: DBUser=%s, DBName=%s, ContainerName=%s",
-		profile.DBUser, profile.DBName, profile.ContainerName)
-
 	// Tạo thư mục backup theo ngày
 	now := time.Now()
 	dateFolder := now.Format("2006-01-02")
 	timestamp := now.Format("20060102_150405")
 
 	backupDir := filepath.Join(backupBaseDir, dateFolder)
-	log.Printf("Đang tạo thư mục backup theo ngày: %s", backupDir)
-
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
 		errMsg := fmt.Sprintf("Không thể tạo thư mục backup: %v", err)
-		log.Printf(errMsg)
+		dumpLogger.Error(errMsg)
 		result.Message = errMsg
 		return result, fmt.Errorf(errMsg)
 	}
@@ -156,126 +105,62 @@ func (d *DatabaseDumper) DumpDatabase(profileId int64) (*DumpResult, error) {
 	// Kiểm tra xem thư mục đã được tạo hay chưa
 	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
 		errMsg := fmt.Sprintf("Thư mục backup đã được tạo nhưng không thể truy cập: %s", backupDir)
-		log.Printf(errMsg)
+		dumpLogger.Error(errMsg)
 		result.Message = errMsg
 		return result, fmt.Errorf(errMsg)
 	}
 
-	log.Printf("Đã tạo thư mục backup theo ngày: %s", backupDir)
-
-	// Tạo tên file output
-	outputFile := filepath.Join(backupDir, fmt.Sprintf("%s_%s_data.sql", profile.DBName, timestamp))
-	log.Printf("Tên file output: %s", outputFile)
-
-	// Kiểm tra Docker có sẵn không
-	dockerCheck := exec.Command("docker", "--version")
-	dockerOut, dockerErr := dockerCheck.CombinedOutput()
-	if dockerErr != nil {
-		errMsg := fmt.Sprintf("Docker không có sẵn: %v\nOutput: %s", dockerErr, string(dockerOut))
-		log.Printf(errMsg)
+	// Tạo tên file output theo định dạng dump, thuật toán nén và chế độ mã hóa
+	output := dumpOutput{Format: profileDumpFormat(profile)}
+	output.Compression, output.Level = profileCompression(profile, output.Format)
+	output.Encryption, output.Keys, err = profileEncryption(profile)
+	if err != nil {
+		errMsg := err.Error()
+		dumpLogger.Error(errMsg)
 		result.Message = errMsg
-		return result, fmt.Errorf(errMsg)
+		return result, err
 	}
-	log.Printf("Docker có sẵn: %s", strings.TrimSpace(string(dockerOut)))
-
-	// Kiểm tra container có tồn tại không
-	containerCheck := exec.Command("docker", "container", "inspect", profile.ContainerName)
-	containerOut, containerErr := containerCheck.CombinedOutput()
-	if containerErr != nil {
-		errMsg := fmt.Sprintf("Container '%s' không tồn tại hoặc không thể truy cập: %v\nOutput: %s",
-			profile.ContainerName, containerErr, string(containerOut))
-		log.Printf(errMsg)
+	outputFile := filepath.Join(backupDir, output.FileName(profile.DBName, timestamp))
+	dumpLogger.Debug("File output", "path", outputFile, "format", output.Format,
+		"compression", output.Compression, "encryption", output.Encryption)
+
+	// Kiểm tra môi trường kết nối (Docker/container hoặc pg_dump cục bộ)
+	if err := checkConnection(profile); err != nil {
+		errMsg := err.Error()
+		dumpLogger.Error(errMsg)
 		result.Message = errMsg
-		return result, fmt.Errorf(errMsg)
-	}
-	log.Printf("Container '%s' tồn tại và có thể truy cập", profile.ContainerName)
-
-	// Kiểm tra container có chạy PostgreSQL không
-	// Thực hiện kiểm tra cơ bản xem container có postgres hay không
-	pgVersionCmd := exec.Command(
-		"docker", "exec",
-		profile.ContainerName,
-		"sh", "-c", "command -v psql && psql --version || echo 'PostgreSQL not found'",
-	)
-	pgVersionOut, pgVersionErr := pgVersionCmd.CombinedOutput()
-	pgVersionOutput := string(pgVersionOut)
-
-	if pgVersionErr != nil || strings.Contains(pgVersionOutput, "not found") {
-		errMsg := fmt.Sprintf("Container không chứa PostgreSQL hoặc PostgreSQL không thể truy cập: %v\nOutput: %s",
-			pgVersionErr, pgVersionOutput)
-		log.Printf(errMsg)
-		result.Message = errMsg
-		return result, fmt.Errorf(errMsg)
+		return result, err
 	}
-	log.Printf("PostgreSQL được tìm thấy trong container: %s", strings.TrimSpace(pgVersionOutput))
 
-	cmd := exec.Command(
-		"docker", "exec",
-		"-e", fmt.Sprintf("PGPASSWORD=%s", profile.DBPassword),
-		profile.ContainerName,
-		"pg_dump",
-		"-v",
-		"-d", profile.DBName,
-		"-U", profile.DBUser,
-		"--inserts",
-		"--no-owner",
-		"--no-privileges",
-		"--data-only",
-		"--column-inserts",
-		"--disable-triggers",
-	)
-
-	log.Printf("Lệnh dump đầy đủ: docker exec -e PGPASSWORD=*** %s pg_dump -v -d %s -U %s --inserts --no-owner --no-privileges --data-only --column-inserts --disable-triggers",
-		profile.ContainerName, profile.DBName, profile.DBUser)
-
-	// Tạo file output
-	outFile, err := os.Create(outputFile)
+	// Thực thi lệnh
+	dumpLogger.Debug("Đang thực hiện lệnh dump")
+	stderrOutput, originalSize, checksum, err := runPgDump(ctx, profile, output, outputFile, true)
 	if err != nil {
-		errMsg := fmt.Sprintf("Không thể tạo file output: %v", err)
-		log.Printf(errMsg)
-		result.Message = errMsg
-		return result, fmt.Errorf(errMsg)
-	}
-	defer outFile.Close()
+		// File dump dở dang không dùng được
+		os.Remove(outputFile)
 
-	// Thiết lập output, stderr
-	cmd.Stdout = outFile
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		errMsg := fmt.Sprintf("Không thể thiết lập stderr pipe: %v", err)
-		log.Printf(errMsg)
-		result.Message = errMsg
-		return result, fmt.Errorf(errMsg)
-	}
+		if ctx.Err() != nil {
+			errMsg := fmt.Sprintf("Dump database bị dừng: %v", ctx.Err())
+			dumpLogger.Error(errMsg)
+			result.Message = errMsg
+			return result, fmt.Errorf("dump database bị dừng: %w", ctx.Err())
+		}
 
-	// Thực thi lệnh
-	log.Printf("Đang thực hiện lệnh dump...")
-	if err := cmd.Start(); err != nil {
-		// Kiểm tra lỗi docker không khả dụng
+		// Kiểm tra lỗi docker/pg_dump không khả dụng
 		if strings.Contains(err.Error(), "executable file not found") {
 			errMsg := "Docker không được cài đặt hoặc không khả dụng, vui lòng kiểm tra cài đặt Docker"
-			log.Printf(errMsg)
+			if !profile.UsesDocker() {
+				errMsg = "pg_dump không được cài đặt hoặc không khả dụng, vui lòng cài đặt postgresql-client"
+			}
+			dumpLogger.Error(errMsg)
 			result.Message = errMsg
 			return result, fmt.Errorf(errMsg)
 		}
 
-		errMsg := fmt.Sprintf("Không thể khởi động lệnh: %v", err)
-		log.Printf(errMsg)
-		result.Message = errMsg
-		return result, fmt.Errorf(errMsg)
-	}
-
-	// Đọc stderr
-	stderrBytes, _ := io.ReadAll(stderrPipe)
-	stderrOutput := string(stderrBytes)
-
-	// Đợi lệnh hoàn thành
-	err = cmd.Wait()
-	if err != nil {
 		// Kiểm tra lỗi PostgreSQL không khả dụng
 		if strings.Contains(stderrOutput, "could not connect to server") {
 			errMsg := fmt.Sprintf("Không thể kết nối đến PostgreSQL server: %v\nOutput: %s", err, stderrOutput)
-			log.Printf(errMsg)
+			dumpLogger.Error(errMsg)
 			result.Message = errMsg
 			return result, fmt.Errorf(errMsg)
 		}
@@ -283,108 +168,363 @@ func (d *DatabaseDumper) DumpDatabase(profileId int64) (*DumpResult, error) {
 		// Kiểm tra lỗi truy cập bị từ chối
 		if strings.Contains(stderrOutput, "permission denied") || strings.Contains(stderrOutput, "authentication failed") {
 			errMsg := fmt.Sprintf("Truy cập đến PostgreSQL bị từ chối (sai username/password): %v\nOutput: %s", err, stderrOutput)
-			log.Printf(errMsg)
+			dumpLogger.Error(errMsg)
 			result.Message = errMsg
 			return result, fmt.Errorf(errMsg)
 		}
 
 		// Các lỗi khác
 		errMsg := fmt.Sprintf("Lỗi khi thực hiện dump: %v\nOutput: %s", err, stderrOutput)
-		log.Printf(errMsg)
+		dumpLogger.Error(errMsg)
 		result.Message = errMsg
 		return result, fmt.Errorf(errMsg)
 	}
 
-	log.Printf("Lệnh dump đã hoàn thành thành công")
+	dumpLogger.Debug("Lệnh dump đã hoàn thành")
 
 	// Kiểm tra file output
 	fileInfo, err := os.Stat(outputFile)
 	if err != nil {
 		errMsg := fmt.Sprintf("Lỗi khi kiểm tra file output: %v", err)
-		log.Printf(errMsg)
+		dumpLogger.Error(errMsg)
 		result.Message = errMsg
 		return result, fmt.Errorf(errMsg)
 	}
 
 	fileSize := fileInfo.Size()
-	log.Printf("Kích thước file output: %d bytes", fileSize)
-
-	if fileSize == 0 {
-		// Thử dùng lệnh pg_dump đơn giản hơn nếu file có kích thước 0
-		log.Printf("File dump rỗng, thử lại với cách khác...")
-
-		// Đóng file cũ
-		outFile.Close()
+	dumpLogger.Debug("Kích thước file output", "size", fileSize, "original_size", originalSize)
 
-		// Xóa file rỗng
-		os.Remove(outputFile)
+	if originalSize == 0 {
+		// Thử lại một lần với cùng định dạng nếu dump rỗng
+		dumpLogger.Warn("File dump rỗng, thử lại với cùng định dạng", "format", output.Format)
 
-		// Tạo lại file output
-		outFile, err = os.Create(outputFile)
+		_, originalSize, checksum, err = runPgDump(ctx, profile, output, outputFile, false)
 		if err != nil {
-			errMsg := fmt.Sprintf("Không thể tạo lại file output: %v", err)
-			log.Printf(errMsg)
-			result.Message = errMsg
-			return result, fmt.Errorf(errMsg)
-		}
-		defer outFile.Close()
-
-		// Tạo lệnh dump đơn giản hơn
-		simpleDumpCmd := exec.Command(
-			"docker", "exec",
-			"-e", fmt.Sprintf("PGPASSWORD=%s", profile.DBPassword),
-			profile.ContainerName,
-			"pg_dump",
-			"-U", profile.DBUser,
-			profile.DBName,
-		)
-
-		log.Printf("Thử lại với lệnh đơn giản hơn: docker exec -e PGPASSWORD=*** %s pg_dump -U %s %s",
-			profile.ContainerName, profile.DBUser, profile.DBName)
-
-		// Thiết lập output
-		simpleDumpCmd.Stdout = outFile
-		simpleDumpCmd.Stderr = os.Stderr
-
-		// Thực thi lệnh
-		if err := simpleDumpCmd.Run(); err != nil {
-			errMsg := fmt.Sprintf("Lệnh dump đơn giản cũng thất bại: %v", err)
-			log.Printf(errMsg)
+			os.Remove(outputFile)
+			if ctx.Err() != nil {
+				return result, fmt.Errorf("dump database bị dừng: %w", ctx.Err())
+			}
+			errMsg := fmt.Sprintf("Lệnh dump thử lại cũng thất bại: %v", err)
+			dumpLogger.Error(errMsg)
 			result.Message = errMsg
 			return result, fmt.Errorf(errMsg)
 		}
 
 		// Kiểm tra lại kích thước file
-		fileInfo, _ = os.Stat(outputFile)
-		fileSize = fileInfo.Size()
+		fileInfo, err = os.Stat(outputFile)
+		if err == nil {
+			fileSize = fileInfo.Size()
+		}
 
-		if fileSize == 0 {
+		if err != nil || originalSize == 0 {
+			os.Remove(outputFile)
 			errMsg := "Không thể tạo file dump có dữ liệu, có thể database không có dữ liệu hoặc không thể truy cập đến nó"
-			log.Printf(errMsg)
+			dumpLogger.Error(errMsg)
 			result.Message = errMsg
 			return result, fmt.Errorf(errMsg)
 		}
 	}
 
 	// Lưu thông tin backup vào database
-	log.Printf("Đang lưu thông tin backup vào database...")
-	backupId, err := backupdb.AddBackup(
-		filepath.Base(outputFile),
-		outputFile,
-		fileSize,
-		now,
-	)
+	backupId, err := backupdb.AddBackup(backupdb.NewBackup{
+		Filename:     filepath.Base(outputFile),
+		Filepath:     outputFile,
+		Filesize:     fileSize,
+		CreatedAt:    now,
+		DumpFormat:   output.Format,
+		Compression:  output.Compression,
+		OriginalSize: originalSize,
+		Encryption:   output.Encryption,
+		Checksum:     checksum,
+		ProfileID:    profile.ID,
+		DBName:       profile.DBName,
+		JobLogID:     events.JobID(ctx),
+	})
 	if err != nil {
-		log.Printf("Cảnh báo: Không thể lưu thông tin backup vào database: %v", err)
+		dumpLogger.Warn("Không thể lưu thông tin backup vào database", "error", err)
 	} else {
-		log.Printf("Đã lưu thông tin backup vào database với ID: %d", backupId)
+		dumpLogger = dumpLogger.With(logger.KeyBackupID, backupId)
+		dumpLogger.Info("Dump database thành công", "path", outputFile, "size", fileSize)
+		result.BackupID = backupId
+
+		// Cập nhật manifest của thư mục ngày với file backup mới
+		if err := integrity.WriteManifest(d.Config, filepath.Dir(outputFile)); err != nil {
+			dumpLogger.Warn("Không thể cập nhật manifest", "error", err)
+		}
 	}
 
 	// Trả về kết quả thành công
 	result.Success = true
 	result.FilePath = outputFile
 	result.FileSize = fileSize
+	result.OriginalSize = originalSize
+	result.Checksum = checksum
 	result.Message = fmt.Sprintf("Dump database thành công, đã lưu tại: %s", outputFile)
 
 	return result, nil
 }
+
+// jobLogger trả về logger của dbdump gắn profile và job (nếu ctx thuộc một job)
+func jobLogger(ctx context.Context, profileID int64) *slog.Logger {
+	l := logger.Component("dbdump").With(logger.KeyProfileID, profileID)
+	if jobID := events.JobID(ctx); jobID > 0 {
+		l = l.With(logger.KeyJobID, jobID)
+	}
+	return l
+}
+
+// pgWaitDelay là thời gian chờ tối đa để đóng stdout/stderr sau khi tiến trình bị kill
+const pgWaitDelay = 10 * time.Second
+
+// terminateBackends ngắt các kết nối có application_name trên server, dùng khi dump bị hủy
+func terminateBackends(profile models.DatabaseProfile, appName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cmd, cleanup, err := pgCommandContext(ctx, profile, false, nil,
+		"psql",
+		"-U", profile.DBUser,
+		"-d", profile.DBName,
+		"-tAc", fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE application_name = %s;", quoteLiteral(appName)),
+	)
+	terminateLogger := logger.Component("dbdump").With(logger.KeyProfileID, profile.ID, "application_name", appName)
+	if err != nil {
+		terminateLogger.Error("Không thể ngắt kết nối của pg_dump", "error", err)
+		return
+	}
+	defer cleanup()
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		terminateLogger.Error("Không thể ngắt kết nối của pg_dump", "error", err, "output", strings.TrimSpace(string(output)))
+		return
+	}
+	terminateLogger.Info("Đã ngắt kết nối của pg_dump trên server")
+}
+
+// profileBackupDir trả về thư mục lưu backup của profile, mặc định là BACKUP_DIR chung.
+// Đường dẫn tương đối được chuyển thành tuyệt đối giống BACKUP_DIR
+func profileBackupDir(cfg *config.Config, profile models.DatabaseProfile) string {
+	dir := strings.TrimSpace(profile.BackupDir)
+	if dir == "" {
+		return cfg.BackupDir
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return dir
+}
+
+// loadProfile lấy profile theo ID, nếu ID = 0 thì dùng profile đang hoạt động
+// hoặc tạo profile tạm thời từ cấu hình hiện có
+func loadProfile(cfg *config.Config, profileId int64) (models.DatabaseProfile, error) {
+	var profile models.DatabaseProfile
+	var err error
+
+	if profileId > 0 {
+		// Lấy profile theo ID
+		profile, err = database.GetProfile(profileId)
+		if err != nil {
+			return profile, fmt.Errorf("Không thể tìm thấy profile với ID=%d: %v", profileId, err)
+		}
+		return profile, nil
+	}
+
+	// Lấy profile đang hoạt động
+	profile, err = database.GetActiveProfile()
+	if err == nil {
+		return profile, nil
+	}
+
+	// Thử lấy thông tin cấu hình từ database/config hoặc từ file .env
+	var dbConfigErrors []string
+
+	// Kiểm tra DB_USER
+	dbUser, err := database.GetConfigValue("DB_USER")
+	if err != nil || dbUser == "" {
+		dbUser = cfg.DBUsername
+		if dbUser == "" {
+			dbConfigErrors = append(dbConfigErrors, "Thiếu thông tin tên người dùng database (DB_USER)")
+		}
+	}
+
+	// Kiểm tra DB_PASSWORD
+	dbPassword, err := database.GetConfigValue("DB_PASSWORD")
+	if err != nil || dbPassword == "" {
+		dbPassword = cfg.DBPassword
+		if dbPassword == "" {
+			dbConfigErrors = append(dbConfigErrors, "Thiếu thông tin mật khẩu database (DB_PASSWORD)")
+		}
+	}
+
+	// Kiểm tra DB_NAME
+	dbName, err := database.GetConfigValue("DB_NAME")
+	if err != nil || dbName == "" {
+		dbName = cfg.DBName
+		if dbName == "" {
+			dbConfigErrors = append(dbConfigErrors, "Thiếu thông tin tên database (DB_NAME)")
+		}
+	}
+
+	// Kiểm tra CONTAINER_NAME, nếu không có container thì kết nối trực tiếp qua DB_HOST
+	connectionMode := models.ConnectionModeDocker
+	containerName, err := database.GetConfigValue("CONTAINER_NAME")
+	if err != nil || containerName == "" {
+		if cfg.DBHost != "" {
+			connectionMode = models.ConnectionModeTCP
+		} else {
+			dbConfigErrors = append(dbConfigErrors, "Thiếu thông tin tên container (CONTAINER_NAME) hoặc host database (DB_HOST)")
+		}
+	}
+
+	// Nếu có lỗi cấu hình, trả về ngay
+	if len(dbConfigErrors) > 0 {
+		return profile, fmt.Errorf("Không thể dump database: %s", strings.Join(dbConfigErrors, "; "))
+	}
+
+	// Tạo profile tạm thời từ cấu hình hiện tại
+	port, err := strconv.Atoi(cfg.DBPort)
+	if err != nil || port <= 0 {
+		port = 5432
+	}
+
+	now := time.Now()
+	return models.DatabaseProfile{
+		Name:           "Temporary",
+		Description:    "Tạm thời từ cấu hình có sẵn",
+		DBUser:         dbUser,
+		DBPassword:     dbPassword,
+		ContainerName:  containerName,
+		DBName:         dbName,
+		ConnectionMode: connectionMode,
+		DBHost:         cfg.DBHost,
+		DBPort:         port,
+		IsActive:       true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, nil
+}
+
+// runPgDump chạy pg_dump theo định dạng và ghi kết quả vào outputFile (ghi đè nếu đã tồn tại),
+// dữ liệu được nén rồi mã hóa trong lúc ghi, không có bản rõ nào được ghi ra đĩa. Với định dạng
+// directory, thư mục dump được đóng gói thành file tar. Trả về stderr của pg_dump, kích thước
+// dữ liệu trước khi nén và checksum SHA-256 (hex) của file output
+func runPgDump(ctx context.Context, profile models.DatabaseProfile, output dumpOutput, outputFile string, verbose bool) (string, int64, string, error) {
+	args := pgDumpArgs(profile, output.Format)
+	if verbose {
+		args = append([]string{"-v"}, args...)
+	}
+
+	var dumpDir string
+	if output.Format == models.DumpFormatDirectory {
+		var err error
+		dumpDir, err = directoryDumpPath(profile, outputFile)
+		if err != nil {
+			return "", 0, "", err
+		}
+		args = append(args, "-f", dumpDir)
+	}
+
+	// Đặt application_name riêng để ngắt kết nối của pg_dump trên server khi bị hủy,
+	// vì kill tiến trình docker exec không dừng pg_dump bên trong container
+	appName := fmt.Sprintf("go-backup-%d", time.Now().UnixNano())
+	cmd, cleanup, err := pgCommandContext(ctx, profile, false, []string{"PGAPPNAME=" + appName}, "pg_dump", args...)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer cleanup()
+	cmd.WaitDelay = pgWaitDelay
+
+	stopTerminate := context.AfterFunc(ctx, func() {
+		terminateBackends(profile, appName)
+	})
+	defer stopTerminate()
+
+	jobLogger(ctx, profile.ID).Debug("Lệnh dump đầy đủ", "command", describeCommand(cmd))
+
+	// Tạo file output
+	outFile, err := os.Create(outputFile)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("không thể tạo file output: %v", err)
+	}
+	defer outFile.Close()
+
+	// Nén rồi mã hóa dữ liệu trong lúc ghi, đếm số byte trước khi nén và tính SHA-256 của file trên đĩa
+	hasher := sha256.New()
+	encryptor, err := encryption.NewWriter(io.MultiWriter(outFile, hasher), output.Encryption, output.Keys)
+	if err != nil {
+		return "", 0, "", err
+	}
+	compressor, err := compression.NewWriter(encryptor, output.Compression, output.Level)
+	if err != nil {
+		return "", 0, "", err
+	}
+	// Đếm số byte trước khi nén và phát sự kiện tiến độ cho job đang chạy
+	counter := &events.ProgressWriter{W: compressor, Ctx: ctx}
+	writersClosed := false
+	defer func() {
+		if !writersClosed {
+			compressor.Close()
+			encryptor.Close()
+		}
+	}()
+
+	// Thiết lập output, stderr
+	if dumpDir == "" {
+		cmd.Stdout = counter
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return "", 0, "", fmt.Errorf("không thể thiết lập stderr pipe: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", 0, "", fmt.Errorf("không thể khởi động lệnh: %w", err)
+	}
+
+	// Đọc stderr theo từng dòng, mỗi dòng (pg_dump -v) được phát thành sự kiện log của job
+	var stderrBuilder strings.Builder
+	scanner := bufio.NewScanner(stderrPipe)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		stderrBuilder.WriteString(line)
+		stderrBuilder.WriteByte('\n')
+		events.Emit(ctx, events.Event{Type: events.TypeLog, Message: line})
+	}
+	// Đọc hết phần còn lại nếu có dòng quá dài để pg_dump không bị chặn khi ghi stderr
+	io.Copy(&stderrBuilder, stderrPipe)
+	stderrOutput := stderrBuilder.String()
+
+	// Đợi lệnh hoàn thành
+	if err := cmd.Wait(); err != nil {
+		if dumpDir != "" {
+			discardDirectoryDump(profile, dumpDir)
+		}
+		return stderrOutput, 0, "", err
+	}
+	if err := ctx.Err(); err != nil {
+		if dumpDir != "" {
+			discardDirectoryDump(profile, dumpDir)
+		}
+		return stderrOutput, 0, "", err
+	}
+
+	if dumpDir != "" {
+		jobLogger(ctx, profile.ID).Debug("Đang đóng gói thư mục dump thành file tar", "dir", dumpDir)
+		if err := archiveDirectoryDump(profile, dumpDir, counter); err != nil {
+			return stderrOutput, 0, "", err
+		}
+	}
+
+	writersClosed = true
+	if err := compressor.Close(); err != nil {
+		encryptor.Close()
+		return stderrOutput, 0, "", fmt.Errorf("lỗi khi hoàn tất nén file dump: %v", err)
+	}
+	if err := encryptor.Close(); err != nil {
+		return stderrOutput, 0, "", fmt.Errorf("lỗi khi hoàn tất mã hóa file dump: %v", err)
+	}
+
+	counter.Flush()
+	return stderrOutput, counter.Count, hex.EncodeToString(hasher.Sum(nil)), outFile.Sync()
+}
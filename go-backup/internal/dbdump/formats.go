@@ -0,0 +1,264 @@
+package dbdump
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/backup-cronjob/internal/compression"
+	"github.com/backup-cronjob/internal/encryption"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// profileDumpFormat trả về định dạng dump của profile, profile cũ chưa thiết lập dùng data-only
+func profileDumpFormat(profile models.DatabaseProfile) string {
+	if models.IsValidDumpFormat(profile.DumpFormat) {
+		return profile.DumpFormat
+	}
+	if profile.DumpFormat != "" {
+		logger.Component("dbdump").Warn("Định dạng dump không hợp lệ, sử dụng data-only", logger.KeyProfileID, profile.ID,
+			"dump_format", profile.DumpFormat)
+	}
+	return models.DumpFormatDataOnly
+}
+
+// profileCompression trả về thuật toán và mức nén của profile.
+// Định dạng custom và directory đã được pg_dump nén sẵn nên không nén thêm
+func profileCompression(profile models.DatabaseProfile, format string) (string, int) {
+	algorithm := compression.Normalize(profile.Compression)
+	if !compression.IsValid(algorithm) {
+		logger.Component("dbdump").Warn("Thuật toán nén không hợp lệ, không nén file dump", logger.KeyProfileID, profile.ID,
+			"compression", profile.Compression)
+		return compression.None, 0
+	}
+	if algorithm != compression.None && models.IsArchiveDumpFormat(format) {
+		logger.Component("dbdump").Debug("Định dạng đã được pg_dump nén sẵn, bỏ qua nén", logger.KeyProfileID, profile.ID,
+			"dump_format", format, "compression", algorithm)
+		return compression.None, 0
+	}
+	if err := compression.ValidateLevel(algorithm, profile.CompressionLevel); err != nil {
+		logger.Component("dbdump").Warn("Mức nén không hợp lệ, sử dụng mức nén mặc định", logger.KeyProfileID, profile.ID, "error", err)
+		return algorithm, 0
+	}
+	return algorithm, profile.CompressionLevel
+}
+
+// dumpOutput mô tả cách ghi output của pg_dump ra file: định dạng, nén và mã hóa
+type dumpOutput struct {
+	Format      string
+	Compression string
+	Level       int
+	Encryption  string
+	Keys        encryption.Keys
+}
+
+// FileName trả về tên file backup: <db>_<timestamp><hậu tố định dạng><đuôi nén><đuôi mã hóa>
+func (o dumpOutput) FileName(dbName, timestamp string) string {
+	return fmt.Sprintf("%s_%s%s%s%s", dbName, timestamp, models.DumpFormatSuffix(o.Format),
+		compression.Extension(o.Compression), encryption.Extension(o.Encryption))
+}
+
+// profileEncryption trả về chế độ và khóa mã hóa của profile.
+// Khóa không hợp lệ trả về lỗi thay vì ghi backup không mã hóa
+func profileEncryption(profile models.DatabaseProfile) (string, encryption.Keys, error) {
+	mode := encryption.Normalize(profile.Encryption)
+	keys := encryption.Keys{
+		Recipients: profile.EncryptionRecipients,
+		Passphrase: profile.EncryptionPassphrase,
+	}
+	if !encryption.IsValid(mode) {
+		return "", keys, fmt.Errorf("chế độ mã hóa không hợp lệ '%s' trong profile '%s'", profile.Encryption, profile.Name)
+	}
+	if err := encryption.ValidateKeys(mode, keys); err != nil {
+		return "", keys, fmt.Errorf("cấu hình mã hóa của profile '%s' không hợp lệ: %v", profile.Name, err)
+	}
+	return mode, keys, nil
+}
+
+// pgDumpArgs trả về các tham số pg_dump tương ứng với định dạng dump
+func pgDumpArgs(profile models.DatabaseProfile, format string) []string {
+	args := []string{
+		"-d", profile.DBName,
+		"-U", profile.DBUser,
+		"--no-owner",
+		"--no-privileges",
+	}
+
+	switch format {
+	case models.DumpFormatCustom:
+		args = append(args, "-Fc")
+	case models.DumpFormatDirectory:
+		args = append(args, "-Fd")
+	case models.DumpFormatSchemaOnly:
+		args = append(args, "--schema-only")
+	case models.DumpFormatDataOnly:
+		args = append(args,
+			"--inserts",
+			"--data-only",
+			"--column-inserts",
+			"--disable-triggers",
+		)
+	}
+
+	return args
+}
+
+// directoryDumpPath trả về thư mục tạm để pg_dump -Fd ghi ra:
+// trong container với chế độ docker, hoặc trên máy cục bộ với chế độ tcp
+func directoryDumpPath(profile models.DatabaseProfile, outputFile string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(outputFile), filepath.Ext(outputFile))
+	if profile.UsesDocker() {
+		return path.Join("/tmp", name), nil
+	}
+
+	parent, err := os.MkdirTemp("", "pgdump-")
+	if err != nil {
+		return "", fmt.Errorf("không thể tạo thư mục tạm cho dump: %v", err)
+	}
+	return filepath.Join(parent, name), nil
+}
+
+// archiveDirectoryDump đóng gói thư mục dump thành file tar (thư mục gốc là tên thư mục dump) và xóa thư mục tạm
+func archiveDirectoryDump(profile models.DatabaseProfile, dumpDir string, out io.Writer) error {
+	defer discardDirectoryDump(profile, dumpDir)
+
+	if profile.UsesDocker() {
+		// docker cp với đích "-" xuất nội dung dưới dạng tar
+		var stderr bytes.Buffer
+		cmd := exec.Command("docker", "cp", profile.ContainerName+":"+dumpDir, "-")
+		cmd.Stdout = out
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("không thể sao chép thư mục dump từ container: %v\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+
+	return tarDirectory(dumpDir, out)
+}
+
+// discardDirectoryDump xóa thư mục tạm của dump dạng directory
+func discardDirectoryDump(profile models.DatabaseProfile, dumpDir string) {
+	if profile.UsesDocker() {
+		removeContainerPath(profile, dumpDir)
+		return
+	}
+	os.RemoveAll(filepath.Dir(dumpDir))
+}
+
+// tarDirectory ghi toàn bộ file trong dir vào tar, đường dẫn bắt đầu bằng tên thư mục
+func tarDirectory(dir string, out io.Writer) error {
+	tw := tar.NewWriter(out)
+	base := filepath.Dir(dir)
+
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, filePath)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("không thể đóng gói thư mục dump: %v", err)
+	}
+
+	return tw.Close()
+}
+
+// tarRootDir đọc tên thư mục gốc trong file tar của dump dạng directory
+func tarRootDir(r io.Reader) (string, error) {
+	tr := tar.NewReader(r)
+	header, err := tr.Next()
+	if err != nil {
+		return "", fmt.Errorf("không thể đọc file tar: %v", err)
+	}
+
+	root := strings.SplitN(strings.TrimPrefix(header.Name, "./"), "/", 2)[0]
+	if root == "" || root == "." || root == ".." {
+		return "", fmt.Errorf("file tar không chứa thư mục dump hợp lệ")
+	}
+	return root, nil
+}
+
+// extractTar giải nén tar vào thư mục dest, bỏ qua các đường dẫn nằm ngoài dest
+func extractTar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("không thể đọc file tar: %v", err)
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("đường dẫn không hợp lệ trong file tar: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			f.Close()
+		}
+	}
+}
+
+// removeContainerPath xóa thư mục tạm bên trong container
+func removeContainerPath(profile models.DatabaseProfile, target string) {
+	cmd := exec.Command("docker", "exec", profile.ContainerName, "rm", "-rf", target)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		logger.Component("dbdump").Warn("Không thể xóa thư mục tạm trong container", logger.KeyProfileID, profile.ID,
+			"container", profile.ContainerName, "path", target, "error", err, "output", strings.TrimSpace(string(output)))
+	}
+}
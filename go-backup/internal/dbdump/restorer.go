@@ -0,0 +1,468 @@
+package dbdump
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/compression"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/encryption"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/storage"
+)
+
+// ErrProfileMismatch được trả về khi profile đích khác profile đã tạo backup mà không được cho phép
+var ErrProfileMismatch = errors.New("profile đích khác profile đã tạo backup")
+
+// RestoreOptions chứa các tùy chọn khi khôi phục một backup
+type RestoreOptions struct {
+	ProfileID    int64 // Profile đích, 0 = profile đã tạo backup
+	DropDatabase bool  // Xóa và tạo lại database trước khi khôi phục
+	// AllowOtherProfile cho phép khôi phục vào profile khác profile đã tạo backup
+	AllowOtherProfile bool
+	// Passphrase dùng để giải mã backup, được thử trước passphrase của profile đã tạo backup
+	Passphrase string
+}
+
+// RestoreResult chứa thông tin kết quả restore
+type RestoreResult struct {
+	BackupID  int64
+	ProfileID int64
+	JobLogID  int64
+	Success   bool
+	Message   string
+	Duration  time.Duration
+}
+
+// Restorer là struct quản lý việc khôi phục backup vào database
+type Restorer struct {
+	Config *config.Config
+	// Storage dùng để tải backup từ destination khi file cục bộ không còn
+	Storage *storage.Manager
+}
+
+// NewRestorer tạo instance mới của Restorer
+func NewRestorer(cfg *config.Config) *Restorer {
+	return &Restorer{
+		Config:  cfg,
+		Storage: storage.NewManager(cfg, drive.NewDriveUploader(cfg)),
+	}
+}
+
+// RestoreBackup khôi phục một file backup vào database của profile (qua docker exec hoặc kết nối TCP)
+func (r *Restorer) RestoreBackup(backupID int64, opts RestoreOptions) (*RestoreResult, error) {
+	startTime := time.Now()
+	result := &RestoreResult{
+		BackupID: backupID,
+		Success:  false,
+	}
+	restoreLogger := logger.Component("dbdump").With(logger.KeyBackupID, backupID)
+
+	// Lấy thông tin backup
+	backup, err := backupdb.GetBackupByID(backupID)
+	if err != nil {
+		result.Message = err.Error()
+		restoreLogger.Error(result.Message)
+		return result, err
+	}
+
+	// Lấy thông tin profile đích, mặc định là profile đã tạo backup
+	profile, err := targetProfile(r.Config, backup, opts.ProfileID, opts.AllowOtherProfile)
+	if err != nil {
+		result.Message = err.Error()
+		restoreLogger.Error(result.Message)
+		return result, err
+	}
+	result.ProfileID = profile.ID
+	restoreLogger = restoreLogger.With(logger.KeyProfileID, profile.ID, "profile", profile.Name)
+
+	// File cục bộ đã bị xóa thì tải lại từ destination đã upload
+	if !backup.FileExists && r.Storage != nil {
+		if destination, err := r.Storage.DownloadBackup(context.Background(), backupID, backup.Path); err == nil {
+			restoreLogger.Info("Đã tải backup từ destination để khôi phục", "destination", destination)
+			backup.FileExists = true
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			restoreLogger.Warn("Không thể tải backup từ destination", "error", err)
+		}
+	}
+
+	if !backup.FileExists {
+		errMsg := fmt.Sprintf("File backup không tồn tại trên hệ thống: %s", backup.Path)
+		restoreLogger.Error(errMsg)
+		result.Message = errMsg
+		return result, fmt.Errorf(errMsg)
+	}
+
+	// Ghi log bắt đầu (profile tạm thời không có ID nên không ghi được log)
+	var logID int64
+	if profile.ID > 0 {
+		logID, err = database.CreateJobLogWithType(profile.ID, database.JobTypeRestore, "running", startTime)
+		if err != nil {
+			restoreLogger.Error("Lỗi khi tạo log job restore", "error", err)
+		}
+	}
+	result.JobLogID = logID
+	if logID > 0 {
+		restoreLogger = restoreLogger.With(logger.KeyJobID, logID)
+	}
+	restoreLogger.Info("Bắt đầu khôi phục backup", "backup", backup.Name, "db_name", profile.DBName, "drop_database", opts.DropDatabase)
+
+	keys := DecryptionKeys(r.Config, backupOwner(backup, profile), opts.Passphrase)
+	err = r.restore(profile, backup, keys, opts)
+	result.Duration = time.Since(startTime)
+
+	if err != nil {
+		result.Message = fmt.Sprintf("Lỗi khi khôi phục backup: %v", err)
+		restoreLogger.Error("Lỗi khi khôi phục backup", "error", err)
+		if logID > 0 {
+			database.UpdateJobLog(logID, "failed", time.Now(), backup.Path, result.Message)
+		}
+		return result, err
+	}
+
+	result.Success = true
+	result.Message = fmt.Sprintf("Khôi phục backup %s vào database '%s' thành công sau %s",
+		backup.Name, profile.DBName, result.Duration.Round(time.Second))
+	restoreLogger.Info("Khôi phục backup thành công", "duration", result.Duration.Round(time.Second))
+	if logID > 0 {
+		database.UpdateJobLog(logID, "success", time.Now(), backup.Path, result.Message)
+	}
+
+	return result, nil
+}
+
+// TargetProfileID trả về ID profile đích của backup: profileID nếu được chỉ định, ngược lại là profile
+// đã tạo backup (0 = profile đang hoạt động với backup cũ không rõ profile). Profile khác profile
+// đã tạo backup bị từ chối nếu không có allowOther
+func TargetProfileID(backup *models.BackupFile, profileID int64, allowOther bool) (int64, error) {
+	if backup.ProfileID == 0 || profileID == backup.ProfileID {
+		return profileID, nil
+	}
+	if profileID == 0 {
+		return backup.ProfileID, nil
+	}
+	if !allowOther {
+		return 0, fmt.Errorf("%w: backup %s thuộc profile ID %d, không phải profile ID %d",
+			ErrProfileMismatch, backup.Name, backup.ProfileID, profileID)
+	}
+	return profileID, nil
+}
+
+// targetProfile lấy thông tin profile đích của backup theo TargetProfileID
+func targetProfile(cfg *config.Config, backup *models.BackupFile, profileID int64, allowOther bool) (models.DatabaseProfile, error) {
+	id, err := TargetProfileID(backup, profileID, allowOther)
+	if err != nil {
+		return models.DatabaseProfile{}, err
+	}
+	return loadProfile(cfg, id)
+}
+
+// restore thực hiện các bước khôi phục: kiểm tra kết nối, tạo lại database (nếu cần) và nạp dữ liệu
+func (r *Restorer) restore(profile models.DatabaseProfile, backup *models.BackupFile, keys encryption.DecryptKeys, opts RestoreOptions) error {
+	// Kiểm tra giải mã và giải nén trước khi đụng đến database đích,
+	// tránh xóa database rồi mới phát hiện sai khóa hoặc file hỏng
+	if err := verifyBackup(backup, keys); err != nil {
+		return err
+	}
+
+	// Kiểm tra môi trường kết nối (Docker/container hoặc công cụ PostgreSQL cục bộ)
+	if err := checkConnection(profile); err != nil {
+		return err
+	}
+
+	// Xóa và tạo lại database nếu được yêu cầu
+	if opts.DropDatabase {
+		if err := recreateDatabase(profile); err != nil {
+			return err
+		}
+	}
+
+	logger.Component("dbdump").Debug("Nạp dữ liệu backup", logger.KeyProfileID, profile.ID, "backup", backup.Name,
+		"format", backup.DumpFormat, "encryption", BackupEncryption(backup))
+	switch backup.DumpFormat {
+	case models.DumpFormatCustom:
+		return restoreCustom(profile, backup, keys)
+	case models.DumpFormatDirectory:
+		return restoreDirectory(profile, backup, keys)
+	default:
+		return restorePlain(profile, backup, keys)
+	}
+}
+
+// restorePlain stream file SQL vào psql, chạy toàn bộ file trong một transaction
+func restorePlain(profile models.DatabaseProfile, backup *models.BackupFile, keys encryption.DecryptKeys) error {
+	file, err := OpenBackup(backup, keys)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Dừng ngay khi gặp lỗi
+	cmd, cleanup, err := pgCommand(profile, true,
+		"psql",
+		"-v", "ON_ERROR_STOP=1",
+		"--single-transaction",
+		"-q",
+		"-U", profile.DBUser,
+		"-d", profile.DBName,
+	)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	logger.Component("dbdump").Debug("Lệnh restore", logger.KeyProfileID, profile.ID, "command", describeCommand(cmd), "input", backup.Path)
+
+	var stderr bytes.Buffer
+	cmd.Stdin = file
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("psql thất bại: %v\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// restoreCustom stream file dump định dạng custom (-Fc) vào pg_restore
+func restoreCustom(profile models.DatabaseProfile, backup *models.BackupFile, keys encryption.DecryptKeys) error {
+	file, err := OpenBackup(backup, keys)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cmd, cleanup, err := pgCommand(profile, true, "pg_restore", pgRestoreArgs(profile)...)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	logger.Component("dbdump").Debug("Lệnh restore", logger.KeyProfileID, profile.ID, "command", describeCommand(cmd), "input", backup.Path)
+
+	var stderr bytes.Buffer
+	cmd.Stdin = file
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_restore thất bại: %v\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// restoreDirectory giải nén file tar của dump định dạng directory (-Fd) rồi chạy pg_restore trên thư mục đó.
+// Với chế độ docker, file tar được giải nén vào /tmp trong container qua docker cp
+func restoreDirectory(profile models.DatabaseProfile, backup *models.BackupFile, keys encryption.DecryptKeys) error {
+	// Đọc tên thư mục gốc trong file tar
+	header, err := OpenBackup(backup, keys)
+	if err != nil {
+		return err
+	}
+	rootDir, err := tarRootDir(header)
+	header.Close()
+	if err != nil {
+		return err
+	}
+
+	file, err := OpenBackup(backup, keys)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var dumpDir string
+	if profile.UsesDocker() {
+		var stderr bytes.Buffer
+		copyCmd := exec.Command("docker", "cp", "-", profile.ContainerName+":/tmp")
+		copyCmd.Stdin = file
+		copyCmd.Stderr = &stderr
+		if err := copyCmd.Run(); err != nil {
+			return fmt.Errorf("không thể sao chép dump vào container: %v\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		dumpDir = path.Join("/tmp", rootDir)
+		defer removeContainerPath(profile, dumpDir)
+	} else {
+		tempDir, err := os.MkdirTemp("", "pgrestore-")
+		if err != nil {
+			return fmt.Errorf("không thể tạo thư mục tạm: %v", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		if err := extractTar(file, tempDir); err != nil {
+			return err
+		}
+		dumpDir = filepath.Join(tempDir, rootDir)
+	}
+
+	cmd, cleanup, err := pgCommand(profile, false, "pg_restore", append(pgRestoreArgs(profile), dumpDir)...)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	logger.Component("dbdump").Debug("Lệnh restore", logger.KeyProfileID, profile.ID, "command", describeCommand(cmd))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pg_restore thất bại: %v\nOutput: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// OpenBackup mở file backup để đọc, tự động giải mã và giải nén theo thông tin của backup
+func OpenBackup(backup *models.BackupFile, keys encryption.DecryptKeys) (io.ReadCloser, error) {
+	file, err := os.Open(backup.Path)
+	if err != nil {
+		return nil, fmt.Errorf("không thể mở file backup: %v", err)
+	}
+
+	reader, err := DecodeBackup(file, BackupEncryption(backup), BackupCompression(backup), keys)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &backupReader{ReadCloser: reader, file: file}, nil
+}
+
+// verifyBackup mở backup và đọc khối dữ liệu đầu tiên để xác nhận giải mã, giải nén được
+func verifyBackup(backup *models.BackupFile, keys encryption.DecryptKeys) error {
+	file, err := OpenBackup(backup, keys)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buf := make([]byte, 32*1024)
+	if _, err := io.ReadFull(file, buf); err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("không thể đọc dữ liệu backup: %v", err)
+	}
+	return nil
+}
+
+// DecodeBackup giải mã rồi giải nén dữ liệu backup đọc từ r. Close không đóng r
+func DecodeBackup(r io.Reader, encryptionMode, algorithm string, keys encryption.DecryptKeys) (io.ReadCloser, error) {
+	decrypted, err := encryption.NewReader(r, encryptionMode, keys)
+	if err != nil {
+		return nil, fmt.Errorf("không thể giải mã file backup: %v", err)
+	}
+
+	reader, err := compression.NewReader(decrypted, algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("không thể giải nén file backup: %v", err)
+	}
+
+	return reader, nil
+}
+
+// DecryptionKeys tập hợp các khóa có thể giải mã backup của profile profileID: age identity trong
+// cấu hình, passphrase được chỉ định và passphrase của profile đó. Passphrase của các profile khác
+// không được thử để backup của profile này không giải mã được bằng khóa của profile khác
+func DecryptionKeys(cfg *config.Config, profileID int64, passphrase string) encryption.DecryptKeys {
+	keys := encryption.DecryptKeys{Identities: cfg.AgeIdentity}
+	keys.AddPassphrase(passphrase)
+	if profileID == 0 {
+		return keys
+	}
+
+	profile, err := database.GetProfile(profileID)
+	if err != nil {
+		logger.Component("dbdump").Warn("Không thể lấy passphrase của profile để giải mã backup", logger.KeyProfileID, profileID, "error", err)
+		return keys
+	}
+	keys.AddPassphrase(profile.EncryptionPassphrase)
+
+	return keys
+}
+
+// backupOwner trả về ID profile đã tạo backup, backup cũ không rõ profile dùng profile đích
+func backupOwner(backup *models.BackupFile, profile models.DatabaseProfile) int64 {
+	if backup.ProfileID > 0 {
+		return backup.ProfileID
+	}
+	return profile.ID
+}
+
+// BackupCompression trả về thuật toán nén của backup, bản ghi cũ được xác định theo tên file
+func BackupCompression(backup *models.BackupFile) string {
+	if backup.Compression != "" && backup.Compression != compression.None {
+		return backup.Compression
+	}
+	return compression.FromFileName(encryption.TrimExtension(backup.Path, BackupEncryption(backup)))
+}
+
+// BackupEncryption trả về chế độ mã hóa của backup, bản ghi cũ được xác định theo tên file
+func BackupEncryption(backup *models.BackupFile) string {
+	if backup.Encryption != "" && backup.Encryption != encryption.None {
+		return backup.Encryption
+	}
+	return encryption.FromFileName(backup.Path)
+}
+
+// backupReader đóng cả reader giải nén và file gốc
+type backupReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (b *backupReader) Close() error {
+	b.ReadCloser.Close()
+	return b.file.Close()
+}
+
+// pgRestoreArgs trả về các tham số chung của pg_restore
+func pgRestoreArgs(profile models.DatabaseProfile) []string {
+	return []string{
+		"--exit-on-error",
+		"--single-transaction",
+		"--no-owner",
+		"--no-privileges",
+		"-U", profile.DBUser,
+		"-d", profile.DBName,
+	}
+}
+
+// recreateDatabase được gán bằng biến để có thể thay thế khi kiểm thử
+var recreateDatabase = recreateTargetDatabase
+
+// recreateTargetDatabase ngắt các kết nối hiện có, xóa và tạo lại database của profile
+func recreateTargetDatabase(profile models.DatabaseProfile) error {
+	recreateLogger := logger.Component("dbdump").With(logger.KeyProfileID, profile.ID, "db_name", profile.DBName)
+	recreateLogger.Info("Đang xóa và tạo lại database")
+
+	cmd, cleanup, err := pgCommand(profile, false,
+		"psql",
+		"-v", "ON_ERROR_STOP=1",
+		"-U", profile.DBUser,
+		"-d", "postgres",
+		"-c", fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = %s AND pid <> pg_backend_pid();",
+			quoteLiteral(profile.DBName)),
+		"-c", fmt.Sprintf("DROP DATABASE IF EXISTS %s;", quoteIdent(profile.DBName)),
+		"-c", fmt.Sprintf("CREATE DATABASE %s;", quoteIdent(profile.DBName)),
+	)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("không thể tạo lại database '%s': %v\nOutput: %s",
+			profile.DBName, err, strings.TrimSpace(string(output)))
+	}
+
+	recreateLogger.Info("Đã tạo lại database")
+	return nil
+}
@@ -0,0 +1,297 @@
+package dbdump
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/compression"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/encryption"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// writeTestBackup tạo file backup đã nén gzip và mã hóa bằng passphrase
+func writeTestBackup(t *testing.T, passphrase string) *models.BackupFile {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "testdb_2024-01-02_03-04-05.sql.gz.enc")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	encrypted, err := encryption.NewWriter(file, encryption.Passphrase, encryption.Keys{Passphrase: passphrase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := compression.NewWriter(encrypted, compression.Gzip, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compressed.Write([]byte("CREATE TABLE items (id integer);\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := encrypted.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &models.BackupFile{
+		Name:        filepath.Base(path),
+		Path:        path,
+		FileExists:  true,
+		DumpFormat:  models.DumpFormatPlain,
+		Compression: compression.Gzip,
+		Encryption:  encryption.Passphrase,
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	backup := writeTestBackup(t, "correct horse battery")
+
+	if err := verifyBackup(backup, encryption.DecryptKeys{Passphrases: []string{"correct horse battery"}}); err != nil {
+		t.Fatalf("verifyBackup với passphrase đúng: %v", err)
+	}
+	if err := verifyBackup(backup, encryption.DecryptKeys{Passphrases: []string{"wrong passphrase!!"}}); err == nil {
+		t.Fatal("verifyBackup với passphrase sai phải trả về lỗi")
+	}
+}
+
+func TestVerifyBackupCorruptStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testdb_2024-01-02_03-04-05.sql.gz")
+	if err := os.WriteFile(path, []byte("không phải dữ liệu gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backup := &models.BackupFile{Path: path, Compression: compression.Gzip}
+
+	if err := verifyBackup(backup, encryption.DecryptKeys{}); err == nil {
+		t.Fatal("verifyBackup với file nén hỏng phải trả về lỗi")
+	}
+}
+
+func TestRestoreWrongKeyKeepsTargetDatabase(t *testing.T) {
+	backup := writeTestBackup(t, "correct horse battery")
+
+	dropped := false
+	original := recreateDatabase
+	recreateDatabase = func(models.DatabaseProfile) error {
+		dropped = true
+		return nil
+	}
+	defer func() { recreateDatabase = original }()
+
+	// pg_dump giả để bước kiểm tra kết nối thành công, chỉ còn việc giải mã quyết định kết quả
+	binDir := t.TempDir()
+	script := "#!/bin/sh\necho 'pg_dump (PostgreSQL) 16.0'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "pg_dump"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	profile := models.DatabaseProfile{
+		Name:           "test",
+		DBName:         "testdb",
+		DBUser:         "postgres",
+		ConnectionMode: models.ConnectionModeTCP,
+		DBHost:         "127.0.0.1",
+	}
+	keys := encryption.DecryptKeys{Passphrases: []string{"wrong passphrase!!"}}
+	err := (&Restorer{}).restore(profile, backup, keys, RestoreOptions{DropDatabase: true})
+	if err == nil {
+		t.Fatal("restore với passphrase sai phải trả về lỗi")
+	}
+	if dropped {
+		t.Fatal("database đích bị xóa dù backup không giải mã được")
+	}
+}
+
+func TestTargetProfileID(t *testing.T) {
+	owned := &models.BackupFile{Name: "shop.sql", ProfileID: 2}
+	legacy := &models.BackupFile{Name: "legacy.sql"}
+
+	tests := []struct {
+		name       string
+		backup     *models.BackupFile
+		profileID  int64
+		allowOther bool
+		want       int64
+		mismatch   bool
+	}{
+		{"mặc định là profile đã tạo backup", owned, 0, false, 2, false},
+		{"chỉ định đúng profile", owned, 2, false, 2, false},
+		{"profile khác bị từ chối", owned, 3, false, 0, true},
+		{"profile khác được cho phép", owned, 3, true, 3, false},
+		{"backup cũ dùng profile đang hoạt động", legacy, 0, false, 0, false},
+		{"backup cũ với profile chỉ định", legacy, 3, false, 3, false},
+	}
+	for _, tt := range tests {
+		got, err := TargetProfileID(tt.backup, tt.profileID, tt.allowOther)
+		if tt.mismatch {
+			if !errors.Is(err, ErrProfileMismatch) {
+				t.Errorf("%s: err = %v, muốn ErrProfileMismatch", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: TargetProfileID = %d, %v; muốn %d", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// setupRestoreTest tạo database tạm, pg_dump và psql giả trên PATH. psql ghi tham số vào file trả về
+func setupRestoreTest(t *testing.T) (*config.Config, string) {
+	t.Helper()
+	cfg := &config.Config{DBSource: filepath.Join(t.TempDir(), "app.db")}
+	if err := database.Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(cfg.DBSource); err != nil {
+		t.Fatal(err)
+	}
+
+	binDir := t.TempDir()
+	argsFile := filepath.Join(t.TempDir(), "psql.args")
+	scripts := map[string]string{
+		"pg_dump": "#!/bin/sh\necho 'pg_dump (PostgreSQL) 16.0'\n",
+		"psql":    "#!/bin/sh\necho \"$@\" > '" + argsFile + "'\ncat > /dev/null\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return cfg, argsFile
+}
+
+// createRestoreProfile tạo profile kết nối TCP với passphrase mã hóa backup
+func createRestoreProfile(t *testing.T, name, dbName, passphrase string) int64 {
+	t.Helper()
+	id, err := database.CreateProfile(models.DatabaseProfile{
+		Name:                 name,
+		DBUser:               "postgres",
+		DBName:               dbName,
+		ConnectionMode:       models.ConnectionModeTCP,
+		DBHost:               "127.0.0.1",
+		EncryptionPassphrase: passphrase,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestRestoreBackupTargetsBackupProfile(t *testing.T) {
+	cfg, argsFile := setupRestoreTest(t)
+
+	activeID := createRestoreProfile(t, "shop", "shop", "active passphrase!!")
+	ownerID := createRestoreProfile(t, "billing", "billing", "billing passphrase!!")
+	if err := database.SetActiveProfile(activeID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backup của profile không hoạt động
+	file := writeTestBackup(t, "billing passphrase!!")
+	backupID, err := backupdb.AddBackup(backupdb.NewBackup{
+		Filename:    file.Name,
+		Filepath:    file.Path,
+		CreatedAt:   time.Now(),
+		DumpFormat:  file.DumpFormat,
+		Compression: file.Compression,
+		Encryption:  file.Encryption,
+		ProfileID:   ownerID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dropped := []string{}
+	original := recreateDatabase
+	recreateDatabase = func(profile models.DatabaseProfile) error {
+		dropped = append(dropped, profile.DBName)
+		return nil
+	}
+	defer func() { recreateDatabase = original }()
+
+	restorer := &Restorer{Config: cfg}
+
+	// Không chỉ định profile: khôi phục vào profile đã tạo backup, không phải profile đang hoạt động
+	result, err := restorer.RestoreBackup(backupID, RestoreOptions{DropDatabase: true})
+	if err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if result.ProfileID != ownerID {
+		t.Errorf("ProfileID = %d, muốn profile đã tạo backup %d", result.ProfileID, ownerID)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), "-d billing") {
+		t.Errorf("psql được chạy với %q, muốn database billing", strings.TrimSpace(string(args)))
+	}
+
+	// Profile khác profile đã tạo backup bị từ chối trước khi đụng đến database đích
+	_, err = restorer.RestoreBackup(backupID, RestoreOptions{ProfileID: activeID, DropDatabase: true})
+	if !errors.Is(err, ErrProfileMismatch) {
+		t.Fatalf("khôi phục vào profile khác: err = %v, muốn ErrProfileMismatch", err)
+	}
+	if len(dropped) != 1 || dropped[0] != "billing" {
+		t.Errorf("database bị xóa = %v, muốn chỉ billing", dropped)
+	}
+
+	// Được phép khi xác nhận rõ ràng
+	result, err = restorer.RestoreBackup(backupID, RestoreOptions{
+		ProfileID:         activeID,
+		AllowOtherProfile: true,
+		Passphrase:        "billing passphrase!!",
+	})
+	if err != nil {
+		t.Fatalf("RestoreBackup với allow_other_profile: %v", err)
+	}
+	if result.ProfileID != activeID {
+		t.Errorf("ProfileID = %d, muốn %d", result.ProfileID, activeID)
+	}
+	if args, _ := os.ReadFile(argsFile); !strings.Contains(string(args), "-d shop") {
+		t.Errorf("psql được chạy với %q, muốn database shop", strings.TrimSpace(string(args)))
+	}
+
+	// Job restore được ghi vào profile đích
+	logs, err := database.GetJobLogsByProfile(ownerID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].JobType != database.JobTypeRestore || logs[0].Status != database.JobStatusSuccess {
+		t.Errorf("job log của profile billing = %+v", logs)
+	}
+}
+
+func TestDecryptionKeysOnlyOwningProfile(t *testing.T) {
+	cfg, _ := setupRestoreTest(t)
+	cfg.AgeIdentity = "AGE-SECRET-KEY-TEST"
+	shop := createRestoreProfile(t, "shop", "shop", "shop passphrase!!")
+	createRestoreProfile(t, "billing", "billing", "billing passphrase!!")
+
+	keys := DecryptionKeys(cfg, shop, "given passphrase!!")
+	want := []string{"given passphrase!!", "shop passphrase!!"}
+	if strings.Join(keys.Passphrases, ",") != strings.Join(want, ",") {
+		t.Errorf("passphrase = %q, muốn %q", keys.Passphrases, want)
+	}
+	if keys.Identities != cfg.AgeIdentity {
+		t.Errorf("age identity = %q", keys.Identities)
+	}
+
+	// Không rõ profile: chỉ dùng khóa được cung cấp
+	if keys := DecryptionKeys(cfg, 0, ""); len(keys.Passphrases) != 0 {
+		t.Errorf("passphrase khi không rõ profile = %q, muốn rỗng", keys.Passphrases)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,6 +13,11 @@ import (
 
 	"github.com/backup-cronjob/internal/backupdb"
 	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/secrets"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
@@ -19,9 +25,10 @@ import (
 
 // DriveUploader quản lý việc upload file lên Google Drive
 type DriveUploader struct {
-	Config  *config.Config
-	client  *http.Client
-	service *drive.Service
+	Config    *config.Config
+	client    *http.Client
+	service   *drive.Service
+	tokenFile string // File token OAuth, rỗng = token.json chung trong TokenDir
 }
 
 // NewDriveUploader tạo instance mới của DriveUploader
@@ -31,6 +38,56 @@ func NewDriveUploader(cfg *config.Config) *DriveUploader {
 	}
 }
 
+// ForProfile trả về DriveUploader dùng thư mục Drive và tài khoản Google của profile.
+// Profile có Google Client ID riêng được xác thực riêng với token lưu ở ProfileTokenFile,
+// các trường profile để trống dùng cấu hình chung
+func (d *DriveUploader) ForProfile(profile models.DatabaseProfile) *DriveUploader {
+	if profile.ID == 0 || (profile.GoogleClientID == "" && profile.FolderDrive == "") {
+		return d
+	}
+
+	cfg := *d.Config
+	uploader := &DriveUploader{Config: &cfg, tokenFile: d.tokenFile}
+	if profile.FolderDrive != "" {
+		cfg.FolderDrive = profile.FolderDrive
+	}
+	if profile.GoogleClientID != "" {
+		cfg.GoogleClientID = profile.GoogleClientID
+		cfg.GoogleClientSecret = profile.GoogleClientSecret
+		uploader.tokenFile = ProfileTokenFile(d.Config.TokenDir, profile.ID)
+	} else if profile.FolderDrive == d.Config.FolderDrive {
+		return d
+	}
+	return uploader
+}
+
+// ProfileTokenFile trả về file token OAuth của tài khoản Google riêng của profile
+func ProfileTokenFile(tokenDir string, profileID int64) string {
+	return filepath.Join(tokenDir, fmt.Sprintf("token-profile-%d.json", profileID))
+}
+
+// TokenFiles trả về các file token OAuth đang có trong tokenDir (token chung và token của các profile)
+func TokenFiles(tokenDir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(tokenDir, "token-profile-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{filepath.Join(tokenDir, "token.json")}, files...), nil
+}
+
+// UsesProfileAccount cho biết uploader dùng tài khoản Google riêng của profile thay vì tài khoản chung
+func (d *DriveUploader) UsesProfileAccount() bool {
+	return d.tokenFile != ""
+}
+
+// TokenFile trả về file token OAuth mà uploader sử dụng
+func (d *DriveUploader) TokenFile() string {
+	if d.tokenFile != "" {
+		return d.tokenFile
+	}
+	return filepath.Join(d.Config.TokenDir, "token.json")
+}
+
 // Init khởi tạo DriveUploader
 func (d *DriveUploader) Init() error {
 	// Kiểm tra client ID và client secret
@@ -95,51 +152,46 @@ func (d *DriveUploader) GetOAuthConfig() *oauth2.Config {
 
 // GetAuthURL tạo URL xác thực
 func (d *DriveUploader) GetAuthURL() string {
+	return d.AuthURL("state-token")
+}
+
+// AuthURL tạo URL xác thực với state cho trước, state được Google gửi lại trong callback
+func (d *DriveUploader) AuthURL(state string) string {
 	config := d.GetOAuthConfig()
 	// Chỉ sử dụng một trong hai tham số: prompt hoặc approval_prompt
 	// Sử dụng access_type=offline để nhận refresh token
 	// Thêm state để bảo vệ CSRF
 	url := config.AuthCodeURL(
-		"state-token",
+		state,
 		oauth2.AccessTypeOffline,
 		oauth2.SetAuthURLParam("prompt", "consent"),
 	)
-	fmt.Printf("Đã tạo URL xác thực: %s\n", url)
+	logger.Component("drive").Debug("Đã tạo URL xác thực Google", "redirect_url", config.RedirectURL)
 	return url
 }
 
 // ExchangeAuthCode đổi mã xác thực lấy token
 func (d *DriveUploader) ExchangeAuthCode(code string) (*oauth2.Token, error) {
-	fmt.Printf("Bắt đầu đổi mã xác thực lấy token với code có độ dài %d ký tự\n", len(code))
+	tokenFile := d.TokenFile()
+	authLogger := logger.Component("drive").With("token_file", tokenFile)
 
 	config := d.GetOAuthConfig()
-	fmt.Printf("Config OAuth2: Scopes=%v, RedirectURL=%s\n", config.Scopes, config.RedirectURL)
-
-	// In ra thông tin chi tiết để debug
-	fmt.Printf("Client ID: %s***\n", d.Config.GoogleClientID[:10])
-	fmt.Printf("Client Secret: %s***\n", d.Config.GoogleClientSecret[:5])
+	authLogger.Debug("Bắt đầu đổi mã xác thực lấy token", "scopes", config.Scopes, "redirect_url", config.RedirectURL)
 
 	token, err := config.Exchange(context.Background(), code)
 	if err != nil {
-		fmt.Printf("Lỗi khi đổi mã xác thực: %v\n", err)
+		authLogger.Error("Không thể đổi mã xác thực", "error", err)
 		return nil, fmt.Errorf("không thể đổi mã xác thực: %v", err)
 	}
 
-	fmt.Printf("Đã lấy được token, hết hạn vào: %v\n", token.Expiry)
-	fmt.Printf("Access Token có độ dài: %d ký tự\n", len(token.AccessToken))
-	fmt.Printf("Có Refresh Token: %v\n", token.RefreshToken != "")
-
 	// Lưu token
-	tokenFile := filepath.Join(d.Config.TokenDir, "token.json")
-	fmt.Printf("Lưu token vào file: %s\n", tokenFile)
-
 	err = d.saveToken(tokenFile, token)
 	if err != nil {
-		fmt.Printf("Lỗi khi lưu token: %v\n", err)
+		authLogger.Error("Không thể lưu token", "error", err)
 		return nil, fmt.Errorf("không thể lưu token: %v", err)
 	}
 
-	fmt.Println("Đã lưu token thành công!")
+	authLogger.Info("Đã lưu token Google Drive", "expiry", token.Expiry, "has_refresh_token", token.RefreshToken != "")
 	return token, nil
 }
 
@@ -164,7 +216,7 @@ func (d *DriveUploader) getClient() (*http.Client, error) {
 		}
 	}
 
-	cacheFile := filepath.Join(d.Config.TokenDir, "token.json")
+	cacheFile := d.TokenFile()
 
 	tok, err := d.tokenFromFile(cacheFile)
 	if err != nil {
@@ -192,9 +244,9 @@ func (d *DriveUploader) getClient() (*http.Client, error) {
 			if err != nil {
 				return nil, fmt.Errorf("không thể lưu token đã làm mới: %v", err)
 			}
-			fmt.Println("Đã làm mới token xác thực Google thành công")
+			logger.Component("drive").Info("Đã làm mới token xác thực Google", "token_file", cacheFile, "expiry", tok.Expiry)
 		} else {
-			return nil, fmt.Errorf("token đã hết hạn và không có refresh token. Vui lòng xóa file token.json và xác thực lại")
+			return nil, fmt.Errorf("token đã hết hạn và không có refresh token. Vui lòng xóa file %s và xác thực lại", filepath.Base(cacheFile))
 		}
 	}
 
@@ -203,18 +255,18 @@ func (d *DriveUploader) getClient() (*http.Client, error) {
 
 // CheckAuth kiểm tra đã xác thực chưa
 func (d *DriveUploader) CheckAuth() bool {
-	tokenFile := filepath.Join(d.Config.TokenDir, "token.json")
-	fmt.Printf("Đang kiểm tra file token tại: %s\n", tokenFile)
+	tokenFile := d.TokenFile()
+	authLogger := logger.Component("drive").With("token_file", tokenFile)
 
 	token, err := d.tokenFromFile(tokenFile)
 	if err != nil {
-		fmt.Printf("Không thể đọc token: %v\n", err)
+		authLogger.Debug("Không thể đọc token", "error", err)
 		return false
 	}
 
 	// Kiểm tra thời hạn token
 	if token.Expiry.Before(time.Now()) {
-		fmt.Printf("Token đã hết hạn vào %v\n", token.Expiry)
+		authLogger.Info("Token đã hết hạn, đang làm mới", "expiry", token.Expiry)
 
 		// Thử refresh token
 		config := d.GetOAuthConfig()
@@ -222,34 +274,33 @@ func (d *DriveUploader) CheckAuth() bool {
 
 		newToken, err := tokenSource.Token()
 		if err != nil {
-			fmt.Printf("Không thể làm mới token: %v\n", err)
+			authLogger.Warn("Không thể làm mới token", "error", err)
 			return false
 		}
 
 		// Lưu token mới
 		if err := d.saveToken(tokenFile, newToken); err != nil {
-			fmt.Printf("Không thể lưu token mới: %v\n", err)
+			authLogger.Error("Không thể lưu token mới", "error", err)
 			return false
 		}
 
-		fmt.Println("Đã làm mới và lưu token thành công")
+		authLogger.Info("Đã làm mới và lưu token", "expiry", newToken.Expiry)
 	} else {
-		fmt.Printf("Token hợp lệ, hết hạn vào: %v\n", token.Expiry)
+		authLogger.Debug("Token hợp lệ", "expiry", token.Expiry)
 	}
 
 	return true
 }
 
-// tokenFromFile đọc token từ file
+// tokenFromFile đọc token từ file, file đã mã hóa bằng master key được giải mã
 func (d *DriveUploader) tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
+	content, err := secrets.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
 	token := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(token)
+	err = json.Unmarshal(content, token)
 	return token, err
 }
 
@@ -283,22 +334,13 @@ func (d *DriveUploader) getTokenFromWeb(config *oauth2.Config) (*oauth2.Token, e
 	return token, nil
 }
 
-// saveToken lưu token vào file
+// saveToken lưu token vào file, nội dung được mã hóa nếu đã cấu hình master key
 func (d *DriveUploader) saveToken(path string, token *oauth2.Token) error {
-	// Đảm bảo thư mục tồn tại
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-		return err
-	}
-
-	// Mở file để ghi
-	f, err := os.Create(path)
+	data, err := json.Marshal(token)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	// Ghi token vào file dưới dạng JSON
-	return json.NewEncoder(f).Encode(token)
+	return secrets.WriteFile(path, data)
 }
 
 // createOrFindFolder tạo hoặc tìm folder trên Drive
@@ -323,7 +365,7 @@ func (d *DriveUploader) createOrFindFolder(name string, parentID string) (string
 	// Nếu folder đã tồn tại
 	if len(r.Files) > 0 {
 		folderID := r.Files[0].Id
-		fmt.Printf("Sử dụng folder có sẵn: %s (ID: %s)\n", name, folderID)
+		logger.Component("drive").Debug("Sử dụng folder có sẵn", "folder", name, "folder_id", folderID)
 		return folderID, nil
 	}
 
@@ -344,7 +386,7 @@ func (d *DriveUploader) createOrFindFolder(name string, parentID string) (string
 		return "", fmt.Errorf("không thể tạo folder: %v", err)
 	}
 
-	fmt.Printf("Đã tạo folder mới: %s (ID: %s)\n", name, folder.Id)
+	logger.Component("drive").Info("Đã tạo folder mới trên Drive", "folder", name, "folder_id", folder.Id)
 	return folder.Id, nil
 }
 
@@ -366,7 +408,6 @@ func (d *DriveUploader) checkFileExists(fileName string, folderID string) (*driv
 
 	// Kiểm tra kết quả
 	if len(fileList.Files) > 0 {
-		fmt.Printf("Đã tìm thấy file tồn tại trên Drive: %s (ID: %s)\n", fileName, fileList.Files[0].Id)
 		return fileList.Files[0], nil
 	}
 
@@ -374,6 +415,37 @@ func (d *DriveUploader) checkFileExists(fileName string, folderID string) (*driv
 	return nil, nil
 }
 
+// findFolder tìm folder trên Drive theo tên, trả về chuỗi rỗng nếu không có (không tạo mới)
+func (d *DriveUploader) findFolder(name string, parentID string) (string, error) {
+	query := fmt.Sprintf("name='%s' and mimeType='application/vnd.google-apps.folder' and trashed=false", name)
+	if parentID != "" {
+		query += fmt.Sprintf(" and '%s' in parents", parentID)
+	}
+
+	r, err := d.service.Files.List().Q(query).Fields("files(id, name)").Do()
+	if err != nil {
+		return "", fmt.Errorf("không thể tìm folder: %v", err)
+	}
+	if len(r.Files) == 0 {
+		return "", nil
+	}
+	return r.Files[0].Id, nil
+}
+
+// FileIDFromLink lấy ID file từ link dạng https://drive.google.com/file/d/<id>/view
+func FileIDFromLink(link string) string {
+	const marker = "/file/d/"
+	idx := strings.Index(link, marker)
+	if idx < 0 {
+		return ""
+	}
+	id := link[idx+len(marker):]
+	if end := strings.Index(id, "/"); end >= 0 {
+		id = id[:end]
+	}
+	return id
+}
+
 // CheckDriveConfig kiểm tra tất cả cấu hình Drive và báo cáo các vấn đề
 func (d *DriveUploader) CheckDriveConfig() map[string]string {
 	issues := make(map[string]string)
@@ -409,7 +481,7 @@ func (d *DriveUploader) CheckDriveConfig() map[string]string {
 	}
 
 	// Kiểm tra xác thực
-	tokenFile := filepath.Join(d.Config.TokenDir, "token.json")
+	tokenFile := d.TokenFile()
 	if _, err := os.Stat(tokenFile); os.IsNotExist(err) {
 		issues["AuthToken"] = "Chưa xác thực với Google Drive, cần thực hiện xác thực"
 	} else {
@@ -436,7 +508,24 @@ func (d *DriveUploader) CheckDriveConfig() map[string]string {
 
 // UploadFile uploads a file to Google Drive and returns the result
 func (d *DriveUploader) UploadFile(filePath string) UploadResult {
-	fmt.Printf("Bắt đầu upload file: %s\n", filePath)
+	return d.UploadFileContext(context.Background(), filePath)
+}
+
+// UploadFileContext giống UploadFile nhưng dừng upload khi ctx bị hủy hoặc hết thời gian
+func (d *DriveUploader) UploadFileContext(ctx context.Context, filePath string) UploadResult {
+	// Tên file backup có dạng <id>.<ext> khi upload từ job, dùng để cập nhật trạng thái trong database
+	fileName := filepath.Base(filePath)
+	var backupID int64
+	fmt.Sscanf(strings.TrimSuffix(fileName, filepath.Ext(fileName)), "%d", &backupID)
+
+	uploadLogger := logger.Component("drive").With("file", fileName)
+	if jobID := events.JobID(ctx); jobID > 0 {
+		uploadLogger = uploadLogger.With(logger.KeyJobID, jobID, logger.KeyProfileID, events.ProfileID(ctx))
+	}
+	if backupID > 0 {
+		uploadLogger = uploadLogger.With(logger.KeyBackupID, backupID)
+	}
+	uploadLogger.Info("Bắt đầu upload file lên Google Drive", "path", filePath)
 
 	// Kiểm tra các vấn đề cấu hình
 	configIssues := d.CheckDriveConfig()
@@ -446,7 +535,7 @@ func (d *DriveUploader) UploadFile(filePath string) UploadResult {
 			errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", key, issue))
 		}
 		errorMsg := fmt.Sprintf("Có vấn đề với cấu hình Google Drive: %s", strings.Join(errorMessages, "; "))
-		fmt.Println(errorMsg)
+		uploadLogger.Error(errorMsg)
 		return UploadResult{
 			Success: false,
 			Message: errorMsg,
@@ -455,29 +544,28 @@ func (d *DriveUploader) UploadFile(filePath string) UploadResult {
 
 	// Kiểm tra service đã khởi tạo chưa
 	if d.service == nil {
-		fmt.Println("Google Drive service chưa được khởi tạo, đang khởi tạo...")
+		uploadLogger.Debug("Google Drive service chưa được khởi tạo, đang khởi tạo")
 		err := d.Init()
 		if err != nil {
-			fmt.Printf("Lỗi khởi tạo Google Drive service: %v\n", err)
+			uploadLogger.Error("Không thể khởi tạo Google Drive service", "error", err)
 			return UploadResult{
 				Success: false,
 				Message: fmt.Sprintf("Không thể khởi tạo Google Drive service: %v", err),
 			}
 		}
-		fmt.Println("Đã khởi tạo Google Drive service thành công")
 	}
 
 	// Kiểm tra file tồn tại
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Printf("File không tồn tại: %s\n", filePath)
+			uploadLogger.Error("File không tồn tại", "path", filePath)
 			return UploadResult{
 				Success: false,
 				Message: fmt.Sprintf("File không tồn tại: %s", filePath),
 			}
 		}
-		fmt.Printf("Không thể đọc thông tin file: %v\n", err)
+		uploadLogger.Error("Không thể đọc thông tin file", "error", err)
 		return UploadResult{
 			Success: false,
 			Message: fmt.Sprintf("Không thể đọc thông tin file: %v", err),
@@ -486,51 +574,42 @@ func (d *DriveUploader) UploadFile(filePath string) UploadResult {
 
 	// Kiểm tra kích thước file
 	if fileInfo.Size() == 0 {
-		fmt.Println("File trống, không thể upload")
+		uploadLogger.Error("File trống, không thể upload")
 		return UploadResult{
 			Success: false,
 			Message: "File trống, không thể upload",
 		}
 	}
 
-	// Lấy tên file
-	fileName := filepath.Base(filePath)
-	fmt.Printf("Tên file upload: %s, Kích thước: %d bytes\n", fileName, fileInfo.Size())
-
 	// Đảm bảo thư mục tồn tại
 	folderName := d.Config.FolderDrive
-	fmt.Printf("Đang kiểm tra/tạo thư mục gốc: %s\n", folderName)
 	folderID, err := d.createFolderIfNotExist(folderName)
 	if err != nil {
-		fmt.Printf("Lỗi tạo thư mục: %v\n", err)
+		uploadLogger.Error("Không thể tạo thư mục gốc trên Drive", "folder", folderName, "error", err)
 		return UploadResult{
 			Success: false,
 			Message: fmt.Sprintf("Không thể tạo thư mục trên Drive: %v", err),
 		}
 	}
-	fmt.Printf("Đã tìm thấy/tạo thư mục gốc với ID: %s\n", folderID)
 
 	// Tìm ra thư mục ngày
 	dirPath := filepath.Dir(filePath)
 	dateFolder := filepath.Base(dirPath)
-	fmt.Printf("Đang kiểm tra/tạo thư mục ngày: %s\n", dateFolder)
 
 	// Tạo hoặc lấy folder ngày trên Drive
 	dateFolderID, err := d.createOrFindFolder(dateFolder, folderID)
 	if err != nil {
-		fmt.Printf("Lỗi tạo thư mục ngày: %v\n", err)
+		uploadLogger.Error("Không thể tạo thư mục ngày trên Drive", "folder", dateFolder, "error", err)
 		return UploadResult{
 			Success: false,
 			Message: fmt.Sprintf("Không thể tạo thư mục ngày trên Drive: %v", err),
 		}
 	}
-	fmt.Printf("Đã tìm thấy/tạo thư mục ngày với ID: %s\n", dateFolderID)
 
 	// Kiểm tra file đã tồn tại chưa
-	fmt.Printf("Kiểm tra file đã tồn tại trên Drive: %s\n", fileName)
 	existingFile, err := d.checkFileExists(fileName, dateFolderID)
 	if err != nil {
-		fmt.Printf("Lỗi kiểm tra file tồn tại: %v\n", err)
+		uploadLogger.Error("Lỗi kiểm tra file tồn tại trên Drive", "error", err)
 		return UploadResult{
 			Success: false,
 			Message: fmt.Sprintf("Lỗi kiểm tra file tồn tại: %v", err),
@@ -539,19 +618,13 @@ func (d *DriveUploader) UploadFile(filePath string) UploadResult {
 
 	// Nếu file đã tồn tại, báo cho người dùng biết và bỏ qua
 	if existingFile != nil {
-		fmt.Printf("File đã tồn tại trên Drive với ID: %s\n", existingFile.Id)
+		uploadLogger.Info("File đã tồn tại trên Drive, bỏ qua upload", "drive_file_id", existingFile.Id)
 		webLink := fmt.Sprintf("https://drive.google.com/file/d/%s/view", existingFile.Id)
 
 		// Cập nhật trạng thái file trong database
-		fileId := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
-		var backupID int64
-		fmt.Sscanf(fileId, "%d", &backupID)
 		if backupID > 0 {
-			fmt.Printf("Cập nhật trạng thái upload cho file ID: %d\n", backupID)
 			if err := backupdb.UpdateBackupUploadStatus(backupID, true, webLink); err != nil {
-				fmt.Printf("Không thể cập nhật trạng thái upload: %v\n", err)
-			} else {
-				fmt.Println("Đã cập nhật trạng thái upload thành công")
+				uploadLogger.Warn("Không thể cập nhật trạng thái upload", "error", err)
 			}
 		}
 
@@ -565,10 +638,9 @@ func (d *DriveUploader) UploadFile(filePath string) UploadResult {
 	}
 
 	// Mở file để đọc
-	fmt.Println("Đang mở file để upload...")
 	file, err := os.Open(filePath)
 	if err != nil {
-		fmt.Printf("Không thể mở file: %v\n", err)
+		uploadLogger.Error("Không thể mở file", "error", err)
 		return UploadResult{
 			Success: false,
 			Message: fmt.Sprintf("Không thể mở file: %v", err),
@@ -576,36 +648,36 @@ func (d *DriveUploader) UploadFile(filePath string) UploadResult {
 	}
 	defer file.Close()
 
+	// Phát sự kiện tiến độ upload nếu đang chạy trong một job backup
+	var fileSize int64
+	if info, err := file.Stat(); err == nil {
+		fileSize = info.Size()
+	}
+	progress := &events.ProgressReader{R: file, Ctx: ctx, Destination: models.DestinationDrive, Total: fileSize}
+
 	// Tạo file mới
-	fmt.Println("Bắt đầu upload lên Google Drive...")
 	driveFile, err := d.service.Files.Create(&drive.File{
 		Name:    fileName,
 		Parents: []string{dateFolderID},
-	}).Media(file).Do()
+	}).Media(progress).Context(ctx).Do()
 
 	// Xử lý kết quả
 	if err != nil {
-		fmt.Printf("Lỗi upload file: %v\n", err)
+		uploadLogger.Error("Lỗi upload file lên Drive", "error", err)
 		return UploadResult{
 			Success: false,
 			Message: fmt.Sprintf("Lỗi upload file lên Drive: %v", err),
 		}
 	}
 
-	fmt.Printf("Upload thành công! File ID: %s\n", driveFile.Id)
+	uploadLogger.Info("Đã upload file lên Google Drive", "drive_file_id", driveFile.Id, "size", fileSize)
 	// Tạo webViewLink
 	webLink := fmt.Sprintf("https://drive.google.com/file/d/%s/view", driveFile.Id)
 
 	// Cập nhật trạng thái file trong database
-	fileId := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
-	var backupID int64
-	fmt.Sscanf(fileId, "%d", &backupID)
 	if backupID > 0 {
-		fmt.Printf("Cập nhật trạng thái upload cho file ID: %d\n", backupID)
 		if err := backupdb.UpdateBackupUploadStatus(backupID, true, webLink); err != nil {
-			fmt.Printf("Không thể cập nhật trạng thái upload: %v\n", err)
-		} else {
-			fmt.Println("Đã cập nhật trạng thái upload thành công")
+			uploadLogger.Warn("Không thể cập nhật trạng thái upload", "error", err)
 		}
 	}
 
@@ -625,16 +697,6 @@ func (d *DriveUploader) createFolderIfNotExist(folderName string) (string, error
 
 // UploadAllBackups tải lên tất cả các file backup
 func (d *DriveUploader) UploadAllBackups() error {
-	// Kiểm tra các vấn đề cấu hình
-	configIssues := d.CheckDriveConfig()
-	if len(configIssues) > 0 {
-		errorMessages := []string{}
-		for key, issue := range configIssues {
-			errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", key, issue))
-		}
-		return fmt.Errorf("Có vấn đề với cấu hình Google Drive: %s", strings.Join(errorMessages, "; "))
-	}
-
 	// Lấy danh sách các file backup
 	backups, err := backupdb.GetAllBackups()
 	if err != nil {
@@ -646,31 +708,21 @@ func (d *DriveUploader) UploadAllBackups() error {
 		return fmt.Errorf("không có file backup nào để upload")
 	}
 
-	// Khởi tạo drive service nếu chưa được khởi tạo
-	if d.service == nil {
-		err := d.Init()
-		if err != nil {
-			return fmt.Errorf("không thể kết nối Google Drive: %v", err)
-		}
-	}
-
-	// Tạo folder gốc nếu chưa có
-	rootFolderID, err := d.createOrFindFolder(d.Config.FolderDrive, "")
-	if err != nil {
-		return fmt.Errorf("không thể tạo folder gốc: %v", err)
-	}
-
-	// Tạo map để theo dõi các thư mục ngày đã tạo
-	dateFolders := make(map[string]string)
+	// Mỗi profile upload vào thư mục Drive (và tài khoản Google) của mình
+	targets := make(map[int64]*uploadTarget)
 	successCount := 0
 	failCount := 0
 	skippedCount := 0
 
 	// Upload từng file backup từ database
 	for _, backup := range backups {
+		var backupID int64
+		fmt.Sscanf(backup.ID, "%d", &backupID)
+		backupLogger := logger.Component("drive").With(logger.KeyBackupID, backupID, logger.KeyProfileID, backup.ProfileID, "file", backup.Name)
+
 		// Bỏ qua những file đã được upload
 		if backup.Uploaded {
-			fmt.Printf("File %s đã được upload trước đó, bỏ qua\n", backup.Name)
+			backupLogger.Debug("File đã được upload trước đó, bỏ qua")
 			skippedCount++
 			continue
 		}
@@ -678,46 +730,56 @@ func (d *DriveUploader) UploadAllBackups() error {
 		// Kiểm tra file có tồn tại không
 		_, err := os.Stat(backup.Path)
 		if os.IsNotExist(err) {
-			fmt.Printf("File %s không còn tồn tại trên filesystem, bỏ qua\n", backup.Path)
+			backupLogger.Warn("File không còn tồn tại trên filesystem, bỏ qua", "path", backup.Path)
 			skippedCount++
 			continue
 		}
 
+		target, ok := targets[backup.ProfileID]
+		if !ok {
+			target = d.uploadTargetFor(backup.ProfileID)
+			targets[backup.ProfileID] = target
+		}
+		if target.err != nil {
+			backupLogger.Error("Không thể upload file", "error", target.err)
+			failCount++
+			continue
+		}
+		u := target.uploader
+
 		// Xác định tên thư mục ngày từ đường dẫn file
 		dirPath := filepath.Dir(backup.Path)
 		dateFolder := filepath.Base(dirPath)
 
 		// Tạo hoặc lấy folder ngày trên Drive nếu chưa có
-		dateFolderID, exists := dateFolders[dateFolder]
+		dateFolderID, exists := target.dateFolders[dateFolder]
 		if !exists {
-			dateFolderID, err = d.createOrFindFolder(dateFolder, rootFolderID)
+			dateFolderID, err = u.createOrFindFolder(dateFolder, target.rootID)
 			if err != nil {
-				fmt.Printf("Không thể tạo folder ngày %s: %v\n", dateFolder, err)
+				backupLogger.Error("Không thể tạo folder ngày", "folder", dateFolder, "error", err)
 				failCount++
 				continue
 			}
-			dateFolders[dateFolder] = dateFolderID
+			target.dateFolders[dateFolder] = dateFolderID
 		}
 
 		// Kiểm tra file đã tồn tại trên Drive chưa
-		existingFile, err := d.checkFileExists(backup.Name, dateFolderID)
+		existingFile, err := u.checkFileExists(backup.Name, dateFolderID)
 		if err != nil {
-			fmt.Printf("Không thể kiểm tra file %s: %v\n", backup.Name, err)
+			backupLogger.Error("Không thể kiểm tra file trên Drive", "error", err)
 			failCount++
 			continue
 		}
 
 		if existingFile != nil {
-			fmt.Printf("File %s đã tồn tại trên Drive, bỏ qua\n", backup.Name)
+			backupLogger.Info("File đã tồn tại trên Drive, bỏ qua", "drive_file_id", existingFile.Id)
 
 			// Tạo webLink
 			webLink := fmt.Sprintf("https://drive.google.com/file/d/%s/view", existingFile.Id)
 
 			// Cập nhật trạng thái trong database
-			var backupID int64
-			fmt.Sscanf(backup.ID, "%d", &backupID)
-			if err := backupdb.UpdateBackupUploadStatus(backupID, true, webLink); err != nil {
-				fmt.Printf("Không thể cập nhật trạng thái file %s: %v\n", backup.Name, err)
+			if err := recordUpload(backupID, backup.ProfileID, dateFolder, backup.Name, webLink); err != nil {
+				backupLogger.Warn("Không thể cập nhật trạng thái upload", "error", err)
 			}
 
 			skippedCount++
@@ -727,7 +789,7 @@ func (d *DriveUploader) UploadAllBackups() error {
 		// Mở file để upload
 		content, err := os.Open(backup.Path)
 		if err != nil {
-			fmt.Printf("Không thể mở file %s: %v\n", backup.Path, err)
+			backupLogger.Error("Không thể mở file", "path", backup.Path, "error", err)
 			failCount++
 			continue
 		}
@@ -743,7 +805,7 @@ func (d *DriveUploader) UploadAllBackups() error {
 		maxRetries := 3
 		for attempt := 1; attempt <= maxRetries; attempt++ {
 			// Upload file
-			file, err = d.service.Files.Create(fileMetadata).
+			file, err = u.service.Files.Create(fileMetadata).
 				Media(content).
 				Fields("id").
 				Do()
@@ -754,8 +816,7 @@ func (d *DriveUploader) UploadAllBackups() error {
 
 			// Lỗi, thử lại nếu còn lượt
 			if attempt < maxRetries {
-				fmt.Printf("Lỗi khi upload file %s (lần thử %d/%d): %v. Đang thử lại...\n",
-					backup.Name, attempt, maxRetries, err)
+				backupLogger.Warn("Lỗi khi upload file, đang thử lại", "attempt", attempt, "max_retries", maxRetries, "error", err)
 				time.Sleep(time.Second * 2) // Đợi 2 giây trước khi thử lại
 
 				// Đặt lại vị trí đọc file
@@ -765,22 +826,20 @@ func (d *DriveUploader) UploadAllBackups() error {
 		content.Close()
 
 		if err != nil {
-			fmt.Printf("Không thể upload file %s sau %d lần thử: %v\n", backup.Name, maxRetries, err)
+			backupLogger.Error("Không thể upload file", "attempts", maxRetries, "error", err)
 			failCount++
 			continue
 		}
 
-		fmt.Printf("Đã upload file %s (ID: %s)\n", backup.Name, file.Id)
+		backupLogger.Info("Đã upload file lên Google Drive", "drive_file_id", file.Id)
 		successCount++
 
 		// Tạo webLink
 		webLink := fmt.Sprintf("https://drive.google.com/file/d/%s/view", file.Id)
 
 		// Cập nhật trạng thái trong database
-		var backupID int64
-		fmt.Sscanf(backup.ID, "%d", &backupID)
-		if err := backupdb.UpdateBackupUploadStatus(backupID, true, webLink); err != nil {
-			fmt.Printf("Không thể cập nhật trạng thái file %s: %v\n", backup.Name, err)
+		if err := recordUpload(backupID, backup.ProfileID, dateFolder, backup.Name, webLink); err != nil {
+			backupLogger.Warn("Không thể cập nhật trạng thái upload", "error", err)
 		}
 	}
 
@@ -798,6 +857,55 @@ func (d *DriveUploader) UploadAllBackups() error {
 	return nil
 }
 
+// uploadTarget là thư mục Drive đích của backup thuộc một profile khi upload hàng loạt
+type uploadTarget struct {
+	uploader    *DriveUploader
+	rootID      string
+	dateFolders map[string]string
+	err         error
+}
+
+// uploadTargetFor khởi tạo uploader và thư mục gốc trên Drive cho backup của profile.
+// Backup không gắn profile hoặc profile đã bị xóa dùng cấu hình chung
+func (d *DriveUploader) uploadTargetFor(profileID int64) *uploadTarget {
+	target := &uploadTarget{uploader: d, dateFolders: make(map[string]string)}
+	if profileID > 0 {
+		if profile, err := database.GetProfileByID(profileID); err == nil {
+			target.uploader = d.ForProfile(*profile)
+		}
+	}
+
+	// Kiểm tra các vấn đề cấu hình
+	if configIssues := target.uploader.CheckDriveConfig(); len(configIssues) > 0 {
+		errorMessages := []string{}
+		for key, issue := range configIssues {
+			errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", key, issue))
+		}
+		target.err = fmt.Errorf("Có vấn đề với cấu hình Google Drive: %s", strings.Join(errorMessages, "; "))
+		return target
+	}
+	if err := target.uploader.ensureService(); err != nil {
+		target.err = err
+		return target
+	}
+	target.rootID, target.err = target.uploader.createOrFindFolder(target.uploader.Config.FolderDrive, "")
+	if target.err != nil {
+		target.err = fmt.Errorf("không thể tạo folder gốc: %v", target.err)
+	}
+	return target
+}
+
+// recordUpload lưu bản sao trên Drive của backup vào database
+func recordUpload(backupID, profileID int64, dateFolder, fileName, webLink string) error {
+	return backupdb.RecordUpload(backupdb.BackupUpload{
+		BackupID:    backupID,
+		ProfileID:   profileID,
+		Destination: models.DestinationDrive,
+		Key:         dateFolder + "/" + fileName,
+		Location:    webLink,
+	})
+}
+
 // Thêm struct UploadResult để trả về kết quả upload
 type UploadResult struct {
 	Success  bool   `json:"success"`
@@ -806,3 +914,159 @@ type UploadResult struct {
 	WebLink  string `json:"web_link,omitempty"`
 	Message  string `json:"message"`
 }
+
+// RemoteFile là thông tin một file backup trên Drive
+type RemoteFile struct {
+	ID      string
+	Key     string // Đường dẫn tương đối dạng <thư mục ngày>/<tên file>
+	Size    int64
+	ModTime time.Time
+	WebLink string
+}
+
+// ensureService khởi tạo Drive service nếu chưa có
+func (d *DriveUploader) ensureService() error {
+	if d.service != nil {
+		return nil
+	}
+	if err := d.Init(); err != nil {
+		return fmt.Errorf("không thể khởi tạo Google Drive service: %v", err)
+	}
+	return nil
+}
+
+// ListFiles liệt kê các file backup trong thư mục gốc trên Drive,
+// chỉ trả về các file có key bắt đầu bằng prefix
+func (d *DriveUploader) ListFiles(ctx context.Context, prefix string) ([]RemoteFile, error) {
+	if err := d.ensureService(); err != nil {
+		return nil, err
+	}
+
+	rootID, err := d.findFolder(d.Config.FolderDrive, "")
+	if err != nil || rootID == "" {
+		return []RemoteFile{}, err
+	}
+
+	folders, err := d.listChildren(ctx, rootID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []RemoteFile{}
+	for _, folder := range folders {
+		if !strings.HasPrefix(folder.Name+"/", prefix) && !strings.HasPrefix(prefix, folder.Name+"/") {
+			continue
+		}
+		children, err := d.listChildren(ctx, folder.Id, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range children {
+			key := folder.Name + "/" + f.Name
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			files = append(files, toRemoteFile(f, key))
+		}
+	}
+
+	return files, nil
+}
+
+// FindFile tìm file trên Drive theo key <thư mục ngày>/<tên file>, trả về nil nếu không có
+func (d *DriveUploader) FindFile(ctx context.Context, key string) (*RemoteFile, error) {
+	if err := d.ensureService(); err != nil {
+		return nil, err
+	}
+
+	dateFolder, fileName := filepath.Split(key)
+	dateFolder = strings.TrimSuffix(dateFolder, "/")
+
+	rootID, err := d.findFolder(d.Config.FolderDrive, "")
+	if err != nil || rootID == "" {
+		return nil, err
+	}
+	dateFolderID, err := d.findFolder(dateFolder, rootID)
+	if err != nil || dateFolderID == "" {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("name='%s' and '%s' in parents and trashed=false", fileName, dateFolderID)
+	r, err := d.service.Files.List().Q(query).Fields("files(id, name, size, modifiedTime)").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("không thể tìm file trên Drive: %v", err)
+	}
+	if len(r.Files) == 0 {
+		return nil, nil
+	}
+
+	file := toRemoteFile(r.Files[0], key)
+	return &file, nil
+}
+
+// DownloadFile tải nội dung file có ID fileID trên Drive và ghi vào w
+func (d *DriveUploader) DownloadFile(ctx context.Context, fileID string, w io.Writer) error {
+	if err := d.ensureService(); err != nil {
+		return err
+	}
+
+	resp, err := d.service.Files.Get(fileID).Context(ctx).Download()
+	if err != nil {
+		return fmt.Errorf("không thể tải file từ Drive: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("lỗi khi tải file từ Drive: %v", err)
+	}
+	return nil
+}
+
+// DeleteFile xóa file có ID fileID trên Drive, trả về false nếu file không tồn tại
+func (d *DriveUploader) DeleteFile(ctx context.Context, fileID string) (bool, error) {
+	if err := d.ensureService(); err != nil {
+		return false, err
+	}
+
+	if err := d.service.Files.Delete(fileID).Context(ctx).Do(); err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return false, nil
+		}
+		return false, fmt.Errorf("không thể xóa file trên Drive: %v", err)
+	}
+	return true, nil
+}
+
+// listChildren liệt kê toàn bộ thư mục con (folders = true) hoặc file trong thư mục parentID
+func (d *DriveUploader) listChildren(ctx context.Context, parentID string, folders bool) ([]*drive.File, error) {
+	query := fmt.Sprintf("'%s' in parents and trashed=false and mimeType", parentID)
+	if folders {
+		query += "='application/vnd.google-apps.folder'"
+	} else {
+		query += "!='application/vnd.google-apps.folder'"
+	}
+
+	var result []*drive.File
+	call := d.service.Files.List().Q(query).
+		Fields("nextPageToken, files(id, name, size, modifiedTime)").
+		PageSize(1000)
+	err := call.Pages(ctx, func(page *drive.FileList) error {
+		result = append(result, page.Files...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("không thể liệt kê file trên Drive: %v", err)
+	}
+	return result, nil
+}
+
+func toRemoteFile(f *drive.File, key string) RemoteFile {
+	modTime, _ := time.Parse(time.RFC3339, f.ModifiedTime)
+	return RemoteFile{
+		ID:      f.Id,
+		Key:     key,
+		Size:    f.Size,
+		ModTime: modTime,
+		WebLink: fmt.Sprintf("https://drive.google.com/file/d/%s/view", f.Id),
+	}
+}
@@ -0,0 +1,191 @@
+package encryption
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Các chế độ mã hóa được hỗ trợ
+const (
+	None       = "none"
+	Age        = "age"        // Mã hóa bằng khóa công khai age (X25519)
+	Passphrase = "passphrase" // Mã hóa AES-256-GCM với khóa sinh từ passphrase (scrypt)
+)
+
+// MinPassphraseLength là độ dài tối thiểu của passphrase
+const MinPassphraseLength = 12
+
+// Keys chứa khóa dùng để mã hóa
+type Keys struct {
+	Recipients string // Danh sách khóa công khai age, mỗi khóa một dòng hoặc cách nhau bởi dấu phẩy
+	Passphrase string
+}
+
+// DecryptKeys chứa các khóa có thể dùng để giải mã, các khóa được thử lần lượt
+type DecryptKeys struct {
+	Identities  string   // Nội dung file identity của age (AGE-SECRET-KEY-...)
+	Passphrases []string // Các passphrase ứng viên
+}
+
+// AddPassphrase thêm passphrase ứng viên nếu chưa có trong danh sách
+func (k *DecryptKeys) AddPassphrase(passphrase string) {
+	if passphrase == "" {
+		return
+	}
+	for _, p := range k.Passphrases {
+		if p == passphrase {
+			return
+		}
+	}
+	k.Passphrases = append(k.Passphrases, passphrase)
+}
+
+// Modes trả về danh sách chế độ mã hóa hợp lệ
+func Modes() []string {
+	return []string{None, Age, Passphrase}
+}
+
+// IsValid kiểm tra chế độ mã hóa có được hỗ trợ không (chuỗi rỗng tương đương none)
+func IsValid(mode string) bool {
+	switch mode {
+	case "", None, Age, Passphrase:
+		return true
+	}
+	return false
+}
+
+// Normalize chuẩn hóa tên chế độ mã hóa, chuỗi rỗng trở thành none
+func Normalize(mode string) string {
+	if mode == "" {
+		return None
+	}
+	return mode
+}
+
+// IsEncrypted cho biết chế độ có mã hóa dữ liệu hay không
+func IsEncrypted(mode string) bool {
+	return Normalize(mode) != None
+}
+
+// Extension trả về phần mở rộng file tương ứng với chế độ mã hóa
+func Extension(mode string) string {
+	switch mode {
+	case Age:
+		return ".age"
+	case Passphrase:
+		return ".enc"
+	}
+	return ""
+}
+
+// FromFileName xác định chế độ mã hóa dựa vào phần mở rộng của tên file
+func FromFileName(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".age"):
+		return Age
+	case strings.HasSuffix(name, ".enc"):
+		return Passphrase
+	}
+	return None
+}
+
+// TrimExtension bỏ phần mở rộng mã hóa khỏi tên file
+func TrimExtension(name, mode string) string {
+	return strings.TrimSuffix(name, Extension(mode))
+}
+
+// ValidateKeys kiểm tra khóa mã hóa có phù hợp với chế độ không
+func ValidateKeys(mode string, keys Keys) error {
+	switch Normalize(mode) {
+	case None:
+		return nil
+	case Age:
+		_, err := parseRecipients(keys.Recipients)
+		return err
+	case Passphrase:
+		if len(keys.Passphrase) < MinPassphraseLength {
+			return fmt.Errorf("passphrase mã hóa phải có ít nhất %d ký tự", MinPassphraseLength)
+		}
+		return nil
+	}
+	return fmt.Errorf("chế độ mã hóa không được hỗ trợ: %s", mode)
+}
+
+// NewWriter tạo writer mã hóa dữ liệu ghi vào w. Với none, trả về writer ghi thẳng vào w.
+// Close phải được gọi để ghi phần cuối của dữ liệu mã hóa, Close không đóng w
+func NewWriter(w io.Writer, mode string, keys Keys) (io.WriteCloser, error) {
+	if err := ValidateKeys(mode, keys); err != nil {
+		return nil, err
+	}
+
+	switch Normalize(mode) {
+	case None:
+		return nopWriteCloser{w}, nil
+	case Age:
+		recipients, err := parseRecipients(keys.Recipients)
+		if err != nil {
+			return nil, err
+		}
+		return age.Encrypt(w, recipients...)
+	case Passphrase:
+		return newPassphraseWriter(w, keys.Passphrase)
+	}
+	return nil, fmt.Errorf("chế độ mã hóa không được hỗ trợ: %s", mode)
+}
+
+// NewReader tạo reader giải mã dữ liệu đọc từ r. Với none, trả về r
+func NewReader(r io.Reader, mode string, keys DecryptKeys) (io.Reader, error) {
+	switch Normalize(mode) {
+	case None:
+		return r, nil
+	case Age:
+		if strings.TrimSpace(keys.Identities) == "" {
+			return nil, fmt.Errorf("chưa cấu hình age identity để giải mã backup (ENCRYPTION_AGE_IDENTITY)")
+		}
+		identities, err := age.ParseIdentities(strings.NewReader(keys.Identities))
+		if err != nil {
+			return nil, fmt.Errorf("age identity không hợp lệ: %v", err)
+		}
+		reader, err := age.Decrypt(r, identities...)
+		if err != nil {
+			return nil, fmt.Errorf("không thể giải mã backup bằng age identity đã cấu hình: %v", err)
+		}
+		return reader, nil
+	case Passphrase:
+		if len(keys.Passphrases) == 0 {
+			return nil, fmt.Errorf("không có passphrase để giải mã backup")
+		}
+		return newPassphraseReader(r, keys.Passphrases)
+	}
+	return nil, fmt.Errorf("chế độ mã hóa không được hỗ trợ: %s", mode)
+}
+
+// parseRecipients đọc danh sách khóa công khai age, bỏ qua dòng trống và dòng chú thích
+func parseRecipients(value string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, line := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		recipient, err := age.ParseX25519Recipient(line)
+		if err != nil {
+			return nil, fmt.Errorf("khóa công khai age không hợp lệ '%s': %v", line, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("cần ít nhất một khóa công khai age (age1...) để mã hóa")
+	}
+	return recipients, nil
+}
+
+// nopWriteCloser bọc một io.Writer với Close không làm gì
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,209 @@
+package encryption
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+const testPassphrase = "correct horse battery"
+
+// testData tạo dữ liệu có kích thước size với nội dung thay đổi theo vị trí
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+// encrypt mã hóa data theo chế độ mode
+func encrypt(t *testing.T, data []byte, mode string, keys Keys) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, mode, keys)
+	if err != nil {
+		t.Fatalf("NewWriter(%s): %v", mode, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// decrypt giải mã toàn bộ dữ liệu, trả về lỗi của bước mở hoặc đọc
+func decrypt(data []byte, mode string, keys DecryptKeys) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(data), mode, keys)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// testIdentity tạo cặp khóa age mới
+func testIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return identity
+}
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	// Các kích thước quanh ranh giới chunk để kiểm tra đánh dấu chunk cuối
+	sizes := []int{0, 1, 1000, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 17}
+	for _, size := range sizes {
+		data := testData(size)
+		encrypted := encrypt(t, data, Passphrase, Keys{Passphrase: testPassphrase})
+		if size > 0 && bytes.Contains(encrypted, data[:min(size, 64)]) {
+			t.Errorf("%d byte: dữ liệu mã hóa chứa dữ liệu gốc", size)
+		}
+		got, err := decrypt(encrypted, Passphrase, DecryptKeys{Passphrases: []string{testPassphrase}})
+		if err != nil {
+			t.Errorf("%d byte: giải mã: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d byte: dữ liệu giải mã khác dữ liệu gốc", size)
+		}
+	}
+}
+
+func TestPassphraseCandidates(t *testing.T) {
+	data := testData(2*chunkSize + 5)
+	encrypted := encrypt(t, data, Passphrase, Keys{Passphrase: testPassphrase})
+
+	// Passphrase đúng không đứng đầu danh sách ứng viên
+	keys := DecryptKeys{}
+	keys.AddPassphrase("old passphrase!!")
+	keys.AddPassphrase(testPassphrase)
+	keys.AddPassphrase(testPassphrase)
+	if len(keys.Passphrases) != 2 {
+		t.Errorf("AddPassphrase không bỏ trùng: %q", keys.Passphrases)
+	}
+	got, err := decrypt(encrypted, Passphrase, keys)
+	if err != nil {
+		t.Fatalf("giải mã với danh sách ứng viên: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("dữ liệu giải mã khác dữ liệu gốc")
+	}
+}
+
+func TestPassphraseWrongKey(t *testing.T) {
+	encrypted := encrypt(t, testData(1000), Passphrase, Keys{Passphrase: testPassphrase})
+
+	if _, err := decrypt(encrypted, Passphrase, DecryptKeys{Passphrases: []string{"wrong passphrase!!"}}); err != errWrongPassphrase {
+		t.Errorf("passphrase sai: err = %v, muốn errWrongPassphrase", err)
+	}
+	if _, err := decrypt(encrypted, Passphrase, DecryptKeys{}); err == nil {
+		t.Error("không có passphrase phải trả về lỗi")
+	}
+	// File không phải định dạng mã hóa bằng passphrase
+	if _, err := decrypt([]byte(strings.Repeat("x", 100)), Passphrase, DecryptKeys{Passphrases: []string{testPassphrase}}); err == nil {
+		t.Error("file không đúng định dạng phải trả về lỗi")
+	}
+}
+
+func TestPassphraseTruncatedOrTampered(t *testing.T) {
+	data := testData(2*chunkSize + 5)
+	encrypted := encrypt(t, data, Passphrase, Keys{Passphrase: testPassphrase})
+	header := len(passphraseMagic) + saltSize + noncePrefixSize
+	sealedChunk := chunkSize + 16
+
+	tampered := bytes.Clone(encrypted)
+	tampered[header+sealedChunk+100] ^= 1
+
+	tests := map[string][]byte{
+		"chỉ còn một phần header":     encrypted[:header-3],
+		"chỉ còn header":              encrypted[:header],
+		"cắt giữa chunk":              encrypted[:header+sealedChunk+100],
+		"mất chunk cuối":              encrypted[:header+2*sealedChunk],
+		"mất byte cuối":               encrypted[:len(encrypted)-1],
+		"chunk giữa bị sửa":           tampered,
+		"thêm dữ liệu sau chunk cuối": append(bytes.Clone(encrypted), encrypted[header:header+sealedChunk]...),
+	}
+	for name, input := range tests {
+		got, err := decrypt(input, Passphrase, DecryptKeys{Passphrases: []string{testPassphrase}})
+		if err == nil {
+			t.Errorf("%s: giải mã được %d byte, muốn lỗi", name, len(got))
+		}
+	}
+}
+
+func TestAgeRoundTrip(t *testing.T) {
+	identity := testIdentity(t)
+	other := testIdentity(t)
+	data := testData(3*chunkSize + 17)
+
+	// Mã hóa cho hai người nhận, mỗi identity đều giải mã được
+	recipients := identity.Recipient().String() + "\n# khóa dự phòng\n" + other.Recipient().String()
+	encrypted := encrypt(t, data, Age, Keys{Recipients: recipients})
+	for _, id := range []*age.X25519Identity{identity, other} {
+		got, err := decrypt(encrypted, Age, DecryptKeys{Identities: id.String()})
+		if err != nil {
+			t.Fatalf("giải mã bằng age: %v", err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Error("dữ liệu giải mã khác dữ liệu gốc")
+		}
+	}
+}
+
+func TestAgeWrongKey(t *testing.T) {
+	identity := testIdentity(t)
+	encrypted := encrypt(t, testData(1000), Age, Keys{Recipients: identity.Recipient().String()})
+
+	if _, err := decrypt(encrypted, Age, DecryptKeys{Identities: testIdentity(t).String()}); err == nil {
+		t.Error("identity khác phải trả về lỗi")
+	}
+	if _, err := decrypt(encrypted, Age, DecryptKeys{}); err == nil {
+		t.Error("không có identity phải trả về lỗi")
+	}
+	if _, err := decrypt(encrypted, Age, DecryptKeys{Identities: "AGE-SECRET-KEY-INVALID"}); err == nil {
+		t.Error("identity không hợp lệ phải trả về lỗi")
+	}
+}
+
+func TestAgeTruncated(t *testing.T) {
+	identity := testIdentity(t)
+	encrypted := encrypt(t, testData(3*chunkSize+17), Age, Keys{Recipients: identity.Recipient().String()})
+	keys := DecryptKeys{Identities: identity.String()}
+
+	for _, size := range []int{50, len(encrypted) / 2, len(encrypted) - 1} {
+		got, err := decrypt(encrypted[:size], Age, keys)
+		if err == nil {
+			t.Errorf("cắt còn %d/%d byte: giải mã được %d byte, muốn lỗi", size, len(encrypted), len(got))
+		}
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	identity := testIdentity(t)
+	tests := []struct {
+		name  string
+		mode  string
+		keys  Keys
+		valid bool
+	}{
+		{"không mã hóa", None, Keys{}, true},
+		{"passphrase đủ dài", Passphrase, Keys{Passphrase: testPassphrase}, true},
+		{"passphrase quá ngắn", Passphrase, Keys{Passphrase: "short"}, false},
+		{"age hợp lệ", Age, Keys{Recipients: identity.Recipient().String()}, true},
+		{"age không có người nhận", Age, Keys{Recipients: "# chỉ có chú thích"}, false},
+		{"age sai khóa", Age, Keys{Recipients: "age1invalid"}, false},
+		{"chế độ không hỗ trợ", "rot13", Keys{}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateKeys(tt.mode, tt.keys); (err == nil) != tt.valid {
+			t.Errorf("%s: ValidateKeys = %v, hợp lệ muốn %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Định dạng file mã hóa bằng passphrase:
+//
+//	header: magic (8 byte) | salt (16 byte) | nonce prefix (7 byte)
+//	dữ liệu: các chunk AES-256-GCM, mỗi chunk tối đa 64 KiB dữ liệu gốc
+//
+// Nonce của mỗi chunk = nonce prefix | số thứ tự chunk (4 byte) | cờ chunk cuối (1 byte),
+// nhờ đó file bị cắt cụt hoặc bị đảo thứ tự chunk sẽ không giải mã được
+const (
+	passphraseMagic  = "GDPENC1\n"
+	saltSize         = 16
+	noncePrefixSize  = 7
+	chunkSize        = 64 * 1024
+	scryptN          = 1 << 15
+	scryptR          = 8
+	scryptP          = 1
+	passphraseKeyLen = 32
+)
+
+var errWrongPassphrase = errors.New("passphrase không đúng hoặc file mã hóa bị hỏng")
+
+// deriveKey sinh khóa AES-256-GCM từ passphrase và salt
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, passphraseKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("không thể sinh khóa từ passphrase: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce tạo nonce cho chunk thứ counter
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// passphraseWriter mã hóa dữ liệu theo từng chunk
+type passphraseWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+func newPassphraseWriter(w io.Writer, passphrase string) (*passphraseWriter, error) {
+	header := make([]byte, len(passphraseMagic)+saltSize+noncePrefixSize)
+	copy(header, passphraseMagic)
+	if _, err := rand.Read(header[len(passphraseMagic):]); err != nil {
+		return nil, fmt.Errorf("không thể sinh salt ngẫu nhiên: %v", err)
+	}
+	salt := header[len(passphraseMagic) : len(passphraseMagic)+saltSize]
+	prefix := header[len(passphraseMagic)+saltSize:]
+
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &passphraseWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Write gom dữ liệu thành chunk, chunk đầy chỉ được ghi khi có thêm dữ liệu phía sau
+// để chunk cuối cùng luôn được đánh dấu khi Close
+func (pw *passphraseWriter) Write(p []byte) (int, error) {
+	if pw.closed {
+		return 0, errors.New("writer mã hóa đã đóng")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(pw.buf) == chunkSize {
+			if err := pw.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(pw.buf[len(pw.buf):chunkSize], p)
+		pw.buf = pw.buf[:len(pw.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close ghi chunk cuối cùng, không đóng writer gốc
+func (pw *passphraseWriter) Close() error {
+	if pw.closed {
+		return nil
+	}
+	pw.closed = true
+	return pw.flush(true)
+}
+
+func (pw *passphraseWriter) flush(last bool) error {
+	sealed := pw.aead.Seal(nil, chunkNonce(pw.prefix, pw.counter, last), pw.buf, nil)
+	pw.counter++
+	pw.buf = pw.buf[:0]
+	_, err := pw.w.Write(sealed)
+	return err
+}
+
+// passphraseReader giải mã dữ liệu theo từng chunk
+type passphraseReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+// newPassphraseReader đọc header, thử lần lượt các passphrase trên chunk đầu tiên
+func newPassphraseReader(r io.Reader, passphrases []string) (*passphraseReader, error) {
+	br := bufio.NewReaderSize(r, chunkSize+64)
+
+	header := make([]byte, len(passphraseMagic)+saltSize+noncePrefixSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("không thể đọc header file mã hóa: %v", err)
+	}
+	if !bytes.Equal(header[:len(passphraseMagic)], []byte(passphraseMagic)) {
+		return nil, errors.New("file không phải định dạng mã hóa bằng passphrase")
+	}
+	salt := header[len(passphraseMagic) : len(passphraseMagic)+saltSize]
+	prefix := header[len(passphraseMagic)+saltSize:]
+
+	pr := &passphraseReader{r: br, prefix: prefix}
+	sealed, last, err := pr.readSealed()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, passphrase := range passphrases {
+		aead, err := deriveKey(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		plain, err := aead.Open(nil, chunkNonce(prefix, 0, last), sealed, nil)
+		if err != nil {
+			continue
+		}
+		pr.aead = aead
+		pr.plain = plain
+		pr.counter = 1
+		pr.done = last
+		return pr, nil
+	}
+
+	return nil, errWrongPassphrase
+}
+
+// readSealed đọc một chunk đã mã hóa và cho biết đó có phải chunk cuối không
+func (pr *passphraseReader) readSealed() ([]byte, bool, error) {
+	sealed := make([]byte, chunkSize+16)
+	n, err := io.ReadFull(pr.r, sealed)
+	switch err {
+	case nil:
+		// Chunk đầy, là chunk cuối nếu không còn dữ liệu phía sau
+		_, peekErr := pr.r.Peek(1)
+		return sealed, peekErr == io.EOF, nil
+	case io.ErrUnexpectedEOF:
+		return sealed[:n], true, nil
+	case io.EOF:
+		return nil, false, errors.New("file mã hóa bị cắt cụt")
+	}
+	return nil, false, err
+}
+
+func (pr *passphraseReader) Read(p []byte) (int, error) {
+	for len(pr.plain) == 0 {
+		if pr.done {
+			return 0, io.EOF
+		}
+		sealed, last, err := pr.readSealed()
+		if err != nil {
+			return 0, err
+		}
+		plain, err := pr.aead.Open(sealed[:0], chunkNonce(pr.prefix, pr.counter, last), sealed, nil)
+		if err != nil {
+			return 0, errWrongPassphrase
+		}
+		pr.counter++
+		pr.plain = plain
+		pr.done = last
+	}
+
+	n := copy(p, pr.plain)
+	pr.plain = pr.plain[n:]
+	return n, nil
+}
@@ -0,0 +1,195 @@
+package events
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Các loại sự kiện của job backup
+const (
+	TypeQueued   = "queued"   // Job được đưa vào hàng đợi
+	TypeStarted  = "started"  // Job bắt đầu chạy
+	TypeLog      = "log"      // Một dòng stderr của pg_dump -v
+	TypeProgress = "progress" // Số byte dump đã ghi
+	TypeUpload   = "upload"   // Tiến độ upload lên một destination
+	TypeFinished = "finished" // Job kết thúc (success, skipped, cancelled, timeout)
+	TypeFailed   = "failed"   // Job thất bại
+)
+
+// progressInterval là khoảng thời gian tối thiểu giữa hai sự kiện tiến độ của cùng một job
+const progressInterval = time.Second
+
+// subscriberBuffer là số sự kiện tối đa chờ gửi cho mỗi subscriber, subscriber chậm sẽ bị bỏ qua sự kiện
+const subscriberBuffer = 256
+
+// Event là một sự kiện trong vòng đời của job backup
+type Event struct {
+	Type        string    `json:"type"`
+	JobID       int64     `json:"job_id,omitempty"`
+	ProfileID   int64     `json:"profile_id,omitempty"`
+	Status      string    `json:"status,omitempty"`      // Trạng thái job_logs khi kết thúc
+	Message     string    `json:"message,omitempty"`     // Thông báo hoặc dòng log
+	Bytes       int64     `json:"bytes,omitempty"`       // Số byte đã dump/upload
+	Total       int64     `json:"total,omitempty"`       // Tổng số byte cần upload
+	Percent     float64   `json:"percent,omitempty"`     // Phần trăm upload
+	Destination string    `json:"destination,omitempty"` // Destination đang upload
+	Time        time.Time `json:"time"`
+}
+
+// Bus phát sự kiện đến các subscriber
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewBus tạo event bus mới
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe đăng ký nhận sự kiện, hàm trả về dùng để hủy đăng ký
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish gửi sự kiện đến tất cả subscriber, không chờ subscriber chậm
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Default là event bus dùng chung cho ứng dụng
+var Default = NewBus()
+
+// Publish gửi sự kiện qua event bus dùng chung
+func Publish(event Event) {
+	Default.Publish(event)
+}
+
+// Subscribe đăng ký nhận sự kiện từ event bus dùng chung
+func Subscribe() (<-chan Event, func()) {
+	return Default.Subscribe()
+}
+
+type jobKey struct{}
+
+type jobInfo struct {
+	jobID     int64
+	profileID int64
+}
+
+// WithJob gắn job vào context để các bước bên dưới (dump, upload) phát sự kiện của job
+func WithJob(ctx context.Context, jobID, profileID int64) context.Context {
+	return context.WithValue(ctx, jobKey{}, jobInfo{jobID: jobID, profileID: profileID})
+}
+
+// JobID trả về ID job (job_log) gắn trong ctx, 0 nếu ctx không thuộc job nào
+func JobID(ctx context.Context) int64 {
+	info, _ := ctx.Value(jobKey{}).(jobInfo)
+	return info.jobID
+}
+
+// ProfileID trả về ID profile của job gắn trong ctx, 0 nếu ctx không thuộc job nào
+func ProfileID(ctx context.Context) int64 {
+	info, _ := ctx.Value(jobKey{}).(jobInfo)
+	return info.profileID
+}
+
+// Emit phát sự kiện của job gắn trong ctx, bỏ qua nếu ctx không thuộc job nào
+func Emit(ctx context.Context, event Event) {
+	info, ok := ctx.Value(jobKey{}).(jobInfo)
+	if !ok {
+		return
+	}
+	event.JobID = info.jobID
+	event.ProfileID = info.profileID
+	Publish(event)
+}
+
+// ProgressWriter đếm số byte ghi qua W và phát sự kiện progress của job trong ctx
+type ProgressWriter struct {
+	W     io.Writer
+	Ctx   context.Context
+	Count int64
+	last  time.Time
+}
+
+// Write ghi dữ liệu và phát sự kiện tiến độ tối đa mỗi giây một lần
+func (p *ProgressWriter) Write(b []byte) (int, error) {
+	n, err := p.W.Write(b)
+	p.Count += int64(n)
+	if time.Since(p.last) >= progressInterval {
+		p.last = time.Now()
+		Emit(p.Ctx, Event{Type: TypeProgress, Bytes: p.Count})
+	}
+	return n, err
+}
+
+// Flush phát sự kiện tiến độ cuối cùng
+func (p *ProgressWriter) Flush() {
+	Emit(p.Ctx, Event{Type: TypeProgress, Bytes: p.Count})
+}
+
+// ProgressReader đếm số byte đọc từ R và phát sự kiện upload của job trong ctx.
+// Nếu R là nil, Read chỉ đếm len(b), dùng làm PutObjectOptions.Progress của minio
+type ProgressReader struct {
+	R           io.Reader
+	Ctx         context.Context
+	Destination string
+	Total       int64
+	Count       int64 // Số byte đã upload trước đó (upload tiếp tục) cộng số byte đã đọc
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Read đọc dữ liệu và phát sự kiện tiến độ upload tối đa mỗi giây một lần, và khi upload xong
+func (p *ProgressReader) Read(b []byte) (int, error) {
+	n, err := len(b), error(nil)
+	if p.R != nil {
+		n, err = p.R.Read(b)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Count += int64(n)
+	// Sự kiện cuối cùng khi đọc đến byte cuối (hoặc đến EOF nếu không biết tổng kích thước)
+	done := (p.Total > 0 && n > 0 && p.Count >= p.Total) || (p.Total <= 0 && err == io.EOF)
+	if time.Since(p.last) >= progressInterval || done {
+		p.last = time.Now()
+		event := Event{Type: TypeUpload, Destination: p.Destination, Bytes: p.Count, Total: p.Total}
+		if p.Total > 0 {
+			event.Percent = float64(p.Count) * 100 / float64(p.Total)
+		}
+		Emit(p.Ctx, event)
+	}
+	return n, err
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/audit"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// recordAudit ghi hành động của người dùng đang đăng nhập vào audit log.
+// err khác nil đánh dấu hành động thất bại và được dùng làm thông báo
+func (h *Handler) recordAudit(c *gin.Context, event models.AuditEvent, err error) {
+	audit.Record(audit.WithResult(auditEvent(c, event), err))
+}
+
+// auditEvent điền người thực hiện và IP của request vào sự kiện audit,
+// dùng khi kết quả chỉ có sau khi request đã trả về (tác vụ chạy nền)
+func auditEvent(c *gin.Context, event models.AuditEvent) models.AuditEvent {
+	if event.Actor == "" {
+		event.Actor = c.GetString("username")
+	}
+	if event.Actor == "" {
+		event.Actor = "anonymous"
+	}
+	event.ActorType = models.AuditActorUser
+	if c.GetString("auth_source") == "api_token" {
+		event.ActorType = models.AuditActorAPIToken
+	}
+	event.IP = c.ClientIP()
+	return event
+}
+
+// uploadError chuyển kết quả upload (thành công, thông báo) thành error để ghi audit log
+func uploadError(success bool, message string) error {
+	if success {
+		return nil
+	}
+	return errors.New(message)
+}
+
+// GetAuditHandler trả về audit log, mới nhất trước. Lọc theo ?actor=, ?action= (chính xác hoặc
+// theo nhóm như "backup"), ?target_type=, ?target_id=, ?result=, ?since=, ?until= (RFC3339),
+// ?limit= (mặc định 100) và ?offset=. ?format=csv xuất toàn bộ kết quả lọc ra file CSV
+func (h *Handler) GetAuditHandler(c *gin.Context) {
+	filter := models.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Result:     c.Query("result"),
+		Limit:      100,
+	}
+
+	times := []struct {
+		param  string
+		target *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	}
+	for _, t := range times {
+		value := c.Query(t.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Giá trị %s không hợp lệ, dùng RFC3339 (2024-01-02T15:04:05Z)", t.param),
+			})
+			return
+		}
+		*t.target = parsed
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			filter.Limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o > 0 {
+			filter.Offset = o
+		}
+	}
+
+	csvExport := c.Query("format") == "csv"
+	if csvExport && c.Query("limit") == "" {
+		filter.Limit = 0
+	}
+
+	events, err := database.GetAuditEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể lấy audit log: %v", err),
+		})
+		return
+	}
+
+	if csvExport {
+		writeAuditCSV(c, events)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"events":  events,
+		"count":   len(events),
+	})
+}
+
+// writeAuditCSV trả audit log về client dưới dạng file CSV
+func writeAuditCSV(c *gin.Context, events []models.AuditEvent) {
+	filename := fmt.Sprintf("audit_%s.csv", time.Now().Format("20060102_150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "created_at", "actor", "actor_type", "action", "target_type", "target_id", "result", "ip", "message", "changes"})
+	for _, event := range events {
+		changes := ""
+		if len(event.Changes) > 0 {
+			data, _ := json.Marshal(event.Changes)
+			changes = string(data)
+		}
+		writer.Write(csvSafeRow(
+			strconv.FormatInt(event.ID, 10),
+			event.CreatedAt.Format(time.RFC3339),
+			event.Actor,
+			event.ActorType,
+			event.Action,
+			event.TargetType,
+			event.TargetID,
+			event.Result,
+			event.IP,
+			event.Message,
+			changes,
+		))
+	}
+	writer.Flush()
+}
+
+// csvSafeRow thêm dấu ' trước các ô bắt đầu bằng =, +, -, @ (hoặc tab, CR) để Excel và
+// các ứng dụng bảng tính không hiểu giá trị do người dùng nhập là công thức
+func csvSafeRow(cells ...string) []string {
+	for i, cell := range cells {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cells[i] = "'" + cell
+		}
+	}
+	return cells
+}
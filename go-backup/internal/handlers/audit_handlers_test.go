@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteAuditCSVEscapesFormulas(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+
+	writeAuditCSV(c, []models.AuditEvent{{
+		ID:        1,
+		CreatedAt: time.Now(),
+		Actor:     "=HYPERLINK(\"http://evil\")",
+		Action:    "config.update",
+		TargetID:  "+cmd",
+		Result:    "success",
+		Message:   "-2+3",
+		IP:        "@SUM(A1)",
+	}})
+
+	records, err := csv.NewReader(strings.NewReader(recorder.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("số dòng CSV = %d, muốn 2", len(records))
+	}
+	row := records[1]
+	want := map[int]string{
+		0: "1",
+		2: "'=HYPERLINK(\"http://evil\")",
+		4: "config.update",
+		6: "'+cmd",
+		8: "'@SUM(A1)",
+		9: "'-2+3",
+	}
+	for i, value := range want {
+		if row[i] != value {
+			t.Errorf("cột %s = %q, muốn %q", records[0][i], row[i], value)
+		}
+	}
+}
@@ -27,17 +27,19 @@ func (h *Handler) LoginHandler(c *gin.Context) {
 		return
 	}
 
-	// Authenticate using the AuthenticateUser function which checks both database and config
+	// Authenticate using the AuthenticateUser function which checks the users table
 	user, err := auth.AuthenticateUser(&models.Auth{
 		Username: loginData.Username,
 		Password: loginData.Password,
 	})
 
+	loginEvent := models.AuditEvent{Actor: loginData.Username, Action: "auth.login", TargetType: "user", TargetID: loginData.Username}
+	h.recordAudit(c, loginEvent, err)
 	if err != nil {
 		log.Printf("Đăng nhập thất bại: %v", err)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"success": false,
-			"message": "Tên đăng nhập hoặc mật khẩu không chính xác",
+			"message": err.Error(),
 		})
 		return
 	}
@@ -87,6 +89,7 @@ func (h *Handler) LoginHandler(c *gin.Context) {
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.Username,
+			"role":     user.Role,
 		},
 	})
 	log.Printf("User logged in successfully: %s", user.Username)
@@ -113,6 +116,7 @@ func (h *Handler) MeHandler(c *gin.Context) {
 		"user": gin.H{
 			"id":       userID,
 			"username": username,
+			"role":     c.GetString("role"),
 		},
 	})
 }
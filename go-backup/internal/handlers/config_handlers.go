@@ -1,11 +1,13 @@
 package handlers
 
 import (
-	"net/http"
-
+	"errors"
 	"log"
+	"net/http"
 
+	"github.com/backup-cronjob/internal/audit"
 	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/logger"
 	"github.com/backup-cronjob/internal/models"
 	"github.com/gin-gonic/gin"
 )
@@ -29,9 +31,9 @@ func (h *Handler) GetConfigsHandler(c *gin.Context) {
 			continue
 		}
 
-		// Ẩn giá trị của các trường nhạy cảm
-		if cfg.Type == "password" && cfg.Value != "" {
-			cfg.Value = "••••••••" // Ẩn giá trị thực
+		// Ẩn giá trị của các trường nhạy cảm (mật khẩu, khóa giải mã), client dùng is_set để biết đã đặt hay chưa
+		if (cfg.Type == "password" || models.IsSecretConfig(cfg.Key)) && cfg.Value != "" {
+			cfg.Value = models.MaskedValue // Ẩn giá trị thực
 		}
 		configsByGroup[cfg.Group] = append(configsByGroup[cfg.Group], cfg)
 	}
@@ -84,9 +86,9 @@ func (h *Handler) GetConfigsByGroupHandler(c *gin.Context) {
 			continue
 		}
 
-		// Ẩn giá trị của các trường nhạy cảm
-		if cfg.Type == "password" && cfg.Value != "" {
-			cfg.Value = "••••••••" // Ẩn giá trị thực
+		// Ẩn giá trị của các trường nhạy cảm (mật khẩu, khóa giải mã), client dùng is_set để biết đã đặt hay chưa
+		if (cfg.Type == "password" || models.IsSecretConfig(cfg.Key)) && cfg.Value != "" {
+			cfg.Value = models.MaskedValue // Ẩn giá trị thực
 		}
 
 		filteredConfigs = append(filteredConfigs, cfg)
@@ -114,19 +116,37 @@ func (h *Handler) UpdateConfigsHandler(c *gin.Context) {
 	// Ngăn chặn cập nhật JWT_SECRET
 	if _, exists := configUpdates["JWT_SECRET"]; exists {
 		delete(configUpdates, "JWT_SECRET")
-		log.Printf("WARNING: Nỗ lực cập nhật JWT_SECRET bị từ chối vì lý do bảo mật")
+		log.Printf("Cảnh báo: Nỗ lực cập nhật JWT_SECRET bị từ chối vì lý do bảo mật")
+		h.recordAudit(c, models.AuditEvent{Action: "config.update", TargetType: "config", TargetID: "JWT_SECRET"},
+			errors.New("Không được phép cập nhật JWT_SECRET"))
 	}
 
-	// Cập nhật từng cấu hình
+	// Cập nhật từng cấu hình, giá trị cũ được giữ lại để ghi thay đổi vào audit log.
+	// Chỉ ghi tên khóa ra log vì giá trị có thể là dữ liệu nhạy cảm
+	before := make(map[string]string)
+	after := make(map[string]string)
+	var updateErr error
 	for key, value := range configUpdates {
-		log.Printf("Cập nhật cấu hình '%s' = '%s'", key, value)
-		if err := database.UpdateConfig(key, value); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"success": false,
-				"error":   "Lỗi khi cập nhật cấu hình: " + err.Error(),
-			})
-			return
+		// Client gửi lại giá trị đã ẩn nghĩa là không thay đổi
+		if models.IsSecretConfig(key) && value == models.MaskedValue {
+			continue
+		}
+		oldValue, _ := database.GetConfigValue(key)
+		log.Printf("Cập nhật cấu hình '%s'", key)
+		if updateErr = database.UpdateConfig(key, value); updateErr != nil {
+			break
 		}
+		before[key], after[key] = oldValue, value
+	}
+
+	h.recordAudit(c, models.AuditEvent{Action: "config.update", TargetType: "config",
+		Changes: audit.Diff(before, after)}, updateErr)
+	if updateErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Lỗi khi cập nhật cấu hình: " + updateErr.Error(),
+		})
+		return
 	}
 
 	// Tải lại cấu hình cho ứng dụng
@@ -135,6 +155,9 @@ func (h *Handler) UpdateConfigsHandler(c *gin.Context) {
 	} else {
 		log.Printf("Đã tải lại cấu hình sau khi cập nhật thành công")
 	}
+	if err := logger.Configure(h.Config); err != nil {
+		log.Printf("Cảnh báo: %v", err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
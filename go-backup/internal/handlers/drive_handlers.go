@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// driveAuthStateTTL là thời gian hiệu lực của state xác thực Google từ trang profile
+const driveAuthStateTTL = 15 * time.Minute
+
+// driveAuthStates lưu state OAuth đã cấp cho từng profile để callback biết token thuộc profile nào.
+// State ngẫu nhiên chỉ được cấp cho admin nên callback công khai không thể ghi token của profile khác
+var driveAuthStates = struct {
+	sync.Mutex
+	states map[string]driveAuthState
+}{states: make(map[string]driveAuthState)}
+
+type driveAuthState struct {
+	profileID int64
+	expiresAt time.Time
+}
+
+// newDriveAuthState tạo state OAuth mới cho profile
+func newDriveAuthState(profileID int64) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	state := "profile-" + hex.EncodeToString(buf)
+
+	driveAuthStates.Lock()
+	defer driveAuthStates.Unlock()
+	now := time.Now()
+	for key, s := range driveAuthStates.states {
+		if now.After(s.expiresAt) {
+			delete(driveAuthStates.states, key)
+		}
+	}
+	driveAuthStates.states[state] = driveAuthState{profileID: profileID, expiresAt: now.Add(driveAuthStateTTL)}
+	return state, nil
+}
+
+// takeDriveAuthState lấy profile của state OAuth và hủy state, mỗi state chỉ dùng được một lần
+func takeDriveAuthState(state string) (int64, bool) {
+	if state == "" {
+		return 0, false
+	}
+	driveAuthStates.Lock()
+	defer driveAuthStates.Unlock()
+	s, ok := driveAuthStates.states[state]
+	if !ok {
+		return 0, false
+	}
+	delete(driveAuthStates.states, state)
+	if time.Now().After(s.expiresAt) {
+		return 0, false
+	}
+	return s.profileID, true
+}
+
+// profileDriveUploader lấy profile theo :id và DriveUploader dùng thư mục, tài khoản Google của profile
+func (h *Handler) profileDriveUploader(c *gin.Context) (*models.DatabaseProfile, *drive.DriveUploader, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID profile không hợp lệ",
+		})
+		return nil, nil, false
+	}
+	if profileForbidden(c, id) {
+		return nil, nil, false
+	}
+
+	profile, err := database.GetProfileByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Không tìm thấy profile",
+		})
+		return nil, nil, false
+	}
+	return profile, h.DriveUploader.ForProfile(*profile), true
+}
+
+// requireProfileAccount báo lỗi khi profile chưa cấu hình Google Client ID riêng,
+// tránh việc xác thực từ trang profile ghi đè token của tài khoản Google chung
+func requireProfileAccount(c *gin.Context, uploader *drive.DriveUploader) bool {
+	if uploader.UsesProfileAccount() {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"error":   "Profile chưa cấu hình Google Client ID riêng, đang dùng tài khoản Google chung",
+	})
+	return false
+}
+
+// GetProfileDriveStatusHandler trả về trạng thái xác thực và cấu hình Google Drive của profile
+func (h *Handler) GetProfileDriveStatusHandler(c *gin.Context) {
+	_, uploader, ok := h.profileDriveUploader(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"drive_status": driveStatus(uploader),
+	})
+}
+
+// GetProfileDriveAuthURLHandler trả về URL xác thực tài khoản Google riêng của profile.
+// Sau khi đồng ý, Google chuyển hướng về /callback và token được lưu cho profile
+func (h *Handler) GetProfileDriveAuthURLHandler(c *gin.Context) {
+	profile, uploader, ok := h.profileDriveUploader(c)
+	if !ok || !requireProfileAccount(c, uploader) {
+		return
+	}
+
+	state, err := newDriveAuthState(profile.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể tạo URL xác thực: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"auth_url": uploader.AuthURL(state),
+	})
+}
+
+// ExchangeProfileDriveCodeHandler đổi mã xác thực Google lấy token cho tài khoản Google của profile
+func (h *Handler) ExchangeProfileDriveCodeHandler(c *gin.Context) {
+	profile, uploader, ok := h.profileDriveUploader(c)
+	if !ok || !requireProfileAccount(c, uploader) {
+		return
+	}
+
+	var request struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Không nhận được mã xác thực. Vui lòng thử lại.",
+		})
+		return
+	}
+
+	token, err := uploader.ExchangeAuthCode(request.Code)
+	h.recordAudit(c, models.AuditEvent{Action: "drive.connect", TargetType: "profile", TargetID: strconv.FormatInt(profile.ID, 10)}, err)
+	if err != nil {
+		log.Printf("Lỗi khi đổi mã xác thực cho profile %d: %v", profile.ID, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Lỗi xác thực: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Đã xác thực Google Drive cho profile %s", profile.Name),
+		"token_info": map[string]string{
+			"expires_at": token.Expiry.Format("02/01/2006 15:04:05"),
+		},
+	})
+}
+
+// DisconnectProfileDriveHandler xóa token Google của profile, profile cần xác thực lại trước khi upload
+func (h *Handler) DisconnectProfileDriveHandler(c *gin.Context) {
+	profile, uploader, ok := h.profileDriveUploader(c)
+	if !ok || !requireProfileAccount(c, uploader) {
+		return
+	}
+
+	err := os.Remove(uploader.TokenFile())
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	h.recordAudit(c, models.AuditEvent{Action: "drive.disconnect", TargetType: "profile", TargetID: strconv.FormatInt(profile.ID, 10)}, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể xóa token Google Drive: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Đã ngắt kết nối Google Drive của profile %s", profile.Name),
+	})
+}
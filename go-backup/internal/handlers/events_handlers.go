@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/backup-cronjob/internal/events"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+// Upgrader dùng kiểm tra Origin mặc định: chỉ chấp nhận kết nối từ cùng host
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// JobEventsWSHandler mở kết nối WebSocket và đẩy các sự kiện của job backup (queued, started,
+// log, progress, upload, finished, failed) dưới dạng JSON. Có thể lọc theo ?profile_id= hoặc ?job_id=
+func (h *Handler) JobEventsWSHandler(c *gin.Context) {
+	var profileID, jobID int64
+	if value := c.Query("profile_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "ID profile không hợp lệ",
+			})
+			return
+		}
+		profileID = id
+	}
+	if value := c.Query("job_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "ID job không hợp lệ",
+			})
+			return
+		}
+		jobID = id
+	}
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrader đã trả lỗi HTTP cho client
+		log.Printf("Không thể mở kết nối WebSocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	eventCh, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	// Đọc từ client chỉ để nhận pong và phát hiện kết nối bị đóng
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		case event := <-eventCh:
+			if profileID != 0 && event.ProfileID != profileID {
+				continue
+			}
+			if jobID != 0 && event.JobID != jobID {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
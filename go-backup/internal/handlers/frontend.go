@@ -1,12 +1,12 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/backup-cronjob/internal/logger"
 	"github.com/gin-gonic/gin"
 )
 
@@ -35,7 +35,6 @@ func (h *Handler) ServeFrontend(router *gin.Engine) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "API endpoint not found"})
 		})
 	} else {
-		// Log warning thay vì sử dụng logger
-		fmt.Println("Warning: Không tìm thấy thư mục frontend build tại " + publicDir)
+		logger.Component("http").Warn("Không tìm thấy thư mục frontend build", "path", publicDir)
 	}
 }
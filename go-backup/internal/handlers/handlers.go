@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,14 +11,20 @@ import (
 	"strings"
 	"time"
 
+	"github.com/backup-cronjob/internal/audit"
 	"github.com/backup-cronjob/internal/auth"
 	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/compression"
 	"github.com/backup-cronjob/internal/config"
 	"github.com/backup-cronjob/internal/database"
 	"github.com/backup-cronjob/internal/dbdump"
 	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/encryption"
+	"github.com/backup-cronjob/internal/integrity"
+	"github.com/backup-cronjob/internal/logger"
 	"github.com/backup-cronjob/internal/models"
 	"github.com/backup-cronjob/internal/scheduler"
+	"github.com/backup-cronjob/internal/storage"
 	"github.com/gin-gonic/gin"
 )
 
@@ -26,7 +33,9 @@ type Handler struct {
 	Config         *config.Config
 	DatabaseDumper *dbdump.DatabaseDumper
 	DriveUploader  *drive.DriveUploader
+	Restorer       *dbdump.Restorer
 	Scheduler      *scheduler.Scheduler
+	Storage        *storage.Manager
 }
 
 // NewHandler tạo instance mới của Handler
@@ -39,11 +48,18 @@ func NewHandler(cfg *config.Config, scheduler *scheduler.Scheduler) *Handler {
 		panic(fmt.Sprintf("Failed to initialize database: %v", err))
 	}
 
+	driveUploader := drive.NewDriveUploader(cfg)
+	storageManager := storage.NewManager(cfg, driveUploader)
+	restorer := dbdump.NewRestorer(cfg)
+	restorer.Storage = storageManager
+
 	return &Handler{
 		Config:         cfg,
 		DatabaseDumper: dbdump.NewDatabaseDumper(cfg),
-		DriveUploader:  drive.NewDriveUploader(cfg),
+		DriveUploader:  driveUploader,
+		Restorer:       restorer,
 		Scheduler:      scheduler,
+		Storage:        storageManager,
 	}
 }
 
@@ -74,10 +90,10 @@ func (h *Handler) IndexHandler(c *gin.Context) {
 		claims, err := auth.ValidateJWT(authToken)
 		if err == nil && claims != nil {
 			isAuthenticated = true
-			log.Printf("✅ User authenticated via auth_token: %s", claims.Username)
+			log.Printf("Người dùng %s đã xác thực qua auth_token", claims.Username)
 		} else {
 			// Token không hợp lệ, xóa cookie
-			log.Printf("❌ Invalid auth_token: %v", err)
+			log.Printf("Cảnh báo: auth_token không hợp lệ: %v", err)
 			c.SetCookie("auth_token", "", -1, "/", "", false, true)
 			authToken = ""
 		}
@@ -89,14 +105,14 @@ func (h *Handler) IndexHandler(c *gin.Context) {
 		claims, err := auth.ValidateJWT(token)
 		if err == nil && claims != nil {
 			isAuthenticated = true
-			log.Printf("✅ User authenticated via Authorization header: %s", claims.Username)
+			log.Printf("Người dùng %s đã xác thực qua header Authorization", claims.Username)
 		}
 	}
 
 	// Kiểm tra cookie logged_in chỉ khi có auth_token hợp lệ
 	if !isAuthenticated && cookieValue == "true" && authToken != "" {
 		isAuthenticated = true
-		log.Printf("✅ User authenticated via logged_in cookie")
+		log.Printf("Người dùng đã xác thực qua cookie logged_in")
 	}
 
 	// Nếu đã xác thực thành công
@@ -171,15 +187,7 @@ func getAuthMethod(cookieValue, authToken, authHeader string) string {
 func (h *Handler) AuthHandler(c *gin.Context) {
 	// Tạo URL xác thực
 	authURL := h.DriveUploader.GetAuthURL()
-	fmt.Printf("URL xác thực Google Drive: %s\n", authURL)
-
-	// Hiển thị hướng dẫn trong terminal
-	fmt.Println("\n=== HƯỚNG DẪN XÁC THỰC GOOGLE DRIVE ===")
-	fmt.Println("1. Sao chép URL sau vào trình duyệt:")
-	fmt.Println(authURL)
-	fmt.Println("2. Đăng nhập Google và cho phép quyền truy cập")
-	fmt.Println("3. Bạn sẽ được chuyển hướng đến trang callback của ứng dụng")
-	fmt.Println("4. Xác thực sẽ được hoàn tất tự động\n")
+	logger.Component("drive").Info("Chuyển hướng người dùng đến trang xác thực Google Drive", "remote_addr", c.Request.RemoteAddr)
 
 	// Chuyển hướng người dùng đến trang xác thực Google
 	c.Redirect(http.StatusFound, authURL)
@@ -190,7 +198,7 @@ func (h *Handler) AuthCallbackHandler(c *gin.Context) {
 	// Lấy mã xác thực từ query parameters
 	code := c.Query("code")
 	if code == "" {
-		log.Printf("❌ Callback không có mã xác thực")
+		log.Printf("Cảnh báo: callback không có mã xác thực")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Không nhận được mã xác thực từ Google. Vui lòng thử lại.",
@@ -198,13 +206,31 @@ func (h *Handler) AuthCallbackHandler(c *gin.Context) {
 		return
 	}
 
-	log.Printf("✅ Đã nhận mã xác thực từ Google, độ dài: %d", len(code))
+	log.Printf("Đã nhận mã xác thực từ Google, độ dài: %d", len(code))
+
+	// State do trang profile tạo thì token được lưu cho tài khoản Google của profile
+	uploader := h.DriveUploader
+	connectEvent := models.AuditEvent{Action: "drive.connect", TargetType: "destination", TargetID: models.DestinationDrive}
+	if profileID, ok := takeDriveAuthState(c.Query("state")); ok {
+		profile, err := database.GetProfileByID(profileID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   "Không tìm thấy profile",
+			})
+			return
+		}
+		uploader = h.DriveUploader.ForProfile(*profile)
+		connectEvent.TargetType = "profile"
+		connectEvent.TargetID = strconv.FormatInt(profileID, 10)
+	}
 
 	// Đổi mã xác thực lấy token
 	log.Printf("Bắt đầu đổi mã xác thực lấy token...")
-	token, err := h.DriveUploader.ExchangeAuthCode(code)
+	token, err := uploader.ExchangeAuthCode(code)
+	h.recordAudit(c, connectEvent, err)
 	if err != nil {
-		log.Printf("❌ Lỗi khi đổi mã xác thực: %v", err)
+		log.Printf("Lỗi khi đổi mã xác thực: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   fmt.Sprintf("Lỗi xác thực: %v", err),
@@ -212,7 +238,7 @@ func (h *Handler) AuthCallbackHandler(c *gin.Context) {
 		return
 	}
 
-	log.Printf("✅ Đã lấy token thành công. Token hết hạn vào: %v", token.Expiry)
+	log.Printf("Đã lấy token thành công. Token hết hạn vào: %v", token.Expiry)
 
 	// Thay vì trả về JSON, trả về HTML với script tự đóng cửa sổ
 	htmlResponse := `
@@ -276,7 +302,8 @@ func (h *Handler) AuthCallbackHandler(c *gin.Context) {
 	c.String(http.StatusOK, htmlResponse)
 }
 
-// DumpHandler xử lý yêu cầu dump database
+// DumpHandler đưa backup của profile (profile_id trong body hoặc query, mặc định là profile đang hoạt động)
+// vào hàng đợi và trả về job, tiến độ theo dõi qua lịch sử job hoặc WebSocket
 func (h *Handler) DumpHandler(c *gin.Context) {
 	// Lấy profile ID từ query hoặc JSON body
 	var profileId int64 = 0
@@ -300,60 +327,66 @@ func (h *Handler) DumpHandler(c *gin.Context) {
 		}
 	}
 
-	// Thực hiện dump database với profile đã chọn
-	result, err := h.DatabaseDumper.DumpDatabase(profileId)
-	if err != nil {
-		log.Printf("Lỗi khi dump database: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// Mặc định là profile đang hoạt động
+	if profileId == 0 {
+		profile, err := database.GetActiveProfile()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Chưa có profile đang hoạt động, cần chỉ định profile_id",
+			})
+			return
+		}
+		profileId = profile.ID
+	}
+	if profileForbidden(c, profileId) {
+		return
+	}
+	if _, err := database.GetProfileByID(profileId); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
-			"message": fmt.Sprintf("Lỗi khi dump database: %v", err),
+			"message": fmt.Sprintf("Không tìm thấy profile với ID %d: %v", profileId, err),
 		})
 		return
 	}
 
-	// Trả về kết quả thành công
+	// Backup chạy qua hàng đợi như các backup khác: mỗi profile chỉ một job, giới hạn số job đồng thời,
+	// được ghi vào job_logs và có thể hủy qua POST /api/jobs/:id/cancel
+	job, err := h.Scheduler.RunBackupNow(profileId)
+	dumpEvent := models.AuditEvent{Action: "backup.dump", TargetType: "profile", TargetID: strconv.FormatInt(profileId, 10)}
+	if job != nil {
+		dumpEvent.Message = fmt.Sprintf("Job %d", job.ID)
+	}
+	h.recordAudit(c, dumpEvent, err)
+	if err != nil {
+		logger.Component("scheduler").Warn("Không thể đưa backup vào hàng đợi", logger.KeyProfileID, profileId, "error", err)
+		response := gin.H{
+			"success": false,
+			"message": err.Error(),
+		}
+		status := enqueueErrorStatus(err)
+		if status == http.StatusConflict {
+			response["job"] = job
+		}
+		c.JSON(status, response)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": fmt.Sprintf("Đã dump database thành công. File: %s", filepath.Base(result.FilePath)),
-		"result": gin.H{
-			"filePath": result.FilePath,
-			"fileName": filepath.Base(result.FilePath),
-			"fileSize": result.FileSize,
-		},
+		"message": fmt.Sprintf("Đã đưa backup của profile %s vào hàng đợi (job %d)", job.ProfileName, job.ID),
+		"job":     job,
 	})
 }
 
 // UploadLastHandler xử lý yêu cầu upload file mới nhất
 func (h *Handler) UploadLastHandler(c *gin.Context) {
-	// Kiểm tra xác thực Google Drive
-	if !h.DriveUploader.CheckAuth() {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success":  false,
-			"message":  "Chưa xác thực với Google Drive. Vui lòng thực hiện xác thực trước.",
-			"redirect": "/auth",
-		})
+	// Tìm file mới nhất của profile được chỉ định, mặc định là profile đang hoạt động
+	profileID, ok := latestBackupProfileID(c)
+	if !ok {
 		return
 	}
-
-	// Kiểm tra vấn đề cấu hình
-	configIssues := h.DriveUploader.CheckDriveConfig()
-	if len(configIssues) > 0 {
-		errorMessages := []string{}
-		for key, issue := range configIssues {
-			errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", key, issue))
-		}
-		errorMsg := fmt.Sprintf("Có vấn đề với cấu hình Google Drive: %s", strings.Join(errorMessages, "; "))
-
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": errorMsg,
-			"issues":  configIssues,
-		})
-		return
-	}
-
-	// Tìm file mới nhất
-	latestBackup, err := backupdb.FindLatestBackup()
+	latestBackup, err := backupdb.FindLatestBackup(profileID)
 	if err != nil || latestBackup == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -371,8 +404,14 @@ func (h *Handler) UploadLastHandler(c *gin.Context) {
 		return
 	}
 
-	// Upload file
-	result := h.DriveUploader.UploadFile(latestBackup.Path)
+	// Upload bằng thư mục Drive và tài khoản Google của profile đã tạo backup
+	uploader := h.driveUploaderFor(latestBackup.ProfileID)
+	if driveNotReady(c, uploader) {
+		return
+	}
+	result := uploader.UploadFile(latestBackup.Path)
+	h.recordAudit(c, models.AuditEvent{Action: "backup.upload", TargetType: "backup", TargetID: latestBackup.ID,
+		Message: models.DestinationDrive}, uploadError(result.Success, result.Message))
 	if !result.Success {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -386,7 +425,13 @@ func (h *Handler) UploadLastHandler(c *gin.Context) {
 	if err != nil {
 		log.Printf("Cảnh báo: Không thể chuyển đổi ID backup: %v", err)
 	} else {
-		if err := database.UpdateBackupUploadStatus(backupID, true, result.WebLink); err != nil {
+		if err := backupdb.RecordUpload(backupdb.BackupUpload{
+			BackupID:    backupID,
+			ProfileID:   latestBackup.ProfileID,
+			Destination: models.DestinationDrive,
+			Key:         storage.KeyForFile(latestBackup.Path),
+			Location:    result.WebLink,
+		}); err != nil {
 			log.Printf("Cảnh báo: Không thể cập nhật trạng thái upload: %v", err)
 		} else {
 			log.Printf("Đã cập nhật trạng thái upload thành công cho file ID: %d, link: %s", backupID, result.WebLink)
@@ -404,20 +449,39 @@ func (h *Handler) UploadLastHandler(c *gin.Context) {
 	})
 }
 
-// UploadAllHandler xử lý yêu cầu upload tất cả file
-func (h *Handler) UploadAllHandler(c *gin.Context) {
+// driveUploaderFor trả về DriveUploader dùng thư mục và tài khoản Google của profile,
+// profile không tồn tại hoặc profileID = 0 dùng cấu hình chung
+func (h *Handler) driveUploaderFor(profileID int64) *drive.DriveUploader {
+	if profileID > 0 {
+		if profile, err := database.GetProfileByID(profileID); err == nil {
+			return h.DriveUploader.ForProfile(*profile)
+		}
+	}
+	return h.DriveUploader
+}
+
+// driveNotReady kiểm tra uploader đã xác thực và cấu hình đầy đủ,
+// trả về true (kèm response lỗi) nếu chưa thể upload
+func driveNotReady(c *gin.Context, uploader *drive.DriveUploader) bool {
 	// Kiểm tra xác thực Google Drive
-	if !h.DriveUploader.CheckAuth() {
+	if !uploader.CheckAuth() {
+		if uploader.UsesProfileAccount() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Chưa xác thực tài khoản Google của profile. Vui lòng xác thực trong trang profile.",
+			})
+			return true
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success":  false,
 			"message":  "Chưa xác thực với Google Drive. Vui lòng thực hiện xác thực trước.",
 			"redirect": "/auth",
 		})
-		return
+		return true
 	}
 
 	// Kiểm tra vấn đề cấu hình
-	configIssues := h.DriveUploader.CheckDriveConfig()
+	configIssues := uploader.CheckDriveConfig()
 	if len(configIssues) > 0 {
 		errorMessages := []string{}
 		for key, issue := range configIssues {
@@ -430,11 +494,37 @@ func (h *Handler) UploadAllHandler(c *gin.Context) {
 			"message": errorMsg,
 			"issues":  configIssues,
 		})
-		return
+		return true
 	}
+	return false
+}
+
+// latestBackupProfileID lấy profile dùng để tìm backup mới nhất từ profile_id (query hoặc body),
+// mặc định là profile đang hoạt động; 0 nếu chưa có profile nào (tìm trong tất cả backup)
+func latestBackupProfileID(c *gin.Context) (int64, bool) {
+	var req struct {
+		ProfileID int64 `json:"profile_id" form:"profile_id"`
+	}
+	_ = c.ShouldBind(&req)
+	profileID := req.ProfileID
 
+	if profileID == 0 {
+		if profile, err := database.GetActiveProfile(); err == nil {
+			profileID = profile.ID
+		}
+	}
+	if profileID > 0 && profileForbidden(c, profileID) {
+		return 0, false
+	}
+	return profileID, true
+}
+
+// UploadAllHandler xử lý yêu cầu upload tất cả file.
+// Cấu hình Drive được kiểm tra theo từng profile trong lúc upload
+func (h *Handler) UploadAllHandler(c *gin.Context) {
 	// Upload tất cả file
 	err := h.DriveUploader.UploadAllBackups()
+	h.recordAudit(c, models.AuditEvent{Action: "backup.upload_all", Message: models.DestinationDrive}, err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -466,69 +556,6 @@ func (h *Handler) UploadAllHandler(c *gin.Context) {
 
 // UploadSingleHandler xử lý yêu cầu upload một file cụ thể
 func (h *Handler) UploadSingleHandler(c *gin.Context) {
-	// Kiểm tra các phương thức xác thực
-	cookieValue, _ := c.Cookie("logged_in")
-	authToken, _ := c.Cookie("auth_token")
-	authHeader := c.GetHeader("Authorization")
-
-	// Biến để theo dõi trạng thái xác thực
-	isAuthenticated := false
-
-	// Kiểm tra cookie logged_in và auth_token
-	if cookieValue == "true" && authToken != "" {
-		// Xác thực JWT token trong cookie
-		_, err := auth.ValidateJWT(authToken)
-		if err == nil {
-			isAuthenticated = true
-		}
-	}
-
-	// Kiểm tra Authorization header nếu chưa xác thực
-	if !isAuthenticated && authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		token := authHeader[7:]
-		// Xác thực JWT token trong header
-		_, err := auth.ValidateJWT(token)
-		if err == nil {
-			isAuthenticated = true
-		}
-	}
-
-	// Nếu không xác thực được bằng bất kỳ phương thức nào
-	if !isAuthenticated {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"success": false,
-			"message": "Vui lòng đăng nhập để thực hiện thao tác này",
-		})
-		return
-	}
-
-	// Kiểm tra xác thực Google Drive
-	if !h.DriveUploader.CheckAuth() {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success":  false,
-			"message":  "Chưa xác thực với Google Drive. Vui lòng thực hiện xác thực trước.",
-			"redirect": "/auth",
-		})
-		return
-	}
-
-	// Kiểm tra vấn đề cấu hình
-	configIssues := h.DriveUploader.CheckDriveConfig()
-	if len(configIssues) > 0 {
-		errorMessages := []string{}
-		for key, issue := range configIssues {
-			errorMessages = append(errorMessages, fmt.Sprintf("%s: %s", key, issue))
-		}
-		errorMsg := fmt.Sprintf("Có vấn đề với cấu hình Google Drive: %s", strings.Join(errorMessages, "; "))
-
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": errorMsg,
-			"issues":  configIssues,
-		})
-		return
-	}
-
 	fileID := c.Param("id")
 	backups, err := backupdb.GetAllBackups()
 	if err != nil {
@@ -565,8 +592,14 @@ func (h *Handler) UploadSingleHandler(c *gin.Context) {
 		return
 	}
 
-	// Upload file lên Drive
-	result := h.DriveUploader.UploadFile(targetBackup.Path)
+	// Upload file lên thư mục Drive và tài khoản Google của profile đã tạo backup
+	uploader := h.driveUploaderFor(targetBackup.ProfileID)
+	if driveNotReady(c, uploader) {
+		return
+	}
+	result := uploader.UploadFile(targetBackup.Path)
+	h.recordAudit(c, models.AuditEvent{Action: "backup.upload", TargetType: "backup", TargetID: targetBackup.ID,
+		Message: models.DestinationDrive}, uploadError(result.Success, result.Message))
 	if !result.Success {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -580,7 +613,13 @@ func (h *Handler) UploadSingleHandler(c *gin.Context) {
 	if err != nil {
 		log.Printf("Cảnh báo: Không thể chuyển đổi ID backup: %v", err)
 	} else {
-		if err := backupdb.UpdateBackupUploadStatus(backupID, true, result.WebLink); err != nil {
+		if err := backupdb.RecordUpload(backupdb.BackupUpload{
+			BackupID:    backupID,
+			ProfileID:   targetBackup.ProfileID,
+			Destination: models.DestinationDrive,
+			Key:         storage.KeyForFile(targetBackup.Path),
+			Location:    result.WebLink,
+		}); err != nil {
 			log.Printf("Cảnh báo: Không thể cập nhật trạng thái upload: %v", err)
 		} else {
 			log.Printf("Đã cập nhật trạng thái upload thành công cho file ID: %d, link: %s", backupID, result.WebLink)
@@ -600,27 +639,6 @@ func (h *Handler) UploadSingleHandler(c *gin.Context) {
 
 // DownloadHandler xử lý yêu cầu tải xuống file backup
 func (h *Handler) DownloadHandler(c *gin.Context) {
-	// Kiểm tra các phương thức xác thực
-	cookieValue, _ := c.Cookie("logged_in")
-	authToken, _ := c.Cookie("auth_token")
-	authHeader := c.GetHeader("Authorization")
-	token := c.Query("token")
-
-	// Nếu không có bất kỳ phương thức xác thực nào
-	if cookieValue != "true" && authToken == "" && authHeader == "" && token == "" {
-		c.Redirect(http.StatusSeeOther, "/?success=false&message=Vui lòng đăng nhập để thực hiện thao tác này")
-		return
-	}
-
-	// Nếu có token từ query parameter, xác thực token
-	if token != "" {
-		_, err := auth.ValidateJWT(token)
-		if err != nil {
-			c.Redirect(http.StatusSeeOther, "/?success=false&message=Phiên đăng nhập không hợp lệ hoặc đã hết hạn")
-			return
-		}
-	}
-
 	fileID := c.Param("id")
 	backups, err := backupdb.GetAllBackups()
 	if err != nil {
@@ -648,13 +666,69 @@ func (h *Handler) DownloadHandler(c *gin.Context) {
 		return
 	}
 
-	// Trả về file để tải xuống
+	algorithm := dbdump.BackupCompression(targetBackup)
+	encryptionMode := dbdump.BackupEncryption(targetBackup)
+	c.Header("X-Dump-Format", targetBackup.DumpFormat)
+
+	// Giải nén trong lúc tải xuống nếu được yêu cầu (?decompress=true). Backup mã hóa luôn được trả về
+	// nguyên bản mã hóa, dữ liệu gốc chỉ được giải mã bằng khóa của người tải (--decrypt)
+	decompress := algorithm != compression.None && encryptionMode == encryption.None && c.Query("decompress") == "true"
+
+	downloadEvent := models.AuditEvent{Action: "backup.download", TargetType: "backup", TargetID: targetBackup.ID}
+	if decompress {
+		downloadEvent.Message = "decompress"
+	}
+	h.recordAudit(c, downloadEvent, nil)
+
+	if decompress {
+		reader, err := dbdump.OpenBackup(targetBackup, encryption.DecryptKeys{})
+		if err != nil {
+			c.Redirect(http.StatusSeeOther, "/?success=false&message="+fmt.Sprintf("Không thể đọc file %s: %v", targetBackup.Name, err))
+			return
+		}
+		defer reader.Close()
+
+		fileName := compression.TrimExtension(targetBackup.Name, algorithm)
+		c.DataFromReader(http.StatusOK, -1, models.DumpFormatContentType(targetBackup.DumpFormat), reader, map[string]string{
+			"Content-Disposition": fmt.Sprintf("attachment; filename=\"%s\"", fileName),
+		})
+		return
+	}
+
+	// Trả về file để tải xuống kèm định dạng dump, thuật toán nén và chế độ mã hóa
+	contentType := models.DumpFormatContentType(targetBackup.DumpFormat)
+	if algorithm != compression.None {
+		contentType = compression.ContentType(algorithm)
+		c.Header("X-Compression", algorithm)
+	}
+	if encryptionMode != encryption.None {
+		contentType = "application/octet-stream"
+		c.Header("X-Encryption", encryptionMode)
+	}
+	c.Header("Content-Type", contentType)
 	c.FileAttachment(targetBackup.Path, targetBackup.Name)
 }
 
-// GetBackupsHandler xử lý lấy danh sách backup qua API
+// GetBackupsHandler xử lý lấy danh sách backup qua API, lọc theo profile_id nếu có
 func (h *Handler) GetBackupsHandler(c *gin.Context) {
-	backups, err := backupdb.GetAllBackups()
+	var backups []*models.BackupFile
+	var err error
+	if idStr := c.Query("profile_id"); idStr != "" {
+		profileID, parseErr := strconv.ParseInt(idStr, 10, 64)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "profile_id không hợp lệ",
+			})
+			return
+		}
+		if profileForbidden(c, profileID) {
+			return
+		}
+		backups, err = backupdb.GetProfileBackups(profileID)
+	} else {
+		backups, err = backupdb.GetAllBackups()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -671,9 +745,18 @@ func (h *Handler) GetBackupsHandler(c *gin.Context) {
 
 // CheckDriveStatusHandler xử lý kiểm tra trạng thái và cấu hình Google Drive
 func (h *Handler) CheckDriveStatusHandler(c *gin.Context) {
+	// Trả về tất cả thông tin
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"drive_status": driveStatus(h.DriveUploader),
+	})
+}
+
+// driveStatus thu thập trạng thái xác thực và cấu hình Google Drive của uploader
+func driveStatus(uploader *drive.DriveUploader) map[string]interface{} {
 	// Kiểm tra và thu thập thông tin về Google Drive
-	isAuthenticated := h.DriveUploader.CheckAuth()
-	configIssues := h.DriveUploader.CheckDriveConfig()
+	isAuthenticated := uploader.CheckAuth()
+	configIssues := uploader.CheckDriveConfig()
 
 	// Kiểm tra thông tin cấu hình
 	clientIDStatus := "OK"
@@ -699,9 +782,9 @@ func (h *Handler) CheckDriveStatusHandler(c *gin.Context) {
 
 	// Kiểm tra token info
 	var tokenInfo map[string]interface{}
-	tokenFile := filepath.Join(h.Config.TokenDir, "token.json")
+	tokenFile := uploader.TokenFile()
 	if _, err := os.Stat(tokenFile); err == nil {
-		token, err := h.DriveUploader.TokenFromFile(tokenFile)
+		token, err := uploader.TokenFromFile(tokenFile)
 		if err == nil {
 			tokenInfo = map[string]interface{}{
 				"expires_at":        token.Expiry.Format("02/01/2006 15:04:05"),
@@ -711,20 +794,18 @@ func (h *Handler) CheckDriveStatusHandler(c *gin.Context) {
 		}
 	}
 
-	// Trả về tất cả thông tin
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"drive_status": map[string]interface{}{
-			"is_authenticated":     isAuthenticated,
-			"has_issues":           len(configIssues) > 0,
-			"config_issues":        configIssues,
-			"client_id_status":     clientIDStatus,
-			"client_secret_status": clientSecretStatus,
-			"token_status":         tokenStatus,
-			"folder_status":        folderStatus,
-			"token_info":           tokenInfo,
-		},
-	})
+	return map[string]interface{}{
+		"is_authenticated":     isAuthenticated,
+		"has_issues":           len(configIssues) > 0,
+		"config_issues":        configIssues,
+		"client_id_status":     clientIDStatus,
+		"client_secret_status": clientSecretStatus,
+		"token_status":         tokenStatus,
+		"folder_status":        folderStatus,
+		"token_info":           tokenInfo,
+		"folder":               uploader.Config.FolderDrive,
+		"profile_account":      uploader.UsesProfileAccount(),
+	}
 }
 
 // GetAuthURLHandler trả về URL xác thực Google Drive
@@ -748,7 +829,7 @@ func (h *Handler) ExchangeAuthCodeHandler(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		log.Printf("❌ Lỗi khi đọc mã xác thực: %v", err)
+		log.Printf("Lỗi khi đọc mã xác thực: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Không nhận được mã xác thực. Vui lòng thử lại.",
@@ -758,7 +839,7 @@ func (h *Handler) ExchangeAuthCodeHandler(c *gin.Context) {
 
 	// Kiểm tra mã xác thực có trống không
 	if request.Code == "" {
-		log.Printf("❌ Mã xác thực trống")
+		log.Printf("Cảnh báo: mã xác thực trống")
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Mã xác thực không được để trống. Vui lòng thử lại.",
@@ -766,13 +847,14 @@ func (h *Handler) ExchangeAuthCodeHandler(c *gin.Context) {
 		return
 	}
 
-	log.Printf("✅ Đã nhận mã xác thực từ Google, độ dài: %d", len(request.Code))
+	log.Printf("Đã nhận mã xác thực từ Google, độ dài: %d", len(request.Code))
 
 	// Đổi mã xác thực lấy token
 	log.Printf("Bắt đầu đổi mã xác thực lấy token...")
 	token, err := h.DriveUploader.ExchangeAuthCode(request.Code)
+	h.recordAudit(c, models.AuditEvent{Action: "drive.connect", TargetType: "destination", TargetID: models.DestinationDrive}, err)
 	if err != nil {
-		log.Printf("❌ Lỗi khi đổi mã xác thực: %v", err)
+		log.Printf("Lỗi khi đổi mã xác thực: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   fmt.Sprintf("Lỗi xác thực: %v", err),
@@ -780,7 +862,7 @@ func (h *Handler) ExchangeAuthCodeHandler(c *gin.Context) {
 		return
 	}
 
-	log.Printf("✅ Đã lấy token thành công. Token hết hạn vào: %v", token.Expiry)
+	log.Printf("Đã lấy token thành công. Token hết hạn vào: %v", token.Expiry)
 
 	// Trả về thông tin token
 	c.JSON(http.StatusOK, gin.H{
@@ -849,7 +931,10 @@ func (h *Handler) DeleteBackupHandler(c *gin.Context) {
 	}
 
 	// Xóa bản ghi từ database
-	if err := database.DeleteBackup(backupID); err != nil {
+	err = database.DeleteBackup(backupID)
+	h.recordAudit(c, models.AuditEvent{Action: "backup.delete", TargetType: "backup", TargetID: fileID,
+		Changes: audit.Diff(targetBackup, nil)}, err)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": fmt.Sprintf("Lỗi khi xóa bản ghi backup: %v", err),
@@ -857,6 +942,10 @@ func (h *Handler) DeleteBackupHandler(c *gin.Context) {
 		return
 	}
 
+	if err := integrity.WriteManifest(h.Config, filepath.Dir(targetBackup.Path)); err != nil {
+		log.Printf("Không thể cập nhật manifest sau khi xóa %s: %v", targetBackup.Name, err)
+	}
+
 	// Trả về kết quả thành công
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -876,9 +965,60 @@ func (h *Handler) GetScheduleOptionsHandler(c *gin.Context) {
 // GetActiveJobsHandler trả về danh sách các công việc backup đang chạy
 func (h *Handler) GetActiveJobsHandler(c *gin.Context) {
 	jobs := h.Scheduler.GetActiveJobs()
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"jobs":             jobs,
+		"schedules_paused": h.Scheduler.SchedulesPaused(),
+	})
+}
+
+// CancelJobHandler hủy backup đang chạy hoặc đang chờ trong hàng đợi theo ID job (ID trong job_logs)
+func (h *Handler) CancelJobHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID job không hợp lệ",
+		})
+		return
+	}
+
+	// Với API token, kiểm tra token được dùng với profile của job
+	if _, limited := c.Get("api_token"); limited {
+		jobLog, err := database.GetJobLog(id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Không tìm thấy job %d", id),
+			})
+			return
+		}
+		if profileForbidden(c, jobLog.ProfileID) {
+			return
+		}
+	}
+
+	err = h.Scheduler.CancelJob(id)
+	h.recordAudit(c, models.AuditEvent{Action: "job.cancel", TargetType: "job", TargetID: strconv.FormatInt(id, 10)}, err)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"jobs":    jobs,
+		"message": fmt.Sprintf("Đã yêu cầu hủy job %d", id),
+	})
+}
+
+// GetBackupQueueHandler trả về các backup đang chạy và đang chờ trong hàng đợi
+func (h *Handler) GetBackupQueueHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"queue":   h.Scheduler.GetQueuedJobs(),
 	})
 }
 
@@ -908,8 +1048,12 @@ func (h *Handler) UpdateScheduleHandler(c *gin.Context) {
 	}
 
 	// Cập nhật lịch trình
+	oldSchedule := profile.CronSchedule
 	profile.CronSchedule = req.CronSchedule
-	if err := database.UpdateProfile(*profile); err != nil {
+	err = database.UpdateProfile(*profile)
+	h.recordAudit(c, models.AuditEvent{Action: "schedule.update", TargetType: "profile", TargetID: strconv.FormatInt(profile.ID, 10),
+		Changes: audit.Diff(gin.H{"cron_schedule": oldSchedule}, gin.H{"cron_schedule": req.CronSchedule})}, err)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   fmt.Sprintf("Không thể cập nhật profile: %v", err),
@@ -966,8 +1110,12 @@ func (h *Handler) DeleteScheduleHandler(c *gin.Context) {
 	h.Scheduler.RemoveJob(req.ProfileID)
 
 	// Cập nhật lịch trình trong cơ sở dữ liệu
+	oldSchedule := profile.CronSchedule
 	profile.CronSchedule = ""
-	if err := database.UpdateProfile(*profile); err != nil {
+	err = database.UpdateProfile(*profile)
+	h.recordAudit(c, models.AuditEvent{Action: "schedule.delete", TargetType: "profile", TargetID: strconv.FormatInt(profile.ID, 10),
+		Changes: audit.Diff(gin.H{"cron_schedule": oldSchedule}, gin.H{"cron_schedule": ""})}, err)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   fmt.Sprintf("Không thể cập nhật profile: %v", err),
@@ -981,10 +1129,11 @@ func (h *Handler) DeleteScheduleHandler(c *gin.Context) {
 	})
 }
 
-// PauseScheduleHandler tạm dừng một lịch backup
+// PauseScheduleHandler tạm dừng một lịch backup, có thể kèm paused_until (RFC3339) để tự chạy lại
 func (h *Handler) PauseScheduleHandler(c *gin.Context) {
 	var req struct {
-		ProfileID int64 `json:"profile_id" binding:"required"`
+		ProfileID   int64      `json:"profile_id" binding:"required"`
+		PausedUntil *time.Time `json:"paused_until"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -994,6 +1143,9 @@ func (h *Handler) PauseScheduleHandler(c *gin.Context) {
 		})
 		return
 	}
+	if profileForbidden(c, req.ProfileID) {
+		return
+	}
 
 	// Lấy thông tin profile
 	profile, err := database.GetProfileByID(req.ProfileID)
@@ -1006,17 +1158,26 @@ func (h *Handler) PauseScheduleHandler(c *gin.Context) {
 	}
 
 	// Tạm dừng job trong scheduler
-	if err := h.Scheduler.PauseJob(req.ProfileID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	err = h.Scheduler.PauseJob(req.ProfileID, req.PausedUntil)
+	h.recordAudit(c, models.AuditEvent{Action: "schedule.pause", TargetType: "profile", TargetID: strconv.FormatInt(profile.ID, 10),
+		Changes: audit.Diff(gin.H{"paused_until": profile.PausedUntil}, gin.H{"paused_until": req.PausedUntil})}, err)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   fmt.Sprintf("Không thể tạm dừng lịch backup: %v", err),
 		})
 		return
 	}
 
+	message := fmt.Sprintf("Đã tạm dừng lịch backup cho profile %s", profile.Name)
+	if req.PausedUntil != nil {
+		message = fmt.Sprintf("Đã tạm dừng lịch backup cho profile %s đến %s", profile.Name, req.PausedUntil.Format("02/01/2006 15:04:05"))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": fmt.Sprintf("Đã tạm dừng lịch backup cho profile %s", profile.Name),
+		"success":      true,
+		"message":      message,
+		"paused_until": req.PausedUntil,
 	})
 }
 
@@ -1033,6 +1194,9 @@ func (h *Handler) ResumeScheduleHandler(c *gin.Context) {
 		})
 		return
 	}
+	if profileForbidden(c, req.ProfileID) {
+		return
+	}
 
 	// Lấy thông tin profile
 	profile, err := database.GetProfileByID(req.ProfileID)
@@ -1045,7 +1209,9 @@ func (h *Handler) ResumeScheduleHandler(c *gin.Context) {
 	}
 
 	// Tiếp tục chạy job trong scheduler
-	if err := h.Scheduler.ResumeJob(req.ProfileID); err != nil {
+	err = h.Scheduler.ResumeJob(req.ProfileID)
+	h.recordAudit(c, models.AuditEvent{Action: "schedule.resume", TargetType: "profile", TargetID: strconv.FormatInt(profile.ID, 10)}, err)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   fmt.Sprintf("Không thể tiếp tục lịch backup: %v", err),
@@ -1059,6 +1225,52 @@ func (h *Handler) ResumeScheduleHandler(c *gin.Context) {
 	})
 }
 
+// GetMaintenanceHandler trả về trạng thái tạm dừng tất cả lịch backup
+func (h *Handler) GetMaintenanceHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"paused":  h.Scheduler.SchedulesPaused(),
+	})
+}
+
+// SetMaintenanceHandler bật/tắt tạm dừng tất cả lịch backup (chế độ bảo trì)
+func (h *Handler) SetMaintenanceHandler(c *gin.Context) {
+	var req struct {
+		Paused *bool `json:"paused" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	wasPaused := h.Scheduler.SchedulesPaused()
+	err := h.Scheduler.SetSchedulesPaused(*req.Paused)
+	h.recordAudit(c, models.AuditEvent{Action: "schedule.maintenance",
+		Changes: audit.Diff(gin.H{"paused": wasPaused}, gin.H{"paused": *req.Paused})}, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể cập nhật trạng thái lịch backup: %v", err),
+		})
+		return
+	}
+
+	message := "Đã tiếp tục tất cả lịch backup"
+	if *req.Paused {
+		message = "Đã tạm dừng tất cả lịch backup"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"paused":  *req.Paused,
+	})
+}
+
 // GetJobLogsHandler trả về lịch sử chạy job của một profile
 func (h *Handler) GetJobLogsHandler(c *gin.Context) {
 	profileID := c.Param("id")
@@ -1078,6 +1290,9 @@ func (h *Handler) GetJobLogsHandler(c *gin.Context) {
 		})
 		return
 	}
+	if profileForbidden(c, id) {
+		return
+	}
 
 	// Mặc định lấy 20 bản ghi gần nhất
 	limit := 20
@@ -1115,6 +1330,9 @@ func (h *Handler) RunBackupNowHandler(c *gin.Context) {
 		})
 		return
 	}
+	if profileForbidden(c, req.ProfileID) {
+		return
+	}
 
 	// Kiểm tra profile tồn tại
 	profile, err := database.GetProfileByID(req.ProfileID)
@@ -1126,16 +1344,43 @@ func (h *Handler) RunBackupNowHandler(c *gin.Context) {
 		return
 	}
 
-	// Thực hiện backup ngay lập tức
-	go func() {
-		err := h.Scheduler.RunBackupNow(req.ProfileID)
-		if err != nil {
-			log.Printf("Lỗi khi thực hiện backup ngay lập tức cho profile %s: %v", profile.Name, err)
+	// Đưa backup vào hàng đợi, backup chạy ngay khi có worker rảnh
+	job, err := h.Scheduler.RunBackupNow(req.ProfileID)
+	runEvent := models.AuditEvent{Action: "schedule.run_now", TargetType: "profile", TargetID: strconv.FormatInt(profile.ID, 10)}
+	if job != nil {
+		runEvent.Message = fmt.Sprintf("Job %d", job.ID)
+	}
+	h.recordAudit(c, runEvent, err)
+	if err != nil {
+		logger.Component("scheduler").Warn("Không thể đưa backup vào hàng đợi", logger.KeyProfileID, profile.ID, "profile", profile.Name, "error", err)
+		response := gin.H{
+			"success": false,
+			"error":   err.Error(),
 		}
-	}()
+		status := enqueueErrorStatus(err)
+		if status == http.StatusConflict {
+			response["job"] = job
+		}
+		c.JSON(status, response)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": fmt.Sprintf("Đã bắt đầu thực hiện backup cho profile %s", profile.Name),
+		"message": fmt.Sprintf("Đã đưa backup của profile %s vào hàng đợi", profile.Name),
+		"job":     job,
 	})
 }
+
+// enqueueErrorStatus trả về mã HTTP cho lỗi khi đưa backup vào hàng đợi: profile đã có backup
+// đang chờ là 409 (lần kích hoạt được gộp vào job đang chờ), hàng đợi đã dừng là 503
+func enqueueErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, scheduler.ErrAlreadyQueued):
+		return http.StatusConflict
+	case errors.Is(err, scheduler.ErrQueueStopped):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/compression"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/encryption"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+// setupHandlerTest tạo database tạm với schema đầy đủ và Handler dùng database đó
+func setupHandlerTest(t *testing.T) *Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{DBSource: filepath.Join(t.TempDir(), "app.db")}
+	if err := database.Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(cfg.DBSource); err != nil {
+		t.Fatal(err)
+	}
+	return &Handler{Config: cfg}
+}
+
+// addTestBackup ghi file backup nén gzip (mã hóa bằng passphrase nếu khác rỗng) và thêm vào catalog
+func addTestBackup(t *testing.T, content, passphrase string) (int64, []byte) {
+	t.Helper()
+	name := "shop_20240102_030405.sql.gz"
+	mode := encryption.None
+	if passphrase != "" {
+		name += ".enc"
+		mode = encryption.Passphrase
+	}
+	var buf bytes.Buffer
+	encrypted, err := encryption.NewWriter(&buf, mode, encryption.Keys{Passphrase: passphrase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := compression.NewWriter(encrypted, compression.Gzip, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed.Write([]byte(content))
+	if err := compressed.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := encrypted.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	id, err := backupdb.AddBackup(backupdb.NewBackup{
+		Filename:    name,
+		Filepath:    path,
+		Filesize:    int64(buf.Len()),
+		CreatedAt:   time.Now(),
+		Compression: compression.Gzip,
+		Encryption:  mode,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, buf.Bytes()
+}
+
+// download gọi DownloadHandler cho backup id với query cho trước
+func download(h *Handler, id int64, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodGet, "/download/"+strconv.FormatInt(id, 10)+query, nil)
+	c.Params = gin.Params{{Key: "id", Value: strconv.FormatInt(id, 10)}}
+	h.DownloadHandler(c)
+	return recorder
+}
+
+func TestDownloadDecompress(t *testing.T) {
+	h := setupHandlerTest(t)
+	id, _ := addTestBackup(t, "CREATE TABLE items (id integer);\n", "")
+
+	recorder := download(h, id, "?decompress=true")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("mã trả về = %d", recorder.Code)
+	}
+	if body := recorder.Body.String(); body != "CREATE TABLE items (id integer);\n" {
+		t.Errorf("nội dung = %q, muốn bản đã giải nén", body)
+	}
+}
+
+func TestDownloadEncryptedBackupServesCiphertext(t *testing.T) {
+	h := setupHandlerTest(t)
+	// Server không giải mã backup khi tải xuống, kể cả khi được yêu cầu giải nén
+	id, ciphertext := addTestBackup(t, "SELECT 'dữ liệu cá nhân';\n", "correct horse battery")
+
+	for _, query := range []string{"", "?decompress=true"} {
+		recorder := download(h, id, query)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%q: mã trả về = %d", query, recorder.Code)
+		}
+		if !bytes.Equal(recorder.Body.Bytes(), ciphertext) {
+			t.Errorf("%q: nội dung khác file mã hóa gốc", query)
+		}
+		if got := recorder.Header().Get("X-Encryption"); got != encryption.Passphrase {
+			t.Errorf("%q: X-Encryption = %q", query, got)
+		}
+		if _, err := gzip.NewReader(bytes.NewReader(recorder.Body.Bytes())); err == nil {
+			t.Errorf("%q: nội dung tải về không được mã hóa", query)
+		}
+	}
+}
+
+func TestDumpHandlerQueuesBackup(t *testing.T) {
+	h := setupHandlerTest(t)
+	// Hàng đợi chưa khởi động worker nên job ở trạng thái chờ
+	h.Scheduler = scheduler.NewScheduler(h.Config, drive.NewDriveUploader(h.Config))
+	profileID, err := database.CreateProfile(models.DatabaseProfile{Name: "shop", DBUser: "postgres", DBName: "shop", ContainerName: "pg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetActiveProfile(profileID); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/dump", nil)
+	h.DumpHandler(c)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("mã trả về = %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var resp struct {
+		Job scheduler.Job `json:"job"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Job.ID == 0 || resp.Job.ProfileID != profileID || resp.Job.Status != database.JobStatusQueued {
+		t.Errorf("job = %+v, muốn job đang chờ của profile %d", resp.Job, profileID)
+	}
+	jobLog, err := database.GetJobLog(resp.Job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobLog.Status != database.JobStatusQueued {
+		t.Errorf("job log có trạng thái %q, muốn queued", jobLog.Status)
+	}
+	if jobs := h.Scheduler.GetQueuedJobs(); len(jobs) != 1 || jobs[0].ID != resp.Job.ID {
+		t.Errorf("hàng đợi = %+v", jobs)
+	}
+
+	// Lần gọi thứ hai được gộp vào job đang chờ: 409 kèm job đó, không tạo job mới
+	recorder = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/dump", nil)
+	h.DumpHandler(c)
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("lần gọi thứ hai: mã trả về = %d, muốn 409: %s", recorder.Code, recorder.Body.String())
+	}
+	var conflict struct {
+		Job scheduler.Job `json:"job"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &conflict); err != nil {
+		t.Fatal(err)
+	}
+	if conflict.Job.ID != resp.Job.ID {
+		t.Errorf("job trong phản hồi 409 = %d, muốn job đang chờ %d", conflict.Job.ID, resp.Job.ID)
+	}
+	if jobs := h.Scheduler.GetQueuedJobs(); len(jobs) != 1 {
+		t.Errorf("hàng đợi sau lần gọi thứ hai = %+v", jobs)
+	}
+}
+
+func TestRunBackupNowProfileLimitedToken(t *testing.T) {
+	h := setupHandlerTest(t)
+	h.Scheduler = scheduler.NewScheduler(h.Config, drive.NewDriveUploader(h.Config))
+	shop, err := database.CreateProfile(models.DatabaseProfile{Name: "shop", DBUser: "postgres", DBName: "shop", ContainerName: "pg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	billing, err := database.CreateProfile(models.DatabaseProfile{Name: "billing", DBUser: "postgres", DBName: "billing", ContainerName: "pg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runNow := func(profileID int64) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		body := `{"profile_id": ` + strconv.FormatInt(profileID, 10) + `}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/schedule/run-now", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		// API token chỉ được dùng với profile shop
+		c.Set("api_token", &models.APIToken{Scopes: []string{models.ScopeBackupsRun}, ProfileIDs: []int64{shop}})
+		h.RunBackupNowHandler(c)
+		return recorder
+	}
+
+	if recorder := runNow(billing); recorder.Code != http.StatusForbidden {
+		t.Errorf("profile khác: mã trả về = %d, muốn 403: %s", recorder.Code, recorder.Body.String())
+	}
+	if jobs := h.Scheduler.GetQueuedJobs(); len(jobs) != 0 {
+		t.Errorf("request bị từ chối vẫn tạo job: %+v", jobs)
+	}
+	if recorder := runNow(shop); recorder.Code != http.StatusOK {
+		t.Errorf("profile được phép: mã trả về = %d, muốn 200: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestDriveTokensPerProfile(t *testing.T) {
+	h := setupHandlerTest(t)
+	h.Config.TokenDir = t.TempDir()
+	h.DriveUploader = drive.NewDriveUploader(h.Config)
+
+	createProfile := func(profile models.DatabaseProfile) int64 {
+		profile.DBUser, profile.DBName, profile.ContainerName = "postgres", profile.Name, "pg"
+		id, err := database.CreateProfile(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	drives := []string{models.DestinationDrive}
+	shop := createProfile(models.DatabaseProfile{Name: "shop", Destinations: drives})
+	billing := createProfile(models.DatabaseProfile{Name: "billing", Destinations: drives,
+		GoogleClientID: "billing-client", GoogleClientSecret: "billing-secret"})
+	createProfile(models.DatabaseProfile{Name: "crm", Destinations: []string{models.DestinationS3}})
+
+	writeToken := func(path string, expiry time.Time, refreshToken string) {
+		data, err := json.Marshal(map[string]interface{}{"access_token": "x", "refresh_token": refreshToken, "expiry": expiry})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sharedExpiry := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	billingExpiry := time.Date(2024, 1, 5, 3, 0, 0, 0, time.UTC)
+	writeToken(filepath.Join(h.Config.TokenDir, "token.json"), sharedExpiry, "refresh")
+	writeToken(drive.ProfileTokenFile(h.Config.TokenDir, billing), billingExpiry, "")
+
+	tokens := h.driveTokens()
+	if len(tokens) != 2 {
+		t.Fatalf("token = %+v, muốn token của shop và billing", tokens)
+	}
+	for _, token := range tokens {
+		switch token.ProfileID {
+		case shop:
+			if !token.Expiry.Equal(sharedExpiry) || !token.Refreshable {
+				t.Errorf("shop dùng token chung, nhận %+v", token)
+			}
+		case billing:
+			if !token.Expiry.Equal(billingExpiry) || token.Refreshable {
+				t.Errorf("billing dùng token riêng, nhận %+v", token)
+			}
+		default:
+			t.Errorf("profile %d không upload lên Drive nhưng có metric token", token.ProfileID)
+		}
+	}
+
+	// Token riêng chưa có: profile chưa xác thực không có metric
+	if err := os.Remove(drive.ProfileTokenFile(h.Config.TokenDir, billing)); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := h.driveTokens(); len(tokens) != 1 || tokens[0].ProfileID != shop {
+		t.Errorf("token sau khi xóa token của billing = %+v", tokens)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// GetLogsHandler trả về các mục log gần nhất của ứng dụng, mới nhất trước.
+// Lọc theo ?level= (cấp độ tối thiểu), ?component=, ?profile_id=, ?job_id=, ?backup_id=,
+// ?since= (RFC3339 hoặc khoảng thời gian như 2h, 30m) và ?limit= (mặc định 200)
+func (h *Handler) GetLogsHandler(c *gin.Context) {
+	filter := logger.Filter{Component: c.Query("component"), Limit: 200}
+
+	level, err := logger.ParseLevel(c.Query("level"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	filter.Level = level
+
+	ids := []struct {
+		param  string
+		target *int64
+	}{
+		{"profile_id", &filter.ProfileID},
+		{"job_id", &filter.JobID},
+		{"backup_id", &filter.BackupID},
+	}
+	for _, id := range ids {
+		value := c.Query(id.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Giá trị %s không hợp lệ: %s", id.param, value),
+			})
+			return
+		}
+		*id.target = parsed
+	}
+
+	if value := c.Query("since"); value != "" {
+		if since, err := time.Parse(time.RFC3339, value); err == nil {
+			filter.Since = since
+		} else if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+			filter.Since = time.Now().Add(-duration)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Giá trị since không hợp lệ, dùng RFC3339 (2024-01-02T15:04:05Z) hoặc khoảng thời gian (2h, 30m)",
+			})
+			return
+		}
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			filter.Limit = l
+		}
+	}
+
+	logs := logger.Query(filter)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"logs":    logs,
+		"count":   len(logs),
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/metrics"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// MetricsHandler trả về metric theo định dạng text của Prometheus.
+// Nếu cấu hình METRICS_TOKEN, request phải gửi header Authorization: Bearer <token>
+func (h *Handler) MetricsHandler(c *gin.Context) {
+	if token := h.Config.MetricsToken; token != "" {
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.String(http.StatusUnauthorized, "unauthorized\n")
+			return
+		}
+	}
+
+	families := metrics.Collected()
+
+	profileFamilies, err := metrics.ProfileFamilies()
+	if err != nil {
+		log.Printf("Lỗi khi thu thập metric của profile: %v", err)
+		c.String(http.StatusInternalServerError, "%v\n", err)
+		return
+	}
+	families = append(families, profileFamilies...)
+
+	queued, running := 0, 0
+	if h.Scheduler != nil {
+		for _, job := range h.Scheduler.GetQueuedJobs() {
+			if job.StartedAt != nil {
+				running++
+			} else {
+				queued++
+			}
+		}
+	}
+	families = append(families, metrics.QueueFamilies(queued, running)...)
+
+	families = append(families, metrics.DriveTokenFamilies(h.driveTokens())...)
+
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	if err := metrics.Write(c.Writer, families); err != nil {
+		log.Printf("Lỗi khi ghi metric: %v", err)
+	}
+}
+
+// driveTokens đọc token Google Drive của các profile upload lên Drive: token riêng nếu profile
+// dùng tài khoản Google riêng, token chung nếu không. Profile chưa xác thực bị bỏ qua
+func (h *Handler) driveTokens() []metrics.DriveToken {
+	if h.DriveUploader == nil {
+		return nil
+	}
+	profiles, err := database.GetAllProfiles()
+	if err != nil {
+		logger.Component("http").Warn("Không thể lấy danh sách profile cho metric token Drive", "error", err)
+		return nil
+	}
+
+	// Nhiều profile dùng chung một file token, mỗi file chỉ đọc một lần
+	tokens := make(map[string]*oauth2.Token)
+	var result []metrics.DriveToken
+	for _, profile := range profiles {
+		if !profile.HasDestination(models.DestinationDrive) {
+			continue
+		}
+		uploader := h.DriveUploader.ForProfile(profile)
+		tokenFile := uploader.TokenFile()
+		token, read := tokens[tokenFile]
+		if !read {
+			if _, err := os.Stat(tokenFile); err == nil {
+				token, _ = uploader.TokenFromFile(tokenFile)
+			}
+			tokens[tokenFile] = token
+		}
+		if token == nil {
+			continue
+		}
+		result = append(result, metrics.DriveToken{
+			ProfileID:   profile.ID,
+			Profile:     profile.Name,
+			Expiry:      token.Expiry,
+			Refreshable: token.RefreshToken != "",
+		})
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifyChannelsHandler trả về các kênh thông báo đã được cấu hình đầy đủ
+func (h *Handler) GetNotifyChannelsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"channels": notify.NewNotifier(h.Config).ConfiguredChannels(),
+	})
+}
+
+// TestNotifyHandler gửi thông báo thử qua một kênh (email, webhook, telegram) hoặc tất cả kênh đã cấu hình
+func (h *Handler) TestNotifyHandler(c *gin.Context) {
+	var req struct {
+		Channel string `json:"channel"`
+	}
+
+	// Body có thể để trống
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+			})
+			return
+		}
+	}
+
+	err := notify.NewNotifier(h.Config).SendTest(req.Channel)
+	h.recordAudit(c, models.AuditEvent{Action: "notify.test", TargetType: "channel", TargetID: req.Channel}, err)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể gửi thông báo thử: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Đã gửi thông báo thử",
+	})
+}
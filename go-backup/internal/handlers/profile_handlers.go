@@ -4,12 +4,21 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/backup-cronjob/internal/audit"
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/compression"
 	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/encryption"
 	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
 )
 
 // GetProfilesHandler trả về danh sách profiles
@@ -23,11 +32,9 @@ func (h *Handler) GetProfilesHandler(c *gin.Context) {
 		return
 	}
 
-	// Bỏ mật khẩu trước khi trả về client
+	// Bỏ mật khẩu và khóa trước khi trả về client
 	for i := range profiles {
-		if profiles[i].DBPassword != "" {
-			profiles[i].DBPassword = "••••••••"
-		}
+		maskProfileSecrets(&profiles[i])
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -47,6 +54,9 @@ func (h *Handler) GetProfileHandler(c *gin.Context) {
 		})
 		return
 	}
+	if profileForbidden(c, id) {
+		return
+	}
 
 	profile, err := database.GetProfile(id)
 	if err != nil {
@@ -57,10 +67,8 @@ func (h *Handler) GetProfileHandler(c *gin.Context) {
 		return
 	}
 
-	// Ẩn mật khẩu
-	if profile.DBPassword != "" {
-		profile.DBPassword = "••••••••"
-	}
+	// Ẩn mật khẩu và khóa
+	maskProfileSecrets(&profile)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -68,9 +76,48 @@ func (h *Handler) GetProfileHandler(c *gin.Context) {
 	})
 }
 
+// GetProfileBackupsHandler trả về danh sách backup do profile tạo ra, mới nhất trước
+func (h *Handler) GetProfileBackupsHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID profile không hợp lệ",
+		})
+		return
+	}
+	if profileForbidden(c, id) {
+		return
+	}
+
+	if _, err := database.GetProfileByID(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Không tìm thấy profile",
+		})
+		return
+	}
+
+	backups, err := backupdb.GetProfileBackups(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Không thể lấy danh sách backup: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"backups": backups,
+	})
+}
+
 // CreateProfileHandler tạo một profile mới
 func (h *Handler) CreateProfileHandler(c *gin.Context) {
 	var profile models.DatabaseProfile
+	// Mặc định gửi thông báo khi backup thất bại nếu client không chỉ định
+	profile.NotifyOnFailure = true
 	if err := c.ShouldBindJSON(&profile); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -80,10 +127,24 @@ func (h *Handler) CreateProfileHandler(c *gin.Context) {
 	}
 
 	// Kiểm tra thông tin bắt buộc
-	if profile.Name == "" || profile.DBUser == "" || profile.ContainerName == "" || profile.DBName == "" {
+	if profile.Name == "" || profile.DBUser == "" || profile.DBName == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "Thiếu thông tin bắt buộc (tên, tên người dùng database, tên container, tên database)",
+			"error":   "Thiếu thông tin bắt buộc (tên, tên người dùng database, tên database)",
+		})
+		return
+	}
+
+	// Client cũ chỉ gửi upload_to_drive
+	if len(profile.Destinations) == 0 && profile.UploadToDrive {
+		profile.Destinations = []string{models.DestinationDrive}
+	}
+
+	// Kiểm tra thông tin kết nối (container hoặc host/port)
+	if errMsg := validateConnection(&profile); errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   errMsg,
 		})
 		return
 	}
@@ -101,6 +162,10 @@ func (h *Handler) CreateProfileHandler(c *gin.Context) {
 		profile.FolderDrive = "Postgres Backup" // Mặc định tên thư mục Drive
 	}
 
+	if profile.DumpFormat == "" {
+		profile.DumpFormat = models.DefaultDumpFormat // Mặc định dump đầy đủ schema + dữ liệu
+	}
+
 	// Thiết lập thời gian
 	now := time.Now()
 	profile.CreatedAt = now
@@ -108,6 +173,8 @@ func (h *Handler) CreateProfileHandler(c *gin.Context) {
 
 	// Tạo profile
 	id, err := database.CreateProfile(profile)
+	h.recordAudit(c, models.AuditEvent{Action: "profile.create", TargetType: "profile", TargetID: strconv.FormatInt(id, 10),
+		Changes: audit.Diff(nil, profile)}, err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -118,14 +185,10 @@ func (h *Handler) CreateProfileHandler(c *gin.Context) {
 
 	// Lấy profile đã tạo
 	profile, _ = database.GetProfile(id)
+	h.syncDrillSchedule(profile)
 
-	// Ẩn mật khẩu
-	if profile.DBPassword != "" {
-		profile.DBPassword = "••••••••"
-	}
-	if profile.GoogleClientSecret != "" {
-		profile.GoogleClientSecret = "••••••••"
-	}
+	// Ẩn mật khẩu và khóa
+	maskProfileSecrets(&profile)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
@@ -158,20 +221,53 @@ func (h *Handler) UpdateProfileHandler(c *gin.Context) {
 
 	// Lấy dữ liệu cập nhật
 	var updateData struct {
-		Name               string `json:"name"`
-		Description        string `json:"description"`
-		DBUser             string `json:"db_user"`
-		DBPassword         string `json:"db_password"`
-		ContainerName      string `json:"container_name"`
-		DBName             string `json:"db_name"`
-		GoogleClientID     string `json:"google_client_id"`
-		GoogleClientSecret string `json:"google_client_secret"`
-		BackupDir          string `json:"backup_dir"`
-		CronSchedule       string `json:"cron_schedule"`
-		BackupRetention    int    `json:"backup_retention"`
-		UploadToDrive      *bool  `json:"upload_to_drive"`
-		FolderDrive        string `json:"folder_drive"`
-		IsActive           *bool  `json:"is_active"`
+		Name                 string    `json:"name"`
+		Description          string    `json:"description"`
+		DBUser               string    `json:"db_user"`
+		DBPassword           string    `json:"db_password"`
+		ContainerName        string    `json:"container_name"`
+		DBName               string    `json:"db_name"`
+		GoogleClientID       string    `json:"google_client_id"`
+		GoogleClientSecret   string    `json:"google_client_secret"`
+		BackupDir            string    `json:"backup_dir"`
+		CronSchedule         string    `json:"cron_schedule"`
+		BackupRetention      int       `json:"backup_retention"`
+		RetentionKeepLast    *int      `json:"retention_keep_last"`
+		RetentionKeepDaily   *int      `json:"retention_keep_daily"`
+		RetentionKeepWeekly  *int      `json:"retention_keep_weekly"`
+		RetentionKeepMonthly *int      `json:"retention_keep_monthly"`
+		Destinations         *[]string `json:"destinations"`
+		UploadToDrive        *bool     `json:"upload_to_drive"`
+		FolderDrive          string    `json:"folder_drive"`
+		ConnectionMode       string    `json:"connection_mode"`
+		DBHost               string    `json:"db_host"`
+		DBPort               int       `json:"db_port"`
+		SSLMode              string    `json:"ssl_mode"`
+		SSLRootCert          *string   `json:"ssl_root_cert"`
+		DumpFormat           string    `json:"dump_format"`
+		Compression          string    `json:"compression"`
+		CompressionLevel     *int      `json:"compression_level"`
+		Encryption           string    `json:"encryption"`
+		EncryptionRecipients *string   `json:"encryption_recipients"`
+		EncryptionPassphrase string    `json:"encryption_passphrase"`
+		SFTPHost             *string   `json:"sftp_host"`
+		SFTPPort             int       `json:"sftp_port"`
+		SFTPUser             *string   `json:"sftp_user"`
+		SFTPPassword         string    `json:"sftp_password"`
+		SFTPPrivateKey       string    `json:"sftp_private_key"`
+		SFTPHostKey          *string   `json:"sftp_host_key"`
+		SFTPRemoteDir        *string   `json:"sftp_remote_dir"`
+		JobTimeoutMinutes    *int      `json:"job_timeout_minutes"`
+		DrillAfterBackup     *bool     `json:"drill_after_backup"`
+		DrillSchedule        *string   `json:"drill_schedule"`
+		DrillSQL             *string   `json:"drill_sql"`
+		DrillImage           *string   `json:"drill_image"`
+		NotifyOnFailure      *bool     `json:"notify_on_failure"`
+		NotifyOnSuccess      *bool     `json:"notify_on_success"`
+		NotifyOnRecovery     *bool     `json:"notify_on_recovery"`
+		NotifyDigest         *bool     `json:"notify_digest"`
+		NotifyChannels       *[]string `json:"notify_channels"`
+		IsActive             *bool     `json:"is_active"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -182,6 +278,9 @@ func (h *Handler) UpdateProfileHandler(c *gin.Context) {
 		return
 	}
 
+	// Giữ lại bản trước khi cập nhật để ghi thay đổi vào audit log
+	previousProfile := currentProfile
+
 	// Cập nhật thông tin
 	if updateData.Name != "" {
 		currentProfile.Name = updateData.Name
@@ -192,7 +291,7 @@ func (h *Handler) UpdateProfileHandler(c *gin.Context) {
 	if updateData.DBUser != "" {
 		currentProfile.DBUser = updateData.DBUser
 	}
-	if updateData.DBPassword != "" && updateData.DBPassword != "••••••••" {
+	if updateData.DBPassword != "" && updateData.DBPassword != models.MaskedValue {
 		currentProfile.DBPassword = updateData.DBPassword
 	}
 	if updateData.ContainerName != "" {
@@ -206,7 +305,7 @@ func (h *Handler) UpdateProfileHandler(c *gin.Context) {
 	if updateData.GoogleClientID != "" {
 		currentProfile.GoogleClientID = updateData.GoogleClientID
 	}
-	if updateData.GoogleClientSecret != "" && updateData.GoogleClientSecret != "••••••••" {
+	if updateData.GoogleClientSecret != "" && updateData.GoogleClientSecret != models.MaskedValue {
 		currentProfile.GoogleClientSecret = updateData.GoogleClientSecret
 	}
 	if updateData.BackupDir != "" {
@@ -218,13 +317,133 @@ func (h *Handler) UpdateProfileHandler(c *gin.Context) {
 	if updateData.BackupRetention > 0 {
 		currentProfile.BackupRetention = updateData.BackupRetention
 	}
-	if updateData.UploadToDrive != nil {
-		currentProfile.UploadToDrive = *updateData.UploadToDrive
+	if updateData.RetentionKeepLast != nil && *updateData.RetentionKeepLast >= 0 {
+		currentProfile.RetentionKeepLast = *updateData.RetentionKeepLast
+	}
+	if updateData.RetentionKeepDaily != nil && *updateData.RetentionKeepDaily >= 0 {
+		currentProfile.RetentionKeepDaily = *updateData.RetentionKeepDaily
+	}
+	if updateData.RetentionKeepWeekly != nil && *updateData.RetentionKeepWeekly >= 0 {
+		currentProfile.RetentionKeepWeekly = *updateData.RetentionKeepWeekly
+	}
+	if updateData.RetentionKeepMonthly != nil && *updateData.RetentionKeepMonthly >= 0 {
+		currentProfile.RetentionKeepMonthly = *updateData.RetentionKeepMonthly
+	}
+	if updateData.Destinations != nil {
+		currentProfile.Destinations = *updateData.Destinations
+	} else if updateData.UploadToDrive != nil {
+		// Client cũ chỉ gửi upload_to_drive: bật/tắt destination drive, giữ nguyên các destination khác
+		destinations := []string{}
+		for _, name := range currentProfile.Destinations {
+			if name != models.DestinationDrive {
+				destinations = append(destinations, name)
+			}
+		}
+		if *updateData.UploadToDrive {
+			destinations = append(destinations, models.DestinationDrive)
+		}
+		currentProfile.Destinations = destinations
 	}
 	if updateData.FolderDrive != "" {
 		currentProfile.FolderDrive = updateData.FolderDrive
 	}
 
+	// Cập nhật thông tin kết nối
+	if updateData.ConnectionMode != "" {
+		currentProfile.ConnectionMode = updateData.ConnectionMode
+	}
+	if updateData.DBHost != "" {
+		currentProfile.DBHost = updateData.DBHost
+	}
+	if updateData.DBPort > 0 {
+		currentProfile.DBPort = updateData.DBPort
+	}
+	if updateData.SSLMode != "" {
+		currentProfile.SSLMode = updateData.SSLMode
+	}
+	if updateData.SSLRootCert != nil {
+		currentProfile.SSLRootCert = *updateData.SSLRootCert
+	}
+	if updateData.DumpFormat != "" {
+		currentProfile.DumpFormat = updateData.DumpFormat
+	}
+	if updateData.Compression != "" {
+		currentProfile.Compression = updateData.Compression
+	}
+	if updateData.CompressionLevel != nil {
+		currentProfile.CompressionLevel = *updateData.CompressionLevel
+	}
+	if updateData.Encryption != "" {
+		currentProfile.Encryption = updateData.Encryption
+	}
+	if updateData.EncryptionRecipients != nil {
+		currentProfile.EncryptionRecipients = *updateData.EncryptionRecipients
+	}
+	if updateData.EncryptionPassphrase != "" && updateData.EncryptionPassphrase != models.MaskedValue {
+		currentProfile.EncryptionPassphrase = updateData.EncryptionPassphrase
+	}
+
+	// Cập nhật thông tin SFTP
+	if updateData.SFTPHost != nil {
+		currentProfile.SFTPHost = *updateData.SFTPHost
+	}
+	if updateData.SFTPPort > 0 {
+		currentProfile.SFTPPort = updateData.SFTPPort
+	}
+	if updateData.SFTPUser != nil {
+		currentProfile.SFTPUser = *updateData.SFTPUser
+	}
+	if updateData.SFTPPassword != "" && updateData.SFTPPassword != models.MaskedValue {
+		currentProfile.SFTPPassword = updateData.SFTPPassword
+	}
+	if updateData.SFTPPrivateKey != "" && updateData.SFTPPrivateKey != models.MaskedValue {
+		currentProfile.SFTPPrivateKey = updateData.SFTPPrivateKey
+	}
+	if updateData.SFTPHostKey != nil {
+		currentProfile.SFTPHostKey = *updateData.SFTPHostKey
+	}
+	if updateData.SFTPRemoteDir != nil {
+		currentProfile.SFTPRemoteDir = *updateData.SFTPRemoteDir
+	}
+	if updateData.JobTimeoutMinutes != nil {
+		currentProfile.JobTimeoutMinutes = *updateData.JobTimeoutMinutes
+	}
+	if updateData.DrillAfterBackup != nil {
+		currentProfile.DrillAfterBackup = *updateData.DrillAfterBackup
+	}
+	if updateData.DrillSchedule != nil {
+		currentProfile.DrillSchedule = strings.TrimSpace(*updateData.DrillSchedule)
+	}
+	if updateData.DrillSQL != nil {
+		currentProfile.DrillSQL = *updateData.DrillSQL
+	}
+	if updateData.DrillImage != nil {
+		currentProfile.DrillImage = strings.TrimSpace(*updateData.DrillImage)
+	}
+	if updateData.NotifyOnFailure != nil {
+		currentProfile.NotifyOnFailure = *updateData.NotifyOnFailure
+	}
+	if updateData.NotifyOnSuccess != nil {
+		currentProfile.NotifyOnSuccess = *updateData.NotifyOnSuccess
+	}
+	if updateData.NotifyOnRecovery != nil {
+		currentProfile.NotifyOnRecovery = *updateData.NotifyOnRecovery
+	}
+	if updateData.NotifyDigest != nil {
+		currentProfile.NotifyDigest = *updateData.NotifyDigest
+	}
+	if updateData.NotifyChannels != nil {
+		currentProfile.NotifyChannels = *updateData.NotifyChannels
+	}
+
+	if errMsg := validateConnection(&currentProfile); errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   errMsg,
+		})
+		return
+	}
+
 	if updateData.IsActive != nil {
 		currentProfile.IsActive = *updateData.IsActive
 	}
@@ -234,6 +453,8 @@ func (h *Handler) UpdateProfileHandler(c *gin.Context) {
 
 	// Lưu cập nhật
 	err = database.UpdateProfile(currentProfile)
+	h.recordAudit(c, models.AuditEvent{Action: "profile.update", TargetType: "profile", TargetID: idStr,
+		Changes: audit.Diff(previousProfile, currentProfile)}, err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -250,11 +471,10 @@ func (h *Handler) UpdateProfileHandler(c *gin.Context) {
 		}
 	}
 
-	// Ẩn mật khẩu trước khi trả về
-	currentProfile.DBPassword = "••••••••"
-	if currentProfile.GoogleClientSecret != "" {
-		currentProfile.GoogleClientSecret = "••••••••"
-	}
+	h.syncDrillSchedule(currentProfile)
+
+	// Ẩn mật khẩu và khóa trước khi trả về
+	maskProfileSecrets(&currentProfile)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -276,7 +496,7 @@ func (h *Handler) DeleteProfileHandler(c *gin.Context) {
 	}
 
 	// Kiểm tra profile có tồn tại không
-	_, err = database.GetProfile(id)
+	profile, err := database.GetProfile(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
@@ -287,6 +507,8 @@ func (h *Handler) DeleteProfileHandler(c *gin.Context) {
 
 	// Xóa profile
 	err = database.DeleteProfile(id)
+	h.recordAudit(c, models.AuditEvent{Action: "profile.delete", TargetType: "profile", TargetID: idStr,
+		Changes: audit.Diff(profile, nil)}, err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -294,6 +516,13 @@ func (h *Handler) DeleteProfileHandler(c *gin.Context) {
 		})
 		return
 	}
+	if h.Scheduler != nil {
+		h.Scheduler.AddDrillJob(id, "", "")
+	}
+	// Token Google riêng của profile không còn được dùng
+	if err := os.Remove(drive.ProfileTokenFile(h.Config.TokenDir, id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Cảnh báo: Không thể xóa token Google Drive của profile %d: %v", id, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -325,6 +554,7 @@ func (h *Handler) SetActiveProfileHandler(c *gin.Context) {
 
 	// Đặt profile làm hoạt động
 	err = database.SetActiveProfile(id)
+	h.recordAudit(c, models.AuditEvent{Action: "profile.activate", TargetType: "profile", TargetID: idStr}, err)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -333,8 +563,8 @@ func (h *Handler) SetActiveProfileHandler(c *gin.Context) {
 		return
 	}
 
-	// Ẩn mật khẩu
-	profile.DBPassword = "••••••••"
+	// Ẩn mật khẩu và khóa
+	maskProfileSecrets(&profile)
 	profile.IsActive = true
 
 	c.JSON(http.StatusOK, gin.H{
@@ -355,13 +585,143 @@ func (h *Handler) GetActiveProfileHandler(c *gin.Context) {
 		return
 	}
 
-	// Ẩn mật khẩu
-	if profile.DBPassword != "" {
-		profile.DBPassword = "••••••••"
-	}
+	// Ẩn mật khẩu và khóa
+	maskProfileSecrets(&profile)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"profile": profile,
 	})
 }
+
+// validateConnection kiểm tra và chuẩn hóa thông tin kết nối, định dạng dump, nén, mã hóa và destination của profile,
+// trả về thông báo lỗi nếu không hợp lệ
+func validateConnection(profile *models.DatabaseProfile) string {
+	if profile.ConnectionMode == "" {
+		profile.ConnectionMode = models.ConnectionModeDocker
+	}
+	if profile.DBPort <= 0 {
+		profile.DBPort = 5432
+	}
+
+	switch profile.ConnectionMode {
+	case models.ConnectionModeDocker:
+		if profile.ContainerName == "" {
+			return "Chế độ kết nối docker cần có tên container (container_name)"
+		}
+	case models.ConnectionModeTCP:
+		if profile.DBHost == "" {
+			return "Chế độ kết nối tcp cần có host database (db_host)"
+		}
+	default:
+		return fmt.Sprintf("Chế độ kết nối không hợp lệ: %s (chỉ hỗ trợ docker hoặc tcp)", profile.ConnectionMode)
+	}
+
+	switch profile.SSLMode {
+	case "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Sprintf("sslmode không hợp lệ: %s", profile.SSLMode)
+	}
+
+	if profile.DumpFormat != "" && !models.IsValidDumpFormat(profile.DumpFormat) {
+		return fmt.Sprintf("Định dạng dump không hợp lệ: %s (hỗ trợ: %s)",
+			profile.DumpFormat, strings.Join(models.DumpFormats(), ", "))
+	}
+
+	if !compression.IsValid(profile.Compression) {
+		return fmt.Sprintf("Thuật toán nén không hợp lệ: %s (hỗ trợ: %s)",
+			profile.Compression, strings.Join(compression.Algorithms(), ", "))
+	}
+	profile.Compression = compression.Normalize(profile.Compression)
+	if err := compression.ValidateLevel(profile.Compression, profile.CompressionLevel); err != nil {
+		return err.Error()
+	}
+
+	if !encryption.IsValid(profile.Encryption) {
+		return fmt.Sprintf("Chế độ mã hóa không hợp lệ: %s (hỗ trợ: %s)",
+			profile.Encryption, strings.Join(encryption.Modes(), ", "))
+	}
+	profile.Encryption = encryption.Normalize(profile.Encryption)
+	err := encryption.ValidateKeys(profile.Encryption, encryption.Keys{
+		Recipients: profile.EncryptionRecipients,
+		Passphrase: profile.EncryptionPassphrase,
+	})
+	if err != nil {
+		return err.Error()
+	}
+
+	profile.Destinations = models.ParseDestinations(models.JoinDestinations(profile.Destinations))
+	for _, name := range profile.Destinations {
+		if !models.IsValidDestination(name) {
+			return fmt.Sprintf("Destination không hợp lệ: %s (hỗ trợ: %s)",
+				name, strings.Join(models.Destinations(), ", "))
+		}
+	}
+	profile.UploadToDrive = profile.HasDestination(models.DestinationDrive)
+
+	if profile.JobTimeoutMinutes < 0 {
+		return "Thời gian chạy tối đa (job_timeout_minutes) không được âm"
+	}
+
+	profile.NotifyChannels = models.ParseDestinations(models.JoinDestinations(profile.NotifyChannels))
+	for _, name := range profile.NotifyChannels {
+		if !models.IsValidNotifyChannel(name) {
+			return fmt.Sprintf("Kênh thông báo không hợp lệ: %s (hỗ trợ: %s)",
+				name, strings.Join(models.NotifyChannels(), ", "))
+		}
+	}
+
+	if profile.DrillSchedule != "" {
+		if _, err := cron.ParseStandard(profile.DrillSchedule); err != nil {
+			return fmt.Sprintf("Lịch restore drill (drill_schedule) không hợp lệ: %v", err)
+		}
+	}
+	if (profile.DrillAfterBackup || profile.DrillSchedule != "") && profile.DumpFormat == models.DumpFormatDataOnly {
+		return "Không thể bật restore drill cho profile có định dạng dump data-only vì backup không chứa schema"
+	}
+
+	if profile.SFTPPort <= 0 {
+		profile.SFTPPort = 22
+	}
+	if profile.HasDestination(models.DestinationSFTP) {
+		if _, err := storage.NewSFTPBackend(storage.SFTPConfigFromProfile(*profile)); err != nil {
+			return err.Error()
+		}
+	}
+
+	return ""
+}
+
+// maskProfileSecrets ẩn mật khẩu và khóa của profile trước khi trả về client,
+// client chỉ biết từng credentials đã được đặt hay chưa qua secrets_set
+func maskProfileSecrets(profile *models.DatabaseProfile) {
+	fields := map[string]*string{
+		"db_password":           &profile.DBPassword,
+		"google_client_secret":  &profile.GoogleClientSecret,
+		"encryption_passphrase": &profile.EncryptionPassphrase,
+		"sftp_password":         &profile.SFTPPassword,
+		"sftp_private_key":      &profile.SFTPPrivateKey,
+	}
+	profile.SecretsSet = make(map[string]bool, len(fields))
+	for name, value := range fields {
+		profile.SecretsSet[name] = *value != ""
+		if *value != "" {
+			*value = models.MaskedValue
+		}
+	}
+}
+
+// syncDrillSchedule cập nhật lịch restore drill của profile trong scheduler, profile không hoạt động thì xóa lịch
+func (h *Handler) syncDrillSchedule(profile models.DatabaseProfile) {
+	if h.Scheduler == nil || profile.ID == 0 {
+		return
+	}
+
+	schedule := profile.DrillSchedule
+	if !profile.IsActive {
+		schedule = ""
+	}
+	if err := h.Scheduler.AddDrillJob(profile.ID, schedule, profile.Name); err != nil {
+		log.Printf("Không thể cập nhật lịch restore drill cho profile '%s': %v", profile.Name, err)
+	}
+}
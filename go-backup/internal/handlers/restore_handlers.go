@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/backup-cronjob/internal/audit"
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/dbdump"
+	"github.com/backup-cronjob/internal/integrity"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RestoreBackupHandler khôi phục một file backup vào database của profile
+func (h *Handler) RestoreBackupHandler(c *gin.Context) {
+	backupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("ID backup không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	var req struct {
+		ProfileID    int64  `json:"profile_id"` // Profile đích, mặc định là profile đã tạo backup
+		DropDatabase bool   `json:"drop_database"`
+		Passphrase   string `json:"passphrase"` // Passphrase giải mã, mặc định dùng passphrase của profile đã tạo backup
+		// Cho phép khôi phục vào profile khác profile đã tạo backup
+		AllowOtherProfile bool `json:"allow_other_profile"`
+	}
+
+	// Body rỗng dùng các giá trị mặc định
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	// Kiểm tra backup tồn tại trước khi chạy nền
+	backup, err := backupdb.GetBackupByID(backupID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// File cục bộ không còn thì restorer tải lại từ destination, chỉ từ chối khi không còn bản sao nào
+	available, err := backupAvailable(backup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể lấy danh sách bản sao của backup: %v", err),
+		})
+		return
+	}
+	if !available {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Backup %s không còn bản sao nào, cả trên máy chủ lẫn trên destination", backup.Name),
+		})
+		return
+	}
+
+	profileID, err := dbdump.TargetProfileID(backup, req.ProfileID, req.AllowOtherProfile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Thực hiện restore trong nền, trạng thái được ghi vào job_logs và kết quả vào audit log
+	event := auditEvent(c, models.AuditEvent{Action: "backup.restore", TargetType: "backup", TargetID: backup.ID,
+		Message: fmt.Sprintf("profile_id=%d, drop_database=%v", profileID, req.DropDatabase)})
+	go func() {
+		_, err := h.Restorer.RestoreBackup(backupID, dbdump.RestoreOptions{
+			ProfileID:         profileID,
+			DropDatabase:      req.DropDatabase,
+			Passphrase:        req.Passphrase,
+			AllowOtherProfile: req.AllowOtherProfile,
+		})
+		// Lỗi đã được Restorer ghi log cùng backup_id và profile_id
+		audit.Record(audit.WithResult(event, err))
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Đã bắt đầu khôi phục backup %s", backup.Name),
+	})
+}
+
+// backupAvailable kiểm tra backup còn file cục bộ hoặc bản sao trên destination để khôi phục
+func backupAvailable(backup *models.BackupFile) (bool, error) {
+	if backup.FileExists {
+		return true, nil
+	}
+	id, err := strconv.ParseInt(backup.ID, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	uploads, err := backupdb.GetBackupUploads(id)
+	if err != nil {
+		return false, err
+	}
+	return len(uploads) > 0, nil
+}
+
+// RestoreDrillHandler chạy restore drill cho backup: khôi phục vào container PostgreSQL tạm thời và chạy
+// các câu SQL kiểm tra của profile. Kết quả được lưu vào bản ghi backup và job_logs (loại drill)
+func (h *Handler) RestoreDrillHandler(c *gin.Context) {
+	backupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("ID backup không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	var req struct {
+		ProfileID int64 `json:"profile_id"` // Profile của backup, mặc định là profile đã tạo backup
+	}
+
+	// Body có thể để trống
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+			})
+			return
+		}
+	}
+
+	backup, err := backupdb.GetBackupByID(backupID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Drill đối chiếu với database nguồn nên không chạy với profile khác profile đã tạo backup
+	req.ProfileID, err = dbdump.TargetProfileID(backup, req.ProfileID, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Backup cũ không rõ profile dùng profile đang hoạt động
+	if req.ProfileID == 0 {
+		profile, err := database.GetActiveProfile()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Chưa có profile đang hoạt động, cần chỉ định profile_id",
+			})
+			return
+		}
+		req.ProfileID = profile.ID
+	}
+
+	// Restore drill có thể mất vài phút (tải image, nạp dữ liệu) nên chạy trong nền
+	event := auditEvent(c, models.AuditEvent{Action: "backup.drill", TargetType: "backup", TargetID: backup.ID,
+		Message: fmt.Sprintf("profile_id=%d", req.ProfileID)})
+	go func() {
+		_, err := h.Scheduler.RunDrill(context.Background(), backupID, req.ProfileID)
+		audit.Record(audit.WithResult(event, err))
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Đã bắt đầu restore drill cho backup %s", backup.Name),
+	})
+}
+
+// VerifyBackupHandler tính lại SHA-256 của file cục bộ và các bản sao trên destination,
+// đối chiếu với checksum trong catalog và manifest (kèm chữ ký) của thư mục ngày
+func (h *Handler) VerifyBackupHandler(c *gin.Context) {
+	backupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("ID backup không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	report, err := integrity.VerifyBackup(c.Request.Context(), h.Config, h.Storage, backupID)
+	verifyErr := err
+	if err == nil && !report.OK {
+		verifyErr = fmt.Errorf("%s", strings.Join(report.Problems, ", "))
+	}
+	h.recordAudit(c, models.AuditEvent{Action: "backup.verify", TargetType: "backup", TargetID: c.Param("id")}, verifyErr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Backup %s khớp checksum trên tất cả bản sao", report.Name)
+	if !report.OK {
+		message = fmt.Sprintf("Backup %s có vấn đề: %s", report.Name, strings.Join(report.Problems, ", "))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"ok":      report.OK,
+		"message": message,
+		"report":  report,
+	})
+}
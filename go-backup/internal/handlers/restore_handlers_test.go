@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestBackupAvailable(t *testing.T) {
+	setupHandlerTest(t)
+	id, _ := addTestBackup(t, "SELECT 1;\n", "")
+	backup, err := backupdb.GetBackupByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := backupAvailable(backup); !ok || err != nil {
+		t.Errorf("backup còn file cục bộ: available = %v, %v", ok, err)
+	}
+
+	// File cục bộ đã bị xóa và chưa upload đi đâu
+	if err := os.Remove(backup.Path); err != nil {
+		t.Fatal(err)
+	}
+	backup, _ = backupdb.GetBackupByID(id)
+	if ok, err := backupAvailable(backup); ok || err != nil {
+		t.Errorf("backup không còn bản sao: available = %v, %v", ok, err)
+	}
+
+	// Chỉ còn bản sao trên S3: restorer tải về được nên vẫn khôi phục được
+	err = backupdb.RecordUpload(backupdb.BackupUpload{
+		BackupID:    id,
+		Destination: models.DestinationS3,
+		Key:         "2024-01-02/" + backup.Name,
+		UploadedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := backupAvailable(backup); !ok || err != nil {
+		t.Errorf("backup chỉ còn trên S3: available = %v, %v", ok, err)
+	}
+}
+
+func TestRestoreBackupHandlerWithoutAnyCopy(t *testing.T) {
+	h := setupHandlerTest(t)
+	id, _ := addTestBackup(t, "SELECT 1;\n", "")
+	backup, _ := backupdb.GetBackupByID(id)
+	if err := os.Remove(backup.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/backups/"+backup.ID+"/restore", nil)
+	c.Params = gin.Params{{Key: "id", Value: strconv.FormatInt(id, 10)}}
+	h.RestoreBackupHandler(c)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("mã trả về = %d, muốn 404: %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RunRetentionHandler áp dụng chính sách lưu giữ cho một profile.
+// Mặc định chạy ở chế độ dry run, cần gửi {"dry_run": false} để thực sự xóa backup
+func (h *Handler) RunRetentionHandler(c *gin.Context) {
+	profileID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID profile không hợp lệ",
+		})
+		return
+	}
+
+	var req struct {
+		DryRun *bool `json:"dry_run"`
+	}
+
+	// Body có thể để trống
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+			})
+			return
+		}
+	}
+
+	dryRun := true
+	if req.DryRun != nil {
+		dryRun = *req.DryRun
+	}
+
+	report, err := h.Scheduler.RunRetention(profileID, dryRun)
+	if report != nil && !dryRun {
+		event, removeErr := report.AuditEvent()
+		if err != nil {
+			removeErr = err
+		}
+		h.recordAudit(c, event, removeErr)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể áp dụng chính sách lưu giữ: %v", err),
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Đã xóa %d backup, giữ lại %d backup", len(report.Removed)-len(report.Errors), len(report.Kept))
+	if dryRun {
+		message = fmt.Sprintf("Dry run: sẽ xóa %d backup, giữ lại %d backup", len(report.Removed), len(report.Kept))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": len(report.Errors) == 0,
+		"message": message,
+		"report":  report,
+	})
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// storageProfile trả về profile dùng cho destination cấu hình theo profile (sftp):
+// profile theo ?profile_id= nếu có, nếu không thì profile đang active
+func storageProfile(c *gin.Context) (*models.DatabaseProfile, error) {
+	if idStr := c.Query("profile_id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ID profile không hợp lệ")
+		}
+		return database.GetProfileByID(id)
+	}
+	profile, err := database.GetActiveProfile()
+	if err != nil {
+		return nil, nil
+	}
+	return &profile, nil
+}
+
+// GetDestinationsHandler trả về danh sách destination và trạng thái cấu hình của từng destination.
+// Destination cấu hình theo profile được kiểm tra với profile theo ?profile_id= hoặc profile đang active
+func (h *Handler) GetDestinationsHandler(c *gin.Context) {
+	profile, err := storageProfile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	destinations := []gin.H{}
+	for _, name := range models.Destinations() {
+		item := gin.H{
+			"name":       name,
+			"configured": true,
+		}
+		if err := h.Storage.CheckConfig(name, profile); err != nil {
+			item["configured"] = false
+			item["error"] = err.Error()
+		}
+		destinations = append(destinations, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"destinations": destinations,
+	})
+}
+
+// ListStorageObjectsHandler liệt kê các file backup trên một destination, lọc theo ?prefix=.
+// Với sftp, dùng cấu hình của profile theo ?profile_id= hoặc profile đang active
+func (h *Handler) ListStorageObjectsHandler(c *gin.Context) {
+	name := c.Param("name")
+	if !models.IsValidDestination(name) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Destination không được hỗ trợ: %s", name),
+		})
+		return
+	}
+
+	profile, err := storageProfile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	backend, err := h.Storage.Backend(name, profile)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	objects, err := backend.List(c.Request.Context(), c.Query("prefix"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể liệt kê file trên %s: %v", name, err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"objects": objects,
+	})
+}
+
+// GetBackupUploadsHandler trả về các bản sao của một backup trên các destination
+func (h *Handler) GetBackupUploadsHandler(c *gin.Context) {
+	backupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID backup không hợp lệ",
+		})
+		return
+	}
+
+	uploads, err := backupdb.GetBackupUploads(backupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"uploads": uploads,
+	})
+}
+
+// UploadToDestinationHandler upload một backup lên destination chỉ định.
+// Với sftp, dùng cấu hình của profile theo ?profile_id= hoặc profile đang active
+func (h *Handler) UploadToDestinationHandler(c *gin.Context) {
+	backupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID backup không hợp lệ",
+		})
+		return
+	}
+
+	destination := c.Param("destination")
+	if !models.IsValidDestination(destination) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Destination không được hỗ trợ: %s", destination),
+		})
+		return
+	}
+
+	backup, err := backupdb.GetBackupByID(backupID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if _, err := os.Stat(backup.Path); os.IsNotExist(err) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("File không tồn tại trên hệ thống: %s", backup.Path),
+		})
+		return
+	}
+
+	profile, err := storageProfile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// Upload chạy hết kể cả khi client ngắt kết nối để trạng thái upload được ghi nhận đầy đủ
+	results := h.Storage.UploadBackup(context.Background(), profile, backupID, backup.Path, []string{destination})
+	result := results[0]
+	h.recordAudit(c, models.AuditEvent{Action: "backup.upload", TargetType: "backup", TargetID: backup.ID,
+		Message: destination}, uploadError(result.Success, result.Message))
+	if !result.Success {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Lỗi khi upload lên %s: %s", destination, result.Message),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Đã upload file %s lên %s", backup.Name, destination),
+		"result":  result,
+	})
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/audit"
+	"github.com/backup-cronjob/internal/auth"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAPITokensHandler trả về các API token của người dùng đang đăng nhập,
+// admin có thể xem token của tất cả người dùng với ?all=true
+func (h *Handler) GetAPITokensHandler(c *gin.Context) {
+	userID := currentUserID(c)
+	if c.Query("all") == "true" && c.GetString("role") == models.RoleAdmin {
+		userID = 0
+	}
+
+	tokens, err := database.GetAPITokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể lấy danh sách API token: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"tokens":  tokens,
+		"scopes":  models.APITokenScopes(),
+	})
+}
+
+// CreateAPITokenHandler tạo API token mới cho người dùng đang đăng nhập.
+// Token gốc chỉ được trả về một lần trong phản hồi, database chỉ lưu hash
+func (h *Handler) CreateAPITokenHandler(c *gin.Context) {
+	var req struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required"`
+		ProfileIDs    []int64  `json:"profile_ids"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 || req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Cần có tên, ít nhất một scope và số ngày hết hạn không âm",
+		})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.IsValidAPITokenScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Scope không hợp lệ: %s (hỗ trợ: %s)", scope, strings.Join(models.APITokenScopes(), ", ")),
+			})
+			return
+		}
+	}
+	for _, id := range req.ProfileIDs {
+		if _, err := database.GetProfileByID(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Không tìm thấy profile với ID %d", id),
+			})
+			return
+		}
+	}
+
+	plain, hash, prefix, err := auth.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể tạo API token: %v", err),
+		})
+		return
+	}
+
+	token := models.APIToken{
+		UserID:     currentUserID(c),
+		Name:       req.Name,
+		Prefix:     prefix,
+		TokenHash:  hash,
+		Scopes:     req.Scopes,
+		ProfileIDs: req.ProfileIDs,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	created, err := database.CreateAPIToken(token)
+	tokenEvent := models.AuditEvent{Action: "token.create", TargetType: "api_token", TargetID: prefix,
+		Changes: audit.Diff(nil, gin.H{"name": token.Name, "scopes": token.Scopes, "profile_ids": token.ProfileIDs})}
+	h.recordAudit(c, tokenEvent, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể lưu API token: %v", err),
+		})
+		return
+	}
+
+	log.Printf("Người dùng %s đã tạo API token '%s' (scope: %s)",
+		c.GetString("username"), created.Name, strings.Join(created.Scopes, ", "))
+	c.JSON(http.StatusCreated, gin.H{
+		"success":   true,
+		"message":   "Đã tạo API token, hãy lưu lại vì token chỉ hiển thị một lần",
+		"token":     plain,
+		"api_token": created,
+	})
+}
+
+// RevokeAPITokenHandler thu hồi API token, chỉ người tạo token hoặc admin được thu hồi
+func (h *Handler) RevokeAPITokenHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID API token không hợp lệ",
+		})
+		return
+	}
+
+	token, err := database.GetAPIToken(id)
+	if err == nil && token.UserID != currentUserID(c) && c.GetString("role") != models.RoleAdmin {
+		err = sql.ErrNoRows
+	}
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Không tìm thấy API token",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể lấy thông tin API token: %v", err),
+		})
+		return
+	}
+
+	err = database.RevokeAPIToken(token.ID)
+	h.recordAudit(c, models.AuditEvent{Action: "token.revoke", TargetType: "api_token", TargetID: token.Prefix,
+		Message: fmt.Sprintf("%s (%s)", token.Name, token.Username)}, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể thu hồi API token: %v", err),
+		})
+		return
+	}
+
+	log.Printf("Người dùng %s đã thu hồi API token '%s' của %s", c.GetString("username"), token.Name, token.Username)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Đã thu hồi API token",
+	})
+}
+
+// profileForbidden trả lỗi 403 nếu API token của request không được dùng với profile
+func profileForbidden(c *gin.Context, profileID int64) bool {
+	if auth.ProfileAllowed(c, profileID) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error":   fmt.Sprintf("API token không được dùng với profile %d", profileID),
+	})
+	return true
+}
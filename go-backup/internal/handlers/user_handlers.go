@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/backup-cronjob/internal/audit"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// minPasswordLength là độ dài tối thiểu của mật khẩu người dùng
+const minPasswordLength = 8
+
+// GetUsersHandler trả về danh sách người dùng
+func (h *Handler) GetUsersHandler(c *gin.Context) {
+	users, err := database.GetAllUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể lấy danh sách người dùng: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"users":   users,
+		"roles":   models.Roles(),
+	})
+}
+
+// CreateUserHandler tạo người dùng mới, vai trò mặc định là viewer
+func (h *Handler) CreateUserHandler(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Role == "" {
+		req.Role = models.RoleViewer
+	}
+	if err := validateUserInput(req.Username, req.Password, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if _, err := database.GetUserByUsername(req.Username); err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Người dùng '%s' đã tồn tại", req.Username),
+		})
+		return
+	}
+
+	hash, err := models.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể mã hóa mật khẩu: %v", err),
+		})
+		return
+	}
+
+	user, err := database.CreateUser(req.Username, hash, req.Role)
+	h.recordAudit(c, models.AuditEvent{Action: "user.create", TargetType: "user", TargetID: req.Username,
+		Changes: audit.Diff(nil, gin.H{"username": req.Username, "role": req.Role})}, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể tạo người dùng: %v", err),
+		})
+		return
+	}
+
+	log.Printf("Người dùng %s đã tạo tài khoản '%s' với vai trò %s", c.GetString("username"), user.Username, user.Role)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Đã tạo người dùng",
+		"user":    user,
+	})
+}
+
+// UpdateUserHandler đổi vai trò hoặc khóa/mở khóa người dùng.
+// Không thể tự hạ quyền hoặc tự khóa tài khoản của mình, và phải luôn còn ít nhất một admin
+func (h *Handler) UpdateUserHandler(c *gin.Context) {
+	user, ok := h.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Role     *string `json:"role"`
+		Disabled *bool   `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+		})
+		return
+	}
+
+	role, disabled := user.Role, user.Disabled
+	if req.Role != nil {
+		role = *req.Role
+	}
+	if req.Disabled != nil {
+		disabled = *req.Disabled
+	}
+	if !models.IsValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Vai trò không hợp lệ: %s (hỗ trợ: %s)", role, strings.Join(models.Roles(), ", ")),
+		})
+		return
+	}
+
+	losesAdmin := user.Role == models.RoleAdmin && !user.Disabled && (role != models.RoleAdmin || disabled)
+	if losesAdmin {
+		if currentUserID(c) == user.ID {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Không thể tự hạ quyền hoặc tự khóa tài khoản admin của mình",
+			})
+			return
+		}
+		admins, err := database.CountActiveAdmins()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Không thể kiểm tra số admin: %v", err),
+			})
+			return
+		}
+		if admins <= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "Phải còn ít nhất một admin đang hoạt động",
+			})
+			return
+		}
+	}
+
+	err := database.UpdateUserRole(user.ID, role, disabled)
+	h.recordAudit(c, models.AuditEvent{Action: "user.update", TargetType: "user", TargetID: user.Username,
+		Changes: audit.Diff(gin.H{"role": user.Role, "disabled": user.Disabled}, gin.H{"role": role, "disabled": disabled})}, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể cập nhật người dùng: %v", err),
+		})
+		return
+	}
+	user.Role, user.Disabled = role, disabled
+
+	log.Printf("Người dùng %s đã cập nhật tài khoản '%s': vai trò %s, khóa: %v",
+		c.GetString("username"), user.Username, role, disabled)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Đã cập nhật người dùng",
+		"user":    user,
+	})
+}
+
+// ResetUserPasswordHandler đặt lại mật khẩu người dùng. Nếu không gửi mật khẩu mới,
+// một mật khẩu ngẫu nhiên được tạo và chỉ trả về một lần trong phản hồi
+func (h *Handler) ResetUserPasswordHandler(c *gin.Context) {
+	user, ok := h.userFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	// Body có thể để trống
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Dữ liệu không hợp lệ: %v", err),
+			})
+			return
+		}
+	}
+
+	generated := req.Password == ""
+	if generated {
+		password, err := randomPassword()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("Không thể tạo mật khẩu: %v", err),
+			})
+			return
+		}
+		req.Password = password
+	} else if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Mật khẩu phải có ít nhất %d ký tự", minPasswordLength),
+		})
+		return
+	}
+
+	hash, err := models.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể mã hóa mật khẩu: %v", err),
+		})
+		return
+	}
+	err = database.UpdateUserPassword(user.ID, hash)
+	h.recordAudit(c, models.AuditEvent{Action: "user.password_reset", TargetType: "user", TargetID: user.Username}, err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể đặt lại mật khẩu: %v", err),
+		})
+		return
+	}
+
+	log.Printf("Người dùng %s đã đặt lại mật khẩu của tài khoản '%s'", c.GetString("username"), user.Username)
+	response := gin.H{
+		"success": true,
+		"message": "Đã đặt lại mật khẩu",
+	}
+	if generated {
+		response["password"] = req.Password
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// userFromParam đọc người dùng theo tham số :id, trả lỗi cho client nếu không tìm thấy
+func (h *Handler) userFromParam(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "ID người dùng không hợp lệ",
+		})
+		return nil, false
+	}
+
+	user, err := database.GetUserByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Không tìm thấy người dùng",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("Không thể lấy thông tin người dùng: %v", err),
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+// currentUserID trả về ID người dùng đang đăng nhập, được lưu bởi AuthMiddleware
+func currentUserID(c *gin.Context) int64 {
+	id, _ := c.Get("user_id")
+	userID, _ := id.(int64)
+	return userID
+}
+
+// validateUserInput kiểm tra username, mật khẩu và vai trò khi tạo người dùng
+func validateUserInput(username, password, role string) error {
+	if username == "" || strings.ContainsAny(username, " \t\n") {
+		return fmt.Errorf("Tên đăng nhập không hợp lệ")
+	}
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("Mật khẩu phải có ít nhất %d ký tự", minPasswordLength)
+	}
+	if !models.IsValidRole(role) {
+		return fmt.Errorf("Vai trò không hợp lệ: %s (hỗ trợ: %s)", role, strings.Join(models.Roles(), ", "))
+	}
+	return nil
+}
+
+// randomPassword tạo mật khẩu ngẫu nhiên 16 ký tự
+func randomPassword() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,226 @@
+package integrity
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/storage"
+)
+
+// Tên file manifest và chữ ký trong mỗi thư mục ngày
+const (
+	ManifestFile  = "manifest.json"
+	SignatureFile = "manifest.json.sig"
+)
+
+// ManifestEntry là một file backup trong manifest
+type ManifestEntry struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manifest liệt kê các file backup của một thư mục ngày cùng checksum của chúng
+type Manifest struct {
+	Folder      string          `json:"folder"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Algorithm   string          `json:"algorithm"`
+	Files       []ManifestEntry `json:"files"`
+}
+
+// Entry tìm file trong manifest theo tên
+func (m *Manifest) Entry(name string) *ManifestEntry {
+	for i := range m.Files {
+		if m.Files[i].Name == name {
+			return &m.Files[i]
+		}
+	}
+	return nil
+}
+
+// manifestMu tránh hai job ghi manifest của cùng thư mục cùng lúc
+var manifestMu sync.Mutex
+
+// WriteManifest tạo lại manifest của thư mục backup dir từ catalog, chỉ gồm các file còn trên đĩa.
+// Nếu có MANIFEST_SIGNING_KEY, manifest được ký bằng ed25519 và chữ ký (base64) lưu ở manifest.json.sig.
+// Thư mục không còn file backup nào thì manifest bị xóa
+func WriteManifest(cfg *config.Config, dir string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	dir = filepath.Clean(dir)
+	backups, err := backupdb.GetAllBackups()
+	if err != nil {
+		return err
+	}
+
+	manifest := Manifest{
+		Folder:      filepath.Base(dir),
+		GeneratedAt: time.Now(),
+		Algorithm:   "sha256",
+		Files:       []ManifestEntry{},
+	}
+	for _, backup := range backups {
+		if !backup.FileExists || filepath.Clean(filepath.Dir(backup.Path)) != dir {
+			continue
+		}
+		info, err := os.Stat(backup.Path)
+		if err != nil {
+			continue
+		}
+		checksum := backup.Checksum
+		if checksum == "" {
+			// Backup cũ chưa có checksum trong catalog
+			if checksum, err = storage.FileChecksum(backup.Path); err != nil {
+				log.Printf("Không thể tính checksum của %s: %v", backup.Path, err)
+				continue
+			}
+		}
+		manifest.Files = append(manifest.Files, ManifestEntry{
+			Name:      backup.Name,
+			Size:      info.Size(),
+			SHA256:    checksum,
+			CreatedAt: backup.CreatedAt,
+		})
+	}
+
+	manifestPath := filepath.Join(dir, ManifestFile)
+	signaturePath := filepath.Join(dir, SignatureFile)
+
+	if len(manifest.Files) == 0 {
+		for _, path := range []string{manifestPath, signaturePath} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(manifestPath, data); err != nil {
+		return fmt.Errorf("không thể ghi manifest: %v", err)
+	}
+
+	if cfg == nil || strings.TrimSpace(cfg.ManifestSigningKey) == "" {
+		if err := os.Remove(signaturePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	key, err := ParseSigningKey(cfg.ManifestSigningKey)
+	if err != nil {
+		// Không để lại chữ ký của manifest cũ
+		os.Remove(signaturePath)
+		return err
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	if err := writeFileAtomic(signaturePath, []byte(signature+"\n")); err != nil {
+		return fmt.Errorf("không thể ghi chữ ký manifest: %v", err)
+	}
+	return nil
+}
+
+// ReadManifest đọc manifest của thư mục dir, trả về nội dung gốc để kiểm tra chữ ký
+func ReadManifest(dir string) (*Manifest, []byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("manifest không hợp lệ: %v", err)
+	}
+	return &manifest, data, nil
+}
+
+// ErrNoSignature được trả về khi thư mục không có file chữ ký manifest
+var ErrNoSignature = errors.New("manifest chưa được ký")
+
+// VerifySignature kiểm tra chữ ký manifest.json.sig của thư mục dir với khóa công khai
+func VerifySignature(dir string, data []byte, publicKey ed25519.PublicKey) error {
+	content, err := os.ReadFile(filepath.Join(dir, SignatureFile))
+	if os.IsNotExist(err) {
+		return ErrNoSignature
+	}
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return fmt.Errorf("chữ ký manifest không hợp lệ: %v", err)
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return fmt.Errorf("chữ ký manifest không khớp")
+	}
+	return nil
+}
+
+// ParseSigningKey đọc khóa bí mật ed25519 dạng PEM (PKCS#8, như `openssl genpkey -algorithm ed25519`)
+// hoặc base64 của seed 32 byte / khóa 64 byte
+func ParseSigningKey(value string) (ed25519.PrivateKey, error) {
+	value = strings.TrimSpace(value)
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("không thể đọc khóa ký manifest: %v", err)
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("khóa ký manifest không phải ed25519")
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("khóa ký manifest phải là PEM hoặc base64: %v", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, fmt.Errorf("khóa ký manifest phải dài %d hoặc %d byte", ed25519.SeedSize, ed25519.PrivateKeySize)
+}
+
+// writeFileAtomic ghi file tạm rồi đổi tên, tránh để lại manifest dở dang
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
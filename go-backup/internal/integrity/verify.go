@@ -0,0 +1,206 @@
+package integrity
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/storage"
+)
+
+// Trạng thái kiểm tra của một bản sao hoặc manifest
+const (
+	StatusOK         = "ok"
+	StatusMismatch   = "mismatch"    // SHA-256 khác checksum trong catalog
+	StatusMissing    = "missing"     // Không tìm thấy file
+	StatusError      = "error"       // Không thể đọc hoặc tải file
+	StatusNoChecksum = "no_checksum" // Catalog chưa có checksum (backup cũ) và không có bản cục bộ để đối chiếu
+)
+
+// Trạng thái chữ ký manifest
+const (
+	SignatureValid      = "valid"
+	SignatureInvalid    = "invalid"
+	SignatureUnsigned   = "unsigned"
+	SignatureUnverified = "unverified" // Manifest có chữ ký nhưng chưa cấu hình khóa để kiểm tra
+)
+
+// CopyCheck là kết quả kiểm tra một bản sao của backup
+type CopyCheck struct {
+	Destination string `json:"destination"`
+	Key         string `json:"key,omitempty"`
+	Status      string `json:"status"`
+	Checksum    string `json:"checksum,omitempty"` // SHA-256 tính lại từ nội dung bản sao
+	Message     string `json:"message,omitempty"`
+}
+
+// ManifestCheck là kết quả đối chiếu backup với manifest của thư mục ngày
+type ManifestCheck struct {
+	Status    string `json:"status"`
+	Signature string `json:"signature,omitempty"`
+	Checksum  string `json:"checksum,omitempty"` // Checksum ghi trong manifest
+	Message   string `json:"message,omitempty"`
+}
+
+// Report là kết quả kiểm tra toàn vẹn của một backup
+type Report struct {
+	BackupID int64         `json:"backup_id"`
+	Name     string        `json:"name"`
+	Checksum string        `json:"checksum"` // Checksum trong catalog
+	OK       bool          `json:"ok"`
+	Local    CopyCheck     `json:"local"`
+	Remote   []CopyCheck   `json:"remote"`
+	Manifest ManifestCheck `json:"manifest"`
+	Problems []string      `json:"problems,omitempty"`
+}
+
+// VerifyBackup tính lại SHA-256 của file cục bộ và các bản sao trên destination,
+// đối chiếu với checksum trong catalog và manifest của thư mục ngày
+func VerifyBackup(ctx context.Context, cfg *config.Config, storageManager *storage.Manager, backupID int64) (*Report, error) {
+	backup, err := backupdb.GetBackupByID(backupID)
+	if err != nil {
+		return nil, fmt.Errorf("không tìm thấy backup: %v", err)
+	}
+
+	report := &Report{
+		BackupID: backupID,
+		Name:     backup.Name,
+		Checksum: backup.Checksum,
+		Remote:   []CopyCheck{},
+	}
+
+	// Checksum đối chiếu: catalog, hoặc file cục bộ với backup cũ chưa có checksum
+	expected := backup.Checksum
+
+	report.Local = CopyCheck{Destination: "local", Key: backup.Path}
+	if !backup.FileExists {
+		report.Local.Status = StatusMissing
+		report.Local.Message = "File cục bộ không còn tồn tại"
+	} else if checksum, err := storage.FileChecksum(backup.Path); err != nil {
+		report.Local.Status = StatusError
+		report.Local.Message = err.Error()
+	} else {
+		report.Local.Checksum = checksum
+		report.Local.Status = compareChecksum(expected, checksum)
+		if expected == "" {
+			expected = checksum
+		}
+	}
+
+	uploads, err := backupdb.GetBackupUploads(backupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, upload := range uploads {
+		check := CopyCheck{Destination: upload.Destination, Key: upload.Key}
+		if storageManager == nil {
+			check.Status = StatusError
+			check.Message = "Storage chưa được khởi tạo"
+		} else if checksum, err := storageManager.HashCopy(ctx, upload); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				check.Status = StatusMissing
+			} else {
+				check.Status = StatusError
+			}
+			check.Message = err.Error()
+		} else {
+			check.Checksum = checksum
+			check.Status = compareChecksum(expected, checksum)
+		}
+		report.Remote = append(report.Remote, check)
+	}
+
+	report.Manifest = checkManifest(cfg, filepath.Dir(backup.Path), backup.Name, expected)
+
+	// Tổng hợp các vấn đề; file cục bộ đã xóa không phải lỗi nếu còn bản sao trên destination
+	if report.Local.Status == StatusMismatch || report.Local.Status == StatusError ||
+		(report.Local.Status == StatusMissing && len(uploads) == 0) {
+		report.Problems = append(report.Problems, fmt.Sprintf("local: %s", report.Local.Status))
+	}
+	for _, check := range report.Remote {
+		if check.Status != StatusOK && check.Status != StatusNoChecksum {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: %s", check.Destination, check.Status))
+		}
+	}
+	if report.Manifest.Status == StatusMismatch {
+		report.Problems = append(report.Problems, "manifest: mismatch")
+	}
+	if report.Manifest.Signature == SignatureInvalid {
+		report.Problems = append(report.Problems, "manifest: chữ ký không hợp lệ")
+	}
+	report.OK = len(report.Problems) == 0
+
+	return report, nil
+}
+
+// compareChecksum so sánh checksum tính lại với checksum đối chiếu
+func compareChecksum(expected, actual string) string {
+	if expected == "" {
+		return StatusNoChecksum
+	}
+	if !strings.EqualFold(expected, actual) {
+		return StatusMismatch
+	}
+	return StatusOK
+}
+
+// checkManifest tìm backup trong manifest của thư mục dir và kiểm tra chữ ký nếu có khóa
+func checkManifest(cfg *config.Config, dir, name, expected string) ManifestCheck {
+	var check ManifestCheck
+
+	manifest, data, err := ReadManifest(dir)
+	if os.IsNotExist(err) {
+		check.Status = StatusMissing
+		check.Message = "Thư mục backup chưa có manifest"
+		return check
+	}
+	if err != nil {
+		check.Status = StatusError
+		check.Message = err.Error()
+		return check
+	}
+
+	entry := manifest.Entry(name)
+	if entry == nil {
+		check.Status = StatusMissing
+		check.Message = "Backup không có trong manifest"
+	} else {
+		check.Checksum = entry.SHA256
+		check.Status = compareChecksum(expected, entry.SHA256)
+	}
+
+	var publicKey ed25519.PublicKey
+	if cfg != nil && strings.TrimSpace(cfg.ManifestSigningKey) != "" {
+		key, err := ParseSigningKey(cfg.ManifestSigningKey)
+		if err != nil {
+			check.Message = strings.TrimSpace(check.Message + " " + err.Error())
+		} else {
+			publicKey = key.Public().(ed25519.PublicKey)
+		}
+	}
+
+	if publicKey == nil {
+		check.Signature = SignatureUnsigned
+		if _, err := os.Stat(filepath.Join(dir, SignatureFile)); err == nil {
+			check.Signature = SignatureUnverified
+		}
+		return check
+	}
+
+	switch err := VerifySignature(dir, data, publicKey); {
+	case err == nil:
+		check.Signature = SignatureValid
+	case errors.Is(err, ErrNoSignature):
+		check.Signature = SignatureUnsigned
+	default:
+		check.Signature = SignatureInvalid
+		check.Message = strings.TrimSpace(check.Message + " " + err.Error())
+	}
+	return check
+}
@@ -1,25 +1,176 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/backup-cronjob/internal/config"
+)
+
+// Các trường chuẩn gắn vào log của từng thành phần
+const (
+	KeyComponent = "component"
+	KeyProfileID = "profile_id"
+	KeyJobID     = "job_id"
+	KeyBackupID  = "backup_id"
+)
+
+// Định dạng output của log
+const (
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
+// DefaultBufferSize là số mục log gần nhất được giữ lại mặc định để truy vấn qua API
+const DefaultBufferSize = 2000
+
 // LogEntry đại diện cho một mục log
 type LogEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Level     string    `json:"level"`
-	Message   string    `json:"message"`
+	ID        int64                  `json:"id"`
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Component string                 `json:"component,omitempty"`
+	ProfileID int64                  `json:"profile_id,omitempty"`
+	JobID     int64                  `json:"job_id,omitempty"`
+	BackupID  int64                  `json:"backup_id,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Options là cấu hình của logger
+type Options struct {
+	Level      string    // debug, info, warn, error
+	Format     string    // text hoặc json
+	BufferSize int       // Số mục log gần nhất được giữ lại, 0 = không lưu
+	Output     io.Writer // Mặc định os.Stderr
+}
+
+var (
+	level = new(slog.LevelVar)
+	store = newRingBuffer(DefaultBufferSize)
+)
+
+// Setup thay logger mặc định bằng logger có cấp độ, định dạng text/JSON và lưu các mục gần nhất
+// vào bộ nhớ đệm. Log ghi bằng package log chuẩn cũng được chuyển qua logger này.
+// Có thể gọi lại khi cấu hình thay đổi, các mục log đã lưu được giữ nguyên
+func Setup(opts Options) error {
+	lvl, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var inner slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", FormatText:
+		inner = slog.NewTextHandler(output, handlerOpts)
+	case FormatJSON:
+		inner = slog.NewJSONHandler(output, handlerOpts)
+	default:
+		return fmt.Errorf("định dạng log không hợp lệ: %s (hỗ trợ: text, json)", opts.Format)
+	}
+
+	level.Set(lvl)
+	store.resize(opts.BufferSize)
+	slog.SetDefault(slog.New(&storeHandler{inner: inner, store: store}))
+
+	// slog.SetDefault đã chuyển package log sang logger mới với cấp INFO,
+	// thay bằng legacyWriter để đoán cấp độ của các log cũ theo nội dung
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(legacyWriter{})
+	return nil
+}
+
+// Configure áp dụng cấu hình log (LOG_LEVEL, LOG_FORMAT, LOG_BUFFER_SIZE) của ứng dụng
+func Configure(cfg *config.Config) error {
+	return Setup(Options{Level: cfg.LogLevel, Format: cfg.LogFormat, BufferSize: cfg.LogBufferSize})
+}
+
+// ParseLevel đọc cấp độ log, rỗng = info
+func ParseLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("cấp độ log không hợp lệ: %s (hỗ trợ: debug, info, warn, error)", value)
+}
+
+// levelName trả về tên cấp độ log dạng chữ thường
+func levelName(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "error"
+	case l >= slog.LevelWarn:
+		return "warn"
+	case l >= slog.LevelInfo:
+		return "info"
+	}
+	return "debug"
+}
+
+// Component trả về logger gắn trường component, ví dụ logger.Component("scheduler")
+func Component(name string) *slog.Logger {
+	return slog.Default().With(KeyComponent, name)
 }
 
 // Info ghi log cấp độ Info
 func Info(message string) {
-	// Ghi log vào console
-	log.Printf("[INFO] %s", message)
+	slog.Info(message)
 }
 
 // Error ghi log cấp độ Error
 func Error(message string) {
-	// Ghi log vào console
-	log.Printf("[ERROR] %s", message)
+	slog.Error(message)
+}
+
+// legacyWriter nhận output của package log chuẩn và ghi lại qua slog
+type legacyWriter struct{}
+
+// Write ghi một dòng log cũ với cấp độ được đoán theo tiền tố của thông báo
+func (legacyWriter) Write(p []byte) (int, error) {
+	message := strings.TrimRight(string(p), "\n")
+	lvl := guessLevel(message)
+
+	handler := slog.Default().Handler()
+	if handler.Enabled(context.Background(), lvl) {
+		record := slog.NewRecord(time.Now(), lvl, message, 0)
+		if err := handler.Handle(context.Background(), record); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// guessLevel đoán cấp độ của log cũ: thông báo lỗi là error, cảnh báo là warn
+func guessLevel(message string) slog.Level {
+	lower := strings.ToLower(message)
+	for _, prefix := range []string{"lỗi", "error", "không thể"} {
+		if strings.HasPrefix(lower, prefix) {
+			return slog.LevelError
+		}
+	}
+	for _, prefix := range []string{"cảnh báo", "warning", "bỏ qua"} {
+		if strings.HasPrefix(lower, prefix) {
+			return slog.LevelWarn
+		}
+	}
+	return slog.LevelInfo
 }
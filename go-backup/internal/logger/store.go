@@ -0,0 +1,234 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Filter là điều kiện truy vấn các mục log đã lưu
+type Filter struct {
+	Level     slog.Level // Cấp độ tối thiểu
+	Component string
+	ProfileID int64
+	JobID     int64
+	BackupID  int64
+	Since     time.Time
+	Limit     int // 0 = không giới hạn
+}
+
+// ringBuffer giữ các mục log gần nhất, mục cũ nhất bị ghi đè khi đầy
+type ringBuffer struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int // Vị trí ghi mục tiếp theo
+	count   int
+	lastID  int64
+}
+
+// newRingBuffer tạo bộ nhớ đệm chứa tối đa size mục log
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{entries: make([]LogEntry, size)}
+}
+
+// add thêm một mục log vào bộ nhớ đệm
+func (b *ringBuffer) add(entry LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.entries) == 0 {
+		return
+	}
+	b.lastID++
+	entry.ID = b.lastID
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.count < len(b.entries) {
+		b.count++
+	}
+}
+
+// resize đổi kích thước bộ nhớ đệm, giữ lại các mục mới nhất
+func (b *ringBuffer) resize(size int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if size < 0 {
+		size = 0
+	}
+	if size == len(b.entries) {
+		return
+	}
+	kept := b.newestFirst()
+	if len(kept) > size {
+		kept = kept[:size]
+	}
+	b.entries = make([]LogEntry, size)
+	b.count = len(kept)
+	for i := range kept {
+		b.entries[i] = kept[len(kept)-1-i]
+	}
+	b.next = 0
+	if size > 0 {
+		b.next = b.count % size
+	}
+}
+
+// newestFirst trả về các mục log theo thứ tự mới nhất trước, gọi khi đã giữ khóa
+func (b *ringBuffer) newestFirst() []LogEntry {
+	result := make([]LogEntry, 0, b.count)
+	for i := 1; i <= b.count; i++ {
+		result = append(result, b.entries[(b.next-i+len(b.entries))%len(b.entries)])
+	}
+	return result
+}
+
+// Query trả về các mục log đã lưu khớp điều kiện, mới nhất trước
+func Query(filter Filter) []LogEntry {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	result := []LogEntry{}
+	minLevel := levelName(filter.Level)
+	for _, entry := range store.newestFirst() {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		if !filter.Since.IsZero() && entry.Timestamp.Before(filter.Since) {
+			continue
+		}
+		if levelRank(entry.Level) < levelRank(minLevel) {
+			continue
+		}
+		if (filter.Component != "" && entry.Component != filter.Component) ||
+			(filter.ProfileID != 0 && entry.ProfileID != filter.ProfileID) ||
+			(filter.JobID != 0 && entry.JobID != filter.JobID) ||
+			(filter.BackupID != 0 && entry.BackupID != filter.BackupID) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// levelRank trả về thứ tự của cấp độ log để so sánh
+func levelRank(name string) int {
+	switch name {
+	case "debug":
+		return 0
+	case "info":
+		return 1
+	case "warn":
+		return 2
+	}
+	return 3
+}
+
+// storeHandler chuyển log cho handler text/JSON và lưu một bản vào bộ nhớ đệm
+type storeHandler struct {
+	inner slog.Handler
+	store *ringBuffer
+	attrs []slog.Attr // Các trường thêm bằng With, tên đã gồm tiền tố group
+	group string      // Tiền tố group hiện tại, ví dụ "request."
+}
+
+func (h *storeHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h *storeHandler) Handle(ctx context.Context, r slog.Record) error {
+	entry := LogEntry{
+		Timestamp: r.Time,
+		Level:     levelName(r.Level),
+		Message:   r.Message,
+	}
+	for _, attr := range h.attrs {
+		entry.addAttr("", attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		entry.addAttr(h.group, attr)
+		return true
+	})
+	h.store.add(entry)
+
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *storeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	merged := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	merged = append(merged, h.attrs...)
+	for _, attr := range attrs {
+		attr.Key = h.group + attr.Key
+		merged = append(merged, attr)
+	}
+	return &storeHandler{inner: h.inner.WithAttrs(attrs), store: h.store, attrs: merged, group: h.group}
+}
+
+func (h *storeHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &storeHandler{inner: h.inner.WithGroup(name), store: h.store, attrs: h.attrs, group: h.group + name + "."}
+}
+
+// addAttr gán trường chuẩn (component, profile_id, job_id, backup_id) hoặc thêm vào Fields
+func (e *LogEntry) addAttr(prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	key := prefix + attr.Key
+
+	if value.Kind() == slog.KindGroup {
+		for _, child := range value.Group() {
+			if attr.Key == "" {
+				e.addAttr(prefix, child)
+			} else {
+				e.addAttr(key+".", child)
+			}
+		}
+		return
+	}
+	if attr.Key == "" {
+		return
+	}
+
+	switch key {
+	case KeyComponent:
+		e.Component = value.String()
+		return
+	case KeyProfileID, KeyJobID, KeyBackupID:
+		if id, ok := int64Value(value); ok {
+			switch key {
+			case KeyProfileID:
+				e.ProfileID = id
+			case KeyJobID:
+				e.JobID = id
+			default:
+				e.BackupID = id
+			}
+			return
+		}
+	}
+
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
+	}
+	switch v := value.Any().(type) {
+	case error:
+		e.Fields[key] = v.Error()
+	case time.Duration:
+		e.Fields[key] = v.String()
+	default:
+		e.Fields[key] = v
+	}
+}
+
+// int64Value đọc giá trị số nguyên của trường ID
+func int64Value(value slog.Value) (int64, bool) {
+	switch value.Kind() {
+	case slog.KindInt64:
+		return value.Int64(), true
+	case slog.KindUint64:
+		return int64(value.Uint64()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/database"
+)
+
+// ProfileFamilies đọc từ job_logs và danh mục backup thời điểm, thời gian chạy và kích thước
+// của lần backup thành công gần nhất cùng thời điểm thất bại gần nhất của từng profile.
+// Giá trị được đọc từ database nên không bị mất khi ứng dụng khởi động lại
+func ProfileFamilies() ([]Family, error) {
+	profiles, err := database.GetAllProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy danh sách profile: %v", err)
+	}
+
+	active := Family{Name: "profile_active", Help: "Profile đang bật (1) hoặc tắt (0)", Type: TypeGauge}
+	lastSuccess := Family{Name: "last_success_timestamp_seconds", Help: "Thời điểm kết thúc lần backup thành công gần nhất (unix)", Type: TypeGauge}
+	lastDuration := Family{Name: "last_duration_seconds", Help: "Thời gian chạy của lần backup thành công gần nhất", Type: TypeGauge}
+	lastSize := Family{Name: "last_backup_size_bytes", Help: "Kích thước file của lần backup thành công gần nhất", Type: TypeGauge}
+	lastFailure := Family{Name: "last_failure_timestamp_seconds", Help: "Thời điểm kết thúc lần backup thất bại gần nhất (unix)", Type: TypeGauge}
+
+	for _, profile := range profiles {
+		labels := profileLabels(profile.ID, profile.Name)
+		active.Samples = append(active.Samples, Sample{Labels: labels, Value: boolValue(profile.IsActive)})
+
+		jobLog, found, err := database.GetLastBackupJobLog(profile.ID, database.JobStatusSuccess)
+		if err != nil {
+			return nil, fmt.Errorf("không thể đọc lịch sử backup của profile '%s': %v", profile.Name, err)
+		}
+		if found && !jobLog.EndTime.IsZero() {
+			lastSuccess.Samples = append(lastSuccess.Samples, Sample{Labels: labels, Value: unixSeconds(jobLog.EndTime)})
+			lastDuration.Samples = append(lastDuration.Samples, Sample{Labels: labels, Value: jobLog.EndTime.Sub(jobLog.StartTime).Seconds()})
+			if jobLog.BackupFile != "" {
+				if backup, err := backupdb.GetBackupByPath(jobLog.BackupFile); err == nil {
+					lastSize.Samples = append(lastSize.Samples, Sample{Labels: labels, Value: float64(backup.Size)})
+				}
+			}
+		}
+
+		var failedAt time.Time
+		for _, status := range []string{database.JobStatusFailed, database.JobStatusTimeout} {
+			jobLog, found, err := database.GetLastBackupJobLog(profile.ID, status)
+			if err != nil {
+				return nil, fmt.Errorf("không thể đọc lịch sử backup của profile '%s': %v", profile.Name, err)
+			}
+			if found && jobLog.EndTime.After(failedAt) {
+				failedAt = jobLog.EndTime
+			}
+		}
+		if !failedAt.IsZero() {
+			lastFailure.Samples = append(lastFailure.Samples, Sample{Labels: labels, Value: unixSeconds(failedAt)})
+		}
+	}
+
+	return []Family{active, lastSuccess, lastDuration, lastSize, lastFailure}, nil
+}
+
+// QueueFamilies trả về số job đang chờ và đang chạy trong hàng đợi backup
+func QueueFamilies(queued, running int) []Family {
+	return []Family{
+		{Name: "queue_depth", Help: "Số job backup đang chờ trong hàng đợi", Type: TypeGauge,
+			Samples: []Sample{{Value: float64(queued)}}},
+		{Name: "running_jobs", Help: "Số job backup đang chạy", Type: TypeGauge,
+			Samples: []Sample{{Value: float64(running)}}},
+	}
+}
+
+// DriveToken là token Google Drive mà một profile dùng để upload
+type DriveToken struct {
+	ProfileID   int64
+	Profile     string
+	Expiry      time.Time
+	Refreshable bool // Có refresh token để tự gia hạn
+}
+
+// DriveTokenFamilies trả về thời điểm hết hạn của access token Google Drive theo profile
+// và token có refresh token để tự gia hạn hay không
+func DriveTokenFamilies(tokens []DriveToken) []Family {
+	expiry := Family{Name: "drive_token_expiry_timestamp_seconds", Help: "Thời điểm hết hạn của access token Google Drive (unix)", Type: TypeGauge}
+	refreshable := Family{Name: "drive_token_refreshable", Help: "Token Google Drive có refresh token (1) hay không (0)", Type: TypeGauge}
+	for _, token := range tokens {
+		labels := profileLabels(token.ProfileID, token.Profile)
+		if !token.Expiry.IsZero() {
+			expiry.Samples = append(expiry.Samples, Sample{Labels: labels, Value: unixSeconds(token.Expiry)})
+		}
+		refreshable.Samples = append(refreshable.Samples, Sample{Labels: labels, Value: boolValue(token.Refreshable)})
+	}
+	return []Family{expiry, refreshable}
+}
+
+// unixSeconds đổi thời điểm sang số giây unix
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// boolValue đổi bool sang 1 hoặc 0
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
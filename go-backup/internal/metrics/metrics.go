@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Namespace là tiền tố tên của tất cả metric
+const Namespace = "gobackup_"
+
+// ContentType là định dạng text của Prometheus (exposition format 0.0.4)
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Các loại metric
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeSummary = "summary"
+)
+
+// Label là một nhãn của metric
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample là một giá trị của metric. Suffix dùng cho _sum, _count của summary
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family là một metric cùng các giá trị theo nhãn
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Write ghi các metric theo định dạng text của Prometheus, bỏ qua metric không có giá trị
+func Write(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+		name := Namespace + family.Name
+		bw.WriteString("# HELP " + name + " " + escapeHelp(family.Help) + "\n")
+		bw.WriteString("# TYPE " + name + " " + family.Type + "\n")
+		for _, sample := range family.Samples {
+			bw.WriteString(name + sample.Suffix)
+			if len(sample.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, label := range sample.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(label.Name + `="` + escapeLabel(label.Value) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// escapeHelp escape chuỗi help theo định dạng text
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel escape giá trị nhãn theo định dạng text
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+// formatValue định dạng giá trị float theo định dạng text
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// jobKey là nhãn của bộ đếm job backup
+type jobKey struct {
+	profileID int64
+	profile   string
+	status    string
+}
+
+// uploadStats là số liệu upload lên một destination
+type uploadStats struct {
+	bytes    float64
+	seconds  float64
+	count    float64
+	failures float64
+}
+
+var (
+	mu      sync.Mutex
+	jobs    = make(map[jobKey]float64)
+	uploads = make(map[string]*uploadStats)
+)
+
+// ObserveJob đếm một job backup đã kết thúc với trạng thái status (success, failed, timeout...)
+func ObserveJob(profileID int64, profile, status string) {
+	mu.Lock()
+	defer mu.Unlock()
+	jobs[jobKey{profileID: profileID, profile: profile, status: status}]++
+}
+
+// ObserveUpload ghi nhận một lần upload lên destination: số byte và thời gian khi thành công, số lỗi khi thất bại
+func ObserveUpload(destination string, bytes int64, duration time.Duration, failed bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	stats, ok := uploads[destination]
+	if !ok {
+		stats = &uploadStats{}
+		uploads[destination] = stats
+	}
+	if failed {
+		stats.failures++
+		return
+	}
+	stats.bytes += float64(bytes)
+	stats.seconds += duration.Seconds()
+	stats.count++
+}
+
+// Collected trả về các bộ đếm được ghi nhận từ vòng đời job và upload kể từ khi ứng dụng khởi động
+func Collected() []Family {
+	mu.Lock()
+	defer mu.Unlock()
+
+	jobFamily := Family{Name: "backup_jobs_total", Help: "Số job backup đã kết thúc theo trạng thái", Type: TypeCounter}
+	keys := make([]jobKey, 0, len(jobs))
+	for key := range jobs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].profileID != keys[j].profileID {
+			return keys[i].profileID < keys[j].profileID
+		}
+		return keys[i].status < keys[j].status
+	})
+	for _, key := range keys {
+		jobFamily.Samples = append(jobFamily.Samples, Sample{
+			Labels: profileLabels(key.profileID, key.profile, Label{"status", key.status}),
+			Value:  jobs[key],
+		})
+	}
+
+	bytesFamily := Family{Name: "upload_bytes_total", Help: "Tổng số byte đã upload theo destination", Type: TypeCounter}
+	durationFamily := Family{Name: "upload_duration_seconds", Help: "Thời gian upload thành công theo destination", Type: TypeSummary}
+	failureFamily := Family{Name: "upload_failures_total", Help: "Số lần upload thất bại theo destination", Type: TypeCounter}
+	destinations := make([]string, 0, len(uploads))
+	for name := range uploads {
+		destinations = append(destinations, name)
+	}
+	sort.Strings(destinations)
+	for _, name := range destinations {
+		stats := uploads[name]
+		labels := []Label{{"destination", name}}
+		bytesFamily.Samples = append(bytesFamily.Samples, Sample{Labels: labels, Value: stats.bytes})
+		durationFamily.Samples = append(durationFamily.Samples,
+			Sample{Suffix: "_sum", Labels: labels, Value: stats.seconds},
+			Sample{Suffix: "_count", Labels: labels, Value: stats.count},
+		)
+		failureFamily.Samples = append(failureFamily.Samples, Sample{Labels: labels, Value: stats.failures})
+	}
+
+	return []Family{jobFamily, bytesFamily, durationFamily, failureFamily}
+}
+
+// profileLabels tạo nhãn profile_id, profile cùng các nhãn bổ sung
+func profileLabels(profileID int64, profile string, extra ...Label) []Label {
+	labels := []Label{{"profile_id", strconv.FormatInt(profileID, 10)}, {"profile", profile}}
+	return append(labels, extra...)
+}
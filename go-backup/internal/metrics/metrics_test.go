@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeString ghi các metric và trả về nội dung text
+func writeString(t *testing.T, families []Family) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, families); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWrite(t *testing.T) {
+	families := []Family{
+		{
+			Name: "backup_jobs_total",
+			Help: "Số job backup đã kết thúc",
+			Type: TypeCounter,
+			Samples: []Sample{
+				{Labels: profileLabels(1, "shop", Label{"status", "success"}), Value: 3},
+				{Labels: profileLabels(2, "billing", Label{"status", "failed"}), Value: 1},
+			},
+		},
+		// Metric không có giá trị bị bỏ qua, kể cả HELP và TYPE
+		{Name: "empty", Help: "Không có giá trị", Type: TypeGauge},
+		{
+			Name: "upload_duration_seconds",
+			Help: "Thời gian upload",
+			Type: TypeSummary,
+			Samples: []Sample{
+				{Suffix: "_sum", Labels: []Label{{"destination", "s3"}}, Value: 1.5},
+				{Suffix: "_count", Labels: []Label{{"destination", "s3"}}, Value: 2},
+			},
+		},
+		{Name: "queue_depth", Help: "Số job đang chờ", Type: TypeGauge, Samples: []Sample{{Value: 0}}},
+	}
+
+	want := `# HELP gobackup_backup_jobs_total Số job backup đã kết thúc
+# TYPE gobackup_backup_jobs_total counter
+gobackup_backup_jobs_total{profile_id="1",profile="shop",status="success"} 3
+gobackup_backup_jobs_total{profile_id="2",profile="billing",status="failed"} 1
+# HELP gobackup_upload_duration_seconds Thời gian upload
+# TYPE gobackup_upload_duration_seconds summary
+gobackup_upload_duration_seconds_sum{destination="s3"} 1.5
+gobackup_upload_duration_seconds_count{destination="s3"} 2
+# HELP gobackup_queue_depth Số job đang chờ
+# TYPE gobackup_queue_depth gauge
+gobackup_queue_depth 0
+`
+	if got := writeString(t, families); got != want {
+		t.Errorf("Write =\n%s\nmuốn\n%s", got, want)
+	}
+}
+
+func TestWriteEscaping(t *testing.T) {
+	families := []Family{{
+		Name: "profile_active",
+		Help: "Help có \\ và\nxuống dòng",
+		Type: TypeGauge,
+		Samples: []Sample{
+			{Labels: profileLabels(1, `shop "main" \ kho`+"\n"+"2"), Value: 1},
+		},
+	}}
+
+	got := writeString(t, families)
+	wantHelp := `# HELP gobackup_profile_active Help có \\ và\nxuống dòng` + "\n"
+	if !strings.HasPrefix(got, wantHelp) {
+		t.Errorf("dòng HELP = %q, muốn %q", strings.SplitN(got, "\n", 2)[0], wantHelp)
+	}
+	wantSample := `gobackup_profile_active{profile_id="1",profile="shop \"main\" \\ kho\n2"} 1` + "\n"
+	if !strings.HasSuffix(got, wantSample) {
+		t.Errorf("nội dung =\n%s\nmuốn kết thúc bằng %q", got, wantSample)
+	}
+	// Mỗi dòng là một HELP, TYPE hoặc một giá trị: giá trị chứa xuống dòng không làm vỡ định dạng
+	if lines := strings.Count(got, "\n"); lines != 3 {
+		t.Errorf("số dòng = %d, muốn 3:\n%s", lines, got)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{0.25, "0.25"},
+		{1.7e9, "1.7e+09"},
+		{-3, "-3"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+	}
+	for _, tt := range tests {
+		if got := formatValue(tt.value); got != tt.want {
+			t.Errorf("formatValue(%v) = %q, muốn %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDriveTokenFamilies(t *testing.T) {
+	expiry := time.Unix(1700000000, 0)
+	families := DriveTokenFamilies([]DriveToken{
+		{ProfileID: 1, Profile: "shop", Expiry: expiry, Refreshable: true},
+		// Token không có thời điểm hết hạn chỉ có metric refreshable
+		{ProfileID: 2, Profile: "billing"},
+	})
+
+	want := `# HELP gobackup_drive_token_expiry_timestamp_seconds Thời điểm hết hạn của access token Google Drive (unix)
+# TYPE gobackup_drive_token_expiry_timestamp_seconds gauge
+gobackup_drive_token_expiry_timestamp_seconds{profile_id="1",profile="shop"} 1.7e+09
+# HELP gobackup_drive_token_refreshable Token Google Drive có refresh token (1) hay không (0)
+# TYPE gobackup_drive_token_refreshable gauge
+gobackup_drive_token_refreshable{profile_id="1",profile="shop"} 1
+gobackup_drive_token_refreshable{profile_id="2",profile="billing"} 0
+`
+	if got := writeString(t, families); got != want {
+		t.Errorf("DriveTokenFamilies =\n%s\nmuốn\n%s", got, want)
+	}
+
+	// Không có token nào: không xuất metric
+	if got := writeString(t, DriveTokenFamilies(nil)); got != "" {
+		t.Errorf("DriveTokenFamilies(nil) = %q, muốn rỗng", got)
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Các phạm vi (scope) của API token
+const (
+	ScopeBackupsRead   = "backups:read"   // Xem danh sách backup, profile, lịch, log và tải backup
+	ScopeBackupsRun    = "backups:run"    // Chạy backup ngay, hủy job
+	ScopeBackupsUpload = "backups:upload" // Upload backup lên destination
+	ScopeBackupsVerify = "backups:verify" // Kiểm tra checksum, restore drill
+	ScopeScheduleWrite = "schedule:write" // Tạm dừng, tiếp tục lịch backup và chế độ bảo trì
+	ScopeAll           = "*"              // Tất cả quyền của vai trò người tạo token
+)
+
+// APIToken là token cá nhân dùng cho tự động hóa (CI, script), chỉ lưu hash SHA-256 của token
+type APIToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Username   string     `json:"username,omitempty"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // Vài ký tự đầu của token để nhận diện
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ProfileIDs []int64    `json:"profile_ids"` // Rỗng = tất cả profile
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APITokenScopes trả về các scope được hỗ trợ
+func APITokenScopes() []string {
+	return []string{ScopeBackupsRead, ScopeBackupsRun, ScopeBackupsUpload, ScopeBackupsVerify, ScopeScheduleWrite, ScopeAll}
+}
+
+// IsValidAPITokenScope kiểm tra scope có được hỗ trợ hay không
+func IsValidAPITokenScope(scope string) bool {
+	for _, s := range APITokenScopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope kiểm tra token có scope hay không, ScopeAll bao gồm mọi scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProfile kiểm tra token có được dùng với profile hay không
+func (t *APIToken) AllowsProfile(profileID int64) bool {
+	if len(t.ProfileIDs) == 0 {
+		return true
+	}
+	for _, id := range t.ProfileIDs {
+		if id == profileID {
+			return true
+		}
+	}
+	return false
+}
+
+// Active kiểm tra token chưa bị thu hồi và chưa hết hạn
+func (t *APIToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
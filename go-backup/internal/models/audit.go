@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Loại người thực hiện hành động trong audit log
+const (
+	AuditActorUser     = "user"      // Người dùng đăng nhập bằng mật khẩu/JWT
+	AuditActorAPIToken = "api_token" // Người dùng gọi API bằng API token
+	AuditActorSystem   = "system"    // Scheduler và các tác vụ tự động
+)
+
+// Kết quả của hành động trong audit log
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+// AuditChange là giá trị trước và sau khi thay đổi của một trường, giá trị nhạy cảm đã được ẩn
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEvent là một bản ghi trong audit log, chỉ được thêm mới, không sửa hoặc xóa
+type AuditEvent struct {
+	ID         int64                  `json:"id"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Actor      string                 `json:"actor"`      // Username, hoặc "system"
+	ActorType  string                 `json:"actor_type"` // user, api_token, system
+	Action     string                 `json:"action"`     // Ví dụ: backup.delete, profile.update
+	TargetType string                 `json:"target_type,omitempty"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	Changes    map[string]AuditChange `json:"changes,omitempty"`
+	IP         string                 `json:"ip,omitempty"`
+	Result     string                 `json:"result"` // success, failure
+	Message    string                 `json:"message,omitempty"`
+}
+
+// AuditFilter là điều kiện lọc khi truy vấn audit log
+type AuditFilter struct {
+	Actor      string
+	Action     string // Khớp chính xác hoặc theo nhóm, ví dụ "backup" khớp "backup.delete"
+	TargetType string
+	TargetID   string
+	Result     string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
@@ -16,8 +16,41 @@ type BackupFile struct {
 	FileExists bool       `json:"fileExists,omitempty"`
 	UploadedAt *time.Time `json:"uploadedAt,omitempty"`
 	DriveLink  string     `json:"driveLink,omitempty"`
+	DumpFormat string     `json:"dumpFormat,omitempty"`
+	// Compression là thuật toán nén của file (none, gzip, zstd)
+	Compression string `json:"compression,omitempty"`
+	// OriginalSize là kích thước dữ liệu trước khi nén
+	OriginalSize int64 `json:"originalSize,omitempty"`
+	// Encryption là chế độ mã hóa của file (none, age, passphrase)
+	Encryption string `json:"encryption,omitempty"`
+	// Encrypted cho biết file đã được mã hóa hay chưa
+	Encrypted bool `json:"encrypted"`
+	// Checksum là SHA-256 (hex) của file backup, tính trong lúc dump
+	Checksum string `json:"checksum,omitempty"`
+	// Destinations là các nơi lưu trữ đã có bản sao của file (drive, s3...)
+	Destinations []string `json:"destinations,omitempty"`
+	// DrillStatus là kết quả restore drill gần nhất (passed, failed), rỗng nếu chưa kiểm tra
+	DrillStatus string `json:"drillStatus,omitempty"`
+	// DrilledAt là thời điểm chạy restore drill gần nhất
+	DrilledAt *time.Time `json:"drilledAt,omitempty"`
+	// DrillDurationMs là thời gian chạy restore drill (mili giây)
+	DrillDurationMs int64 `json:"drillDurationMs,omitempty"`
+	// DrillMessage là kết quả các câu SQL kiểm tra hoặc lỗi của restore drill
+	DrillMessage string `json:"drillMessage,omitempty"`
+	// ProfileID là profile đã tạo backup, 0 nếu không xác định được (backup cũ hoặc cấu hình tạm từ configs)
+	ProfileID int64 `json:"profileId,omitempty"`
+	// DBName là tên database nguồn của backup
+	DBName string `json:"dbName,omitempty"`
+	// JobLogID là job_log của lần chạy đã tạo backup, 0 nếu backup được tạo ngoài scheduler
+	JobLogID int64 `json:"jobLogId,omitempty"`
 }
 
+// Kết quả restore drill của một backup
+const (
+	DrillStatusPassed = "passed"
+	DrillStatusFailed = "failed"
+)
+
 // FormatSize trả về kích thước file đã được format
 func (b *BackupFile) FormatSize() string {
 	const (
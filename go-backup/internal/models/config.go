@@ -9,9 +9,10 @@ type AppConfig struct {
 	ID        int64     `json:"id"`
 	Key       string    `json:"key"`
 	Value     string    `json:"value"`
-	Group     string    `json:"group"` // Nhóm cấu hình (database, google, backup, system)
-	Label     string    `json:"label"` // Nhãn hiển thị trên UI
-	Type      string    `json:"type"`  // Loại dữ liệu (text, password, select, etc)
+	Group     string    `json:"group"`  // Nhóm cấu hình (database, google, backup, system)
+	Label     string    `json:"label"`  // Nhãn hiển thị trên UI
+	Type      string    `json:"type"`   // Loại dữ liệu (text, password, select, etc)
+	IsSet     bool      `json:"is_set"` // Cấu hình đã có giá trị hay chưa, dùng thay cho giá trị của cấu hình nhạy cảm
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -25,23 +26,77 @@ func DefaultConfigs() []AppConfig {
 		{Key: "DB_PASSWORD", Value: "", Group: "database", Label: "Mật khẩu Database", Type: "password", CreatedAt: now, UpdatedAt: now},
 		{Key: "CONTAINER_NAME", Value: "", Group: "database", Label: "Tên container Docker", Type: "text", CreatedAt: now, UpdatedAt: now},
 		{Key: "DB_NAME", Value: "", Group: "database", Label: "Tên Database", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "DB_HOST", Value: "", Group: "database", Label: "Host Database (kết nối trực tiếp, không dùng Docker)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "DB_PORT", Value: "5432", Group: "database", Label: "Port Database", Type: "text", CreatedAt: now, UpdatedAt: now},
 
 		// Nhóm Google Drive
 		{Key: "GOOGLE_CLIENT_ID", Value: "", Group: "google", Label: "Google Client ID", Type: "text", CreatedAt: now, UpdatedAt: now},
 		{Key: "GOOGLE_CLIENT_SECRET", Value: "", Group: "google", Label: "Google Client Secret", Type: "password", CreatedAt: now, UpdatedAt: now},
 		{Key: "FOLDER_DRIVE", Value: "", Group: "google", Label: "ID thư mục trên Google Drive", Type: "text", CreatedAt: now, UpdatedAt: now},
 
+		// Nhóm S3 (S3 hoặc dịch vụ tương thích S3 như MinIO)
+		{Key: "S3_ENDPOINT", Value: "", Group: "storage", Label: "S3 endpoint (ví dụ s3.amazonaws.com, minio:9000)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "S3_REGION", Value: "", Group: "storage", Label: "S3 region", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "S3_BUCKET", Value: "", Group: "storage", Label: "Tên bucket", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "S3_ACCESS_KEY", Value: "", Group: "storage", Label: "Access key", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "S3_SECRET_KEY", Value: "", Group: "storage", Label: "Secret key", Type: "password", CreatedAt: now, UpdatedAt: now},
+		{Key: "S3_USE_SSL", Value: "true", Group: "storage", Label: "Kết nối S3 qua HTTPS (true/false)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "S3_PREFIX", Value: "", Group: "storage", Label: "Thư mục (prefix) chứa backup trong bucket", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "LOCAL_MIRROR_DIR", Value: "", Group: "storage", Label: "Thư mục mirror backup (NFS, ổ đĩa ngoài) đã mount", Type: "text", CreatedAt: now, UpdatedAt: now},
+
 		// Nhóm Backup
 		{Key: "BACKUP_DIR", Value: "./backups", Group: "backup", Label: "Thư mục lưu backup", Type: "text", CreatedAt: now, UpdatedAt: now},
 		{Key: "BACKUP_RETENTION_DAYS", Value: "", Group: "backup", Label: "Số ngày lưu giữ backup", Type: "number", CreatedAt: now, UpdatedAt: now},
 		{Key: "CRON_SCHEDULE", Value: "", Group: "backup", Label: "Lịch backup tự động (Cron format)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: ConfigSchedulesPaused, Value: "false", Group: "backup", Label: "Tạm dừng tất cả lịch backup (true/false)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "BACKUP_WORKERS", Value: "", Group: "backup", Label: "Số backup chạy đồng thời (cần khởi động lại)", Type: "number", CreatedAt: now, UpdatedAt: now},
+		{Key: "BACKUP_QUEUE_TIMEOUT", Value: "", Group: "backup", Label: "Số phút tối đa một backup chờ trong hàng đợi (0 = không giới hạn)", Type: "number", CreatedAt: now, UpdatedAt: now},
+		{Key: "ENCRYPTION_AGE_IDENTITY", Value: "", Group: "backup", Label: "Age identity (AGE-SECRET-KEY-...) để giải mã backup", Type: "password", CreatedAt: now, UpdatedAt: now},
+		{Key: "MANIFEST_SIGNING_KEY", Value: "", Group: "backup", Label: "Khóa ed25519 (PEM hoặc base64) để ký manifest của thư mục backup", Type: "password", CreatedAt: now, UpdatedAt: now},
+
+		// Nhóm Thông báo
+		{Key: "NOTIFY_SMTP_HOST", Value: "", Group: "notify", Label: "SMTP host gửi email thông báo", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_SMTP_PORT", Value: "587", Group: "notify", Label: "SMTP port (465 = TLS, 587/25 = STARTTLS nếu server hỗ trợ)", Type: "number", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_SMTP_USERNAME", Value: "", Group: "notify", Label: "Tên đăng nhập SMTP (để trống nếu không cần xác thực)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_SMTP_PASSWORD", Value: "", Group: "notify", Label: "Mật khẩu SMTP", Type: "password", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_SMTP_FROM", Value: "", Group: "notify", Label: "Địa chỉ email gửi", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_EMAIL_TO", Value: "", Group: "notify", Label: "Email nhận thông báo (phân cách bằng dấu phẩy)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_WEBHOOK_URL", Value: "", Group: "notify", Label: "Incoming webhook (Slack, Mattermost...)", Type: "password", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_TELEGRAM_BOT_TOKEN", Value: "", Group: "notify", Label: "Token của Telegram bot", Type: "password", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_TELEGRAM_CHAT_ID", Value: "", Group: "notify", Label: "Telegram chat ID nhận thông báo", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_TELEGRAM_API_URL", Value: "https://api.telegram.org", Group: "notify", Label: "Địa chỉ Telegram Bot API", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_DIGEST_SCHEDULE", Value: "0 8 * * *", Group: "notify", Label: "Lịch gửi báo cáo tổng hợp (Cron format, cần khởi động lại)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_TEMPLATE_FAILURE", Value: "", Group: "notify", Label: "Mẫu thông báo backup thất bại (để trống = mặc định)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_TEMPLATE_SUCCESS", Value: "", Group: "notify", Label: "Mẫu thông báo backup thành công (để trống = mặc định)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_TEMPLATE_RECOVERY", Value: "", Group: "notify", Label: "Mẫu thông báo backup hoạt động trở lại (để trống = mặc định)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "NOTIFY_TEMPLATE_DIGEST", Value: "", Group: "notify", Label: "Mẫu báo cáo tổng hợp hằng ngày (để trống = mặc định)", Type: "text", CreatedAt: now, UpdatedAt: now},
 
 		// Nhóm Hệ thống
 		{Key: "ADMIN_USERNAME", Value: "admin", Group: "system", Label: "Tên đăng nhập Admin", Type: "text", CreatedAt: now, UpdatedAt: now},
 		{Key: "ADMIN_PASSWORD", Value: "admin123", Group: "system", Label: "Mật khẩu Admin", Type: "password", CreatedAt: now, UpdatedAt: now},
 		{Key: "JWT_SECRET", Value: "", Group: "system", Label: "JWT Secret Key", Type: "password", CreatedAt: now, UpdatedAt: now},
 		{Key: "WEBAPP_PORT", Value: "8080", Group: "system", Label: "Port cho Web UI", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "LOG_LEVEL", Value: "info", Group: "system", Label: "Cấp độ log tối thiểu (debug, info, warn, error)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "LOG_FORMAT", Value: "text", Group: "system", Label: "Định dạng log (text hoặc json)", Type: "text", CreatedAt: now, UpdatedAt: now},
+		{Key: "LOG_BUFFER_SIZE", Value: "2000", Group: "system", Label: "Số mục log gần nhất được giữ lại để xem qua API", Type: "number", CreatedAt: now, UpdatedAt: now},
+		{Key: "METRICS_TOKEN", Value: "", Group: "system", Label: "Bearer token bảo vệ endpoint /metrics (để trống = không yêu cầu)", Type: "password", CreatedAt: now, UpdatedAt: now},
+	}
+}
+
+// ConfigSchedulesPaused là key cấu hình tạm dừng tất cả lịch backup (chế độ bảo trì)
+const ConfigSchedulesPaused = "SCHEDULES_PAUSED"
+
+// MaskedValue là giá trị thay thế cho các cấu hình nhạy cảm khi trả về client
+const MaskedValue = "••••••••"
+
+// IsSecretConfig cho biết cấu hình có chứa dữ liệu nhạy cảm (mật khẩu, khóa) hay không
+func IsSecretConfig(key string) bool {
+	for _, cfg := range DefaultConfigs() {
+		if cfg.Key == key {
+			return cfg.Type == "password"
+		}
 	}
+	return false
 }
 
 // ConfigGroups trả về danh sách các nhóm cấu hình
@@ -49,7 +104,9 @@ func ConfigGroups() []string {
 	return []string{
 		"database", // Cấu hình kết nối database
 		"google",   // Cấu hình Google Drive API
+		"storage",  // Cấu hình S3/MinIO và thư mục mirror
 		"backup",   // Cấu hình backup
+		"notify",   // Cấu hình kênh thông báo
 		"system",   // Cấu hình hệ thống
 	}
 }
@@ -59,7 +116,9 @@ func ConfigGroupLabels() map[string]string {
 	return map[string]string{
 		"database": "Cấu hình Database",
 		"google":   "Cấu hình Google Drive",
+		"storage":  "Cấu hình lưu trữ (S3/MinIO, thư mục mirror)",
 		"backup":   "Cấu hình Backup",
+		"notify":   "Cấu hình Thông báo",
 		"system":   "Cấu hình Hệ thống",
 	}
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+)
+
+// Các nơi lưu trữ backup ngoài máy chủ (destination) mà profile có thể chọn
+const (
+	DestinationDrive = "drive" // Google Drive
+	DestinationS3    = "s3"    // S3 hoặc dịch vụ tương thích S3 (MinIO...)
+	DestinationLocal = "local" // Thư mục mirror đã mount (NFS, ổ đĩa ngoài...)
+	DestinationSFTP  = "sftp"  // SFTP server, cấu hình theo từng profile
+)
+
+// Destinations trả về danh sách destination được hỗ trợ
+func Destinations() []string {
+	return []string{DestinationDrive, DestinationS3, DestinationLocal, DestinationSFTP}
+}
+
+// IsValidDestination kiểm tra destination có được hỗ trợ không
+func IsValidDestination(name string) bool {
+	for _, d := range Destinations() {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDestinations đọc danh sách destination lưu dạng "drive,s3", bỏ phần tử rỗng và trùng lặp
+func ParseDestinations(value string) []string {
+	destinations := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || containsString(destinations, name) {
+			continue
+		}
+		destinations = append(destinations, name)
+	}
+	return destinations
+}
+
+// JoinDestinations chuyển danh sách destination thành chuỗi để lưu vào database
+func JoinDestinations(destinations []string) string {
+	return strings.Join(ParseDestinations(strings.Join(destinations, ",")), ",")
+}
+
+// HasDestination cho biết profile có upload backup lên destination name hay không
+func (p DatabaseProfile) HasDestination(name string) bool {
+	return containsString(p.Destinations, name)
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package models
+
+// Các định dạng dump được hỗ trợ
+const (
+	DumpFormatPlain      = "plain"       // SQL đầy đủ schema + dữ liệu
+	DumpFormatCustom     = "custom"      // pg_dump -Fc, khôi phục bằng pg_restore
+	DumpFormatDirectory  = "directory"   // pg_dump -Fd, được đóng gói thành file tar
+	DumpFormatSchemaOnly = "schema-only" // SQL chỉ chứa schema
+	DumpFormatDataOnly   = "data-only"   // SQL chỉ chứa dữ liệu dạng INSERT (định dạng cũ)
+)
+
+// DefaultDumpFormat là định dạng mặc định cho profile mới
+const DefaultDumpFormat = DumpFormatPlain
+
+// DumpFormats trả về danh sách định dạng dump hợp lệ
+func DumpFormats() []string {
+	return []string{DumpFormatPlain, DumpFormatCustom, DumpFormatDirectory, DumpFormatSchemaOnly, DumpFormatDataOnly}
+}
+
+// IsValidDumpFormat kiểm tra định dạng dump có được hỗ trợ không
+func IsValidDumpFormat(format string) bool {
+	for _, f := range DumpFormats() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// IsArchiveDumpFormat cho biết định dạng cần khôi phục bằng pg_restore thay vì psql
+func IsArchiveDumpFormat(format string) bool {
+	return format == DumpFormatCustom || format == DumpFormatDirectory
+}
+
+// DumpFormatSuffix trả về phần đuôi tên file backup theo định dạng
+func DumpFormatSuffix(format string) string {
+	switch format {
+	case DumpFormatCustom:
+		return ".dump"
+	case DumpFormatDirectory:
+		return "_dir.tar"
+	case DumpFormatSchemaOnly:
+		return "_schema.sql"
+	case DumpFormatDataOnly:
+		return "_data.sql"
+	default:
+		return "_full.sql"
+	}
+}
+
+// DumpFormatContentType trả về Content-Type khi tải file backup xuống
+func DumpFormatContentType(format string) string {
+	switch format {
+	case DumpFormatCustom:
+		return "application/octet-stream"
+	case DumpFormatDirectory:
+		return "application/x-tar"
+	default:
+		return "application/sql"
+	}
+}
+
+// BackupFileExtensions trả về các phần mở rộng của file backup, kể cả file đã nén hoặc mã hóa
+func BackupFileExtensions() []string {
+	return []string{".sql", ".dump", ".tar", ".sql.gz", ".sql.zst", ".age", ".enc"}
+}
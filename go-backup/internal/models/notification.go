@@ -0,0 +1,18 @@
+package models
+
+// Các kênh gửi thông báo kết quả backup
+const (
+	NotifyChannelEmail    = "email"    // Email qua SMTP
+	NotifyChannelWebhook  = "webhook"  // Incoming webhook kiểu Slack/Mattermost
+	NotifyChannelTelegram = "telegram" // Telegram Bot API
+)
+
+// NotifyChannels trả về danh sách kênh thông báo được hỗ trợ
+func NotifyChannels() []string {
+	return []string{NotifyChannelEmail, NotifyChannelWebhook, NotifyChannelTelegram}
+}
+
+// IsValidNotifyChannel kiểm tra kênh thông báo có được hỗ trợ không
+func IsValidNotifyChannel(name string) bool {
+	return containsString(NotifyChannels(), name)
+}
@@ -6,23 +6,80 @@ import (
 
 // DatabaseProfile đại diện cho một profile cấu hình database
 type DatabaseProfile struct {
-	ID                 int64     `json:"id"`
-	Name               string    `json:"name"`                 // Tên profile
-	Description        string    `json:"description"`          // Mô tả
-	DBUser             string    `json:"db_user"`              // Tên đăng nhập Database
-	DBPassword         string    `json:"db_password"`          // Mật khẩu Database
-	ContainerName      string    `json:"container_name"`       // Tên container Docker
-	DBName             string    `json:"db_name"`              // Tên Database
-	IsActive           bool      `json:"is_active"`            // Trạng thái hoạt động
-	GoogleClientID     string    `json:"google_client_id"`     // Google Client ID
-	GoogleClientSecret string    `json:"google_client_secret"` // Google Client Secret
-	BackupDir          string    `json:"backup_dir"`           // Thư mục lưu backup
-	CronSchedule       string    `json:"cron_schedule"`        // Lịch backup tự động
-	BackupRetention    int       `json:"backup_retention"`     // Số ngày giữ file backup
-	UploadToDrive      bool      `json:"upload_to_drive"`      // Tự động upload lên Google Drive
-	FolderDrive        string    `json:"folder_drive"`         // Tên thư mục trên Google Drive
-	CreatedAt          time.Time `json:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at"`
+	ID                   int64      `json:"id"`
+	Name                 string     `json:"name"`                   // Tên profile
+	Description          string     `json:"description"`            // Mô tả
+	DBUser               string     `json:"db_user"`                // Tên đăng nhập Database
+	DBPassword           string     `json:"db_password"`            // Mật khẩu Database
+	ContainerName        string     `json:"container_name"`         // Tên container Docker
+	DBName               string     `json:"db_name"`                // Tên Database
+	IsActive             bool       `json:"is_active"`              // Trạng thái hoạt động
+	GoogleClientID       string     `json:"google_client_id"`       // Google Client ID
+	GoogleClientSecret   string     `json:"google_client_secret"`   // Google Client Secret
+	BackupDir            string     `json:"backup_dir"`             // Thư mục lưu backup
+	CronSchedule         string     `json:"cron_schedule"`          // Lịch backup tự động
+	BackupRetention      int        `json:"backup_retention"`       // Số ngày giữ file backup
+	RetentionKeepLast    int        `json:"retention_keep_last"`    // Luôn giữ N bản backup mới nhất
+	RetentionKeepDaily   int        `json:"retention_keep_daily"`   // Giữ bản mới nhất của N ngày gần nhất
+	RetentionKeepWeekly  int        `json:"retention_keep_weekly"`  // Giữ bản mới nhất của N tuần gần nhất
+	RetentionKeepMonthly int        `json:"retention_keep_monthly"` // Giữ bản mới nhất của N tháng gần nhất
+	Destinations         []string   `json:"destinations"`           // Nơi upload backup sau khi dump: drive, s3, local, sftp
+	UploadToDrive        bool       `json:"upload_to_drive"`        // Giữ để tương thích: true khi destinations có drive
+	FolderDrive          string     `json:"folder_drive"`           // Tên thư mục trên Google Drive
+	ConnectionMode       string     `json:"connection_mode"`        // Cách kết nối: docker hoặc tcp
+	DBHost               string     `json:"db_host"`                // Host của PostgreSQL (chế độ tcp)
+	DBPort               int        `json:"db_port"`                // Port của PostgreSQL (chế độ tcp)
+	SSLMode              string     `json:"ssl_mode"`               // sslmode của libpq (disable, require, verify-full...)
+	SSLRootCert          string     `json:"ssl_root_cert"`          // Nội dung CA certificate (PEM) dùng để xác thực server
+	DumpFormat           string     `json:"dump_format"`            // Định dạng dump: plain, custom, directory, schema-only, data-only
+	Compression          string     `json:"compression"`            // Thuật toán nén file dump: none, gzip, zstd
+	CompressionLevel     int        `json:"compression_level"`      // Mức nén, 0 = mặc định của thuật toán
+	Encryption           string     `json:"encryption"`             // Chế độ mã hóa file backup: none, age, passphrase
+	EncryptionRecipients string     `json:"encryption_recipients"`  // Khóa công khai age (age1...), mỗi khóa một dòng
+	EncryptionPassphrase string     `json:"encryption_passphrase"`  // Passphrase sinh khóa AES-256-GCM, không trả về client
+	SFTPHost             string     `json:"sftp_host"`              // Host của SFTP server (destination sftp)
+	SFTPPort             int        `json:"sftp_port"`              // Port của SFTP server, mặc định 22
+	SFTPUser             string     `json:"sftp_user"`              // Tên đăng nhập SFTP
+	SFTPPassword         string     `json:"sftp_password"`          // Mật khẩu SFTP, không trả về client
+	SFTPPrivateKey       string     `json:"sftp_private_key"`       // Private key (PEM/OpenSSH) đăng nhập SFTP, không trả về client
+	SFTPHostKey          string     `json:"sftp_host_key"`          // Public key hoặc fingerprint SHA256 của server để xác thực host
+	SFTPRemoteDir        string     `json:"sftp_remote_dir"`        // Thư mục gốc chứa backup trên server
+	SchedulePaused       bool       `json:"schedule_paused"`        // Lịch backup đang tạm dừng
+	PausedUntil          *time.Time `json:"paused_until,omitempty"` // Thời điểm lịch tự chạy lại, nil = tạm dừng đến khi resume
+	JobTimeoutMinutes    int        `json:"job_timeout_minutes"`    // Thời gian chạy tối đa của một backup (phút), 0 = không giới hạn
+	DrillAfterBackup     bool       `json:"drill_after_backup"`     // Chạy restore drill sau mỗi lần backup thành công
+	DrillSchedule        string     `json:"drill_schedule"`         // Lịch cron chạy restore drill cho backup mới nhất
+	DrillSQL             string     `json:"drill_sql"`              // Các câu SQL kiểm tra sau khi restore, mỗi câu một dòng
+	DrillImage           string     `json:"drill_image"`            // Image dùng cho restore drill, mặc định postgres:<phiên bản server>
+	NotifyOnFailure      bool       `json:"notify_on_failure"`      // Gửi thông báo khi backup thất bại hoặc quá thời gian
+	NotifyOnSuccess      bool       `json:"notify_on_success"`      // Gửi thông báo sau mỗi lần backup thành công
+	NotifyOnRecovery     bool       `json:"notify_on_recovery"`     // Gửi thông báo khi backup thành công trở lại sau lần thất bại
+	NotifyDigest         bool       `json:"notify_digest"`          // Gửi báo cáo tổng hợp hằng ngày
+	NotifyChannels       []string   `json:"notify_channels"`        // Kênh nhận thông báo: email, webhook, telegram; rỗng = tất cả kênh đã cấu hình
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+
+	// SecretsSet cho biết credentials nào đã được đặt, chỉ có trong phản hồi API thay cho giá trị thật
+	SecretsSet map[string]bool `json:"secrets_set,omitempty"`
+}
+
+// Các chế độ kết nối đến database
+const (
+	ConnectionModeDocker = "docker" // Chạy pg_dump bên trong container qua docker exec
+	ConnectionModeTCP    = "tcp"    // Chạy pg_dump cục bộ và kết nối trực tiếp qua host/port
+)
+
+// UsesDocker cho biết profile có kết nối qua container Docker hay không
+func (p DatabaseProfile) UsesDocker() bool {
+	return p.ConnectionMode != ConnectionModeTCP
+}
+
+// IsSchedulePaused cho biết lịch backup của profile có đang tạm dừng tại thời điểm now hay không
+func (p DatabaseProfile) IsSchedulePaused(now time.Time) bool {
+	if !p.SchedulePaused {
+		return false
+	}
+	return p.PausedUntil == nil || now.Before(*p.PausedUntil)
 }
 
 // NewDatabaseProfile tạo một profile mới với các giá trị mặc định
@@ -32,10 +89,16 @@ func NewDatabaseProfile(name, description string) DatabaseProfile {
 		Name:            name,
 		Description:     description,
 		IsActive:        true,
-		BackupRetention: 0,     // Mặc định không thiết lập
-		CronSchedule:    "",    // Mặc định không thiết lập
-		UploadToDrive:   false, // Mặc định không upload lên Drive
-		FolderDrive:     "",    // Mặc định không thiết lập tên thư mục
+		ConnectionMode:  ConnectionModeDocker,
+		DBPort:          5432,
+		SFTPPort:        22,
+		DumpFormat:      DefaultDumpFormat,
+		BackupRetention: 0,          // Mặc định không thiết lập
+		CronSchedule:    "",         // Mặc định không thiết lập
+		Destinations:    []string{}, // Mặc định không upload lên đâu
+		FolderDrive:     "",         // Mặc định không thiết lập tên thư mục
+		NotifyOnFailure: true,
+		NotifyChannels:  []string{},
 		CreatedAt:       now,
 		UpdatedAt:       now,
 	}
@@ -13,11 +13,12 @@ type ScheduleOption struct {
 type JobLog struct {
 	ID         int64     `json:"id"`
 	ProfileID  int64     `json:"profile_id"`
-	Status     string    `json:"status"` // success, failed, running
+	Status     string    `json:"status"` // queued, running, success, failed, skipped, timeout
 	StartTime  time.Time `json:"start_time"`
 	EndTime    time.Time `json:"end_time"`
 	BackupFile string    `json:"backup_file"` // Đường dẫn file backup nếu thành công
 	Message    string    `json:"message"`     // Thông báo lỗi hoặc thành công
+	JobType    string    `json:"job_type"`    // Loại job: backup, restore
 }
 
 // GetScheduleOptions trả về danh sách các tùy chọn lên lịch backup
@@ -6,15 +6,49 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Các vai trò của người dùng, mỗi vai trò có tất cả quyền của vai trò thấp hơn
+const (
+	RoleViewer   = "viewer"   // Xem danh sách, tải backup
+	RoleOperator = "operator" // Chạy backup, upload, tạm dừng lịch
+	RoleAdmin    = "admin"    // Quản lý profile, cấu hình, người dùng, khôi phục và xóa backup
+)
+
 // User đại diện cho một người dùng trong hệ thống
 type User struct {
 	ID        int64     `json:"id"`
 	Username  string    `json:"username"`
 	Password  string    `json:"-"` // Không trả về password trong JSON
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Roles trả về các vai trò được hỗ trợ, từ thấp đến cao
+func Roles() []string {
+	return []string{RoleViewer, RoleOperator, RoleAdmin}
+}
+
+// IsValidRole kiểm tra vai trò có được hỗ trợ hay không
+func IsValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// RoleAllows kiểm tra vai trò role có đủ quyền của vai trò required hay không
+func RoleAllows(role, required string) bool {
+	return roleRank(role) >= 0 && roleRank(role) >= roleRank(required)
+}
+
+// roleRank trả về thứ tự của vai trò, -1 nếu không hợp lệ
+func roleRank(role string) int {
+	for i, r := range Roles() {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
 // HashPassword mã hóa mật khẩu người dùng
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -37,4 +71,5 @@ type Auth struct {
 type JWTClaims struct {
 	Username string `json:"username"`
 	UserID   int64  `json:"user_id"`
+	Role     string `json:"role"`
 }
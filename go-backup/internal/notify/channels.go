@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout là thời gian tối đa cho một lần gửi email
+const smtpTimeout = 30 * time.Second
+
+// sendEmail gửi email qua SMTP. Port 465 dùng TLS ngay khi kết nối,
+// các port khác dùng STARTTLS nếu server hỗ trợ
+func (n *Notifier) sendEmail(subject, body string) error {
+	cfg := n.Config
+	host := cfg.NotifySMTPHost
+	addr := net.JoinHostPort(host, strconv.Itoa(cfg.NotifySMTPPort))
+	from := cfg.NotifySMTPFrom
+	if from == "" {
+		from = cfg.NotifySMTPUsername
+	}
+	to := splitList(cfg.NotifyEmailTo)
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if cfg.NotifySMTPPort == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("không thể kết nối SMTP %s: %v", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if cfg.NotifySMTPPort != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				return fmt.Errorf("lỗi STARTTLS: %v", err)
+			}
+		}
+	}
+	if cfg.NotifySMTPUsername != "" {
+		auth := smtp.PlainAuth("", cfg.NotifySMTPUsername, cfg.NotifySMTPPassword, host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("lỗi xác thực SMTP: %v", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("địa chỉ nhận %s bị từ chối: %v", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildEmail(from, to, subject, body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildEmail tạo nội dung email dạng text/plain UTF-8
+func buildEmail(from string, to []string, subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// sendWebhook gửi thông báo đến incoming webhook kiểu Slack/Mattermost ({"text": "..."})
+func (n *Notifier) sendWebhook(text string) error {
+	return n.postJSON(n.Config.NotifyWebhookURL, map[string]interface{}{"text": text}, nil)
+}
+
+// sendTelegram gửi tin nhắn qua phương thức sendMessage của Telegram Bot API
+func (n *Notifier) sendTelegram(text string) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage",
+		strings.TrimRight(n.Config.NotifyTelegramAPIURL, "/"), n.Config.NotifyTelegramToken)
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	err := n.postJSON(endpoint, map[string]interface{}{
+		"chat_id":                  n.Config.NotifyTelegramChatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, &result)
+	if err != nil {
+		// Không để lộ token trong log
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), n.Config.NotifyTelegramToken, "***"))
+	}
+	if !result.OK {
+		return fmt.Errorf("Telegram trả về lỗi: %s", result.Description)
+	}
+	return nil
+}
+
+// postJSON gửi payload dạng JSON, trả lỗi nếu server không trả về mã 2xx. Nếu out khác nil,
+// nội dung phản hồi được đọc vào out
+func (n *Notifier) postJSON(url string, payload interface{}, out interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.Client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("server trả về mã %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("phản hồi không hợp lệ: %v", err)
+		}
+	}
+	return nil
+}
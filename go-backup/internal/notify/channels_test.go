@@ -0,0 +1,308 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// recordedRequest là request mà server giả lập nhận được
+type recordedRequest struct {
+	Path    string
+	Payload map[string]interface{}
+}
+
+// startHTTPServer chạy server HTTP giả lập, ghi lại các request JSON và trả về response
+func startHTTPServer(t *testing.T, status int, response string) (*httptest.Server, func() []recordedRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, muốn application/json", ct)
+		}
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("payload không phải JSON: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, recordedRequest{Path: r.URL.Path, Payload: payload})
+		mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	server, requests := startHTTPServer(t, http.StatusOK, "ok")
+	n := NewNotifier(&config.Config{NotifyWebhookURL: server.URL + "/hooks/backup"})
+
+	if err := n.Send([]string{models.NotifyChannelWebhook}, testMessage(EventFailure)); err != nil {
+		t.Fatal(err)
+	}
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("server nhận %d request, muốn 1", len(got))
+	}
+	if got[0].Path != "/hooks/backup" {
+		t.Errorf("path = %q", got[0].Path)
+	}
+	text, _ := got[0].Payload["text"].(string)
+	if !strings.HasPrefix(text, "[go-backup] Backup thất bại: shop\n") || !strings.Contains(text, "Lỗi: pg_dump: connection refused") {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestSendWebhookServerError(t *testing.T) {
+	server, _ := startHTTPServer(t, http.StatusBadGateway, "upstream down")
+	n := NewNotifier(&config.Config{NotifyWebhookURL: server.URL})
+
+	err := n.Send([]string{models.NotifyChannelWebhook}, testMessage(EventFailure))
+	if err == nil || !strings.Contains(err.Error(), "webhook: server trả về mã 502: upstream down") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSendTelegram(t *testing.T) {
+	server, requests := startHTTPServer(t, http.StatusOK, `{"ok":true}`)
+	n := NewNotifier(&config.Config{
+		NotifyTelegramAPIURL: server.URL + "/",
+		NotifyTelegramToken:  "123:secret",
+		NotifyTelegramChatID: "-100200",
+	})
+
+	if err := n.Send([]string{models.NotifyChannelTelegram}, testMessage(EventRecovery)); err != nil {
+		t.Fatal(err)
+	}
+	got := requests()
+	if len(got) != 1 {
+		t.Fatalf("server nhận %d request, muốn 1", len(got))
+	}
+	if got[0].Path != "/bot123:secret/sendMessage" {
+		t.Errorf("path = %q", got[0].Path)
+	}
+	payload := got[0].Payload
+	if payload["chat_id"] != "-100200" || payload["disable_web_page_preview"] != true {
+		t.Errorf("payload = %v", payload)
+	}
+	if text, _ := payload["text"].(string); !strings.HasPrefix(text, "[go-backup] Backup hoạt động trở lại: shop\n") {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestSendTelegramErrorHidesToken(t *testing.T) {
+	server, _ := startHTTPServer(t, http.StatusUnauthorized, `{"ok":false,"description":"Unauthorized"}`)
+	n := NewNotifier(&config.Config{
+		NotifyTelegramAPIURL: server.URL,
+		NotifyTelegramToken:  "123:secret",
+		NotifyTelegramChatID: "1",
+	})
+	if err := n.sendTelegram("xin chào"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, muốn lỗi mã 401", err)
+	}
+
+	// Lỗi kết nối có chứa URL, token phải được che đi
+	server.Close()
+	err := n.sendTelegram("xin chào")
+	if err == nil {
+		t.Fatal("gửi đến server đã đóng phải trả lỗi")
+	}
+	if strings.Contains(err.Error(), "secret") || !strings.Contains(err.Error(), "***") {
+		t.Errorf("lỗi để lộ token: %v", err)
+	}
+}
+
+func TestSendTelegramNotOK(t *testing.T) {
+	server, _ := startHTTPServer(t, http.StatusOK, `{"ok":false,"description":"chat not found"}`)
+	n := NewNotifier(&config.Config{NotifyTelegramAPIURL: server.URL, NotifyTelegramToken: "t", NotifyTelegramChatID: "1"})
+	if err := n.sendTelegram("xin chào"); err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+// smtpMessage là email mà server SMTP giả lập nhận được
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// startSMTPServer chạy server SMTP tối giản (không TLS, không xác thực) để nhận email thử
+func startSMTPServer(t *testing.T) (host string, port int, messages <-chan smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	ch := make(chan smtpMessage, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var msg smtpMessage
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				msg.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					dataLine, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				msg.Data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				ch <- msg
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, ch
+}
+
+func TestSendEmail(t *testing.T) {
+	host, port, messages := startSMTPServer(t)
+	n := NewNotifier(&config.Config{
+		NotifySMTPHost: host,
+		NotifySMTPPort: port,
+		NotifySMTPFrom: "backup@example.com",
+		NotifyEmailTo:  "ops@example.com; dba@example.com",
+	})
+
+	if err := n.Send([]string{models.NotifyChannelEmail}, testMessage(EventFailure)); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg smtpMessage
+	select {
+	case msg = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server SMTP không nhận được email")
+	}
+	if msg.From != "backup@example.com" {
+		t.Errorf("MAIL FROM = %q", msg.From)
+	}
+	if strings.Join(msg.To, ",") != "ops@example.com,dba@example.com" {
+		t.Errorf("RCPT TO = %q", msg.To)
+	}
+	for _, want := range []string{
+		"To: ops@example.com, dba@example.com\r\n",
+		"Subject: =?utf-8?q?[go-backup]_Backup_th=E1=BA=A5t_b=E1=BA=A1i:_shop?=\r\n",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"\r\n\r\n[go-backup] Backup thất bại: shop\r\nThời gian: 02/01/2024 03:04:05\r\n",
+	} {
+		if !strings.Contains(msg.Data, want) {
+			t.Errorf("email thiếu %q:\n%s", want, msg.Data)
+		}
+	}
+}
+
+func TestChannelConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []string
+	}{
+		{"chưa cấu hình", config.Config{}, []string{}},
+		{"email thiếu người gửi", config.Config{NotifySMTPHost: "smtp", NotifyEmailTo: "a@example.com"}, []string{}},
+		{"email dùng username làm người gửi", config.Config{NotifySMTPHost: "smtp", NotifyEmailTo: "a@example.com", NotifySMTPUsername: "u"},
+			[]string{models.NotifyChannelEmail}},
+		{"email danh sách nhận rỗng", config.Config{NotifySMTPHost: "smtp", NotifyEmailTo: " , ", NotifySMTPFrom: "f"}, []string{}},
+		{"telegram thiếu chat id", config.Config{NotifyTelegramToken: "t"}, []string{}},
+		{"tất cả", config.Config{
+			NotifySMTPHost: "smtp", NotifyEmailTo: "a@example.com", NotifySMTPFrom: "f",
+			NotifyWebhookURL:    "http://hook",
+			NotifyTelegramToken: "t", NotifyTelegramChatID: "1",
+		}, []string{models.NotifyChannelEmail, models.NotifyChannelWebhook, models.NotifyChannelTelegram}},
+	}
+	for _, tt := range tests {
+		cfg := tt.cfg
+		got := NewNotifier(&cfg).ConfiguredChannels()
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: ConfiguredChannels() = %q, muốn %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSendSkipsUnconfiguredChannels(t *testing.T) {
+	server, requests := startHTTPServer(t, http.StatusOK, "ok")
+	n := NewNotifier(&config.Config{NotifyWebhookURL: server.URL})
+
+	// Không chỉ định kênh = gửi qua các kênh đã cấu hình
+	if err := n.Send(nil, testMessage(EventSuccess)); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests()) != 1 {
+		t.Errorf("server nhận %d request, muốn 1", len(requests()))
+	}
+
+	// Kênh được chọn nhưng chưa cấu hình trả lỗi, các kênh khác vẫn được gửi
+	err := n.Send([]string{models.NotifyChannelTelegram, models.NotifyChannelWebhook}, testMessage(EventSuccess))
+	if err == nil || !strings.Contains(err.Error(), "telegram: chưa cấu hình") {
+		t.Errorf("err = %v", err)
+	}
+	if len(requests()) != 2 {
+		t.Errorf("server nhận %d request, muốn 2", len(requests()))
+	}
+
+	// Không có kênh nào: thông báo thường bị bỏ qua, thông báo thử báo lỗi
+	empty := NewNotifier(&config.Config{})
+	if err := empty.Send(nil, testMessage(EventFailure)); err != nil {
+		t.Errorf("thông báo khi chưa cấu hình kênh: %v", err)
+	}
+	if err := empty.SendTest(""); err == nil {
+		t.Error("SendTest khi chưa cấu hình kênh phải trả lỗi")
+	}
+	if err := empty.SendTest("sms"); err == nil || !strings.Contains(err.Error(), "không hợp lệ") {
+		t.Errorf("SendTest kênh không hỗ trợ: err = %v", err)
+	}
+}
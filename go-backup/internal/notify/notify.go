@@ -0,0 +1,314 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// Các loại thông báo
+const (
+	EventFailure  = "failure"  // Backup thất bại hoặc quá thời gian
+	EventSuccess  = "success"  // Backup thành công
+	EventRecovery = "recovery" // Backup thành công trở lại sau lần thất bại
+	EventDigest   = "digest"   // Báo cáo tổng hợp hằng ngày
+	EventTest     = "test"     // Thông báo thử kênh
+)
+
+// digestPeriod là khoảng thời gian được tổng hợp trong báo cáo hằng ngày
+const digestPeriod = 24 * time.Hour
+
+// Message là dữ liệu truyền vào template thông báo
+type Message struct {
+	Event          string
+	ProfileID      int64
+	ProfileName    string
+	JobID          int64
+	Status         string // Trạng thái job: success, failed, timeout
+	Error          string // Thông báo lỗi khi backup thất bại
+	Message        string // Thông báo của job
+	BackupFile     string
+	BackupSize     int64
+	BackupSizeText string
+	DriveLink      string
+	Destinations   []string
+	Time           time.Time
+
+	// Dùng cho báo cáo tổng hợp
+	Success int
+	Failed  int
+	Total   int
+}
+
+// Notifier gửi thông báo kết quả backup qua email, webhook và Telegram
+type Notifier struct {
+	Config *config.Config
+	Client *http.Client
+
+	unsubscribe func()
+}
+
+// NewNotifier tạo instance mới của Notifier
+func NewNotifier(cfg *config.Config) *Notifier {
+	return &Notifier{
+		Config: cfg,
+		Client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Start đăng ký nhận sự kiện kết thúc job backup từ event bus để gửi thông báo
+func (n *Notifier) Start() {
+	eventCh, unsubscribe := events.Subscribe()
+	n.unsubscribe = unsubscribe
+
+	go func() {
+		for event := range eventCh {
+			if event.Type != events.TypeFinished && event.Type != events.TypeFailed {
+				continue
+			}
+			// Gửi trong goroutine riêng để không làm chậm việc nhận sự kiện
+			go n.jobFinished(event)
+		}
+	}()
+}
+
+// Stop hủy đăng ký nhận sự kiện
+func (n *Notifier) Stop() {
+	if n.unsubscribe != nil {
+		n.unsubscribe()
+	}
+}
+
+// jobFinished gửi thông báo cho job backup vừa kết thúc theo quy tắc của profile
+func (n *Notifier) jobFinished(event events.Event) {
+	notifyLogger := logger.Component("notify").With(logger.KeyJobID, event.JobID, logger.KeyProfileID, event.ProfileID)
+	profile, err := database.GetProfileByID(event.ProfileID)
+	if err != nil {
+		notifyLogger.Error("Không thể gửi thông báo cho job", "error", err)
+		return
+	}
+
+	var kind string
+	switch event.Status {
+	case database.JobStatusFailed, database.JobStatusTimeout:
+		if !profile.NotifyOnFailure {
+			return
+		}
+		kind = EventFailure
+	case database.JobStatusSuccess:
+		previous, err := database.PreviousBackupStatus(profile.ID, event.JobID)
+		if err != nil {
+			notifyLogger.Warn("Không thể đọc trạng thái backup trước của profile", "error", err)
+		}
+		recovered := previous == database.JobStatusFailed || previous == database.JobStatusTimeout
+		if profile.NotifyOnRecovery && recovered {
+			kind = EventRecovery
+		} else if profile.NotifyOnSuccess {
+			kind = EventSuccess
+		} else {
+			return
+		}
+	default:
+		// skipped, cancelled không cần thông báo
+		return
+	}
+
+	msg := Message{
+		Event:       kind,
+		ProfileID:   profile.ID,
+		ProfileName: profile.Name,
+		JobID:       event.JobID,
+		Status:      event.Status,
+		Message:     event.Message,
+		Time:        event.Time,
+	}
+	if kind == EventFailure {
+		msg.Error = event.Message
+	}
+	if jobLog, err := database.GetJobLog(event.JobID); err == nil {
+		attachBackup(&msg, jobLog.BackupFile)
+	}
+
+	if err := n.Send(profile.NotifyChannels, msg); err != nil {
+		notifyLogger.Error("Lỗi khi gửi thông báo", "event", kind, "error", err)
+	}
+}
+
+// attachBackup bổ sung kích thước và link của file backup vào thông báo
+func attachBackup(msg *Message, backupFile string) {
+	if backupFile == "" {
+		return
+	}
+	msg.BackupFile = backupFile
+	backup, err := backupdb.GetBackupByPath(backupFile)
+	if err != nil {
+		return
+	}
+	msg.BackupFile = backup.Name
+	msg.BackupSize = backup.Size
+	msg.BackupSizeText = backup.FormatSize()
+	msg.DriveLink = backup.DriveLink
+	msg.Destinations = backup.Destinations
+}
+
+// SendDigests gửi báo cáo tổng hợp 24 giờ qua cho các profile bật notify_digest
+func (n *Notifier) SendDigests() {
+	profiles, err := database.GetAllProfiles()
+	if err != nil {
+		log.Printf("Không thể lấy danh sách profile để gửi báo cáo: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, profile := range profiles {
+		if !profile.NotifyDigest {
+			continue
+		}
+
+		logs, err := database.GetBackupJobLogsSince(profile.ID, now.Add(-digestPeriod))
+		if err != nil {
+			log.Printf("Không thể lấy lịch sử backup của profile '%s': %v", profile.Name, err)
+			continue
+		}
+
+		msg := Message{
+			Event:       EventDigest,
+			ProfileID:   profile.ID,
+			ProfileName: profile.Name,
+			Time:        now,
+		}
+		// logs được sắp xếp mới nhất trước
+		for _, jobLog := range logs {
+			switch jobLog.Status {
+			case database.JobStatusSuccess:
+				msg.Success++
+				if msg.BackupFile == "" {
+					attachBackup(&msg, jobLog.BackupFile)
+				}
+			case database.JobStatusFailed, database.JobStatusTimeout:
+				msg.Failed++
+				if msg.Error == "" {
+					msg.Error = jobLog.Message
+				}
+			}
+		}
+		msg.Total = len(logs)
+
+		if err := n.Send(profile.NotifyChannels, msg); err != nil {
+			log.Printf("Lỗi khi gửi báo cáo tổng hợp cho profile '%s': %v", profile.Name, err)
+		}
+	}
+}
+
+// SendTest gửi thông báo thử qua kênh channel, rỗng = tất cả kênh đã cấu hình
+func (n *Notifier) SendTest(channel string) error {
+	var channels []string
+	if channel != "" {
+		if !models.IsValidNotifyChannel(channel) {
+			return fmt.Errorf("kênh thông báo không hợp lệ: %s (hỗ trợ: %s)",
+				channel, strings.Join(models.NotifyChannels(), ", "))
+		}
+		channels = []string{channel}
+	}
+	return n.Send(channels, Message{Event: EventTest, Time: time.Now()})
+}
+
+// ConfiguredChannels trả về các kênh thông báo đã được cấu hình đầy đủ
+func (n *Notifier) ConfiguredChannels() []string {
+	channels := []string{}
+	for _, name := range models.NotifyChannels() {
+		if n.channelConfigError(name) == nil {
+			channels = append(channels, name)
+		}
+	}
+	return channels
+}
+
+// Send dựng nội dung thông báo từ template và gửi qua các kênh channels,
+// rỗng = tất cả kênh đã cấu hình. Lỗi ở một kênh không ảnh hưởng đến các kênh còn lại
+func (n *Notifier) Send(channels []string, msg Message) error {
+	if len(channels) == 0 {
+		channels = n.ConfiguredChannels()
+		if len(channels) == 0 {
+			if msg.Event == EventTest {
+				return fmt.Errorf("chưa cấu hình kênh thông báo nào")
+			}
+			return nil
+		}
+	}
+
+	subject, body, err := n.render(msg)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, name := range channels {
+		err := n.channelConfigError(name)
+		if err == nil {
+			switch name {
+			case models.NotifyChannelEmail:
+				err = n.sendEmail(subject, body)
+			case models.NotifyChannelWebhook:
+				err = n.sendWebhook(body)
+			case models.NotifyChannelTelegram:
+				err = n.sendTelegram(body)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		log.Printf("Đã gửi thông báo %s qua %s", msg.Event, name)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// channelConfigError kiểm tra kênh đã được cấu hình đầy đủ hay chưa
+func (n *Notifier) channelConfigError(name string) error {
+	cfg := n.Config
+	switch name {
+	case models.NotifyChannelEmail:
+		if cfg.NotifySMTPHost == "" || len(splitList(cfg.NotifyEmailTo)) == 0 {
+			return fmt.Errorf("chưa cấu hình NOTIFY_SMTP_HOST hoặc NOTIFY_EMAIL_TO")
+		}
+		if cfg.NotifySMTPFrom == "" && cfg.NotifySMTPUsername == "" {
+			return fmt.Errorf("chưa cấu hình NOTIFY_SMTP_FROM")
+		}
+	case models.NotifyChannelWebhook:
+		if cfg.NotifyWebhookURL == "" {
+			return fmt.Errorf("chưa cấu hình NOTIFY_WEBHOOK_URL")
+		}
+	case models.NotifyChannelTelegram:
+		if cfg.NotifyTelegramToken == "" || cfg.NotifyTelegramChatID == "" {
+			return fmt.Errorf("chưa cấu hình NOTIFY_TELEGRAM_BOT_TOKEN hoặc NOTIFY_TELEGRAM_CHAT_ID")
+		}
+	default:
+		return fmt.Errorf("kênh thông báo không được hỗ trợ")
+	}
+	return nil
+}
+
+// splitList tách danh sách phân cách bằng dấu phẩy hoặc xuống dòng, bỏ phần tử rỗng
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == ';' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
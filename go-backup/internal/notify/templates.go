@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+)
+
+// Mẫu thông báo mặc định, dòng đầu tiên được dùng làm tiêu đề email
+const (
+	defaultFailureTemplate = `[go-backup] Backup thất bại: {{.ProfileName}}
+Thời gian: {{.Time.Format "02/01/2006 15:04:05"}}
+Trạng thái: {{.Status}}
+Lỗi: {{.Error}}`
+
+	defaultSuccessTemplate = `[go-backup] Backup thành công: {{.ProfileName}}
+Thời gian: {{.Time.Format "02/01/2006 15:04:05"}}
+{{- if .BackupFile}}
+File: {{.BackupFile}}{{if .BackupSizeText}} ({{.BackupSizeText}}){{end}}{{end}}
+{{- if .DriveLink}}
+Google Drive: {{.DriveLink}}{{end}}
+{{- if .Message}}
+{{.Message}}{{end}}`
+
+	defaultRecoveryTemplate = `[go-backup] Backup hoạt động trở lại: {{.ProfileName}}
+Thời gian: {{.Time.Format "02/01/2006 15:04:05"}}
+Backup đã thành công sau lần thất bại trước đó.
+{{- if .BackupFile}}
+File: {{.BackupFile}}{{if .BackupSizeText}} ({{.BackupSizeText}}){{end}}{{end}}
+{{- if .DriveLink}}
+Google Drive: {{.DriveLink}}{{end}}`
+
+	defaultDigestTemplate = `[go-backup] Báo cáo backup hằng ngày: {{.ProfileName}}
+24 giờ qua: {{.Total}} lần backup, {{.Success}} thành công, {{.Failed}} thất bại
+{{- if .BackupFile}}
+Backup mới nhất: {{.BackupFile}}{{if .BackupSizeText}} ({{.BackupSizeText}}){{end}}{{end}}
+{{- if .DriveLink}}
+Google Drive: {{.DriveLink}}{{end}}
+{{- if .Error}}
+Lỗi gần nhất: {{.Error}}{{end}}`
+
+	testTemplate = `[go-backup] Thông báo thử
+Kênh thông báo đã được cấu hình thành công ({{.Time.Format "02/01/2006 15:04:05"}}).`
+)
+
+// render dựng tiêu đề và nội dung thông báo từ template cấu hình hoặc mẫu mặc định
+func (n *Notifier) render(msg Message) (subject, body string, err error) {
+	custom, fallback := n.templates(msg.Event)
+
+	text := ""
+	if strings.TrimSpace(custom) != "" {
+		text, err = execute(custom, msg)
+		if err != nil {
+			// Mẫu tùy chỉnh lỗi không được làm mất thông báo
+			log.Printf("Mẫu thông báo %s không hợp lệ, dùng mẫu mặc định: %v", msg.Event, err)
+		}
+	}
+	if text == "" {
+		if text, err = execute(fallback, msg); err != nil {
+			return "", "", fmt.Errorf("lỗi khi dựng nội dung thông báo: %v", err)
+		}
+	}
+
+	text = strings.TrimSpace(text)
+	subject = text
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		subject = strings.TrimSpace(text[:i])
+	}
+	return subject, text, nil
+}
+
+// templates trả về mẫu tùy chỉnh (có thể rỗng) và mẫu mặc định của loại thông báo
+func (n *Notifier) templates(event string) (custom, fallback string) {
+	switch event {
+	case EventFailure:
+		return n.Config.NotifyTemplateFailure, defaultFailureTemplate
+	case EventSuccess:
+		return n.Config.NotifyTemplateSuccess, defaultSuccessTemplate
+	case EventRecovery:
+		return n.Config.NotifyTemplateRecovery, defaultRecoveryTemplate
+	case EventDigest:
+		return n.Config.NotifyTemplateDigest, defaultDigestTemplate
+	}
+	return "", testTemplate
+}
+
+// execute chạy text/template với dữ liệu msg
+func execute(text string, msg Message) (string, error) {
+	tmpl, err := template.New("notify").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, msg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
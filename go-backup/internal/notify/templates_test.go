@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/config"
+)
+
+func testMessage(event string) Message {
+	return Message{
+		Event:          event,
+		ProfileName:    "shop",
+		Status:         "failed",
+		Error:          "pg_dump: connection refused",
+		BackupFile:     "shop_20240102_030405.sql",
+		BackupSizeText: "1.5 MB",
+		DriveLink:      "https://drive.google.com/file/d/abc",
+		Time:           time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Success:        3,
+		Failed:         1,
+		Total:          4,
+	}
+}
+
+func TestRenderDefaultTemplates(t *testing.T) {
+	n := NewNotifier(&config.Config{})
+	tests := []struct {
+		event   string
+		subject string
+		body    []string
+	}{
+		{EventFailure, "[go-backup] Backup thất bại: shop",
+			[]string{"Thời gian: 02/01/2024 03:04:05", "Trạng thái: failed", "Lỗi: pg_dump: connection refused"}},
+		{EventSuccess, "[go-backup] Backup thành công: shop",
+			[]string{"File: shop_20240102_030405.sql (1.5 MB)", "Google Drive: https://drive.google.com/file/d/abc"}},
+		{EventRecovery, "[go-backup] Backup hoạt động trở lại: shop",
+			[]string{"Backup đã thành công sau lần thất bại trước đó.", "File: shop_20240102_030405.sql (1.5 MB)"}},
+		{EventDigest, "[go-backup] Báo cáo backup hằng ngày: shop",
+			[]string{"24 giờ qua: 4 lần backup, 3 thành công, 1 thất bại", "Backup mới nhất: shop_20240102_030405.sql (1.5 MB)",
+				"Lỗi gần nhất: pg_dump: connection refused"}},
+		{EventTest, "[go-backup] Thông báo thử", []string{"(02/01/2024 03:04:05)"}},
+	}
+	for _, tt := range tests {
+		subject, body, err := n.render(testMessage(tt.event))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.event, err)
+		}
+		if subject != tt.subject {
+			t.Errorf("%s: subject = %q, muốn %q", tt.event, subject, tt.subject)
+		}
+		if !strings.HasPrefix(body, subject+"\n") {
+			t.Errorf("%s: nội dung không bắt đầu bằng tiêu đề: %q", tt.event, body)
+		}
+		for _, want := range tt.body {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: nội dung thiếu %q:\n%s", tt.event, want, body)
+			}
+		}
+	}
+}
+
+func TestRenderOmitsEmptyFields(t *testing.T) {
+	n := NewNotifier(&config.Config{})
+	_, body, err := n.render(Message{Event: EventSuccess, ProfileName: "shop", Time: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unwanted := range []string{"File:", "Google Drive:", "<no value>"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("nội dung không được chứa %q khi trường rỗng:\n%s", unwanted, body)
+		}
+	}
+	if strings.Count(body, "\n") != 1 {
+		t.Errorf("nội dung có dòng trống thừa:\n%q", body)
+	}
+}
+
+func TestRenderCustomTemplate(t *testing.T) {
+	n := NewNotifier(&config.Config{
+		NotifyTemplateFailure: "\n  {{.ProfileName}} lỗi  \nChi tiết: {{.Error}}\n",
+	})
+	subject, body, err := n.render(testMessage(EventFailure))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "shop lỗi" {
+		t.Errorf("subject = %q, muốn %q", subject, "shop lỗi")
+	}
+	if body != "shop lỗi  \nChi tiết: pg_dump: connection refused" {
+		t.Errorf("body = %q", body)
+	}
+
+	// Mẫu của loại thông báo khác vẫn dùng mặc định
+	subject, _, err = n.render(testMessage(EventSuccess))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject != "[go-backup] Backup thành công: shop" {
+		t.Errorf("subject thông báo thành công = %q", subject)
+	}
+}
+
+func TestRenderInvalidCustomTemplateFallsBack(t *testing.T) {
+	templates := []string{
+		"{{.ProfileName",      // lỗi cú pháp
+		"{{.KhongTonTai}}",    // trường không tồn tại
+		"{{if .ProfileName}}", // thiếu end
+	}
+	for _, custom := range templates {
+		n := NewNotifier(&config.Config{NotifyTemplateDigest: custom})
+		subject, _, err := n.render(testMessage(EventDigest))
+		if err != nil {
+			t.Fatalf("mẫu %q: %v", custom, err)
+		}
+		if subject != "[go-backup] Báo cáo backup hằng ngày: shop" {
+			t.Errorf("mẫu %q: subject = %q, muốn mẫu mặc định", custom, subject)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{}},
+		{" , ;\n", []string{}},
+		{"a@example.com", []string{"a@example.com"}},
+		{"a@example.com, b@example.com;c@example.com\n d@example.com ,", []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}},
+	}
+	for _, tt := range tests {
+		got := splitList(tt.value)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("splitList(%q) = %q, muốn %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+)
+
+// Policy mô tả chính sách lưu giữ backup của một profile.
+// Một backup được giữ lại nếu thỏa mãn ít nhất một quy tắc, các quy tắc có giá trị 0 bị bỏ qua
+type Policy struct {
+	KeepDays    int `json:"keep_days"`    // Giữ các bản backup trong N ngày gần nhất
+	KeepLast    int `json:"keep_last"`    // Giữ N bản backup mới nhất
+	KeepDaily   int `json:"keep_daily"`   // Giữ bản mới nhất của mỗi ngày trong N ngày có backup gần nhất
+	KeepWeekly  int `json:"keep_weekly"`  // Giữ bản mới nhất của mỗi tuần trong N tuần có backup gần nhất
+	KeepMonthly int `json:"keep_monthly"` // Giữ bản mới nhất của mỗi tháng trong N tháng có backup gần nhất
+}
+
+// PolicyForProfile tạo chính sách từ cấu hình của profile,
+// defaultDays là số ngày lưu giữ chung (BACKUP_RETENTION_DAYS) dùng khi profile không thiết lập
+func PolicyForProfile(profile models.DatabaseProfile, defaultDays int) Policy {
+	policy := Policy{
+		KeepDays:    profile.BackupRetention,
+		KeepLast:    profile.RetentionKeepLast,
+		KeepDaily:   profile.RetentionKeepDaily,
+		KeepWeekly:  profile.RetentionKeepWeekly,
+		KeepMonthly: profile.RetentionKeepMonthly,
+	}
+	if policy.KeepDays <= 0 {
+		policy.KeepDays = defaultDays
+	}
+	return policy
+}
+
+// IsEmpty cho biết chính sách không có quy tắc nào (không xóa gì cả)
+func (p Policy) IsEmpty() bool {
+	return p.KeepDays <= 0 && p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0 && p.KeepMonthly <= 0
+}
+
+// String mô tả chính sách dưới dạng dễ đọc
+func (p Policy) String() string {
+	var parts []string
+	if p.KeepDays > 0 {
+		parts = append(parts, fmt.Sprintf("%d ngày", p.KeepDays))
+	}
+	if p.KeepLast > 0 {
+		parts = append(parts, fmt.Sprintf("%d bản mới nhất", p.KeepLast))
+	}
+	if p.KeepDaily > 0 {
+		parts = append(parts, fmt.Sprintf("%d bản theo ngày", p.KeepDaily))
+	}
+	if p.KeepWeekly > 0 {
+		parts = append(parts, fmt.Sprintf("%d bản theo tuần", p.KeepWeekly))
+	}
+	if p.KeepMonthly > 0 {
+		parts = append(parts, fmt.Sprintf("%d bản theo tháng", p.KeepMonthly))
+	}
+	if len(parts) == 0 {
+		return "không giới hạn"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Decision là kết quả đánh giá một backup theo chính sách
+type Decision struct {
+	Backup  *models.BackupFile
+	Keep    bool
+	Reasons []string
+}
+
+// Plan đánh giá danh sách backup theo chính sách tại thời điểm now.
+// Bản backup mới nhất luôn được giữ lại. Kết quả được sắp xếp từ mới đến cũ
+func Plan(backups []*models.BackupFile, policy Policy, now time.Time) []Decision {
+	sorted := make([]*models.BackupFile, len(backups))
+	copy(sorted, backups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	decisions := make([]Decision, len(sorted))
+	for i, backup := range sorted {
+		decisions[i] = Decision{Backup: backup}
+	}
+
+	keep := func(i int, reason string) {
+		decisions[i].Keep = true
+		decisions[i].Reasons = append(decisions[i].Reasons, reason)
+	}
+
+	if policy.IsEmpty() {
+		for i := range decisions {
+			keep(i, "không có chính sách lưu giữ")
+		}
+		return decisions
+	}
+
+	if len(decisions) > 0 {
+		keep(0, "bản mới nhất")
+	}
+
+	if policy.KeepDays > 0 {
+		cutoff := now.AddDate(0, 0, -policy.KeepDays)
+		for i, d := range decisions {
+			if d.Backup.CreatedAt.After(cutoff) {
+				keep(i, fmt.Sprintf("trong %d ngày gần nhất", policy.KeepDays))
+			}
+		}
+	}
+
+	for i := 0; i < policy.KeepLast && i < len(decisions); i++ {
+		keep(i, fmt.Sprintf("thuộc %d bản mới nhất", policy.KeepLast))
+	}
+
+	keepPeriods(decisions, policy.KeepDaily, "ngày", func(t time.Time) string {
+		return t.Format("2006-01-02")
+	}, keep)
+	keepPeriods(decisions, policy.KeepWeekly, "tuần", func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}, keep)
+	keepPeriods(decisions, policy.KeepMonthly, "tháng", func(t time.Time) string {
+		return t.Format("2006-01")
+	}, keep)
+
+	return decisions
+}
+
+// keepPeriods giữ bản mới nhất của mỗi khoảng thời gian (ngày/tuần/tháng) cho count khoảng gần nhất.
+// decisions phải được sắp xếp từ mới đến cũ
+func keepPeriods(decisions []Decision, count int, label string, period func(time.Time) string, keep func(int, string)) {
+	if count <= 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for i, d := range decisions {
+		key := period(d.Backup.CreatedAt.Local())
+		if seen[key] {
+			continue
+		}
+		if len(seen) >= count {
+			return
+		}
+		seen[key] = true
+		keep(i, fmt.Sprintf("bản mới nhất của %s %s", label, key))
+	}
+}
@@ -0,0 +1,198 @@
+package retention
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/models"
+)
+
+// testNow là thứ Sáu 15/03/2024 (tuần ISO 2024-W11)
+var testNow = time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local)
+
+// at trả về thời điểm theo giờ địa phương
+func at(month time.Month, day, hour, min int) time.Time {
+	year := 2024
+	if month == time.December {
+		year = 2023
+	}
+	return time.Date(year, month, day, hour, min, 0, 0, time.Local)
+}
+
+// testBackups tạo các backup với ID "1", "2"... theo thứ tự thời điểm truyền vào
+func testBackups(times ...time.Time) []*models.BackupFile {
+	backups := make([]*models.BackupFile, len(times))
+	for i, createdAt := range times {
+		id := strconv.Itoa(i + 1)
+		backups[i] = &models.BackupFile{ID: id, Name: "shop_" + id + ".sql", CreatedAt: createdAt}
+	}
+	return backups
+}
+
+// keptIDs trả về ID (đã sắp xếp) của các backup được giữ lại
+func keptIDs(decisions []Decision) []int {
+	var ids []int
+	for _, d := range decisions {
+		if d.Keep {
+			id, _ := strconv.Atoi(d.Backup.ID)
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		backups []*models.BackupFile
+		kept    []int
+	}{
+		{
+			name:    "không có chính sách thì giữ tất cả",
+			policy:  Policy{},
+			backups: testBackups(at(time.March, 15, 9, 0), at(time.January, 1, 9, 0)),
+			kept:    []int{1, 2},
+		},
+		{
+			name:   "keep-last",
+			policy: Policy{KeepLast: 2},
+			backups: testBackups(at(time.March, 15, 9, 0), at(time.March, 14, 9, 0), at(time.March, 13, 9, 0),
+				at(time.March, 12, 9, 0)),
+			kept: []int{1, 2},
+		},
+		{
+			name:   "keep-last với danh sách chưa sắp xếp",
+			policy: Policy{KeepLast: 2},
+			backups: testBackups(at(time.March, 12, 9, 0), at(time.March, 15, 9, 0), at(time.March, 13, 9, 0),
+				at(time.March, 14, 9, 0)),
+			kept: []int{2, 4},
+		},
+		{
+			// Mốc cắt là 12/03 12:00, backup đúng mốc đã quá hạn
+			name:   "keep-days",
+			policy: Policy{KeepDays: 3},
+			backups: testBackups(at(time.March, 15, 9, 0), at(time.March, 13, 12, 0), at(time.March, 12, 12, 0),
+				at(time.March, 12, 11, 59), at(time.March, 1, 9, 0)),
+			kept: []int{1, 2},
+		},
+		{
+			name:    "luôn giữ bản mới nhất dù đã quá hạn",
+			policy:  Policy{KeepDays: 2},
+			backups: testBackups(at(time.February, 1, 9, 0), at(time.March, 1, 9, 0)),
+			kept:    []int{2},
+		},
+		{
+			name:   "keep-daily giữ bản mới nhất của mỗi ngày",
+			policy: Policy{KeepDaily: 2},
+			backups: testBackups(at(time.March, 15, 10, 0), at(time.March, 15, 8, 0), at(time.March, 14, 10, 0),
+				at(time.March, 14, 8, 0), at(time.March, 13, 10, 0)),
+			kept: []int{1, 3},
+		},
+		{
+			name:   "keep-weekly theo tuần ISO",
+			policy: Policy{KeepWeekly: 2},
+			backups: testBackups(at(time.March, 15, 9, 0), at(time.March, 11, 9, 0), at(time.March, 10, 9, 0),
+				at(time.March, 4, 9, 0), at(time.February, 28, 9, 0)),
+			kept: []int{1, 3},
+		},
+		{
+			name:   "keep-monthly",
+			policy: Policy{KeepMonthly: 2},
+			backups: testBackups(at(time.March, 10, 9, 0), at(time.March, 1, 9, 0), at(time.February, 20, 9, 0),
+				at(time.February, 1, 9, 0), at(time.January, 15, 9, 0)),
+			kept: []int{1, 3},
+		},
+		{
+			name:   "kết hợp daily, weekly và monthly",
+			policy: Policy{KeepDaily: 1, KeepWeekly: 2, KeepMonthly: 3},
+			backups: testBackups(at(time.March, 15, 9, 0), at(time.March, 14, 9, 0), at(time.March, 8, 9, 0),
+				at(time.March, 4, 9, 0), at(time.February, 25, 9, 0), at(time.February, 10, 9, 0),
+				at(time.January, 20, 9, 0), at(time.December, 20, 9, 0)),
+			kept: []int{1, 3, 5, 7},
+		},
+		{
+			name:   "các quy tắc cộng dồn",
+			policy: Policy{KeepDays: 2, KeepLast: 3, KeepMonthly: 2},
+			backups: testBackups(at(time.March, 15, 9, 0), at(time.March, 14, 9, 0), at(time.March, 10, 9, 0),
+				at(time.March, 5, 9, 0), at(time.February, 20, 9, 0), at(time.February, 10, 9, 0),
+				at(time.January, 5, 9, 0)),
+			kept: []int{1, 2, 3, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := Plan(tt.backups, tt.policy, testNow)
+			if len(decisions) != len(tt.backups) {
+				t.Fatalf("Plan trả về %d quyết định, muốn %d", len(decisions), len(tt.backups))
+			}
+			for i := 1; i < len(decisions); i++ {
+				if decisions[i].Backup.CreatedAt.After(decisions[i-1].Backup.CreatedAt) {
+					t.Fatal("kết quả không được sắp xếp từ mới đến cũ")
+				}
+			}
+			if got := keptIDs(decisions); !equalInts(got, tt.kept) {
+				t.Errorf("giữ lại %v, muốn %v", got, tt.kept)
+			}
+			for _, d := range decisions {
+				if d.Keep && len(d.Reasons) == 0 {
+					t.Errorf("backup %s được giữ nhưng không có lý do", d.Backup.ID)
+				}
+				if !d.Keep && len(d.Reasons) != 0 {
+					t.Errorf("backup %s bị xóa nhưng có lý do %v", d.Backup.ID, d.Reasons)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanEmpty(t *testing.T) {
+	if decisions := Plan(nil, Policy{KeepLast: 1}, testNow); len(decisions) != 0 {
+		t.Errorf("Plan với danh sách rỗng = %v", decisions)
+	}
+}
+
+func TestKeepPeriods(t *testing.T) {
+	decisions := Plan(testBackups(at(time.March, 15, 10, 0), at(time.March, 15, 8, 0), at(time.March, 14, 10, 0),
+		at(time.March, 13, 10, 0)), Policy{KeepLast: 1}, testNow)
+	for i := range decisions {
+		decisions[i].Keep, decisions[i].Reasons = false, nil
+	}
+
+	var reasons []string
+	keepPeriods(decisions, 2, "ngày", func(t time.Time) string {
+		return t.Format("2006-01-02")
+	}, func(i int, reason string) {
+		decisions[i].Keep = true
+		reasons = append(reasons, reason)
+	})
+
+	if got := keptIDs(decisions); !equalInts(got, []int{1, 3}) {
+		t.Errorf("giữ lại %v, muốn [1 3]", got)
+	}
+	want := []string{"bản mới nhất của ngày 2024-03-15", "bản mới nhất của ngày 2024-03-14"}
+	if len(reasons) != len(want) || reasons[0] != want[0] || reasons[1] != want[1] {
+		t.Errorf("lý do = %q, muốn %q", reasons, want)
+	}
+
+	// count = 0 không giữ thêm bản nào
+	keepPeriods(decisions, 0, "ngày", func(time.Time) string { return "" }, func(int, string) {
+		t.Error("count = 0 không được gọi keep")
+	})
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
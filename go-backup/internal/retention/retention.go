@@ -0,0 +1,245 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/integrity"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/storage"
+)
+
+// timestampSuffix khớp phần sau tên database trong tên file backup: <db>_YYYYMMDD_HHMMSS...
+var timestampSuffix = regexp.MustCompile(`^\d{8}_\d{6}`)
+
+// Item là thông tin một backup trong báo cáo retention
+type Item struct {
+	BackupID     int64     `json:"backup_id"`
+	Name         string    `json:"name"`
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+	DriveLink    string    `json:"drive_link,omitempty"`
+	Reasons      []string  `json:"reasons,omitempty"`
+	LocalDeleted bool      `json:"local_deleted"`
+	// RemoteDeleted là các destination đã xóa được bản sao (drive, s3...)
+	RemoteDeleted []string `json:"remote_deleted,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Report là kết quả một lần chạy retention
+type Report struct {
+	ProfileID   int64     `json:"profile_id"`
+	ProfileName string    `json:"profile_name"`
+	Policy      Policy    `json:"policy"`
+	PolicyText  string    `json:"policy_text"`
+	DryRun      bool      `json:"dry_run"`
+	Kept        []Item    `json:"kept"`
+	Removed     []Item    `json:"removed"`
+	FreedBytes  int64     `json:"freed_bytes"`
+	Errors      []string  `json:"errors,omitempty"`
+	RunAt       time.Time `json:"run_at"`
+}
+
+// Engine thực thi chính sách lưu giữ: xóa file trong BackupDir, bản ghi backups và bản sao trên các destination
+type Engine struct {
+	Config  *config.Config
+	Storage *storage.Manager
+}
+
+// NewEngine tạo instance mới của Engine
+func NewEngine(cfg *config.Config, storageManager *storage.Manager) *Engine {
+	return &Engine{
+		Config:  cfg,
+		Storage: storageManager,
+	}
+}
+
+// Run áp dụng chính sách lưu giữ cho một profile. Nếu dryRun = true, chỉ báo cáo những gì sẽ bị xóa
+func (e *Engine) Run(profileID int64, dryRun bool) (*Report, error) {
+	profile, err := database.GetProfileByID(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("không thể lấy thông tin profile %d: %v", profileID, err)
+	}
+
+	policy := PolicyForProfile(*profile, defaultRetentionDays())
+	report := &Report{
+		ProfileID:   profile.ID,
+		ProfileName: profile.Name,
+		Policy:      policy,
+		PolicyText:  policy.String(),
+		DryRun:      dryRun,
+		Kept:        []Item{},
+		Removed:     []Item{},
+		RunAt:       time.Now(),
+	}
+
+	backups, err := ProfileBackups(*profile)
+	if err != nil {
+		return report, err
+	}
+
+	runLogger := logger.Component("retention").With(logger.KeyProfileID, profile.ID, "profile", profile.Name)
+	runLogger.Info("Đang áp dụng retention", "backups", len(backups), "policy", report.PolicyText, "dry_run", dryRun)
+
+	for _, decision := range Plan(backups, policy, report.RunAt) {
+		item := newItem(decision)
+		if decision.Keep {
+			report.Kept = append(report.Kept, item)
+			continue
+		}
+
+		if !dryRun {
+			e.remove(*profile, decision.Backup, &item)
+			if item.Error != "" {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", item.Name, item.Error))
+			}
+		}
+		if item.Error == "" {
+			report.FreedBytes += item.Size
+		}
+		report.Removed = append(report.Removed, item)
+	}
+
+	if dryRun {
+		runLogger.Info("Retention (dry run) hoàn tất", "would_remove", len(report.Removed), "backups", len(backups))
+	} else {
+		runLogger.Info("Retention hoàn tất", "removed", len(report.Removed)-len(report.Errors),
+			"backups", len(backups), "freed_bytes", report.FreedBytes, "errors", len(report.Errors))
+	}
+
+	return report, nil
+}
+
+// AuditEvent trả về sự kiện audit của lần chạy retention (chưa có người thực hiện)
+// và lỗi gộp từ các backup không xóa được
+func (r *Report) AuditEvent() (models.AuditEvent, error) {
+	removed := make([]string, 0, len(r.Removed))
+	for _, item := range r.Removed {
+		if item.Error == "" {
+			removed = append(removed, item.Name)
+		}
+	}
+
+	event := models.AuditEvent{
+		Action:     "retention.apply",
+		TargetType: "profile",
+		TargetID:   strconv.FormatInt(r.ProfileID, 10),
+		Message:    fmt.Sprintf("Đã xóa %d backup (%d bytes): %s", len(removed), r.FreedBytes, strings.Join(removed, ", ")),
+	}
+	if len(r.Errors) > 0 {
+		return event, errors.New(strings.Join(r.Errors, "; "))
+	}
+	return event, nil
+}
+
+// remove xóa file cục bộ, bản sao trên các destination và bản ghi của một backup.
+// Bản ghi chỉ bị xóa khi cả file cục bộ và các bản sao đã được xóa thành công,
+// để lần chạy sau có thể thử lại
+func (e *Engine) remove(profile models.DatabaseProfile, backup *models.BackupFile, item *Item) {
+	var errs []string
+	removeLogger := logger.Component("retention").With(logger.KeyProfileID, profile.ID, logger.KeyBackupID, item.BackupID, "backup", backup.Name)
+
+	if backup.FileExists {
+		if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Sprintf("không thể xóa file cục bộ: %v", err))
+		} else {
+			item.LocalDeleted = true
+		}
+	}
+
+	// Bản sao đã ghi nhận bắt buộc phải xóa được; với destination của profile
+	// nhưng chưa có bản ghi (backup cũ), chỉ tìm và xóa nếu có thể
+	if e.Storage != nil {
+		deleted, remoteErrs := e.Storage.DeleteBackupCopies(context.Background(), backup, &profile)
+		item.RemoteDeleted = deleted
+		errs = append(errs, remoteErrs...)
+	}
+
+	if len(errs) == 0 {
+		if err := database.DeleteBackup(item.BackupID); err != nil {
+			errs = append(errs, fmt.Sprintf("không thể xóa bản ghi backup: %v", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		item.Error = strings.Join(errs, "; ")
+		removeLogger.Error("Lỗi retention với backup", "error", item.Error)
+		return
+	}
+
+	removeLogger.Info("Retention đã xóa backup")
+
+	if err := integrity.WriteManifest(e.Config, filepath.Dir(backup.Path)); err != nil {
+		removeLogger.Warn("Không thể cập nhật manifest sau khi xóa backup", "error", err)
+	}
+}
+
+// ProfileBackups lấy các backup thuộc về profile (mới nhất trước) theo profile_id trong catalog.
+// Backup chưa gắn profile (tạo trước khi có cột profile_id) được nhận diện qua tiền tố <db_name>_ của tên file
+func ProfileBackups(profile models.DatabaseProfile) ([]*models.BackupFile, error) {
+	all, err := backupdb.GetAllBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := profile.DBName + "_"
+	var backups []*models.BackupFile
+	for _, backup := range all {
+		if backup.ProfileID != 0 {
+			if backup.ProfileID == profile.ID {
+				backups = append(backups, backup)
+			}
+			continue
+		}
+		if !strings.HasPrefix(backup.Name, prefix) {
+			continue
+		}
+		if !timestampSuffix.MatchString(strings.TrimPrefix(backup.Name, prefix)) {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// defaultRetentionDays đọc số ngày lưu giữ chung từ cấu hình BACKUP_RETENTION_DAYS
+func defaultRetentionDays() int {
+	value, err := database.GetConfigValue("BACKUP_RETENTION_DAYS")
+	if err != nil || value == "" {
+		return 0
+	}
+	days, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || days < 0 {
+		log.Printf("Giá trị BACKUP_RETENTION_DAYS không hợp lệ: %s", value)
+		return 0
+	}
+	return days
+}
+
+// newItem tạo Item từ kết quả đánh giá
+func newItem(decision Decision) Item {
+	id, _ := strconv.ParseInt(decision.Backup.ID, 10, 64)
+	return Item{
+		BackupID:  id,
+		Name:      decision.Backup.Name,
+		Path:      decision.Backup.Path,
+		Size:      decision.Backup.Size,
+		CreatedAt: decision.Backup.CreatedAt,
+		DriveLink: decision.Backup.DriveLink,
+		Reasons:   decision.Reasons,
+	}
+}
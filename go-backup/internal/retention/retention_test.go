@@ -0,0 +1,141 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// setupRetentionTest tạo database tạm với schema đầy đủ
+func setupRetentionTest(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{DBSource: filepath.Join(dir, "app.db"), BackupDir: filepath.Join(dir, "backups")}
+	if err := database.Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(cfg.DBSource); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// addProfileBackups tạo count file backup (cách nhau một ngày, mới nhất trước) và ghi vào catalog
+func addProfileBackups(t *testing.T, cfg *config.Config, profile models.DatabaseProfile, count int) {
+	t.Helper()
+	now := time.Now()
+	for i := 0; i < count; i++ {
+		createdAt := now.AddDate(0, 0, -i)
+		name := profile.DBName + "_" + createdAt.Format("20060102_150405") + ".sql"
+		path := filepath.Join(cfg.BackupDir, createdAt.Format("2006-01-02"), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("SELECT 1;\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := backupdb.AddBackup(backupdb.NewBackup{
+			Filename:  name,
+			Filepath:  path,
+			Filesize:  10,
+			CreatedAt: createdAt,
+			ProfileID: profile.ID,
+			DBName:    profile.DBName,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunPoliciesArePerProfile(t *testing.T) {
+	cfg := setupRetentionTest(t)
+	engine := NewEngine(cfg, nil)
+
+	profiles := []models.DatabaseProfile{
+		{Name: "shop", DBName: "shop", DBUser: "postgres", ConnectionMode: models.ConnectionModeTCP, DBHost: "127.0.0.1", RetentionKeepLast: 2},
+		{Name: "billing", DBName: "billing", DBUser: "postgres", ConnectionMode: models.ConnectionModeTCP, DBHost: "127.0.0.1", RetentionKeepLast: 4},
+		{Name: "crm", DBName: "crm", DBUser: "postgres", ConnectionMode: models.ConnectionModeTCP, DBHost: "127.0.0.1"},
+	}
+	for i := range profiles {
+		id, err := database.CreateProfile(profiles[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		profiles[i].ID = id
+		addProfileBackups(t, cfg, profiles[i], 5)
+	}
+
+	// Mỗi profile chỉ đánh giá backup của mình theo chính sách của mình
+	want := map[string][2]int{"shop": {2, 3}, "billing": {4, 1}, "crm": {5, 0}}
+	for _, profile := range profiles {
+		report, err := engine.Run(profile.ID, true)
+		if err != nil {
+			t.Fatalf("Run(%s): %v", profile.Name, err)
+		}
+		got := [2]int{len(report.Kept), len(report.Removed)}
+		if got != want[profile.Name] {
+			t.Errorf("%s: giữ/xóa = %v, muốn %v", profile.Name, got, want[profile.Name])
+		}
+		for _, item := range append(report.Kept, report.Removed...) {
+			if !strings.HasPrefix(item.Name, profile.DBName+"_") {
+				t.Errorf("%s: báo cáo chứa backup của profile khác: %s", profile.Name, item.Name)
+			}
+		}
+	}
+
+	// Chạy thật với shop không đụng đến backup của billing
+	if _, err := engine.Run(profiles[0].ID, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, profile := range profiles {
+		backups, err := ProfileBackups(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantCount := 5
+		if profile.Name == "shop" {
+			wantCount = 2
+		}
+		if len(backups) != wantCount {
+			t.Errorf("%s còn %d backup, muốn %d", profile.Name, len(backups), wantCount)
+		}
+	}
+}
+
+func TestProfileBackupsLegacyPrefix(t *testing.T) {
+	cfg := setupRetentionTest(t)
+	shop := models.DatabaseProfile{ID: 1, DBName: "shop"}
+
+	// Backup cũ chưa gắn profile được nhận theo tiền tố <db_name>_<timestamp>
+	for _, name := range []string{"shop_20240101_000000.sql", "shop_archive_20240101_000000.sql", "shopping_20240101_000000.sql"} {
+		path := filepath.Join(cfg.BackupDir, name)
+		if _, err := backupdb.AddBackup(backupdb.NewBackup{Filename: name, Filepath: path, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Backup của profile khác có cùng tiền tố vẫn không thuộc về shop
+	if _, err := backupdb.AddBackup(backupdb.NewBackup{Filename: "shop_20240102_000000.sql", Filepath: filepath.Join(cfg.BackupDir, "other", "shop_20240102_000000.sql"),
+		CreatedAt: time.Now(), ProfileID: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := ProfileBackups(shop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].Name != "shop_20240101_000000.sql" {
+		names := []string{}
+		for _, b := range backups {
+			names = append(names, b.Name)
+		}
+		t.Errorf("ProfileBackups = %v, muốn [shop_20240101_000000.sql]", names)
+	}
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/backup-cronjob/internal/audit"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/dbdump"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/retention"
+)
+
+// AddDrillJob thêm lịch restore drill cho backup mới nhất của profile, schedule rỗng thì xóa lịch cũ
+func (s *Scheduler) AddDrillJob(profileID int64, schedule string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if oldEntryID, exists := s.profileDrills[profileID]; exists {
+		s.cron.Remove(oldEntryID)
+		delete(s.profileDrills, profileID)
+	}
+
+	if schedule == "" {
+		return nil
+	}
+
+	drillLogger := logger.Component("scheduler").With(logger.KeyProfileID, profileID, "profile", name)
+	entryID, err := s.cron.AddFunc(schedule, func() {
+		drillLogger.Info("Đến lịch restore drill")
+		if reason := s.scheduleSkipReason(profileID); reason != "" {
+			drillLogger.Info("Bỏ qua restore drill", "reason", reason)
+			return
+		}
+		_, err := s.DrillLatestBackup(context.Background(), profileID)
+		if err != nil {
+			drillLogger.Error("Restore drill theo lịch thất bại", "error", err)
+		}
+		audit.System("backup.drill", "profile", strconv.FormatInt(profileID, 10), "Restore drill theo lịch", err)
+	})
+	if err != nil {
+		return fmt.Errorf("lỗi khi thêm lịch restore drill: %v", err)
+	}
+
+	s.profileDrills[profileID] = entryID
+	drillLogger.Info("Đã thêm lịch restore drill", "schedule", schedule)
+	return nil
+}
+
+// RunDrill chạy restore drill cho backup vào container tạm thời, mỗi profile chỉ chạy một drill tại một thời điểm
+func (s *Scheduler) RunDrill(ctx context.Context, backupID, profileID int64) (*dbdump.DrillResult, error) {
+	s.mu.Lock()
+	if s.drillsRunning[profileID] {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("profile %d đang có restore drill chạy", profileID)
+	}
+	s.drillsRunning[profileID] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.drillsRunning, profileID)
+		s.mu.Unlock()
+	}()
+
+	return s.driller.RunDrill(ctx, backupID, profileID)
+}
+
+// DrillLatestBackup chạy restore drill cho backup mới nhất của profile
+func (s *Scheduler) DrillLatestBackup(ctx context.Context, profileID int64) (*dbdump.DrillResult, error) {
+	profile, err := database.GetProfileByID(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi khi lấy thông tin profile: %v", err)
+	}
+
+	backups, err := retention.ProfileBackups(*profile)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("profile '%s' chưa có backup nào để kiểm tra", profile.Name)
+	}
+
+	backupID, err := strconv.ParseInt(backups[0].ID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("ID backup không hợp lệ: %s", backups[0].ID)
+	}
+	return s.RunDrill(ctx, backupID, profileID)
+}
+
+// drillAfterBackup chạy restore drill cho backup vừa tạo trong job backup, trả về kết quả để ghi vào log job
+func (s *Scheduler) drillAfterBackup(ctx context.Context, profile models.DatabaseProfile, backupID int64) string {
+	events.Emit(ctx, events.Event{Type: events.TypeLog, Message: "Đang chạy restore drill cho backup vừa tạo..."})
+
+	result, err := s.RunDrill(ctx, backupID, profile.ID)
+	if err != nil {
+		if result == nil {
+			logger.Component("scheduler").Error("Không thể chạy restore drill", logger.KeyJobID, events.JobID(ctx),
+				logger.KeyProfileID, profile.ID, logger.KeyBackupID, backupID, "profile", profile.Name, "error", err)
+			return fmt.Sprintf("Không thể chạy restore drill: %v", err)
+		}
+		events.Emit(ctx, events.Event{Type: events.TypeLog, Message: result.Message})
+		return "Restore drill thất bại"
+	}
+
+	events.Emit(ctx, events.Event{Type: events.TypeLog, Message: result.Message})
+	return "Restore drill thành công"
+}
@@ -0,0 +1,322 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/backup-cronjob/internal/audit"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/metrics"
+)
+
+// Nguồn kích hoạt một backup
+const (
+	TriggerSchedule = "schedule" // Chạy theo lịch cron
+	TriggerManual   = "manual"   // Chạy thủ công từ API
+)
+
+// queueCheckInterval là chu kỳ kiểm tra các job chờ quá thời gian cho phép
+const queueCheckInterval = 30 * time.Second
+
+var (
+	// ErrAlreadyQueued là lỗi khi profile đã có backup đang chờ, lần kích hoạt mới được gộp vào job đó
+	ErrAlreadyQueued = errors.New("profile đã có backup đang chờ trong hàng đợi")
+	// ErrQueueStopped là lỗi khi hàng đợi đã dừng (ứng dụng đang tắt)
+	ErrQueueStopped = errors.New("hàng đợi backup đã dừng")
+)
+
+// Job là một lần backup của profile trong hàng đợi
+type Job struct {
+	ID          int64      `json:"id"` // ID bản ghi trong job_logs
+	ProfileID   int64      `json:"profile_id"`
+	ProfileName string     `json:"profile_name"`
+	Trigger     string     `json:"trigger"` // schedule, manual
+	Status      string     `json:"status"`  // queued, running
+	EnqueuedAt  time.Time  `json:"enqueued_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	Cancelled   bool       `json:"cancelled,omitempty"` // Đã yêu cầu hủy, đang chờ tiến trình dừng
+
+	cancel context.CancelFunc
+}
+
+// jobQueue là hàng đợi backup với số worker cố định.
+// Mỗi profile chỉ có tối đa một job đang chạy và một job đang chờ
+type jobQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  []*Job
+	running  map[int64]*Job // Job đang chạy theo profile ID
+	timeout  time.Duration
+	run      func(ctx context.Context, job *Job)
+	stopping bool
+	done     chan struct{}
+}
+
+// newJobQueue tạo hàng đợi, run là hàm thực hiện một job, ctx bị hủy khi job bị hủy
+func newJobQueue(timeout time.Duration, run func(ctx context.Context, job *Job)) *jobQueue {
+	q := &jobQueue{
+		running: make(map[int64]*Job),
+		timeout: timeout,
+		run:     run,
+		done:    make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Start khởi động các worker xử lý hàng đợi
+func (q *jobQueue) Start(workers int) {
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	go q.watchTimeouts()
+	logger.Component("scheduler").Info("Hàng đợi backup đã khởi động", "workers", workers)
+}
+
+// Stop ngừng nhận job mới; các job đang chờ được ghi log thất bại, job đang chạy vẫn chạy đến khi xong
+func (q *jobQueue) Stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopping {
+		return
+	}
+	q.stopping = true
+	close(q.done)
+
+	for _, job := range q.pending {
+		finishJob(job, "", database.JobStatusFailed, "Ứng dụng dừng trước khi backup được chạy")
+	}
+	q.pending = nil
+	q.cond.Broadcast()
+}
+
+// Enqueue đưa backup của profile vào hàng đợi. Nếu profile đã có job đang chờ, lần chạy này
+// được gộp vào job đó: trả về job đang chờ kèm ErrAlreadyQueued, lần chạy theo lịch bị gộp
+// được ghi vào job_logs với trạng thái skipped
+func (q *jobQueue) Enqueue(profileID int64, profileName, trigger string) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopping {
+		return nil, ErrQueueStopped
+	}
+
+	for _, job := range q.pending {
+		if job.ProfileID == profileID {
+			message := fmt.Sprintf("Đã có backup của profile '%s' đang chờ trong hàng đợi (job %d), bỏ qua lần chạy này", profileName, job.ID)
+			jobLogger := logger.Component("scheduler").With(logger.KeyProfileID, profileID, "profile", profileName)
+			if trigger == TriggerSchedule {
+				if _, err := database.InsertFinishedJobLog(profileID, database.JobTypeBackup, database.JobStatusSkipped, message); err != nil {
+					jobLogger.Error("Lỗi khi ghi log job bị bỏ qua", "error", err)
+				}
+			}
+			jobLogger.Warn(message, "trigger", trigger)
+			pending := *job
+			return &pending, fmt.Errorf("%w (job %d)", ErrAlreadyQueued, job.ID)
+		}
+	}
+
+	job := &Job{
+		ProfileID:   profileID,
+		ProfileName: profileName,
+		Trigger:     trigger,
+		Status:      database.JobStatusQueued,
+		EnqueuedAt:  time.Now(),
+	}
+
+	logID, err := database.CreateJobLog(profileID, database.JobStatusQueued, job.EnqueuedAt)
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo log job: %v", err)
+	}
+	job.ID = logID
+	events.Publish(events.Event{Type: events.TypeQueued, JobID: job.ID, ProfileID: profileID, Status: job.Status, Message: trigger})
+
+	q.pending = append(q.pending, job)
+	q.cond.Signal()
+
+	if _, busy := q.running[profileID]; busy {
+		job.logger().Info("Profile đang có backup chạy, job chờ đến lượt", "trigger", trigger)
+	} else {
+		job.logger().Info("Đã đưa backup vào hàng đợi", "trigger", trigger)
+	}
+	return job, nil
+}
+
+// Jobs trả về các job đang chạy và đang chờ
+func (q *jobQueue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.running)+len(q.pending))
+	for _, job := range q.running {
+		jobs = append(jobs, *job)
+	}
+	for _, job := range q.pending {
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
+
+// Cancel hủy job theo ID: job đang chờ bị xóa khỏi hàng đợi, job đang chạy bị dừng qua context.
+// Trả về false nếu không có job đang chạy hoặc đang chờ với ID này
+func (q *jobQueue) Cancel(id int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, job := range q.pending {
+		if job.ID == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			finishJob(job, "", database.JobStatusCancelled, "Backup đã bị hủy trước khi chạy")
+			return true
+		}
+	}
+
+	for _, job := range q.running {
+		if job.ID == id {
+			job.Cancelled = true
+			job.cancel()
+			job.logger().Info("Đang hủy job")
+			return true
+		}
+	}
+	return false
+}
+
+// worker lấy lần lượt các job có thể chạy và thực hiện
+func (q *jobQueue) worker() {
+	for {
+		job, ctx := q.next()
+		if job == nil {
+			return
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					job.logger().Error("Backup job bị panic", "panic", r)
+					finishJob(job, "", database.JobStatusFailed, fmt.Sprintf("Lỗi không mong muốn: %v", r))
+				}
+			}()
+			q.run(ctx, job)
+		}()
+
+		q.mu.Lock()
+		job.cancel()
+		delete(q.running, job.ProfileID)
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// next chờ đến khi có job của profile không có backup đang chạy, trả về nil khi hàng đợi dừng
+func (q *jobQueue) next() (*Job, context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if q.stopping {
+			return nil, nil
+		}
+		q.expire()
+
+		for i, job := range q.pending {
+			if _, busy := q.running[job.ProfileID]; busy {
+				continue
+			}
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			now := time.Now()
+			job.Status = database.JobStatusRunning
+			job.StartedAt = &now
+			job.cancel = cancel
+			q.running[job.ProfileID] = job
+			return job, ctx
+		}
+
+		q.cond.Wait()
+	}
+}
+
+// watchTimeouts định kỳ hủy các job chờ quá lâu, kể cả khi tất cả worker đang bận
+func (q *jobQueue) watchTimeouts() {
+	ticker := time.NewTicker(queueCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+			q.mu.Lock()
+			q.expire()
+			q.mu.Unlock()
+		}
+	}
+}
+
+// expire hủy các job chờ quá thời gian cho phép và ghi log timeout. Gọi khi đang giữ q.mu
+func (q *jobQueue) expire() {
+	if q.timeout <= 0 {
+		return
+	}
+
+	remaining := q.pending[:0]
+	for _, job := range q.pending {
+		waited := time.Since(job.EnqueuedAt)
+		if waited <= q.timeout {
+			remaining = append(remaining, job)
+			continue
+		}
+		message := fmt.Sprintf("Backup chờ trong hàng đợi quá %s, đã hủy", q.timeout)
+		finishJob(job, "", database.JobStatusTimeout, message)
+	}
+	q.pending = remaining
+}
+
+// logger trả về logger gắn job_id, profile_id và tên profile của job
+func (j *Job) logger() *slog.Logger {
+	return logger.Component("scheduler").With(logger.KeyJobID, j.ID, logger.KeyProfileID, j.ProfileID, "profile", j.ProfileName)
+}
+
+// finishJob ghi trạng thái kết thúc của job vào job_logs và phát sự kiện finished/failed
+func finishJob(job *Job, backupFile, status, message string) {
+	jobLogger := job.logger()
+	if err := database.UpdateJobLog(job.ID, status, time.Now(), backupFile, message); err != nil {
+		jobLogger.Error("Lỗi khi cập nhật log job", "error", err)
+	}
+
+	level := slog.LevelInfo
+	switch status {
+	case database.JobStatusFailed, database.JobStatusTimeout:
+		level = slog.LevelError
+	case database.JobStatusCancelled, database.JobStatusSkipped:
+		level = slog.LevelWarn
+	}
+	jobLogger.Log(context.Background(), level, "Job backup kết thúc", "status", status, "message", message, "backup_file", backupFile)
+	metrics.ObserveJob(job.ProfileID, job.ProfileName, status)
+
+	var jobErr error
+	if status == database.JobStatusFailed || status == database.JobStatusTimeout {
+		jobErr = errors.New(message)
+	}
+	audit.System("backup.run", "job", strconv.FormatInt(job.ID, 10),
+		fmt.Sprintf("Profile '%s' (%s): %s", job.ProfileName, job.Trigger, status), jobErr)
+
+	eventType := events.TypeFinished
+	if status == database.JobStatusFailed {
+		eventType = events.TypeFailed
+	}
+	events.Publish(events.Event{Type: eventType, JobID: job.ID, ProfileID: job.ProfileID, Status: status, Message: message})
+}
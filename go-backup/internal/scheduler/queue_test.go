@@ -0,0 +1,318 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/database"
+)
+
+// blockingRunner là hàm chạy job giả: mỗi job chặn đến khi được release hoặc bị hủy qua ctx
+type blockingRunner struct {
+	started chan *Job
+
+	mu         sync.Mutex
+	release    map[int64]chan struct{}
+	running    int
+	maxRunning int
+}
+
+func newBlockingRunner() *blockingRunner {
+	return &blockingRunner{started: make(chan *Job, 10), release: make(map[int64]chan struct{})}
+}
+
+// releaseCh trả về channel mở khóa job, tạo mới nếu chưa có
+func (r *blockingRunner) releaseCh(jobID int64) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.release[jobID]
+	if !ok {
+		ch = make(chan struct{})
+		r.release[jobID] = ch
+	}
+	return ch
+}
+
+// Release cho job kết thúc thành công
+func (r *blockingRunner) Release(jobID int64) {
+	close(r.releaseCh(jobID))
+}
+
+func (r *blockingRunner) run(ctx context.Context, job *Job) {
+	r.mu.Lock()
+	r.running++
+	if r.running > r.maxRunning {
+		r.maxRunning = r.running
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running--
+		r.mu.Unlock()
+	}()
+
+	r.started <- job
+	select {
+	case <-ctx.Done():
+		finishJob(job, "", database.JobStatusCancelled, "Backup đã bị hủy")
+	case <-r.releaseCh(job.ID):
+		finishJob(job, "", database.JobStatusSuccess, "Backup thành công")
+	}
+}
+
+// newTestQueue tạo hàng đợi với runner giả, hàng đợi được dừng khi test kết thúc
+func newTestQueue(t *testing.T, workers int, timeout time.Duration) (*jobQueue, *blockingRunner) {
+	t.Helper()
+	setupSchedulerTest(t)
+	runner := newBlockingRunner()
+	q := newJobQueue(timeout, runner.run)
+	q.Start(workers)
+	t.Cleanup(func() { drainQueue(t, q) })
+	return q, runner
+}
+
+// drainQueue dừng hàng đợi và hủy các job còn chạy, chờ chúng kết thúc trước khi database bị đóng
+func drainQueue(t *testing.T, q *jobQueue) {
+	q.Stop()
+	for _, job := range q.Jobs() {
+		q.Cancel(job.ID)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(q.Jobs()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("còn job chạy sau khi dừng hàng đợi")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// enqueue đưa job của profile vào hàng đợi, báo lỗi nếu không được
+func enqueue(t *testing.T, q *jobQueue, profileID int64, trigger string) *Job {
+	t.Helper()
+	job, err := q.Enqueue(profileID, "profile", trigger)
+	if err != nil {
+		t.Fatalf("Enqueue(%d): %v", profileID, err)
+	}
+	return job
+}
+
+// waitStarted chờ runner nhận một job
+func waitStarted(t *testing.T, r *blockingRunner) *Job {
+	t.Helper()
+	select {
+	case job := <-r.started:
+		return job
+	case <-time.After(5 * time.Second):
+		t.Fatal("không có job nào được chạy")
+		return nil
+	}
+}
+
+// expectNoStart kiểm tra không có job nào được chạy thêm
+func expectNoStart(t *testing.T, r *blockingRunner) {
+	t.Helper()
+	select {
+	case job := <-r.started:
+		t.Fatalf("job %d (profile %d) không được phép chạy lúc này", job.ID, job.ProfileID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// waitJobStatus chờ job_log của job có trạng thái status
+func waitJobStatus(t *testing.T, jobID int64, status string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobLog, err := database.GetJobLog(jobID)
+		if err == nil && jobLog.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d có trạng thái %q (err %v), muốn %q", jobID, jobLog.Status, err, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// testProfiles tạo n profile và trả về ID
+func testProfiles(t *testing.T, names ...string) []int64 {
+	t.Helper()
+	ids := make([]int64, len(names))
+	for i, name := range names {
+		ids[i] = createTestProfile(t, name, nil).ID
+	}
+	return ids
+}
+
+func TestQueueOneJobPerProfile(t *testing.T) {
+	q, runner := newTestQueue(t, 2, 0)
+	ids := testProfiles(t, "shop")
+
+	first := enqueue(t, q, ids[0], TriggerManual)
+	waitStarted(t, runner)
+
+	// Profile đang chạy backup: job mới chờ dù còn worker rảnh
+	second := enqueue(t, q, ids[0], TriggerManual)
+	expectNoStart(t, runner)
+
+	// Chỉ giữ một job chờ, lần kích hoạt thứ ba được gộp vào job đang chờ
+	pending, err := q.Enqueue(ids[0], "shop", TriggerManual)
+	if !errors.Is(err, ErrAlreadyQueued) {
+		t.Fatalf("Enqueue lần ba: err = %v, muốn ErrAlreadyQueued", err)
+	}
+	if pending == nil || pending.ID != second.ID {
+		t.Errorf("Enqueue lần ba trả về job %v, muốn job đang chờ %d", pending, second.ID)
+	}
+
+	runner.Release(first.ID)
+	if job := waitStarted(t, runner); job.ID != second.ID {
+		t.Errorf("job chạy tiếp theo = %d, muốn %d", job.ID, second.ID)
+	}
+	waitJobStatus(t, first.ID, database.JobStatusSuccess)
+	runner.Release(second.ID)
+	waitJobStatus(t, second.ID, database.JobStatusSuccess)
+}
+
+func TestQueueCoalescedScheduleRunIsLogged(t *testing.T) {
+	q, runner := newTestQueue(t, 1, 0)
+	ids := testProfiles(t, "shop", "billing")
+
+	// billing giữ worker duy nhất để job của shop nằm chờ
+	blocker := enqueue(t, q, ids[1], TriggerManual)
+	waitStarted(t, runner)
+	enqueue(t, q, ids[0], TriggerSchedule)
+
+	if _, err := q.Enqueue(ids[0], "shop", TriggerManual); !errors.Is(err, ErrAlreadyQueued) {
+		t.Fatalf("err = %v, muốn ErrAlreadyQueued", err)
+	}
+	if _, err := q.Enqueue(ids[0], "shop", TriggerSchedule); !errors.Is(err, ErrAlreadyQueued) {
+		t.Fatalf("err = %v, muốn ErrAlreadyQueued", err)
+	}
+
+	// Chỉ lần chạy theo lịch bị gộp được ghi vào lịch sử, lần thủ công nhận lỗi qua API
+	logs, err := database.GetJobLogsByProfile(ids[0], 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := 0
+	for _, jobLog := range logs {
+		if jobLog.Status == database.JobStatusSkipped {
+			skipped++
+		}
+	}
+	if skipped != 1 {
+		t.Errorf("số job skipped = %d, muốn 1", skipped)
+	}
+	runner.Release(blocker.ID)
+}
+
+func TestQueueConcurrencyCap(t *testing.T) {
+	q, runner := newTestQueue(t, 2, 0)
+	ids := testProfiles(t, "shop", "billing", "crm")
+
+	jobs := make([]*Job, len(ids))
+	for i, id := range ids {
+		jobs[i] = enqueue(t, q, id, TriggerManual)
+	}
+	first := waitStarted(t, runner)
+	waitStarted(t, runner)
+	expectNoStart(t, runner)
+
+	runner.Release(first.ID)
+	waitStarted(t, runner)
+	for _, job := range jobs {
+		if job.ID != first.ID {
+			runner.Release(job.ID)
+		}
+	}
+	for _, job := range jobs {
+		waitJobStatus(t, job.ID, database.JobStatusSuccess)
+	}
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if runner.maxRunning != 2 {
+		t.Errorf("số job chạy đồng thời tối đa = %d, muốn 2", runner.maxRunning)
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q, runner := newTestQueue(t, 1, 0)
+	ids := testProfiles(t, "shop", "billing")
+
+	running := enqueue(t, q, ids[0], TriggerManual)
+	waitStarted(t, runner)
+	queued := enqueue(t, q, ids[1], TriggerManual)
+
+	// Job đang chờ bị xóa khỏi hàng đợi, không bao giờ chạy
+	if !q.Cancel(queued.ID) {
+		t.Fatal("Cancel job đang chờ trả về false")
+	}
+	waitJobStatus(t, queued.ID, database.JobStatusCancelled)
+	if jobs := q.Jobs(); len(jobs) != 1 || jobs[0].ID != running.ID {
+		t.Errorf("hàng đợi sau khi hủy = %+v", jobs)
+	}
+
+	// Job đang chạy bị dừng qua context
+	if !q.Cancel(running.ID) {
+		t.Fatal("Cancel job đang chạy trả về false")
+	}
+	waitJobStatus(t, running.ID, database.JobStatusCancelled)
+	expectNoStart(t, runner)
+
+	if q.Cancel(running.ID + 100) {
+		t.Error("Cancel job không tồn tại trả về true")
+	}
+}
+
+func TestQueueExpiry(t *testing.T) {
+	q, runner := newTestQueue(t, 1, 50*time.Millisecond)
+	ids := testProfiles(t, "shop", "billing")
+
+	running := enqueue(t, q, ids[0], TriggerManual)
+	waitStarted(t, runner)
+	queued := enqueue(t, q, ids[1], TriggerManual)
+
+	time.Sleep(100 * time.Millisecond)
+	q.mu.Lock()
+	q.expire()
+	q.mu.Unlock()
+
+	waitJobStatus(t, queued.ID, database.JobStatusTimeout)
+	if jobs := q.Jobs(); len(jobs) != 1 || jobs[0].ID != running.ID {
+		t.Errorf("hàng đợi sau khi hết hạn = %+v", jobs)
+	}
+
+	// Job đang chạy không bị ảnh hưởng bởi thời gian chờ
+	runner.Release(running.ID)
+	waitJobStatus(t, running.ID, database.JobStatusSuccess)
+	expectNoStart(t, runner)
+}
+
+func TestQueueStop(t *testing.T) {
+	q, runner := newTestQueue(t, 1, 0)
+	ids := testProfiles(t, "shop", "billing")
+
+	running := enqueue(t, q, ids[0], TriggerManual)
+	waitStarted(t, runner)
+	queued := enqueue(t, q, ids[1], TriggerManual)
+
+	q.Stop()
+
+	// Job đang chờ được ghi thất bại, không nhận thêm job mới
+	waitJobStatus(t, queued.ID, database.JobStatusFailed)
+	if _, err := q.Enqueue(ids[1], "billing", TriggerManual); !errors.Is(err, ErrQueueStopped) {
+		t.Errorf("Enqueue sau khi dừng: err = %v, muốn ErrQueueStopped", err)
+	}
+
+	// Job đang chạy vẫn chạy đến khi xong
+	runner.Release(running.ID)
+	waitJobStatus(t, running.ID, database.JobStatusSuccess)
+	expectNoStart(t, runner)
+
+	// Gọi Stop lần nữa không lỗi
+	q.Stop()
+}
@@ -1,15 +1,25 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/backup-cronjob/internal/audit"
 	"github.com/backup-cronjob/internal/config"
 	"github.com/backup-cronjob/internal/database"
 	"github.com/backup-cronjob/internal/dbdump"
 	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/backup-cronjob/internal/notify"
+	"github.com/backup-cronjob/internal/retention"
+	"github.com/backup-cronjob/internal/storage"
 	"github.com/robfig/cron/v3"
 )
 
@@ -19,11 +29,16 @@ type Scheduler struct {
 	jobs           map[string]cron.EntryID
 	config         *config.Config
 	driveUploader  *drive.DriveUploader
+	storage        *storage.Manager
 	databaseDumper *dbdump.DatabaseDumper
-	jobInProgress  bool
+	retention      *retention.Engine
+	driller        *dbdump.Driller
+	notifier       *notify.Notifier
+	queue          *jobQueue
 	mu             sync.Mutex
 	profileBackups map[int64]cron.EntryID // Lưu EntryID theo profile ID
-	jobStatus      map[int64]string       // Lưu trạng thái job theo profile ID: "running", "stopped"
+	profileDrills  map[int64]cron.EntryID // EntryID của lịch restore drill theo profile ID
+	drillsRunning  map[int64]bool         // Profile đang chạy restore drill
 }
 
 // NewScheduler tạo một scheduler mới
@@ -32,33 +47,57 @@ func NewScheduler(cfg *config.Config, driveUploader *drive.DriveUploader) *Sched
 	c := cron.New(cron.WithParser(cron.NewParser(
 		cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
 	)))
+	storageManager := storage.NewManager(cfg, driveUploader)
 
-	return &Scheduler{
+	s := &Scheduler{
 		cron:           c,
 		jobs:           make(map[string]cron.EntryID),
 		config:         cfg,
 		driveUploader:  driveUploader,
+		storage:        storageManager,
 		databaseDumper: dbdump.NewDatabaseDumper(cfg),
-		jobInProgress:  false,
+		retention:      retention.NewEngine(cfg, storageManager),
+		driller:        dbdump.NewDriller(cfg, storageManager),
+		notifier:       notify.NewNotifier(cfg),
 		profileBackups: make(map[int64]cron.EntryID),
-		jobStatus:      make(map[int64]string),
+		profileDrills:  make(map[int64]cron.EntryID),
+		drillsRunning:  make(map[int64]bool),
 	}
+	s.queue = newJobQueue(cfg.BackupQueueTimeout, s.runBackup)
+	return s
 }
 
 // Start khởi động scheduler
 func (s *Scheduler) Start() {
-	log.Println("Đang khởi động scheduler...")
+	schedulerLogger := logger.Component("scheduler")
+	schedulerLogger.Info("Đang khởi động scheduler")
+	// Hàng đợi chỉ nằm trong bộ nhớ, các job chưa xong từ lần chạy trước không còn được thực hiện
+	if n, err := database.AbortUnfinishedJobLogs(database.JobTypeBackup, "Ứng dụng đã khởi động lại trước khi backup hoàn tất"); err != nil {
+		schedulerLogger.Error("Lỗi khi cập nhật log các job chưa hoàn tất", "error", err)
+	} else if n > 0 {
+		schedulerLogger.Warn("Đã đánh dấu thất bại các backup chưa hoàn tất từ lần chạy trước", "count", n)
+	}
+	s.queue.Start(s.config.BackupWorkers)
+	s.notifier.Start()
+	if schedule := s.config.NotifyDigestSchedule; schedule != "" {
+		if _, err := s.cron.AddFunc(schedule, s.notifier.SendDigests); err != nil {
+			schedulerLogger.Error("Lịch gửi báo cáo tổng hợp không hợp lệ", "schedule", schedule, "error", err)
+		}
+	}
 	s.cron.Start()
 	// Tải lịch backup từ tất cả các profile
 	s.LoadAllProfiles()
-	log.Println("Scheduler đã khởi động thành công")
+	schedulerLogger.Info("Scheduler đã khởi động thành công")
 }
 
 // Stop dừng scheduler
 func (s *Scheduler) Stop() {
-	log.Println("Đang dừng scheduler...")
+	schedulerLogger := logger.Component("scheduler")
+	schedulerLogger.Info("Đang dừng scheduler")
 	s.cron.Stop()
-	log.Println("Scheduler đã dừng")
+	s.queue.Stop()
+	s.notifier.Stop()
+	schedulerLogger.Info("Scheduler đã dừng")
 }
 
 // AddJob thêm một công việc backup mới
@@ -66,101 +105,31 @@ func (s *Scheduler) AddJob(profileID int64, schedule string, name string) error
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	profileLogger := logger.Component("scheduler").With(logger.KeyProfileID, profileID, "profile", name)
+
 	// Kiểm tra nếu đã có job cho profile này, xóa job cũ
 	if oldEntryID, exists := s.profileBackups[profileID]; exists {
 		s.cron.Remove(oldEntryID)
 		delete(s.profileBackups, profileID)
-		log.Printf("Đã xóa job cũ cho profile ID %d", profileID)
+		profileLogger.Debug("Đã xóa lịch backup cũ")
 	}
 
 	// Nếu schedule rỗng, không thêm job mới
 	if schedule == "" {
-		log.Printf("Schedule rỗng cho profile ID %d, không thêm job", profileID)
-		delete(s.jobStatus, profileID)
+		profileLogger.Debug("Schedule rỗng, không thêm lịch backup")
 		return nil
 	}
 
-	// Thêm công việc backup mới
+	// Thêm công việc backup mới, backup được đưa vào hàng đợi và chờ đến lượt
 	jobID, err := s.cron.AddFunc(schedule, func() {
-		s.mu.Lock()
-		// Kiểm tra nếu có công việc đang chạy
-		if s.jobInProgress {
-			log.Printf("Đã có công việc đang chạy, bỏ qua backup cho profile '%s'", name)
-			s.mu.Unlock()
-			return
-		}
-		s.jobInProgress = true
-		s.mu.Unlock()
-
-		log.Printf("Đang thực hiện backup tự động cho profile '%s'", name)
-
-		// Ghi log bắt đầu
-		startTime := time.Now()
-		logID, logErr := database.CreateJobLog(profileID, "running", startTime)
-		if logErr != nil {
-			log.Printf("Lỗi khi tạo log job: %v", logErr)
-		}
-
-		// Đảm bảo luôn cập nhật trạng thái job khi kết thúc
-		defer func() {
-			s.mu.Lock()
-			s.jobInProgress = false
-			s.mu.Unlock()
-		}()
-
-		// Thực hiện backup
-		profile, err := database.GetProfileByID(profileID)
-		if err != nil {
-			log.Printf("Lỗi khi lấy thông tin profile %d: %v", profileID, err)
-			if logID > 0 {
-				database.UpdateJobLog(logID, "failed", time.Now(), "", fmt.Sprintf("Lỗi khi lấy thông tin profile: %v", err))
-			}
-			return
-		}
-
-		// Thực hiện dump database
-		result, err := s.databaseDumper.DumpDatabase(profile.ID)
-		if err != nil {
-			log.Printf("Lỗi khi backup profile '%s': %v", profile.Name, err)
-			if logID > 0 {
-				database.UpdateJobLog(logID, "failed", time.Now(), "", fmt.Sprintf("Lỗi khi backup: %v", err))
-			}
+		profileLogger.Info("Đến lịch backup tự động")
+		if reason := s.scheduleSkipReason(profileID); reason != "" {
+			s.logSkippedRun(profileID, name, reason)
 			return
 		}
-		backupFilePath := result.FilePath
-
-		log.Printf("Đã tạo backup: %s", backupFilePath)
-		uploadSuccess := true
-		uploadMessage := ""
-
-		// Upload lên Google Drive nếu được cấu hình
-		if profile.UploadToDrive && s.driveUploader != nil {
-			log.Printf("Đang upload backup lên Google Drive...")
-			uploadResult := s.driveUploader.UploadFile(backupFilePath)
-			if uploadResult.Success {
-				log.Printf("Upload thành công: %s", uploadResult.WebLink)
-				uploadMessage = fmt.Sprintf("Upload thành công: %s", uploadResult.WebLink)
-			} else {
-				log.Printf("Lỗi khi upload: %s", uploadResult.Message)
-				uploadSuccess = false
-				uploadMessage = fmt.Sprintf("Lỗi khi upload: %s", uploadResult.Message)
-			}
-		}
-
-		// Cập nhật log hoàn thành
-		if logID > 0 {
-			endTime := time.Now()
-			status := "success"
-			message := "Backup thành công"
-
-			if !uploadSuccess {
-				message = uploadMessage
-			}
-
-			database.UpdateJobLog(logID, status, endTime, backupFilePath, message)
+		if _, err := s.queue.Enqueue(profileID, name, TriggerSchedule); err != nil {
+			profileLogger.Warn("Không thể đưa backup vào hàng đợi", "error", err)
 		}
-
-		log.Printf("Hoàn thành backup tự động cho profile '%s'", name)
 	})
 
 	if err != nil {
@@ -169,8 +138,7 @@ func (s *Scheduler) AddJob(profileID int64, schedule string, name string) error
 
 	// Lưu ID của job theo profile ID
 	s.profileBackups[profileID] = jobID
-	s.jobStatus[profileID] = "running"
-	log.Printf("Đã thêm lịch backup '%s' cho profile ID %d", schedule, profileID)
+	profileLogger.Info("Đã thêm lịch backup", "schedule", schedule)
 
 	return nil
 }
@@ -183,7 +151,7 @@ func (s *Scheduler) RemoveJob(profileID int64) {
 	if entryID, exists := s.profileBackups[profileID]; exists {
 		s.cron.Remove(entryID)
 		delete(s.profileBackups, profileID)
-		log.Printf("Đã xóa job cho profile ID %d", profileID)
+		logger.Component("scheduler").Info("Đã xóa lịch backup", logger.KeyProfileID, profileID)
 	}
 }
 
@@ -199,97 +167,191 @@ func (s *Scheduler) LoadAllProfiles() error {
 		if profile.IsActive && profile.CronSchedule != "" {
 			err := s.AddJob(profile.ID, profile.CronSchedule, profile.Name)
 			if err != nil {
-				log.Printf("Lỗi khi thêm job cho profile '%s': %v", profile.Name, err)
+				logger.Component("scheduler").Error("Lỗi khi thêm lịch backup", logger.KeyProfileID, profile.ID, "profile", profile.Name, "error", err)
 				continue
 			}
 		}
+		if profile.IsActive && profile.DrillSchedule != "" {
+			if err := s.AddDrillJob(profile.ID, profile.DrillSchedule, profile.Name); err != nil {
+				logger.Component("scheduler").Error("Lỗi khi thêm lịch restore drill", logger.KeyProfileID, profile.ID, "profile", profile.Name, "error", err)
+			}
+		}
 	}
 
 	return nil
 }
 
-// RunBackupNow chạy backup ngay lập tức
-func (s *Scheduler) RunBackupNow(profileID int64) error {
-	s.mu.Lock()
-	// Kiểm tra nếu đang có công việc backup đang chạy
-	if s.jobInProgress {
-		s.mu.Unlock()
-		return fmt.Errorf("đã có công việc backup đang chạy, vui lòng thử lại sau")
+// RunBackupNow đưa backup của profile vào hàng đợi để chạy ngay khi có worker rảnh
+func (s *Scheduler) RunBackupNow(profileID int64) (*Job, error) {
+	// Lấy thông tin profile
+	profile, err := database.GetProfileByID(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("lỗi khi lấy thông tin profile: %v", err)
 	}
-	s.jobInProgress = true
-	s.mu.Unlock()
 
-	// Đảm bảo luôn đặt jobInProgress về false khi kết thúc
-	defer func() {
-		s.mu.Lock()
-		s.jobInProgress = false
-		s.mu.Unlock()
-	}()
+	return s.queue.Enqueue(profile.ID, profile.Name, TriggerManual)
+}
+
+// GetQueuedJobs trả về các backup đang chạy và đang chờ trong hàng đợi
+func (s *Scheduler) GetQueuedJobs() []Job {
+	return s.queue.Jobs()
+}
+
+// runBackup thực hiện một job trong hàng đợi: dump database, upload lên các destination và áp dụng retention.
+// Job dừng khi ctx bị hủy (POST /api/jobs/:id/cancel) hoặc chạy quá thời gian tối đa của profile
+func (s *Scheduler) runBackup(ctx context.Context, job *Job) {
+	jobLogger := job.logger()
+	jobLogger.Info("Đang thực hiện backup", "trigger", job.Trigger)
+
+	// Lịch có thể bị tạm dừng trong lúc job chờ trong hàng đợi
+	if job.Trigger == TriggerSchedule {
+		if reason := s.scheduleSkipReason(job.ProfileID); reason != "" {
+			finishJob(job, "", database.JobStatusSkipped, reason)
+			return
+		}
+	}
+
+	if err := database.StartJobLog(job.ID, *job.StartedAt); err != nil {
+		jobLogger.Error("Lỗi khi cập nhật log job", "error", err)
+	}
+	// Các bước dump và upload phát sự kiện tiến độ của job qua ctx
+	ctx = events.WithJob(ctx, job.ID, job.ProfileID)
+	events.Emit(ctx, events.Event{Type: events.TypeStarted, Status: database.JobStatusRunning, Message: job.ProfileName})
 
 	// Lấy thông tin profile
-	profile, err := database.GetProfileByID(profileID)
+	profile, err := database.GetProfileByID(job.ProfileID)
 	if err != nil {
-		return fmt.Errorf("lỗi khi lấy thông tin profile: %v", err)
+		finishJob(job, "", database.JobStatusFailed, fmt.Sprintf("Lỗi khi lấy thông tin profile: %v", err))
+		return
 	}
 
-	log.Printf("Đang thực hiện backup ngay lập tức cho profile '%s'", profile.Name)
-
-	// Ghi log bắt đầu
-	startTime := time.Now()
-	logID, logErr := database.CreateJobLog(profileID, "manual", startTime)
-	if logErr != nil {
-		log.Printf("Lỗi khi tạo log job manual: %v", logErr)
+	if profile.JobTimeoutMinutes > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(profile.JobTimeoutMinutes)*time.Minute)
+		defer cancel()
 	}
 
 	// Thực hiện dump database
-	result, err := s.databaseDumper.DumpDatabase(profile.ID)
+	result, err := s.databaseDumper.DumpDatabaseContext(ctx, profile.ID)
 	if err != nil {
-		if logID > 0 {
-			database.UpdateJobLog(logID, "failed", time.Now(), "", fmt.Sprintf("Lỗi khi backup: %v", err))
+		if ctx.Err() != nil {
+			s.finishStopped(ctx, job, *profile, "", "Backup bị dừng khi đang dump database")
+			return
 		}
-		return fmt.Errorf("lỗi khi backup: %v", err)
+		finishJob(job, "", database.JobStatusFailed, fmt.Sprintf("Lỗi khi backup: %v", err))
+		return
 	}
 	backupFilePath := result.FilePath
 
-	log.Printf("Đã tạo backup: %s", backupFilePath)
-	uploadSuccess := true
-	uploadMessage := ""
-
-	// Upload lên Google Drive nếu được cấu hình
-	if profile.UploadToDrive && s.driveUploader != nil {
-		log.Printf("Đang upload backup lên Google Drive...")
-		uploadResult := s.driveUploader.UploadFile(backupFilePath)
-		if uploadResult.Success {
-			log.Printf("Upload thành công: %s", uploadResult.WebLink)
-			uploadMessage = fmt.Sprintf("Upload thành công: %s", uploadResult.WebLink)
-		} else {
-			log.Printf("Lỗi khi upload: %s", uploadResult.Message)
-			uploadSuccess = false
-			uploadMessage = fmt.Sprintf("Lỗi khi upload: %s", uploadResult.Message)
-		}
+	jobLogger.Info("Đã tạo backup", logger.KeyBackupID, result.BackupID, "backup_file", backupFilePath)
+
+	// Upload lên các destination được cấu hình cho profile
+	uploadSuccess, uploadMessage := s.uploadBackup(ctx, *profile, result.BackupID, backupFilePath)
+	if ctx.Err() != nil {
+		// File backup cục bộ đã hoàn chỉnh, chỉ việc upload bị dừng
+		s.finishStopped(ctx, job, *profile, backupFilePath, "Đã tạo backup nhưng upload bị dừng")
+		return
 	}
 
-	// Cập nhật log hoàn thành
-	if logID > 0 {
-		endTime := time.Now()
-		status := "success"
-		message := "Backup thủ công thành công"
+	// Cập nhật log hoàn thành. Backup chỉ thành công khi đã có bản sao trên mọi destination của profile,
+	// upload lỗi làm job thất bại để gửi thông báo và được tính là thất bại trong metrics
+	status := database.JobStatusSuccess
+	message := "Backup thành công"
+	if job.Trigger == TriggerManual {
+		message = "Backup thủ công thành công"
+	}
+	if !uploadSuccess {
+		status = database.JobStatusFailed
+		message = "Đã tạo backup nhưng upload thất bại. " + uploadMessage
+	}
 
-		if !uploadSuccess {
-			message = uploadMessage
+	// Restore drill ngay sau backup nếu profile yêu cầu, kết quả được lưu riêng vào bản ghi backup
+	if profile.DrillAfterBackup && result.BackupID > 0 {
+		drillMessage := s.drillAfterBackup(ctx, *profile, result.BackupID)
+		if ctx.Err() != nil {
+			s.finishStopped(ctx, job, *profile, backupFilePath, "Đã tạo backup nhưng restore drill bị dừng")
+			return
 		}
+		message = fmt.Sprintf("%s. %s", message, drillMessage)
+	}
+	finishJob(job, backupFilePath, status, message)
+
+	// Áp dụng chính sách lưu giữ sau khi backup thành công, không xóa bản cũ khi bản mới chưa upload đủ
+	if status == database.JobStatusSuccess {
+		s.applyRetention(profile.ID)
+	}
+}
 
-		database.UpdateJobLog(logID, status, endTime, backupFilePath, message)
+// finishStopped ghi log cho job bị dừng giữa chừng: cancelled nếu bị hủy theo yêu cầu,
+// timeout nếu chạy quá thời gian tối đa của profile
+func (s *Scheduler) finishStopped(ctx context.Context, job *Job, profile models.DatabaseProfile, backupFile, message string) {
+	status := database.JobStatusCancelled
+	reason := "bị hủy theo yêu cầu"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		status = database.JobStatusTimeout
+		reason = fmt.Sprintf("vượt quá thời gian tối đa %d phút", profile.JobTimeoutMinutes)
 	}
 
-	log.Printf("Hoàn thành backup ngay lập tức cho profile '%s'", profile.Name)
+	message = fmt.Sprintf("%s: %s", message, reason)
+	finishJob(job, backupFile, status, message)
+}
+
+// CancelJob hủy backup đang chờ hoặc đang chạy theo ID job (ID trong job_logs)
+func (s *Scheduler) CancelJob(id int64) error {
+	if !s.queue.Cancel(id) {
+		return fmt.Errorf("không có backup đang chạy hoặc đang chờ với ID %d", id)
+	}
 	return nil
 }
 
+// uploadBackup upload file backup lên các destination của profile.
+// Trả về false cùng thông báo lỗi nếu có destination upload thất bại
+func (s *Scheduler) uploadBackup(ctx context.Context, profile models.DatabaseProfile, backupID int64, filePath string) (bool, string) {
+	if len(profile.Destinations) == 0 {
+		return true, ""
+	}
+
+	var failed []string
+	for _, r := range s.storage.UploadBackup(ctx, &profile, backupID, filePath, profile.Destinations) {
+		if !r.Success {
+			failed = append(failed, fmt.Sprintf("%s: %s", r.Destination, r.Message))
+		}
+	}
+	if len(failed) > 0 {
+		return false, fmt.Sprintf("Lỗi khi upload: %s", strings.Join(failed, "; "))
+	}
+	return true, ""
+}
+
+// applyRetention xóa các backup hết hạn của profile theo chính sách lưu giữ
+func (s *Scheduler) applyRetention(profileID int64) {
+	report, err := s.retention.Run(profileID, false)
+	if report != nil {
+		event, removeErr := report.AuditEvent()
+		if err != nil {
+			removeErr = err
+		}
+		event.Actor, event.ActorType = models.AuditActorSystem, models.AuditActorSystem
+		audit.Record(audit.WithResult(event, removeErr))
+	}
+	if err != nil {
+		logger.Component("retention").Error("Lỗi khi áp dụng retention", logger.KeyProfileID, profileID, "error", err)
+	}
+}
+
+// RunRetention áp dụng chính sách lưu giữ cho profile theo yêu cầu, dryRun = true chỉ trả về báo cáo
+func (s *Scheduler) RunRetention(profileID int64, dryRun bool) (*retention.Report, error) {
+	return s.retention.Run(profileID, dryRun)
+}
+
 // GetActiveJobs trả về thông tin tất cả các job đang chạy
 func (s *Scheduler) GetActiveJobs() []map[string]interface{} {
 	entries := s.cron.Entries()
 	jobs := make([]map[string]interface{}, 0, len(entries))
+	schedulesPaused := database.SchedulesPaused()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	for _, entry := range entries {
 		for profileID, entryID := range s.profileBackups {
@@ -297,7 +359,7 @@ func (s *Scheduler) GetActiveJobs() []map[string]interface{} {
 				// Lấy thông tin profile
 				profile, err := database.GetProfileByID(profileID)
 				if err != nil {
-					log.Printf("Lỗi khi lấy thông tin profile %d: %v", profileID, err)
+					logger.Component("scheduler").Error("Lỗi khi lấy thông tin profile", logger.KeyProfileID, profileID, "error", err)
 					continue
 				}
 
@@ -314,9 +376,9 @@ func (s *Scheduler) GetActiveJobs() []map[string]interface{} {
 				duration := time.Until(nextRun)
 
 				// Lấy trạng thái job
-				status := s.jobStatus[profileID]
-				if status == "" {
-					status = "running" // Mặc định là running
+				status := "running"
+				if schedulesPaused || profile.IsSchedulePaused(time.Now()) {
+					status = "paused"
 				}
 
 				jobs = append(jobs, map[string]interface{}{
@@ -328,6 +390,7 @@ func (s *Scheduler) GetActiveJobs() []map[string]interface{} {
 					"status":        status,
 					"success_count": successCount,
 					"failed_count":  failedCount,
+					"paused_until":  profile.PausedUntil,
 				})
 				break
 			}
@@ -350,41 +413,110 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%d phút", m)
 }
 
-// PauseJob tạm dừng một job
-func (s *Scheduler) PauseJob(profileID int64) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, exists := s.profileBackups[profileID]; !exists {
-		return fmt.Errorf("không tìm thấy job cho profile ID %d", profileID)
+// PauseJob tạm dừng lịch backup của profile, trạng thái được lưu vào database.
+// until khác nil thì lịch tự chạy lại sau thời điểm đó
+func (s *Scheduler) PauseJob(profileID int64, until *time.Time) error {
+	if until != nil && !until.After(time.Now()) {
+		return fmt.Errorf("thời điểm tạm dừng đến (paused_until) phải ở tương lai")
+	}
+	if err := database.SetProfileSchedulePause(profileID, true, until); err != nil {
+		return err
 	}
 
-	s.jobStatus[profileID] = "paused"
-	log.Printf("Đã tạm dừng job cho profile ID %d", profileID)
+	if until != nil {
+		logger.Component("scheduler").Info("Đã tạm dừng lịch backup", logger.KeyProfileID, profileID, "paused_until", until)
+	} else {
+		logger.Component("scheduler").Info("Đã tạm dừng lịch backup", logger.KeyProfileID, profileID)
+	}
 	return nil
 }
 
-// ResumeJob tiếp tục chạy một job đã tạm dừng
+// ResumeJob tiếp tục chạy lịch backup đã tạm dừng của profile
 func (s *Scheduler) ResumeJob(profileID int64) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if err := database.SetProfileSchedulePause(profileID, false, nil); err != nil {
+		return err
+	}
+
+	logger.Component("scheduler").Info("Đã tiếp tục chạy lịch backup", logger.KeyProfileID, profileID)
+	return nil
+}
 
-	if _, exists := s.profileBackups[profileID]; !exists {
-		return fmt.Errorf("không tìm thấy job cho profile ID %d", profileID)
+// SetSchedulesPaused bật/tắt tạm dừng tất cả lịch backup (chế độ bảo trì). Backup thủ công vẫn chạy được
+func (s *Scheduler) SetSchedulesPaused(paused bool) error {
+	if err := database.SetSchedulesPaused(paused); err != nil {
+		return err
 	}
 
-	s.jobStatus[profileID] = "running"
-	log.Printf("Đã tiếp tục chạy job cho profile ID %d", profileID)
+	if paused {
+		logger.Component("scheduler").Info("Đã tạm dừng tất cả lịch backup")
+	} else {
+		logger.Component("scheduler").Info("Đã tiếp tục tất cả lịch backup")
+	}
 	return nil
 }
 
-// GetJobStatus lấy trạng thái hiện tại của job
+// SchedulesPaused cho biết tất cả lịch backup có đang tạm dừng hay không
+func (s *Scheduler) SchedulesPaused() bool {
+	return database.SchedulesPaused()
+}
+
+// GetJobStatus lấy trạng thái hiện tại của lịch backup: running, paused hoặc unknown nếu profile không có lịch
 func (s *Scheduler) GetJobStatus(profileID int64) string {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	_, exists := s.profileBackups[profileID]
+	s.mu.Unlock()
+	if !exists {
+		return "unknown"
+	}
+
+	if s.scheduleSkipReason(profileID) != "" {
+		return "paused"
+	}
+	return "running"
+}
+
+// scheduleSkipReason trả về lý do bỏ qua lần chạy theo lịch của profile, rỗng nếu được chạy.
+// Profile hết thời gian tạm dừng được tự động tiếp tục
+func (s *Scheduler) scheduleSkipReason(profileID int64) string {
+	if database.SchedulesPaused() {
+		return "Tất cả lịch backup đang tạm dừng (chế độ bảo trì), bỏ qua lần chạy theo lịch"
+	}
+
+	profile, err := database.GetProfileByID(profileID)
+	if err != nil {
+		// Lỗi đọc profile được xử lý khi job chạy
+		return ""
+	}
+	if !profile.SchedulePaused {
+		return ""
+	}
+
+	now := time.Now()
+	if profile.IsSchedulePaused(now) {
+		if profile.PausedUntil != nil {
+			return fmt.Sprintf("Lịch backup đang tạm dừng đến %s, bỏ qua lần chạy theo lịch",
+				profile.PausedUntil.Local().Format("02/01/2006 15:04:05"))
+		}
+		return "Lịch backup đang tạm dừng, bỏ qua lần chạy theo lịch"
+	}
+
+	// Đã hết thời gian tạm dừng
+	err = database.SetProfileSchedulePause(profileID, false, nil)
+	audit.System("schedule.auto_resume", "profile", strconv.FormatInt(profileID, 10), "Hết thời gian tạm dừng", err)
+	resumeLogger := logger.Component("scheduler").With(logger.KeyProfileID, profileID, "profile", profile.Name)
+	if err != nil {
+		resumeLogger.Error("Lỗi khi tự động tiếp tục lịch backup", "error", err)
+	} else {
+		resumeLogger.Info("Lịch backup đã hết thời gian tạm dừng, tự động tiếp tục")
+	}
+	return ""
+}
 
-	if status, exists := s.jobStatus[profileID]; exists {
-		return status
+// logSkippedRun ghi lần chạy theo lịch bị bỏ qua vào job_logs
+func (s *Scheduler) logSkippedRun(profileID int64, name, reason string) {
+	skipLogger := logger.Component("scheduler").With(logger.KeyProfileID, profileID, "profile", name)
+	skipLogger.Info("Bỏ qua backup tự động", "reason", reason)
+	if _, err := database.InsertFinishedJobLog(profileID, database.JobTypeBackup, database.JobStatusSkipped, reason); err != nil {
+		skipLogger.Error("Lỗi khi ghi log job bị bỏ qua", "error", err)
 	}
-	return "unknown"
 }
@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// setupSchedulerTest tạo database tạm với schema đầy đủ và pg_dump giả trên PATH
+func setupSchedulerTest(t *testing.T) *config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DBSource:  filepath.Join(dir, "app.db"),
+		BackupDir: filepath.Join(dir, "backups"),
+	}
+	if err := database.Open(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if _, err := database.MigrateUp(cfg.DBSource); err != nil {
+		t.Fatal(err)
+	}
+
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$1\" = \"--version\" ]; then echo 'pg_dump (PostgreSQL) 16.0'; exit 0; fi\necho 'CREATE TABLE items (id integer);'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "pg_dump"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return cfg
+}
+
+// createTestProfile tạo profile kết nối TCP với các destination cho trước
+func createTestProfile(t *testing.T, name string, destinations []string) models.DatabaseProfile {
+	t.Helper()
+	profile := models.DatabaseProfile{
+		Name:           name,
+		DBUser:         "postgres",
+		DBName:         name,
+		ConnectionMode: models.ConnectionModeTCP,
+		DBHost:         "127.0.0.1",
+		Destinations:   destinations,
+	}
+	id, err := database.CreateProfile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile.ID = id
+	return profile
+}
+
+// runTestBackup chạy một job backup của profile và trả về sự kiện kết thúc của job
+func runTestBackup(t *testing.T, s *Scheduler, profile models.DatabaseProfile) events.Event {
+	t.Helper()
+	logID, err := database.CreateJobLog(profile.ID, database.JobStatusQueued, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventCh, unsubscribe := events.Subscribe()
+	defer unsubscribe()
+
+	startedAt := time.Now()
+	job := &Job{ID: logID, ProfileID: profile.ID, ProfileName: profile.Name, Trigger: TriggerManual, StartedAt: &startedAt}
+	s.runBackup(context.Background(), job)
+
+	for {
+		select {
+		case event := <-eventCh:
+			if event.JobID == logID && (event.Type == events.TypeFinished || event.Type == events.TypeFailed) {
+				return event
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("không nhận được sự kiện kết thúc job")
+		}
+	}
+}
+
+func TestRunBackupSuccess(t *testing.T) {
+	cfg := setupSchedulerTest(t)
+	s := NewScheduler(cfg, drive.NewDriveUploader(cfg))
+	profile := createTestProfile(t, "shop", nil)
+
+	event := runTestBackup(t, s, profile)
+	if event.Type != events.TypeFinished || event.Status != database.JobStatusSuccess {
+		t.Errorf("sự kiện = %s/%s (%s), muốn finished/success", event.Type, event.Status, event.Message)
+	}
+}
+
+func TestRunBackupUploadFailureFailsJob(t *testing.T) {
+	cfg := setupSchedulerTest(t)
+	// Share NFS chưa được mount: upload lên destination local thất bại
+	cfg.LocalMirrorDir = filepath.Join(t.TempDir(), "not-mounted")
+	s := NewScheduler(cfg, drive.NewDriveUploader(cfg))
+	profile := createTestProfile(t, "shop", []string{models.DestinationLocal})
+
+	event := runTestBackup(t, s, profile)
+	if event.Type != events.TypeFailed || event.Status != database.JobStatusFailed {
+		t.Fatalf("sự kiện = %s/%s, muốn failed/failed", event.Type, event.Status)
+	}
+	if !strings.Contains(event.Message, "upload thất bại") || !strings.Contains(event.Message, models.DestinationLocal) {
+		t.Errorf("thông báo = %q", event.Message)
+	}
+
+	jobLog, err := database.GetJobLog(event.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobLog.Status != database.JobStatusFailed || jobLog.BackupFile == "" {
+		t.Errorf("job log = %+v, muốn failed kèm file backup cục bộ", jobLog)
+	}
+}
+
+func TestRunBackupLogsCarryJobFields(t *testing.T) {
+	cfg := setupSchedulerTest(t)
+	if err := logger.Setup(logger.Options{Level: "debug", BufferSize: 100, Output: io.Discard}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewScheduler(cfg, drive.NewDriveUploader(cfg))
+	profile := createTestProfile(t, "shop", nil)
+
+	event := runTestBackup(t, s, profile)
+
+	// Log của bước dump lọc được theo job và profile như trên /api/logs
+	entries := logger.Query(logger.Filter{Component: "dbdump", JobID: event.JobID})
+	if len(entries) == 0 {
+		t.Fatal("không có log dbdump nào gắn job_id của job backup")
+	}
+	for _, entry := range entries {
+		if entry.ProfileID != profile.ID {
+			t.Errorf("log %q có profile_id = %d, muốn %d", entry.Message, entry.ProfileID, profile.ID)
+		}
+	}
+}
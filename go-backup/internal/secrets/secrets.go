@@ -0,0 +1,338 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Prefix đánh dấu giá trị đã được mã hóa, giá trị không có prefix được coi là plaintext cũ
+const Prefix = "enc:v1:"
+
+// KeySize là độ dài master key và data key (AES-256)
+const KeySize = 32
+
+// ErrNoMasterKey là lỗi khi cần giải mã nhưng chưa cấu hình master key
+var ErrNoMasterKey = errors.New("chưa cấu hình master key (SECRETS_MASTER_KEY hoặc SECRETS_MASTER_KEY_FILE)")
+
+var (
+	mu        sync.RWMutex
+	masterKey []byte
+)
+
+// ParseKey đọc master key dạng base64 hoặc hex, khóa phải dài đúng 32 byte
+func ParseKey(raw string) ([]byte, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, errors.New("master key rỗng")
+	}
+	if key, err := hex.DecodeString(raw); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(raw); err == nil && len(key) == KeySize {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("master key phải là %d byte ở dạng base64 hoặc hex (tạo bằng: openssl rand -base64 32)", KeySize)
+}
+
+// Configure đặt master key dùng để mã hóa credentials, raw rỗng nghĩa là không mã hóa
+func Configure(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		SetMasterKey(nil)
+		return nil
+	}
+	key, err := ParseKey(raw)
+	if err != nil {
+		return err
+	}
+	SetMasterKey(key)
+	return nil
+}
+
+// SetMasterKey thay master key đang dùng, nil để tắt mã hóa
+func SetMasterKey(key []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+	masterKey = key
+}
+
+// Enabled cho biết đã cấu hình master key hay chưa
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return masterKey != nil
+}
+
+// KeyID trả về mã nhận diện ngắn của master key, được lưu kèm giá trị mã hóa
+// để phát hiện dùng sai khóa mà không lộ khóa
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// IsEncrypted cho biết giá trị đã được mã hóa hay chưa
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Encrypt mã hóa giá trị bằng data key ngẫu nhiên, data key được bọc bằng master key.
+// Khi chưa cấu hình master key, giá trị rỗng hoặc đã mã hóa thì trả về nguyên bản
+func Encrypt(plain string) (string, error) {
+	mu.RLock()
+	key := masterKey
+	mu.RUnlock()
+	if key == nil || plain == "" || IsEncrypted(plain) {
+		return plain, nil
+	}
+	return encryptWith(key, plain)
+}
+
+// Decrypt giải mã giá trị đã mã hóa, giá trị plaintext cũ được trả về nguyên bản
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	mu.RLock()
+	key := masterKey
+	mu.RUnlock()
+	if key == nil {
+		return "", ErrNoMasterKey
+	}
+
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := env.unwrap(key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(dataKey, env.data, nil)
+	if err != nil {
+		return "", fmt.Errorf("không thể giải mã dữ liệu: %w", err)
+	}
+	return string(plain), nil
+}
+
+// Rewrap bọc lại data key của giá trị bằng newKey, dữ liệu đã mã hóa giữ nguyên.
+// Giá trị plaintext được mã hóa luôn bằng newKey
+func Rewrap(value string, newKey []byte) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	if !IsEncrypted(value) {
+		return encryptWith(newKey, value)
+	}
+	mu.RLock()
+	key := masterKey
+	mu.RUnlock()
+	if key == nil {
+		return "", ErrNoMasterKey
+	}
+
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := env.unwrap(key)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(newKey, dataKey, []byte(KeyID(newKey)))
+	if err != nil {
+		return "", err
+	}
+	return envelope{keyID: KeyID(newKey), wrappedKey: wrapped, data: env.data}.String(), nil
+}
+
+// ReadFile đọc file, nội dung đã mã hóa được giải mã trước khi trả về
+func ReadFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	value := strings.TrimSpace(string(content))
+	if !IsEncrypted(value) {
+		return content, nil
+	}
+	plain, err := Decrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("không thể giải mã file %s: %w", filepath.Base(path), err)
+	}
+	return []byte(plain), nil
+}
+
+// WriteFile ghi file với quyền 0600, nội dung được mã hóa nếu đã cấu hình master key
+func WriteFile(path string, data []byte) error {
+	value, err := Encrypt(string(data))
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(value))
+}
+
+// EncryptFile mã hóa file plaintext cũ, trả về true nếu file đã được ghi lại.
+// File không tồn tại, đã mã hóa hoặc chưa cấu hình master key thì bỏ qua
+func EncryptFile(path string) (bool, error) {
+	if !Enabled() {
+		return false, nil
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if IsEncrypted(strings.TrimSpace(string(content))) || len(content) == 0 {
+		return false, nil
+	}
+	return true, WriteFile(path, content)
+}
+
+// RewrapFile bọc lại data key của file bằng newKey, file không tồn tại thì bỏ qua
+func RewrapFile(path string, newKey []byte) error {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	value, err := Rewrap(strings.TrimSpace(string(content)), newKey)
+	if err != nil {
+		return fmt.Errorf("không thể mã hóa lại file %s: %w", filepath.Base(path), err)
+	}
+	return writeFileAtomic(path, []byte(value))
+}
+
+// envelope là giá trị đã mã hóa: enc:v1:<key id>:<data key đã bọc>:<dữ liệu đã mã hóa>
+type envelope struct {
+	keyID      string
+	wrappedKey []byte
+	data       []byte
+}
+
+// String mã hóa envelope thành chuỗi để lưu trữ
+func (e envelope) String() string {
+	return Prefix + e.keyID + ":" +
+		base64.RawStdEncoding.EncodeToString(e.wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(e.data)
+}
+
+// unwrap lấy data key bằng master key, báo lỗi rõ ràng khi dùng sai khóa
+func (e envelope) unwrap(key []byte) ([]byte, error) {
+	if e.keyID != KeyID(key) {
+		return nil, fmt.Errorf("dữ liệu được mã hóa bằng master key %s, khóa đang cấu hình là %s", e.keyID, KeyID(key))
+	}
+	dataKey, err := open(key, e.wrappedKey, []byte(e.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("không thể mở data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// parseEnvelope tách các thành phần của giá trị đã mã hóa
+func parseEnvelope(value string) (envelope, error) {
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 {
+		return envelope{}, errors.New("giá trị mã hóa không đúng định dạng")
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return envelope{}, fmt.Errorf("data key không đúng định dạng: %w", err)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return envelope{}, fmt.Errorf("dữ liệu mã hóa không đúng định dạng: %w", err)
+	}
+	return envelope{keyID: parts[0], wrappedKey: wrapped, data: data}, nil
+}
+
+// encryptWith mã hóa plain bằng data key mới được bọc bởi key
+func encryptWith(key []byte, plain string) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	data, err := seal(dataKey, []byte(plain), nil)
+	if err != nil {
+		return "", err
+	}
+	keyID := KeyID(key)
+	wrapped, err := seal(key, dataKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	return envelope{keyID: keyID, wrappedKey: wrapped, data: data}.String(), nil
+}
+
+// seal mã hóa AES-256-GCM, nonce được đặt trước ciphertext
+func seal(key, plain, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, additional), nil
+}
+
+// open giải mã dữ liệu được tạo bởi seal
+func open(key, sealed, additional []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("dữ liệu mã hóa quá ngắn")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additional)
+}
+
+// newAEAD tạo AES-GCM từ khóa 32 byte
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic ghi file tạm rồi đổi tên để file không bị hỏng giữa chừng
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
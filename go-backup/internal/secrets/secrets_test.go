@@ -0,0 +1,193 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKey tạo master key cố định cho test
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+// useKey đặt master key trong thời gian chạy test
+func useKey(t *testing.T, key []byte) {
+	t.Helper()
+	SetMasterKey(key)
+	t.Cleanup(func() { SetMasterKey(nil) })
+}
+
+func TestParseKey(t *testing.T) {
+	key := testKey(7)
+	for _, raw := range []string{
+		hex.EncodeToString(key),
+		base64.StdEncoding.EncodeToString(key),
+		base64.RawURLEncoding.EncodeToString(key),
+		"  " + base64.StdEncoding.EncodeToString(key) + "\n",
+	} {
+		got, err := ParseKey(raw)
+		if err != nil || !bytes.Equal(got, key) {
+			t.Errorf("ParseKey(%q) = %x, %v", raw, got, err)
+		}
+	}
+	for _, raw := range []string{"", "không phải khóa", base64.StdEncoding.EncodeToString(key[:16])} {
+		if _, err := ParseKey(raw); err == nil {
+			t.Errorf("ParseKey(%q) phải trả về lỗi", raw)
+		}
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	useKey(t, testKey(1))
+
+	encrypted, err := Encrypt("mật khẩu database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, Prefix+KeyID(testKey(1))+":") {
+		t.Fatalf("giá trị mã hóa = %q, muốn prefix %s kèm key id", encrypted, Prefix)
+	}
+	if strings.Contains(encrypted, "mật khẩu") {
+		t.Fatal("giá trị mã hóa chứa plaintext")
+	}
+
+	plain, err := Decrypt(encrypted)
+	if err != nil || plain != "mật khẩu database" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+
+	// Mỗi lần mã hóa dùng data key và nonce mới
+	again, _ := Encrypt("mật khẩu database")
+	if again == encrypted {
+		t.Error("hai lần mã hóa cùng giá trị cho kết quả giống nhau")
+	}
+
+	// Giá trị rỗng, đã mã hóa hoặc plaintext cũ được giữ nguyên
+	if v, _ := Encrypt(""); v != "" {
+		t.Errorf("Encrypt(\"\") = %q", v)
+	}
+	if v, _ := Encrypt(encrypted); v != encrypted {
+		t.Error("giá trị đã mã hóa bị mã hóa lần nữa")
+	}
+	if v, err := Decrypt("plaintext cũ"); err != nil || v != "plaintext cũ" {
+		t.Errorf("Decrypt(plaintext) = %q, %v", v, err)
+	}
+}
+
+func TestEncryptWithoutMasterKey(t *testing.T) {
+	SetMasterKey(nil)
+	if Enabled() {
+		t.Fatal("Enabled() = true khi chưa cấu hình master key")
+	}
+	if v, err := Encrypt("secret"); err != nil || v != "secret" {
+		t.Errorf("Encrypt không có master key = %q, %v; muốn giữ nguyên", v, err)
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	useKey(t, testKey(1))
+	encrypted, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetMasterKey(testKey(2))
+	if _, err := Decrypt(encrypted); err == nil || !strings.Contains(err.Error(), KeyID(testKey(1))) {
+		t.Errorf("Decrypt với khóa sai: err = %v, muốn lỗi nêu key id %s", err, KeyID(testKey(1)))
+	}
+
+	SetMasterKey(nil)
+	if _, err := Decrypt(encrypted); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("Decrypt khi chưa có master key: err = %v, muốn ErrNoMasterKey", err)
+	}
+}
+
+func TestDecryptTamperedValue(t *testing.T) {
+	useKey(t, testKey(1))
+	encrypted, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(encrypted, ":")
+	data, _ := base64.RawStdEncoding.DecodeString(parts[len(parts)-1])
+	data[len(data)-1] ^= 0xff
+	parts[len(parts)-1] = base64.RawStdEncoding.EncodeToString(data)
+	if _, err := Decrypt(strings.Join(parts, ":")); err == nil {
+		t.Error("Decrypt giá trị bị sửa phải trả về lỗi")
+	}
+	if _, err := Decrypt(Prefix + "abc"); err == nil {
+		t.Error("Decrypt giá trị sai định dạng phải trả về lỗi")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	oldKey, newKey := testKey(1), testKey(2)
+	useKey(t, oldKey)
+	encrypted, err := Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewrapped, err := Rewrap(encrypted, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Chỉ data key được bọc lại, dữ liệu đã mã hóa giữ nguyên
+	oldParts, newParts := strings.Split(encrypted, ":"), strings.Split(rewrapped, ":")
+	if newParts[2] != KeyID(newKey) || newParts[4] != oldParts[4] {
+		t.Errorf("Rewrap = %q", rewrapped)
+	}
+
+	// Plaintext cũ được mã hóa luôn bằng khóa mới
+	fromPlain, err := Rewrap("plaintext", newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetMasterKey(newKey)
+	for _, value := range []string{rewrapped, fromPlain} {
+		if _, err := Decrypt(value); err != nil {
+			t.Errorf("Decrypt bằng khóa mới: %v", err)
+		}
+	}
+	if _, err := Decrypt(encrypted); err == nil {
+		t.Error("giá trị cũ vẫn giải mã được bằng khóa mới")
+	}
+}
+
+func TestWriteReadFile(t *testing.T) {
+	useKey(t, testKey(1))
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	if err := WriteFile(path, []byte(`{"access_token":"abc"}`)); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(path)
+	if !IsEncrypted(string(raw)) {
+		t.Fatalf("file được ghi dạng plaintext: %q", raw)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("quyền file = %v, muốn 0600", info.Mode().Perm())
+	}
+	content, err := ReadFile(path)
+	if err != nil || string(content) != `{"access_token":"abc"}` {
+		t.Errorf("ReadFile = %q, %v", content, err)
+	}
+
+	// File plaintext cũ được mã hóa lại một lần
+	legacy := filepath.Join(t.TempDir(), "legacy.json")
+	os.WriteFile(legacy, []byte("plain"), 0644)
+	if changed, err := EncryptFile(legacy); !changed || err != nil {
+		t.Fatalf("EncryptFile = %v, %v", changed, err)
+	}
+	if changed, err := EncryptFile(legacy); changed || err != nil {
+		t.Errorf("EncryptFile lần hai = %v, %v; muốn bỏ qua", changed, err)
+	}
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// DriveBackend lưu backup trên Google Drive, bố cục <thư mục gốc>/<thư mục ngày>/<tên file>
+type DriveBackend struct {
+	uploader *drive.DriveUploader
+}
+
+// NewDriveBackend tạo backend Google Drive từ DriveUploader
+func NewDriveBackend(uploader *drive.DriveUploader) *DriveBackend {
+	return &DriveBackend{uploader: uploader}
+}
+
+// Name trả về tên destination
+func (b *DriveBackend) Name() string {
+	return models.DestinationDrive
+}
+
+// Upload tải file lên Drive. DriveUploader tự tạo thư mục ngày từ đường dẫn file,
+// nên key phải trùng với KeyForFile(localPath)
+func (b *DriveBackend) Upload(ctx context.Context, localPath, key string) (*Object, error) {
+	if key != KeyForFile(localPath) {
+		return nil, fmt.Errorf("Google Drive chỉ hỗ trợ key dạng <thư mục ngày>/<tên file>: %s", key)
+	}
+
+	result := b.uploader.UploadFileContext(ctx, localPath)
+	if !result.Success {
+		return nil, fmt.Errorf("%s", result.Message)
+	}
+
+	object := &Object{Key: key, Location: result.WebLink}
+	if info, err := os.Stat(localPath); err == nil {
+		object.Size = info.Size()
+		object.ModTime = info.ModTime()
+	}
+	return object, nil
+}
+
+// List liệt kê các file backup trên Drive
+func (b *DriveBackend) List(ctx context.Context, prefix string) ([]Object, error) {
+	files, err := b.uploader.ListFiles(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]Object, 0, len(files))
+	for _, f := range files {
+		objects = append(objects, driveObject(f))
+	}
+	return objects, nil
+}
+
+// Delete xóa file trên Drive
+func (b *DriveBackend) Delete(ctx context.Context, key string) error {
+	file, err := b.find(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := b.uploader.DeleteFile(ctx, file.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Download tải nội dung file trên Drive
+func (b *DriveBackend) Download(ctx context.Context, key string, w io.Writer) error {
+	file, err := b.find(ctx, key)
+	if err != nil {
+		return err
+	}
+	return b.uploader.DownloadFile(ctx, file.ID, w)
+}
+
+// Stat lấy thông tin file trên Drive
+func (b *DriveBackend) Stat(ctx context.Context, key string) (*Object, error) {
+	file, err := b.find(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	object := driveObject(*file)
+	return &object, nil
+}
+
+// DeleteByLink xóa file trên Drive theo link đã lưu khi upload (backup cũ chỉ có drive_link)
+func (b *DriveBackend) DeleteByLink(ctx context.Context, localPath, link string) error {
+	if id := drive.FileIDFromLink(link); id != "" {
+		deleted, err := b.uploader.DeleteFile(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrNotFound
+		}
+		return nil
+	}
+	return b.Delete(ctx, KeyForFile(filepath.Clean(localPath)))
+}
+
+func (b *DriveBackend) find(ctx context.Context, key string) (*drive.RemoteFile, error) {
+	file, err := b.uploader.FindFile(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, ErrNotFound
+	}
+	return file, nil
+}
+
+func driveObject(f drive.RemoteFile) Object {
+	return Object{
+		Key:      f.Key,
+		Size:     f.Size,
+		ModTime:  f.ModTime,
+		Location: f.WebLink,
+	}
+}
@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// LocalBackend sao chép backup sang một thư mục đã mount (NFS, ổ đĩa ngoài...),
+// bố cục <thư mục mirror>/<thư mục ngày>/<tên file>
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend tạo backend thư mục mirror. Thư mục phải tồn tại sẵn: nếu share NFS
+// chưa được mount, backup không bị ghi nhầm vào ổ đĩa cục bộ
+func NewLocalBackend(root string) (*LocalBackend, error) {
+	if root == "" {
+		return nil, fmt.Errorf("chưa cấu hình thư mục mirror (LOCAL_MIRROR_DIR)")
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("thư mục mirror không truy cập được: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("đường dẫn mirror không phải thư mục: %s", root)
+	}
+
+	return &LocalBackend{root: filepath.Clean(root)}, nil
+}
+
+// Name trả về tên destination
+func (b *LocalBackend) Name() string {
+	return models.DestinationLocal
+}
+
+// Upload sao chép file vào thư mục mirror. File được ghi ra file tạm, kiểm tra lại checksum
+// rồi mới đổi tên, nên thư mục mirror không bao giờ chứa bản sao dở dang
+func (b *LocalBackend) Upload(ctx context.Context, localPath, key string) (*Object, error) {
+	target, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, fmt.Errorf("không thể tạo thư mục trên mirror: %v", err)
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("không thể mở file backup: %v", err)
+	}
+	defer src.Close()
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc thông tin file backup: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo file tạm trên mirror: %v", err)
+	}
+	tmpPath := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	// Tính checksum của file nguồn trong lúc copy
+	hash := sha256.New()
+	progress := &events.ProgressReader{R: src, Ctx: ctx, Destination: b.Name(), Total: srcInfo.Size()}
+	size, err := io.Copy(io.MultiWriter(tmp, hash), &contextReader{ctx: ctx, r: progress})
+	if err != nil {
+		return nil, fmt.Errorf("lỗi khi sao chép file sang mirror: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, fmt.Errorf("không thể ghi file xuống mirror: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("không thể đóng file trên mirror: %v", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	// Đọc lại bản sao từ mirror để phát hiện lỗi ghi
+	copied, err := FileChecksum(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("không thể kiểm tra checksum trên mirror: %v", err)
+	}
+	if copied != checksum {
+		return nil, fmt.Errorf("checksum của bản sao trên mirror không khớp (%s != %s)", copied, checksum)
+	}
+
+	if err := os.Rename(tmpPath, target); err != nil {
+		return nil, fmt.Errorf("không thể đổi tên file trên mirror: %v", err)
+	}
+	committed = true
+	syncDir(filepath.Dir(target))
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc thông tin file trên mirror: %v", err)
+	}
+
+	return &Object{
+		Key:      key,
+		Size:     size,
+		ModTime:  info.ModTime(),
+		Location: target,
+		Checksum: checksum,
+	}, nil
+}
+
+// List liệt kê các file trong thư mục mirror có key bắt đầu bằng prefix, bỏ qua file tạm
+func (b *LocalBackend) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	err := filepath.WalkDir(b.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{
+			Key:      key,
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			Location: p,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("không thể liệt kê file trên mirror: %v", err)
+	}
+	return objects, nil
+}
+
+// Delete xóa file trong thư mục mirror
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	target, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("không thể xóa file trên mirror: %v", err)
+	}
+
+	// Dọn thư mục ngày nếu đã trống, lỗi (thư mục còn file) được bỏ qua
+	if dir := filepath.Dir(target); dir != b.root {
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// Download đọc file từ thư mục mirror
+func (b *LocalBackend) Download(ctx context.Context, key string, w io.Writer) error {
+	target, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("không thể mở file trên mirror: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, &contextReader{ctx: ctx, r: f}); err != nil {
+		return fmt.Errorf("lỗi khi đọc file trên mirror: %v", err)
+	}
+	return nil
+}
+
+// Stat lấy thông tin file trong thư mục mirror
+func (b *LocalBackend) Stat(ctx context.Context, key string) (*Object, error) {
+	target, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("không thể đọc thông tin file trên mirror: %v", err)
+	}
+	return &Object{
+		Key:      key,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Location: target,
+	}, nil
+}
+
+// path chuyển key thành đường dẫn trong thư mục mirror, không cho phép thoát ra ngoài thư mục
+func (b *LocalBackend) path(key string) (string, error) {
+	target := filepath.Join(b.root, filepath.FromSlash(key))
+	if target == b.root || !strings.HasPrefix(target, b.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("key không hợp lệ: %s", key)
+	}
+	return target, nil
+}
+
+// FileChecksum tính SHA-256 (hex) của file
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// syncDir fsync thư mục để thao tác đổi tên được ghi xuống đĩa, lỗi được bỏ qua
+// vì một số hệ thống file (NFS, Windows) không hỗ trợ
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// contextReader dừng đọc khi context bị hủy
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
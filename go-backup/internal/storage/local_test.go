@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLocalBackendRequiresExistingDir(t *testing.T) {
+	if _, err := NewLocalBackend(""); err == nil {
+		t.Error("thư mục mirror rỗng phải trả về lỗi")
+	}
+	// Share NFS chưa mount: không được tự tạo thư mục trên ổ đĩa cục bộ
+	missing := filepath.Join(t.TempDir(), "not-mounted")
+	if _, err := NewLocalBackend(missing); err == nil {
+		t.Error("thư mục mirror không tồn tại phải trả về lỗi")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("NewLocalBackend không được tạo thư mục mirror")
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewLocalBackend(file); err == nil {
+		t.Error("đường dẫn mirror là file phải trả về lỗi")
+	}
+}
+
+func TestLocalBackend(t *testing.T) {
+	backend, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseBackend(t, backend)
+
+	// Thư mục ngày trống được dọn sau khi xóa file cuối cùng
+	if _, err := os.Stat(filepath.Join(backend.root, "2024-01-02")); !os.IsNotExist(err) {
+		t.Errorf("thư mục ngày vẫn còn sau khi xóa: %v", err)
+	}
+}
+
+func TestLocalBackendUploadChecksum(t *testing.T) {
+	backend, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPath := filepath.Join(t.TempDir(), "shop.sql")
+	if err := os.WriteFile(localPath, []byte("SELECT 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := FileChecksum(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	object, err := backend.Upload(context.Background(), localPath, "2024-01-02/shop.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.Checksum != want {
+		t.Errorf("checksum = %q, muốn %q", object.Checksum, want)
+	}
+	if object.Location != filepath.Join(backend.root, "2024-01-02", "shop.sql") {
+		t.Errorf("location = %q", object.Location)
+	}
+	if got, _ := FileChecksum(object.Location); got != want {
+		t.Errorf("checksum bản sao trên mirror = %q, muốn %q", got, want)
+	}
+}
+
+func TestLocalBackendListSkipsTempFiles(t *testing.T) {
+	root := t.TempDir()
+	backend, err := NewLocalBackend(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{
+		"2024-01-02/shop_20240102_030405.sql",
+		"2024-01-02/.shop_20240103_030405.sql.123.tmp", // bản sao đang ghi dở
+		"2024-01-03/shop_20240103_030405.sql",
+		"2024-02-01/shop_20240201_030405.sql",
+	}
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, err := backend.List(context.Background(), "2024-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	want := []string{"2024-01-02/shop_20240102_030405.sql", "2024-01-03/shop_20240103_030405.sql"}
+	if len(keys) != len(want) || keys[0] != want[0] || keys[1] != want[1] {
+		t.Errorf("List(2024-01) = %v, muốn %v", keys, want)
+	}
+}
+
+func TestLocalBackendRejectsPathTraversal(t *testing.T) {
+	backend, err := NewLocalBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", ".", "../outside.sql", "2024-01-02/../../outside.sql"} {
+		if _, err := backend.path(key); err == nil {
+			t.Errorf("path(%q) phải trả về lỗi", key)
+		}
+	}
+	if _, err := backend.path("2024-01-02/shop.sql"); err != nil {
+		t.Errorf("path hợp lệ: %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/drive"
+	"github.com/backup-cronjob/internal/logger"
+	"github.com/backup-cronjob/internal/metrics"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// Manager tạo backend cho từng destination và ghi nhận các bản sao của backup
+type Manager struct {
+	Config        *config.Config
+	DriveUploader *drive.DriveUploader
+}
+
+// NewManager tạo instance mới của Manager
+func NewManager(cfg *config.Config, driveUploader *drive.DriveUploader) *Manager {
+	return &Manager{
+		Config:        cfg,
+		DriveUploader: driveUploader,
+	}
+}
+
+// Backend trả về backend của destination name. Destination cấu hình theo profile (sftp)
+// cần profile; Google Drive dùng thư mục và tài khoản Google của profile nếu có,
+// các destination khác dùng cấu hình chung và bỏ qua profile
+func (m *Manager) Backend(name string, profile *models.DatabaseProfile) (Backend, error) {
+	switch name {
+	case models.DestinationDrive:
+		uploader, err := m.driveUploader(profile)
+		if err != nil {
+			return nil, err
+		}
+		return NewDriveBackend(uploader), nil
+	case models.DestinationS3:
+		return NewS3Backend(S3ConfigFromConfig(m.Config))
+	case models.DestinationLocal:
+		return NewLocalBackend(m.Config.LocalMirrorDir)
+	case models.DestinationSFTP:
+		if profile == nil {
+			return nil, fmt.Errorf("destination sftp được cấu hình theo profile, cần chọn profile")
+		}
+		return NewSFTPBackend(SFTPConfigFromProfile(*profile))
+	}
+	return nil, fmt.Errorf("destination không được hỗ trợ: %s", name)
+}
+
+// CheckConfig kiểm tra destination đã được cấu hình đầy đủ để upload hay chưa
+func (m *Manager) CheckConfig(name string, profile *models.DatabaseProfile) error {
+	if _, err := m.Backend(name, profile); err != nil {
+		return err
+	}
+	if name == models.DestinationDrive {
+		uploader, _ := m.driveUploader(profile)
+		issues := uploader.CheckDriveConfig()
+		if len(issues) > 0 {
+			messages := make([]string, 0, len(issues))
+			for key, issue := range issues {
+				messages = append(messages, fmt.Sprintf("%s: %s", key, issue))
+			}
+			sort.Strings(messages)
+			return fmt.Errorf("có vấn đề với cấu hình Google Drive: %s", strings.Join(messages, "; "))
+		}
+	}
+	return nil
+}
+
+// driveUploader trả về DriveUploader dùng cho profile, profile nil dùng cấu hình chung
+func (m *Manager) driveUploader(profile *models.DatabaseProfile) (*drive.DriveUploader, error) {
+	if m.DriveUploader == nil {
+		return nil, fmt.Errorf("Google Drive chưa được cấu hình")
+	}
+	if profile == nil {
+		return m.DriveUploader, nil
+	}
+	return m.DriveUploader.ForProfile(*profile), nil
+}
+
+// UploadResult là kết quả upload backup lên một destination
+type UploadResult struct {
+	Destination string  `json:"destination"`
+	Success     bool    `json:"success"`
+	Object      *Object `json:"object,omitempty"`
+	Message     string  `json:"message"`
+}
+
+// UploadBackup upload file backup của profile lên các destination và lưu lại bản sao cho backupID.
+// Lỗi ở một destination không ảnh hưởng đến các destination còn lại
+func (m *Manager) UploadBackup(ctx context.Context, profile *models.DatabaseProfile, backupID int64, filePath string, destinations []string) []UploadResult {
+	key := KeyForFile(filePath)
+	results := make([]UploadResult, 0, len(destinations))
+
+	for _, name := range destinations {
+		result := UploadResult{Destination: name}
+		uploadLogger := logger.Component("storage").With(logger.KeyBackupID, backupID, "destination", name, "key", key)
+		if profile != nil {
+			uploadLogger = uploadLogger.With(logger.KeyProfileID, profile.ID)
+		}
+
+		backend, err := m.Backend(name, profile)
+		if err != nil {
+			result.Message = err.Error()
+			results = append(results, result)
+			uploadLogger.Error("Lỗi khi upload backup", "error", err)
+			continue
+		}
+
+		uploadLogger.Info("Đang upload backup")
+		startedAt := time.Now()
+		object, err := backend.Upload(ctx, filePath, key)
+		if err != nil {
+			metrics.ObserveUpload(name, 0, 0, true)
+			result.Message = err.Error()
+			results = append(results, result)
+			uploadLogger.Error("Lỗi khi upload backup", "error", err)
+			continue
+		}
+		duration := time.Since(startedAt)
+		metrics.ObserveUpload(name, object.Size, duration, false)
+
+		result.Success = true
+		result.Object = object
+		result.Message = fmt.Sprintf("Upload thành công: %s", object.Location)
+		results = append(results, result)
+		uploadLogger.Info("Đã upload backup", "location", object.Location, "size", object.Size, "duration", duration)
+
+		if backupID > 0 {
+			upload := backupdb.BackupUpload{
+				BackupID:    backupID,
+				Destination: name,
+				Key:         object.Key,
+				Location:    object.Location,
+				Size:        object.Size,
+				Checksum:    object.Checksum,
+			}
+			if profile != nil {
+				upload.ProfileID = profile.ID
+			}
+			if err := backupdb.RecordUpload(upload); err != nil {
+				uploadLogger.Error("Không thể lưu thông tin upload của backup", "error", err)
+			}
+		}
+	}
+
+	return results
+}
+
+// DeleteBackupCopies xóa các bản sao của backup trên destination.
+// Bản sao đã ghi nhận (hoặc link Drive của backup cũ) bắt buộc phải xóa được, lỗi được trả về trong errs.
+// Với các destination của profile chưa có bản ghi, chỉ tìm và xóa nếu có thể.
+// Trả về danh sách destination đã xóa được bản sao
+func (m *Manager) DeleteBackupCopies(ctx context.Context, backup *models.BackupFile, profile *models.DatabaseProfile) (deleted []string, errs []string) {
+	backupID, _ := strconv.ParseInt(backup.ID, 10, 64)
+	uploads, err := backupdb.GetBackupUploads(backupID)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	handled := make(map[string]bool)
+	for _, upload := range uploads {
+		handled[upload.Destination] = true
+
+		backend, err := m.uploadBackend(upload, profile)
+		if err == nil {
+			err = backend.Delete(ctx, upload.Key)
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Sprintf("không thể xóa bản sao trên %s: %v", upload.Destination, err))
+			continue
+		}
+		if err == nil {
+			deleted = append(deleted, upload.Destination)
+		}
+		if err := backupdb.DeleteBackupUpload(backupID, upload.Destination); err != nil {
+			log.Printf("Không thể xóa thông tin upload của backup %d: %v", backupID, err)
+		}
+	}
+
+	// Backup được upload lên Drive trước khi có bảng backup_uploads chỉ lưu drive_link
+	if !handled[models.DestinationDrive] && (backup.Uploaded || backup.DriveLink != "") && m.DriveUploader != nil {
+		handled[models.DestinationDrive] = true
+		uploader, _ := m.driveUploader(profile)
+		err := NewDriveBackend(uploader).DeleteByLink(ctx, backup.Path, backup.DriveLink)
+		switch {
+		case err == nil:
+			deleted = append(deleted, models.DestinationDrive)
+		case !errors.Is(err, ErrNotFound):
+			errs = append(errs, fmt.Sprintf("không thể xóa bản sao trên Drive: %v", err))
+		}
+	}
+
+	if profile == nil {
+		return deleted, errs
+	}
+	for _, name := range profile.Destinations {
+		if handled[name] {
+			continue
+		}
+		backend, err := m.Backend(name, profile)
+		if err == nil {
+			err = backend.Delete(ctx, KeyForFile(backup.Path))
+		}
+		switch {
+		case err == nil:
+			deleted = append(deleted, name)
+		case !errors.Is(err, ErrNotFound):
+			log.Printf("Bỏ qua lỗi khi tìm bản sao trên %s của %s: %v", name, backup.Name, err)
+		}
+	}
+
+	return deleted, errs
+}
+
+// ErrChecksumMismatch được trả về khi nội dung bản sao không khớp checksum đã lưu trong catalog
+var ErrChecksumMismatch = errors.New("checksum của bản sao không khớp với catalog")
+
+// DownloadBackup tải bản sao của backup về localPath, thử lần lượt các destination đã ghi nhận.
+// Bản sao có SHA-256 không khớp checksum trong catalog bị bỏ qua.
+// Trả về tên destination đã tải thành công
+func (m *Manager) DownloadBackup(ctx context.Context, backupID int64, localPath string) (string, error) {
+	uploads, err := backupdb.GetBackupUploads(backupID)
+	if err != nil {
+		return "", err
+	}
+	if len(uploads) == 0 {
+		return "", ErrNotFound
+	}
+
+	var expected string
+	if backup, err := backupdb.GetBackupByID(backupID); err == nil {
+		expected = backup.Checksum
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return "", fmt.Errorf("không thể tạo thư mục backup: %v", err)
+	}
+
+	var lastErr error
+	for _, upload := range uploads {
+		checksum := expected
+		if checksum == "" {
+			checksum = upload.Checksum
+		}
+		backend, err := m.uploadBackend(upload, nil)
+		if err == nil {
+			err = downloadToFile(ctx, backend, upload.Key, localPath, checksum)
+		}
+		if err == nil {
+			log.Printf("Đã tải backup %d từ %s về %s", backupID, upload.Destination, localPath)
+			return upload.Destination, nil
+		}
+		lastErr = fmt.Errorf("không thể tải backup từ %s: %w", upload.Destination, err)
+		log.Print(lastErr)
+	}
+	return "", lastErr
+}
+
+// HashCopy tải bản sao của backup trên destination và tính SHA-256 (hex) của nội dung
+func (m *Manager) HashCopy(ctx context.Context, upload backupdb.BackupUpload) (string, error) {
+	backend, err := m.uploadBackend(upload, nil)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if err := backend.Download(ctx, upload.Key, hash); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// uploadBackend trả về backend chứa bản sao đã ghi nhận. Với destination cấu hình theo profile,
+// dùng profile đã upload bản sao, nếu không còn thì dùng profile dự phòng
+func (m *Manager) uploadBackend(upload backupdb.BackupUpload, fallback *models.DatabaseProfile) (Backend, error) {
+	profile := fallback
+	if upload.ProfileID > 0 && (profile == nil || profile.ID != upload.ProfileID) {
+		if p, err := database.GetProfileByID(upload.ProfileID); err == nil {
+			profile = p
+		}
+	}
+	return m.Backend(upload.Destination, profile)
+}
+
+// downloadToFile tải object vào file tạm rồi đổi tên, tránh để lại file dở dang khi lỗi.
+// Nếu checksum khác rỗng, nội dung tải về phải có SHA-256 trùng khớp
+func downloadToFile(ctx context.Context, backend Backend, key, localPath, checksum string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if err := backend.Download(ctx, key, io.MultiWriter(tmp, hash)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if checksum != "" && !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), checksum) {
+		return ErrChecksumMismatch
+	}
+	return os.Rename(tmp.Name(), localPath)
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/backup-cronjob/internal/backupdb"
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/database"
+	"github.com/backup-cronjob/internal/models"
+)
+
+// setupManagerTest tạo database tạm, thư mục mirror và một backup trong catalog
+func setupManagerTest(t *testing.T) (*Manager, *models.BackupFile, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		DBSource:       filepath.Join(dir, "app.db"),
+		LocalMirrorDir: filepath.Join(dir, "mirror"),
+	}
+	if err := os.Mkdir(cfg.LocalMirrorDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitDB(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+
+	content := []byte("CREATE TABLE items (id integer);\n")
+	localPath := filepath.Join(dir, "backups", "2024-01-02", "shop_20240102_030405.sql")
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := FileChecksum(localPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := backupdb.AddBackup(backupdb.NewBackup{
+		Filename:  filepath.Base(localPath),
+		Filepath:  localPath,
+		Filesize:  int64(len(content)),
+		CreatedAt: time.Now(),
+		Checksum:  checksum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := backupdb.GetBackupByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(cfg, nil), backup, content
+}
+
+func TestManagerUploadDownloadDelete(t *testing.T) {
+	manager, backup, content := setupManagerTest(t)
+	ctx := context.Background()
+	backupID, _ := strconv.ParseInt(backup.ID, 10, 64)
+
+	results := manager.UploadBackup(ctx, nil, backupID, backup.Path, []string{models.DestinationLocal, "ftp"})
+	if len(results) != 2 || !results[0].Success || results[1].Success {
+		t.Fatalf("UploadBackup = %+v, muốn local thành công và ftp thất bại", results)
+	}
+
+	uploads, err := backupdb.GetBackupUploads(backupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0].Destination != models.DestinationLocal || uploads[0].Key != "2024-01-02/shop_20240102_030405.sql" {
+		t.Fatalf("bản sao trong catalog = %+v", uploads)
+	}
+	if uploads[0].Checksum != backup.Checksum {
+		t.Errorf("checksum bản sao = %q, muốn %q", uploads[0].Checksum, backup.Checksum)
+	}
+
+	// File cục bộ bị xóa thì tải lại được từ mirror
+	if err := os.Remove(backup.Path); err != nil {
+		t.Fatal(err)
+	}
+	destination, err := manager.DownloadBackup(ctx, backupID, backup.Path)
+	if err != nil || destination != models.DestinationLocal {
+		t.Fatalf("DownloadBackup = %q, %v", destination, err)
+	}
+	if data, err := os.ReadFile(backup.Path); err != nil || string(data) != string(content) {
+		t.Errorf("nội dung tải về = %q, %v", data, err)
+	}
+
+	deleted, errs := manager.DeleteBackupCopies(ctx, backup, nil)
+	if len(errs) > 0 || len(deleted) != 1 || deleted[0] != models.DestinationLocal {
+		t.Fatalf("DeleteBackupCopies = %v, %v", deleted, errs)
+	}
+	if uploads, _ := backupdb.GetBackupUploads(backupID); len(uploads) != 0 {
+		t.Errorf("catalog vẫn còn bản sao sau khi xóa: %+v", uploads)
+	}
+	if _, err := manager.DownloadBackup(ctx, backupID, backup.Path); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DownloadBackup sau khi xóa: err = %v, muốn ErrNotFound", err)
+	}
+}
+
+func TestManagerDownloadRejectsChecksumMismatch(t *testing.T) {
+	manager, backup, _ := setupManagerTest(t)
+	ctx := context.Background()
+	backupID, _ := strconv.ParseInt(backup.ID, 10, 64)
+
+	if results := manager.UploadBackup(ctx, nil, backupID, backup.Path, []string{models.DestinationLocal}); !results[0].Success {
+		t.Fatalf("UploadBackup: %s", results[0].Message)
+	}
+
+	// Bản sao trên mirror bị thay đổi sau khi upload
+	mirrorPath := filepath.Join(manager.Config.LocalMirrorDir, "2024-01-02", "shop_20240102_030405.sql")
+	if err := os.WriteFile(mirrorPath, []byte("hỏng"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "restore.sql")
+	if _, err := manager.DownloadBackup(ctx, backupID, target); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("DownloadBackup: err = %v, muốn ErrChecksumMismatch", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("không được để lại file tải dở: %v", err)
+	}
+}
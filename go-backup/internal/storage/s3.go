@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/config"
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config chứa thông tin kết nối đến S3 hoặc dịch vụ tương thích S3 (MinIO...)
+type S3Config struct {
+	Endpoint  string // host:port hoặc URL đầy đủ (https://...)
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // Thư mục chứa backup trong bucket
+	UseSSL    bool
+}
+
+// S3ConfigFromConfig lấy cấu hình S3 từ cấu hình ứng dụng
+func S3ConfigFromConfig(cfg *config.Config) S3Config {
+	return S3Config{
+		Endpoint:  cfg.S3Endpoint,
+		Region:    cfg.S3Region,
+		Bucket:    cfg.S3Bucket,
+		AccessKey: cfg.S3AccessKey,
+		SecretKey: cfg.S3SecretKey,
+		Prefix:    cfg.S3Prefix,
+		UseSSL:    cfg.S3UseSSL,
+	}
+}
+
+// S3Backend lưu backup trên S3, bố cục <bucket>/<prefix>/<thư mục ngày>/<tên file>
+type S3Backend struct {
+	client *minio.Client
+	config S3Config
+}
+
+// NewS3Backend tạo backend S3 và kiểm tra cấu hình kết nối
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("chưa cấu hình S3 endpoint (S3_ENDPOINT)")
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("chưa cấu hình S3 bucket (S3_BUCKET)")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("chưa cấu hình S3 access key/secret key (S3_ACCESS_KEY, S3_SECRET_KEY)")
+	}
+
+	// Cho phép nhập endpoint dạng URL, scheme quyết định có dùng HTTPS hay không
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	switch {
+	case strings.HasPrefix(endpoint, "https://"):
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+		cfg.UseSSL = true
+	case strings.HasPrefix(endpoint, "http://"):
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+		cfg.UseSSL = false
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo S3 client: %v", err)
+	}
+
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &S3Backend{client: client, config: cfg}, nil
+}
+
+// Name trả về tên destination
+func (b *S3Backend) Name() string {
+	return models.DestinationS3
+}
+
+// Upload tải file lên bucket, tạo bucket nếu chưa tồn tại
+func (b *S3Backend) Upload(ctx context.Context, localPath, key string) (*Object, error) {
+	if err := b.ensureBucket(ctx); err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc thông tin file backup: %v", err)
+	}
+
+	info, err := b.client.FPutObject(ctx, b.config.Bucket, b.objectName(key), localPath, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+		Progress:    &events.ProgressReader{Ctx: ctx, Destination: b.Name(), Total: stat.Size()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("không thể upload file lên S3: %v", err)
+	}
+
+	// Phản hồi của PutObject thường không có LastModified
+	modTime := info.LastModified
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+
+	return &Object{
+		Key:      key,
+		Size:     info.Size,
+		ModTime:  modTime,
+		Location: b.location(key),
+	}, nil
+}
+
+// List liệt kê các object trong bucket có key bắt đầu bằng prefix
+func (b *S3Backend) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	for info := range b.client.ListObjects(ctx, b.config.Bucket, minio.ListObjectsOptions{
+		Prefix:    b.objectName(prefix),
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			if isNotFound(info.Err) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("không thể liệt kê file trên S3: %v", info.Err)
+		}
+		key := b.keyFromObjectName(info.Key)
+		objects = append(objects, Object{
+			Key:      key,
+			Size:     info.Size,
+			ModTime:  info.LastModified,
+			Location: b.location(key),
+		})
+	}
+	return objects, nil
+}
+
+// Delete xóa object trên S3
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	// RemoveObject không báo lỗi khi object không tồn tại nên cần kiểm tra trước
+	if _, err := b.Stat(ctx, key); err != nil {
+		return err
+	}
+	if err := b.client.RemoveObject(ctx, b.config.Bucket, b.objectName(key), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("không thể xóa file trên S3: %v", err)
+	}
+	return nil
+}
+
+// Download tải nội dung object trên S3
+func (b *S3Backend) Download(ctx context.Context, key string, w io.Writer) error {
+	object, err := b.client.GetObject(ctx, b.config.Bucket, b.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("không thể tải file từ S3: %v", err)
+	}
+	defer object.Close()
+
+	if _, err := io.Copy(w, object); err != nil {
+		if isNotFound(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("lỗi khi tải file từ S3: %v", err)
+	}
+	return nil
+}
+
+// Stat lấy thông tin object trên S3
+func (b *S3Backend) Stat(ctx context.Context, key string) (*Object, error) {
+	info, err := b.client.StatObject(ctx, b.config.Bucket, b.objectName(key), minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("không thể lấy thông tin file trên S3: %v", err)
+	}
+	return &Object{
+		Key:      key,
+		Size:     info.Size,
+		ModTime:  info.LastModified,
+		Location: b.location(key),
+	}, nil
+}
+
+// ensureBucket tạo bucket nếu chưa tồn tại
+func (b *S3Backend) ensureBucket(ctx context.Context) error {
+	exists, err := b.client.BucketExists(ctx, b.config.Bucket)
+	if err != nil {
+		return fmt.Errorf("không thể kiểm tra bucket %s: %v", b.config.Bucket, err)
+	}
+	if exists {
+		return nil
+	}
+	if err := b.client.MakeBucket(ctx, b.config.Bucket, minio.MakeBucketOptions{Region: b.config.Region}); err != nil {
+		return fmt.Errorf("không thể tạo bucket %s: %v", b.config.Bucket, err)
+	}
+	return nil
+}
+
+// objectName chuyển key thành tên object trong bucket (thêm prefix)
+func (b *S3Backend) objectName(key string) string {
+	if b.config.Prefix == "" {
+		return key
+	}
+	if key == "" {
+		return b.config.Prefix + "/"
+	}
+	return path.Join(b.config.Prefix, key)
+}
+
+// keyFromObjectName bỏ prefix khỏi tên object
+func (b *S3Backend) keyFromObjectName(name string) string {
+	if b.config.Prefix == "" {
+		return name
+	}
+	return strings.TrimPrefix(name, b.config.Prefix+"/")
+}
+
+func (b *S3Backend) location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", b.config.Bucket, b.objectName(key))
+}
+
+// isNotFound kiểm tra lỗi S3 có phải do object hoặc bucket không tồn tại
+func isNotFound(err error) bool {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket", "NotFound":
+		return true
+	}
+	return false
+}
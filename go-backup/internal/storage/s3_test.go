@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+func testS3Backend(t *testing.T, prefix string) *S3Backend {
+	t.Helper()
+	backend, err := NewS3Backend(S3Config{
+		Endpoint:  "localhost:9000",
+		Bucket:    "backups",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    prefix,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestS3ObjectNames(t *testing.T) {
+	cases := []struct {
+		prefix     string
+		key        string
+		objectName string
+		location   string
+	}{
+		{"", "2024-01-02/shop.sql", "2024-01-02/shop.sql", "s3://backups/2024-01-02/shop.sql"},
+		{"/db/prod/", "2024-01-02/shop.sql", "db/prod/2024-01-02/shop.sql", "s3://backups/db/prod/2024-01-02/shop.sql"},
+		{"db", "", "db/", "s3://backups/db/"},
+	}
+	for _, tc := range cases {
+		backend := testS3Backend(t, tc.prefix)
+		if got := backend.objectName(tc.key); got != tc.objectName {
+			t.Errorf("prefix %q: objectName(%q) = %q, muốn %q", tc.prefix, tc.key, got, tc.objectName)
+		}
+		if got := backend.location(tc.key); got != tc.location {
+			t.Errorf("prefix %q: location(%q) = %q, muốn %q", tc.prefix, tc.key, got, tc.location)
+		}
+		if tc.key != "" {
+			if got := backend.keyFromObjectName(tc.objectName); got != tc.key {
+				t.Errorf("prefix %q: keyFromObjectName(%q) = %q, muốn %q", tc.prefix, tc.objectName, got, tc.key)
+			}
+		}
+	}
+}
+
+func TestNewS3BackendConfig(t *testing.T) {
+	valid := S3Config{Endpoint: "minio:9000", Bucket: "backups", AccessKey: "access", SecretKey: "secret"}
+
+	for name, mutate := range map[string]func(*S3Config){
+		"thiếu endpoint":   func(c *S3Config) { c.Endpoint = "" },
+		"thiếu bucket":     func(c *S3Config) { c.Bucket = "" },
+		"thiếu secret key": func(c *S3Config) { c.SecretKey = "" },
+	} {
+		cfg := valid
+		mutate(&cfg)
+		if _, err := NewS3Backend(cfg); err == nil {
+			t.Errorf("%s: NewS3Backend phải trả về lỗi", name)
+		}
+	}
+
+	// Scheme của endpoint quyết định có dùng HTTPS
+	for endpoint, secure := range map[string]bool{
+		"https://s3.example.com/": true,
+		"http://minio:9000":       false,
+	} {
+		cfg := valid
+		cfg.Endpoint = endpoint
+		cfg.UseSSL = !secure
+		backend, err := NewS3Backend(cfg)
+		if err != nil {
+			t.Fatalf("endpoint %q: %v", endpoint, err)
+		}
+		if backend.config.UseSSL != secure {
+			t.Errorf("endpoint %q: UseSSL = %v, muốn %v", endpoint, backend.config.UseSSL, secure)
+		}
+		if scheme := backend.client.EndpointURL().Scheme; (scheme == "https") != secure {
+			t.Errorf("endpoint %q: client dùng scheme %s", endpoint, scheme)
+		}
+	}
+}
+
+func TestS3IsNotFound(t *testing.T) {
+	for code, want := range map[string]bool{
+		"NoSuchKey":    true,
+		"NoSuchBucket": true,
+		"NotFound":     true,
+		"AccessDenied": false,
+	} {
+		if got := isNotFound(minio.ErrorResponse{Code: code}); got != want {
+			t.Errorf("isNotFound(%s) = %v, muốn %v", code, got, want)
+		}
+	}
+}
+
+// TestS3BackendMinIO chạy với MinIO hoặc S3 thật khi đặt S3_TEST_ENDPOINT, S3_TEST_ACCESS_KEY,
+// S3_TEST_SECRET_KEY (vd: docker run -p 9000:9000 minio/minio server /data)
+func TestS3BackendMinIO(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("chưa đặt S3_TEST_ENDPOINT")
+	}
+	backend, err := NewS3Backend(S3Config{
+		Endpoint:  endpoint,
+		Bucket:    "backup-test-" + strings.ToLower(time.Now().Format("20060102150405")),
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		Prefix:    "ci",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseBackend(t, backend)
+}
+
+// exerciseBackend kiểm tra vòng đời upload, liệt kê, tải về và xóa của một backend
+func exerciseBackend(t *testing.T, backend Backend) {
+	t.Helper()
+	ctx := context.Background()
+	content := []byte("CREATE TABLE items (id integer);\n")
+	localPath := filepath.Join(t.TempDir(), "2024-01-02", "shop_20240102_030405.sql")
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := KeyForFile(localPath)
+
+	object, err := backend.Upload(ctx, localPath, key)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if object.Key != key || object.Size != int64(len(content)) {
+		t.Errorf("Upload trả về key %q, size %d; muốn %q, %d", object.Key, object.Size, key, len(content))
+	}
+
+	objects, err := backend.List(ctx, "2024-01-02/")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != key {
+		t.Errorf("List = %+v, muốn một object %q", objects, key)
+	}
+	if objects, err := backend.List(ctx, "2024-01-03/"); err != nil || len(objects) != 0 {
+		t.Errorf("List prefix khác = %+v, %v; muốn danh sách rỗng", objects, err)
+	}
+
+	stat, err := backend.Stat(ctx, key)
+	if err != nil || stat.Size != int64(len(content)) {
+		t.Errorf("Stat = %+v, %v", stat, err)
+	}
+
+	var buf bytes.Buffer
+	if err := backend.Download(ctx, key, &buf); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("Download = %q, muốn %q", buf.Bytes(), content)
+	}
+
+	if err := backend.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := backend.Delete(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete lần hai: err = %v, muốn ErrNotFound", err)
+	}
+	if _, err := backend.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat sau khi xóa: err = %v, muốn ErrNotFound", err)
+	}
+}
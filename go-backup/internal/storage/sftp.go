@@ -0,0 +1,443 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/backup-cronjob/internal/events"
+	"github.com/backup-cronjob/internal/models"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Số lần thử upload lên SFTP, mỗi lần thử tiếp tục từ phần đã upload trước đó
+const (
+	sftpMaxRetries        = 3
+	sftpRetryDelay        = 2 * time.Second
+	sftpDialTimeout       = 30 * time.Second
+	sftpPartSuffix        = ".part"
+	sftpDefaultPort       = 22
+	sftpFingerprintPrefix = "SHA256:"
+)
+
+// SFTPConfig chứa thông tin kết nối SFTP của một profile
+type SFTPConfig struct {
+	Host       string
+	Port       int
+	User       string
+	Password   string // Mật khẩu đăng nhập, hoặc passphrase của private key
+	PrivateKey string // Private key dạng PEM/OpenSSH
+	HostKey    string // Public key của server (authorized_keys) hoặc fingerprint SHA256:...
+	RemoteDir  string // Thư mục gốc chứa backup trên server
+}
+
+// SFTPConfigFromProfile lấy cấu hình SFTP từ profile
+func SFTPConfigFromProfile(profile models.DatabaseProfile) SFTPConfig {
+	return SFTPConfig{
+		Host:       profile.SFTPHost,
+		Port:       profile.SFTPPort,
+		User:       profile.SFTPUser,
+		Password:   profile.SFTPPassword,
+		PrivateKey: profile.SFTPPrivateKey,
+		HostKey:    profile.SFTPHostKey,
+		RemoteDir:  profile.SFTPRemoteDir,
+	}
+}
+
+// SFTPBackend lưu backup trên SFTP server, bố cục <thư mục gốc>/<thư mục ngày>/<tên file>
+type SFTPBackend struct {
+	config    SFTPConfig
+	sshConfig *ssh.ClientConfig
+}
+
+// NewSFTPBackend tạo backend SFTP và kiểm tra cấu hình, chưa mở kết nối
+func NewSFTPBackend(cfg SFTPConfig) (*SFTPBackend, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("chưa cấu hình SFTP host (sftp_host)")
+	}
+	if cfg.User == "" {
+		return nil, fmt.Errorf("chưa cấu hình tên đăng nhập SFTP (sftp_user)")
+	}
+	if cfg.Port <= 0 {
+		cfg.Port = sftpDefaultPort
+	}
+	if cfg.RemoteDir == "" {
+		cfg.RemoteDir = "."
+	}
+
+	var auth []ssh.AuthMethod
+	if strings.TrimSpace(cfg.PrivateKey) != "" {
+		signer, err := parsePrivateKey(cfg.PrivateKey, cfg.Password)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("cần mật khẩu (sftp_password) hoặc private key (sftp_private_key) để đăng nhập SFTP")
+	}
+
+	hostKeyCallback, err := hostKeyCallback(cfg.HostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SFTPBackend{
+		config: cfg,
+		sshConfig: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         sftpDialTimeout,
+		},
+	}, nil
+}
+
+// Name trả về tên destination
+func (b *SFTPBackend) Name() string {
+	return models.DestinationSFTP
+}
+
+// Upload tải file lên SFTP server. File được ghi ra <tên file>.part rồi đổi tên khi hoàn tất;
+// nếu kết nối bị gián đoạn, lần thử sau tiếp tục từ phần đã upload
+func (b *SFTPBackend) Upload(ctx context.Context, localPath, key string) (*Object, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc thông tin file backup: %v", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= sftpMaxRetries; attempt++ {
+		object, err := b.upload(ctx, localPath, key, info.Size())
+		if err == nil {
+			return object, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || attempt == sftpMaxRetries {
+			break
+		}
+
+		log.Printf("Lỗi khi upload %s lên SFTP (lần thử %d/%d): %v. Đang thử lại...", key, attempt, sftpMaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sftpRetryDelay * time.Duration(attempt)):
+		}
+	}
+
+	return nil, fmt.Errorf("không thể upload file lên SFTP sau %d lần thử: %v", sftpMaxRetries, lastErr)
+}
+
+// upload thực hiện một lần upload, tiếp tục từ file .part nếu có
+func (b *SFTPBackend) upload(ctx context.Context, localPath, key string, size int64) (*Object, error) {
+	client, closeFn, err := b.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	target := b.remotePath(key)
+	part := target + sftpPartSuffix
+	if err := client.MkdirAll(path.Dir(target)); err != nil {
+		return nil, fmt.Errorf("không thể tạo thư mục %s trên SFTP: %v", path.Dir(target), err)
+	}
+
+	// Tiếp tục từ phần đã upload, bắt đầu lại nếu file .part lớn hơn file gốc
+	var offset int64
+	if partInfo, err := client.Stat(part); err == nil {
+		offset = partInfo.Size()
+		if offset > size {
+			offset = 0
+		}
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("không thể mở file backup: %v", err)
+	}
+	defer src.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	dst, err := client.OpenFile(part, flags)
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo file %s trên SFTP: %v", part, err)
+	}
+
+	if offset > 0 {
+		log.Printf("Tiếp tục upload %s lên SFTP từ byte %d/%d", key, offset, size)
+	}
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		dst.Close()
+		return nil, err
+	}
+	if _, err := dst.Seek(offset, io.SeekStart); err != nil {
+		dst.Close()
+		return nil, err
+	}
+	progress := &events.ProgressReader{R: src, Ctx: ctx, Destination: b.Name(), Total: size, Count: offset}
+	if _, err := io.Copy(dst, &contextReader{ctx: ctx, r: progress}); err != nil {
+		dst.Close()
+		return nil, fmt.Errorf("lỗi khi upload file lên SFTP: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		return nil, fmt.Errorf("không thể đóng file trên SFTP: %v", err)
+	}
+
+	partInfo, err := client.Stat(part)
+	if err != nil {
+		return nil, fmt.Errorf("không thể kiểm tra file đã upload: %v", err)
+	}
+	if partInfo.Size() != size {
+		return nil, fmt.Errorf("kích thước file trên SFTP không khớp (%d != %d bytes)", partInfo.Size(), size)
+	}
+
+	if err := renameRemote(client, part, target); err != nil {
+		return nil, fmt.Errorf("không thể đổi tên file trên SFTP: %v", err)
+	}
+
+	return &Object{
+		Key:      key,
+		Size:     size,
+		ModTime:  time.Now(),
+		Location: b.location(target),
+	}, nil
+}
+
+// List liệt kê các file backup trên SFTP server có key bắt đầu bằng prefix, bỏ qua file .part
+func (b *SFTPBackend) List(ctx context.Context, prefix string) ([]Object, error) {
+	client, closeFn, err := b.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	root := path.Clean(b.config.RemoteDir)
+	objects := []Object{}
+	walker := client.Walk(root)
+	for walker.Step() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := walker.Err(); err != nil {
+			if isRemoteNotExist(err) && walker.Path() == root {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("không thể liệt kê file trên SFTP: %v", err)
+		}
+
+		info := walker.Stat()
+		if info.IsDir() || strings.HasSuffix(info.Name(), sftpPartSuffix) {
+			continue
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		objects = append(objects, Object{
+			Key:      key,
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			Location: b.location(walker.Path()),
+		})
+	}
+	return objects, nil
+}
+
+// Delete xóa file trên SFTP server
+func (b *SFTPBackend) Delete(ctx context.Context, key string) error {
+	client, closeFn, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	target := b.remotePath(key)
+	if err := client.Remove(target); err != nil {
+		if isRemoteNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("không thể xóa file trên SFTP: %v", err)
+	}
+
+	// Dọn thư mục ngày nếu đã trống, lỗi (thư mục còn file) được bỏ qua
+	if dir := path.Dir(target); dir != path.Clean(b.config.RemoteDir) {
+		client.RemoveDirectory(dir)
+	}
+	return nil
+}
+
+// Download tải nội dung file trên SFTP server
+func (b *SFTPBackend) Download(ctx context.Context, key string, w io.Writer) error {
+	client, closeFn, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	f, err := client.Open(b.remotePath(key))
+	if err != nil {
+		if isRemoteNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("không thể mở file trên SFTP: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, &contextReader{ctx: ctx, r: f}); err != nil {
+		return fmt.Errorf("lỗi khi tải file từ SFTP: %v", err)
+	}
+	return nil
+}
+
+// Stat lấy thông tin file trên SFTP server
+func (b *SFTPBackend) Stat(ctx context.Context, key string) (*Object, error) {
+	client, closeFn, err := b.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	target := b.remotePath(key)
+	info, err := client.Stat(target)
+	if err != nil {
+		if isRemoteNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("không thể lấy thông tin file trên SFTP: %v", err)
+	}
+	return &Object{
+		Key:      key,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		Location: b.location(target),
+	}, nil
+}
+
+// connect mở kết nối SSH và phiên SFTP, closeFn đóng cả hai
+func (b *SFTPBackend) connect(ctx context.Context) (*sftp.Client, func(), error) {
+	addr := net.JoinHostPort(b.config.Host, strconv.Itoa(b.config.Port))
+	dialer := net.Dialer{Timeout: sftpDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("không thể kết nối đến SFTP server %s: %v", addr, err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, b.sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("không thể đăng nhập SFTP server %s: %v", addr, err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, nil, fmt.Errorf("không thể mở phiên SFTP: %v", err)
+	}
+
+	// Đóng kết nối khi context bị hủy để dừng các thao tác đang chờ
+	stop := context.AfterFunc(ctx, func() { sshClient.Close() })
+	return client, func() {
+		stop()
+		client.Close()
+		sshClient.Close()
+	}, nil
+}
+
+// remotePath chuyển key thành đường dẫn trên server
+func (b *SFTPBackend) remotePath(key string) string {
+	return path.Join(b.config.RemoteDir, key)
+}
+
+func (b *SFTPBackend) location(remotePath string) string {
+	return fmt.Sprintf("sftp://%s@%s:%d/%s", b.config.User, b.config.Host, b.config.Port,
+		strings.TrimPrefix(remotePath, "/"))
+}
+
+// renameRemote đổi tên file, ghi đè file đích nếu đã tồn tại
+func renameRemote(client *sftp.Client, from, to string) error {
+	// posix-rename@openssh.com ghi đè file đích một cách nguyên tử
+	if err := client.PosixRename(from, to); err == nil {
+		return nil
+	}
+	if err := client.Remove(to); err != nil && !isRemoteNotExist(err) {
+		return err
+	}
+	return client.Rename(from, to)
+}
+
+// parsePrivateKey đọc private key, dùng passphrase nếu key được mã hóa
+func parsePrivateKey(key, passphrase string) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(key))
+	if err == nil {
+		return signer, nil
+	}
+
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("private key SFTP được mã hóa, cần nhập passphrase vào sftp_password")
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+		if err == nil {
+			return signer, nil
+		}
+	}
+	return nil, fmt.Errorf("private key SFTP không hợp lệ: %v", err)
+}
+
+// hostKeyCallback tạo hàm xác thực host key của server. Khi chưa cấu hình host key,
+// mọi server đều được chấp nhận (kèm cảnh báo)
+func hostKeyCallback(hostKey string) (ssh.HostKeyCallback, error) {
+	hostKey = strings.TrimSpace(hostKey)
+	if hostKey == "" {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			log.Printf("Cảnh báo: chưa cấu hình sftp_host_key, chấp nhận host key %s của %s",
+				ssh.FingerprintSHA256(key), hostname)
+			return nil
+		}, nil
+	}
+
+	if strings.HasPrefix(hostKey, sftpFingerprintPrefix) {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if fingerprint := ssh.FingerprintSHA256(key); fingerprint != hostKey {
+				return fmt.Errorf("host key của %s không khớp: %s", hostname, fingerprint)
+			}
+			return nil
+		}, nil
+	}
+
+	// Chấp nhận cả public key (keytype key) lẫn dòng known_hosts (host keytype key)
+	if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey)); err == nil {
+		return ssh.FixedHostKey(key), nil
+	}
+	_, _, key, _, _, err := ssh.ParseKnownHosts([]byte(hostKey))
+	if err != nil {
+		return nil, fmt.Errorf("sftp_host_key không hợp lệ (cần public key hoặc fingerprint SHA256:...): %v", err)
+	}
+	return ssh.FixedHostKey(key), nil
+}
+
+// isRemoteNotExist kiểm tra lỗi SFTP có phải do file không tồn tại
+func isRemoteNotExist(err error) bool {
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	var status *sftp.StatusError
+	return errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxNoSuchFile
+}
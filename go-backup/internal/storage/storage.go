@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotFound được trả về khi object không tồn tại trên destination
+var ErrNotFound = errors.New("không tìm thấy file trên destination")
+
+// Object là thông tin một file backup trên destination
+type Object struct {
+	Key      string    `json:"key"`                // Đường dẫn tương đối dạng <thư mục ngày>/<tên file>
+	Size     int64     `json:"size"`               // Kích thước (bytes)
+	ModTime  time.Time `json:"mod_time"`           // Thời điểm upload
+	Location string    `json:"location,omitempty"` // Link hoặc URL của file (Drive link, s3://bucket/key...)
+	Checksum string    `json:"checksum,omitempty"` // SHA-256 (hex) nếu destination có kiểm tra checksum
+}
+
+// Backend là một nơi lưu trữ backup ngoài máy chủ (Google Drive, S3...)
+type Backend interface {
+	// Name trả về tên destination (drive, s3...)
+	Name() string
+	// Upload tải file cục bộ localPath lên destination với key cho trước
+	Upload(ctx context.Context, localPath, key string) (*Object, error)
+	// List liệt kê các object có key bắt đầu bằng prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Delete xóa object, trả về ErrNotFound nếu object không tồn tại
+	Delete(ctx context.Context, key string) error
+	// Download ghi nội dung object vào w
+	Download(ctx context.Context, key string, w io.Writer) error
+	// Stat lấy thông tin object, trả về ErrNotFound nếu object không tồn tại
+	Stat(ctx context.Context, key string) (*Object, error)
+}
+
+// KeyForFile tạo key của file backup trên destination theo bố cục <thư mục ngày>/<tên file>,
+// giống cấu trúc thư mục backup cục bộ
+func KeyForFile(filePath string) string {
+	return path.Join(filepath.Base(filepath.Dir(filePath)), filepath.Base(filePath))
+}
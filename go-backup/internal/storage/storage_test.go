@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyForFile(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join("/data", "backups", "2024-01-02", "shop_20240102_030405.sql.gz"), "2024-01-02/shop_20240102_030405.sql.gz"},
+		{filepath.Join("backups", "2024-12-31", "shop_20241231_000000.dump"), "2024-12-31/shop_20241231_000000.dump"},
+	}
+	for _, tc := range cases {
+		if got := KeyForFile(tc.path); got != tc.want {
+			t.Errorf("KeyForFile(%q) = %q, muốn %q", tc.path, got, tc.want)
+		}
+	}
+}